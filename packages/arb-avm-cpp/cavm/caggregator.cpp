@@ -159,6 +159,55 @@ int aggregatorSaveRequest(CAggregatorStore* agg,
     }
 }
 
+// tx_hash is 32 bytes long
+Uint64Result aggregatorGetPossibleTxHashInfo(const CAggregatorStore* agg,
+                                             const void* tx_hash) {
+    auto index =
+        static_cast<const AggregatorStore*>(agg)->getPossibleTxHashInfo(
+            receiveUint256(tx_hash));
+    if (index) {
+        return {*index, true};
+    } else {
+        return {0, false};
+    }
+}
+
+int aggregatorSaveTxHash(CAggregatorStore* agg,
+                         const void* tx_hash,
+                         uint64_t log_index) {
+    try {
+        static_cast<AggregatorStore*>(agg)->saveTxHash(receiveUint256(tx_hash),
+                                                       log_index);
+        return 1;
+    } catch (const std::exception&) {
+        return 0;
+    }
+}
+
+Uint64Result aggregatorGetPossibleMessageLog(const CAggregatorStore* agg,
+                                             uint64_t message_index) {
+    auto index =
+        static_cast<const AggregatorStore*>(agg)->getPossibleMessageLog(
+            message_index);
+    if (index) {
+        return {*index, true};
+    } else {
+        return {0, false};
+    }
+}
+
+int aggregatorSaveMessageLog(CAggregatorStore* agg,
+                             uint64_t message_index,
+                             uint64_t log_index) {
+    try {
+        static_cast<AggregatorStore*>(agg)->saveMessageLog(message_index,
+                                                           log_index);
+        return 1;
+    } catch (const std::exception&) {
+        return 0;
+    }
+}
+
 // block_hash is 32 bytes long
 Uint64Result aggregatorGetPossibleBlock(const CAggregatorStore* agg,
                                         const void* block_hash) {
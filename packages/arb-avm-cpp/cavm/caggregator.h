@@ -80,6 +80,19 @@ int aggregatorSaveRequest(CAggregatorStore* agg,
                           const void* request_id,
                           uint64_t log_index);
 
+// tx_hash is 32 bytes long
+Uint64Result aggregatorGetPossibleTxHashInfo(const CAggregatorStore* agg,
+                                             const void* tx_hash);
+int aggregatorSaveTxHash(CAggregatorStore* agg,
+                         const void* tx_hash,
+                         uint64_t log_index);
+
+Uint64Result aggregatorGetPossibleMessageLog(const CAggregatorStore* agg,
+                                             uint64_t message_index);
+int aggregatorSaveMessageLog(CAggregatorStore* agg,
+                             uint64_t message_index,
+                             uint64_t log_index);
+
 // block_hash is 32 bytes long
 Uint64Result aggregatorGetPossibleBlock(const CAggregatorStore* agg,
                                         const void* block_hash);
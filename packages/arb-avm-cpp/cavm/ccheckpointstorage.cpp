@@ -28,6 +28,28 @@
 #include <avm_values/value.hpp>
 
 #include <string>
+#include <vector>
+
+namespace {
+std::vector<uint256_t> receiveHashList(const void* data, int count) {
+    auto data_ptr = reinterpret_cast<const char*>(data);
+    std::vector<uint256_t> hashes;
+    hashes.reserve(count);
+    for (int i = 0; i < count; i++) {
+        hashes.push_back(deserializeUint256t(data_ptr));
+    }
+    return hashes;
+}
+
+HashList returnHashList(const std::vector<uint256_t>& hashes) {
+    std::vector<unsigned char> serializedHashes;
+    for (const auto& hash : hashes) {
+        marshal_uint256_t(hash, serializedHashes);
+    }
+    return {returnCharVectorRaw(serializedHashes),
+            static_cast<int>(hashes.size())};
+}
+}  // namespace
 
 CCheckpointStorage* createCheckpointStorage(const char* db_path) {
     auto string_filename = std::string(db_path);
@@ -201,3 +223,21 @@ int deleteData(CCheckpointStorage* storage_ptr,
 
     return keyvalue_store->deleteData(key_slice).ok();
 }
+
+UnreferencedRecordsResult getUnreferencedRecords(
+    const CCheckpointStorage* storage_ptr,
+    const void* machine_hashes,
+    int machine_count,
+    const void* value_hashes,
+    int value_count) {
+    auto storage = static_cast<const CheckpointStorage*>(storage_ptr);
+    try {
+        auto records = storage->getUnreferencedRecords(
+            receiveHashList(machine_hashes, machine_count),
+            receiveHashList(value_hashes, value_count));
+        return {returnHashList(records.machines),
+                returnHashList(records.values)};
+    } catch (const std::exception&) {
+        return {{nullptr, 0}, {nullptr, 0}};
+    }
+}
@@ -52,6 +52,12 @@ ByteSliceResult getData(CCheckpointStorage* storage_ptr,
 int deleteData(CCheckpointStorage* storage_ptr,
                const void* key,
                int key_length);
+UnreferencedRecordsResult getUnreferencedRecords(
+    const CCheckpointStorage* storage_ptr,
+    const void* machine_hashes,
+    int machine_count,
+    const void* value_hashes,
+    int value_count);
 
 CBlockStore* createBlockStore(CCheckpointStorage* storage_ptr);
 CAggregatorStore* createAggregatorStore(CCheckpointStorage* storage_ptr);
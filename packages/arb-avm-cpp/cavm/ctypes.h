@@ -44,6 +44,11 @@ typedef struct {
     int count;
 } ByteSliceArray;
 
+typedef struct {
+    HashList machines;
+    HashList values;
+} UnreferencedRecordsResult;
+
 struct Uint64ResultStruct {
     uint64_t value;
     int found;
@@ -80,7 +80,7 @@ func (as *AggregatorStore) GetLog(index uint64) (value.Value, error) {
 		return nil, errors.New("failed to get log")
 	}
 	logBytes := toByteSlice(result.slice)
-	return value.UnmarshalValue(bytes.NewBuffer(logBytes))
+	return value.NewDecoder(logBytes).Decode()
 }
 
 func (as *AggregatorStore) MessageCount() (uint64, error) {
@@ -112,7 +112,7 @@ func (as *AggregatorStore) GetMessage(index uint64) (value.Value, error) {
 		return nil, errors.New("failed to get l2message")
 	}
 	logBytes := toByteSlice(result.slice)
-	return value.UnmarshalValue(bytes.NewBuffer(logBytes))
+	return value.NewDecoder(logBytes).Decode()
 }
 
 func parseBlockData(data []byte) (*types.Header, *uint64, error) {
@@ -240,6 +240,44 @@ func (as *AggregatorStore) SaveRequest(requestId common.Hash, logIndex uint64) e
 	return nil
 }
 
+func (as *AggregatorStore) GetPossibleTxHashInfo(txHash common.Hash) *uint64 {
+	cHash := hashToData(txHash)
+	defer C.free(cHash)
+
+	result := C.aggregatorGetPossibleTxHashInfo(as.c, cHash)
+	if result.found == 0 {
+		return nil
+	}
+	index := uint64(result.value)
+	return &index
+}
+
+func (as *AggregatorStore) SaveTxHash(txHash common.Hash, logIndex uint64) error {
+	cHash := hashToData(txHash)
+	defer C.free(cHash)
+
+	if C.aggregatorSaveTxHash(as.c, cHash, C.uint64_t(logIndex)) == 0 {
+		return errors.New("failed to save tx hash")
+	}
+	return nil
+}
+
+func (as *AggregatorStore) GetPossibleMessageLog(messageIndex uint64) *uint64 {
+	result := C.aggregatorGetPossibleMessageLog(as.c, C.uint64_t(messageIndex))
+	if result.found == 0 {
+		return nil
+	}
+	index := uint64(result.value)
+	return &index
+}
+
+func (as *AggregatorStore) SaveMessageLog(messageIndex uint64, logIndex uint64) error {
+	if C.aggregatorSaveMessageLog(as.c, C.uint64_t(messageIndex), C.uint64_t(logIndex)) == 0 {
+		return errors.New("failed to save message log")
+	}
+	return nil
+}
+
 func (as *AggregatorStore) GetPossibleBlock(blockHash common.Hash) *uint64 {
 	cHash := hashToData(blockHash)
 	defer C.free(cHash)
@@ -132,7 +132,7 @@ func (checkpoint *CheckpointStorage) GetValue(hashValue common.Hash, valueCache
 
 	dataBuff := toByteSlice(cData)
 
-	val, err := value.UnmarshalValue(bytes.NewReader(dataBuff[:]))
+	val, err := value.NewDecoder(dataBuff).Decode()
 	if err != nil {
 		return nil, err
 	}
@@ -186,13 +186,59 @@ func (checkpoint *CheckpointStorage) DeleteData(key []byte) bool {
 	return success == 1
 }
 
+// UnreferencedRecords returns the stored machines and values which can't be
+// reached from the given machines and values, the initial machine or the
+// saved code. Records only referenced by other unreferenced records are left
+// out since deleting the top level records removes them as well
+func (checkpoint *CheckpointStorage) UnreferencedRecords(
+	machineHashes []common.Hash,
+	valueHashes []common.Hash,
+) ([]common.Hash, []common.Hash) {
+	cMachineHashes := C.CBytes(concatHashes(machineHashes))
+	defer C.free(cMachineHashes)
+	cValueHashes := C.CBytes(concatHashes(valueHashes))
+	defer C.free(cValueHashes)
+
+	result := C.getUnreferencedRecords(
+		checkpoint.c,
+		cMachineHashes,
+		C.int(len(machineHashes)),
+		cValueHashes,
+		C.int(len(valueHashes)),
+	)
+	return receiveHashList(result.machines), receiveHashList(result.values)
+}
+
+func concatHashes(hashes []common.Hash) []byte {
+	data := make([]byte, 0, len(hashes)*32)
+	for _, hash := range hashes {
+		data = append(data, hash[:]...)
+	}
+	return data
+}
+
+func receiveHashList(cHashList C.HashList) []common.Hash {
+	if cHashList.data == nil {
+		return nil
+	}
+	defer C.free(cHashList.data)
+	data := C.GoBytes(cHashList.data, cHashList.count*32)
+	ret := make([]common.Hash, 0, int(cHashList.count))
+	for i := 0; i < int(cHashList.count); i++ {
+		var hashVal common.Hash
+		copy(hashVal[:], data[i*32:])
+		ret = append(ret, hashVal)
+	}
+	return ret
+}
+
 func (checkpoint *CheckpointStorage) GetBlockStore() machine.BlockStore {
 	bs := C.createBlockStore(checkpoint.c)
 
 	return NewBlockStore(bs)
 }
 
-func (checkpoint *CheckpointStorage) GetAggregatorStore() *AggregatorStore {
+func (checkpoint *CheckpointStorage) GetAggregatorStore() machine.AggregatorStore {
 	bs := C.createAggregatorStore(checkpoint.c)
 
 	return NewAggregatorStore(bs)
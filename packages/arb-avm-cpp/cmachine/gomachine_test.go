@@ -0,0 +1,118 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmachine
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/gotest"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+)
+
+// TestGoMachineOpCodes steps the C++ and Go machines together through the
+// opcode test cases and checks that their state matches after every step
+func TestGoMachineOpCodes(t *testing.T) {
+	for _, testFile := range gotest.OpCodeTestFiles() {
+		t.Run(testFile, func(t *testing.T) {
+			cmach, err := New(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gomach, err := gomachine.New(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 100000; i++ {
+				if cmach.Hash() != gomach.Hash() {
+					cmach.PrintState()
+					gomach.PrintState()
+					t.Fatalf("machine hashes differ at step %v", i)
+				}
+				cproof, err := cmach.MarshalForProof()
+				if err != nil {
+					t.Fatal(err)
+				}
+				goproof, err := gomach.MarshalForProof()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(cproof, goproof) {
+					t.Fatalf("proofs differ at step %v", i)
+				}
+				if cmach.IsBlocked(false) != nil {
+					break
+				}
+				ca, csteps := cmach.ExecuteAssertion(1, nil, 0)
+				goa, gosteps := gomach.ExecuteAssertion(1, nil, 0)
+				if csteps != gosteps {
+					t.Fatalf("step counts differ at step %v", i)
+				}
+				if !ca.Equals(goa) {
+					t.Fatalf("assertions differ at step %v", i)
+				}
+			}
+			if cmach.CurrentStatus() != gomach.CurrentStatus() {
+				t.Error("machine status differs")
+			}
+		})
+	}
+}
+
+// TestGoMachineArbOS runs ArbOS on both machines in batches and checks that
+// every assertion matches
+func TestGoMachineArbOS(t *testing.T) {
+	for _, testFile := range gotest.ArbOSTestFiles() {
+		data, err := ioutil.ReadFile(testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(testFile, func(t *testing.T) {
+			inboxMessages, _, _, err := inbox.LoadTestVector(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmach, err := New(codeFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gomach, err := gomachine.New(codeFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				ca, csteps := cmach.ExecuteAssertion(10000, inboxMessages, 0)
+				goa, gosteps := gomach.ExecuteAssertion(10000, inboxMessages, 0)
+				if csteps != gosteps {
+					t.Fatalf("ran %v steps instead of %v", gosteps, csteps)
+				}
+				if !ca.Equals(goa) {
+					cmach.PrintState()
+					gomach.PrintState()
+					t.Fatal("assertions differ")
+				}
+				inboxMessages = inboxMessages[ca.InboxMessagesConsumed:]
+				if csteps == 0 || cmach.CurrentStatus() != machine.Extensive {
+					break
+				}
+			}
+		})
+	}
+}
@@ -58,6 +58,14 @@ class AggregatorStore {
         const uint256_t& request_id) const;
     void saveRequest(const uint256_t& request_id, uint64_t log_index);
 
+    nonstd::optional<uint64_t> getPossibleTxHashInfo(
+        const uint256_t& tx_hash) const;
+    void saveTxHash(const uint256_t& tx_hash, uint64_t log_index);
+
+    nonstd::optional<uint64_t> getPossibleMessageLog(
+        uint64_t message_index) const;
+    void saveMessageLog(uint64_t message_index, uint64_t log_index);
+
     nonstd::optional<uint64_t> getPossibleBlock(
         const uint256_t& block_hash) const;
     void saveBlockHash(const uint256_t& block_hash, uint64_t block_height);
@@ -34,6 +34,14 @@ namespace rocksdb {
 class TransactionDB;
 }
 
+// UnreferencedRecords holds the values and machines which are stored but
+// can't be reached from any of the given roots. Values and machines which
+// are only referenced by other unreferenced records are left out
+struct UnreferencedRecords {
+    std::vector<uint256_t> machines;
+    std::vector<uint256_t> values;
+};
+
 class CheckpointStorage {
     std::shared_ptr<DataStorage> datastorage;
     std::shared_ptr<Code> code;
@@ -55,6 +63,11 @@ class CheckpointStorage {
     Machine getMachine(uint256_t machineHash, ValueCache& value_cache) const;
     DbResult<value> getValue(uint256_t value_hash,
                              ValueCache& value_cache) const;
+    // Finds the values and machines which aren't reachable from the given
+    // machines and values, the initial machine or saved code
+    UnreferencedRecords getUnreferencedRecords(
+        std::vector<uint256_t> machine_hashes,
+        std::vector<uint256_t> value_hashes) const;
 };
 
 #endif /* checkpointstorage_hpp */
@@ -22,6 +22,7 @@
 #include <map>
 #include <memory>
 #include <set>
+#include <vector>
 
 class Transaction;
 class CodeSegment;
@@ -38,5 +39,7 @@ void saveCode(Transaction& transaction,
               std::map<uint64_t, uint64_t>& segment_counts);
 void deleteCode(Transaction& transaction,
                 std::map<uint64_t, uint64_t>& segment_counts);
+// Returns the hashes of the immediate values of every saved code segment
+std::vector<uint256_t> getCodeImmediateHashes(const Transaction& transaction);
 
 #endif /* checkpoint_code_hpp */
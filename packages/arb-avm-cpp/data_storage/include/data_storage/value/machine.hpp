@@ -22,6 +22,8 @@
 #include <avm_values/bigint.hpp>
 #include <avm_values/codepointstub.hpp>
 
+#include <vector>
+
 class Transaction;
 
 template <typename T>
@@ -68,5 +70,10 @@ DbResult<MachineStateKeys> getMachineState(const Transaction& transaction,
                                            uint256_t machineHash);
 SaveResults saveMachine(Transaction& transaction, const Machine& machine);
 DeleteResults deleteMachine(Transaction& transaction, uint256_t machine_hash);
+// Returns true if stored_value, the data saved under a hash, holds the state
+// of a machine rather than a value
+bool isMachineState(const std::vector<unsigned char>& stored_value);
+// Returns the hashes of the values which make up a machine's state
+std::vector<uint256_t> machineStateChildren(const MachineStateKeys& state);
 
 #endif /* checkpoint_machine_hpp */
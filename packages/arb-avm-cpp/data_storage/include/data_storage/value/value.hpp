@@ -22,6 +22,7 @@
 
 #include <map>
 #include <set>
+#include <vector>
 
 struct DeleteResults;
 struct SaveResults;
@@ -46,5 +47,9 @@ DbResult<value> getValue(const Transaction& transaction,
                          ValueCache& value_cache);
 SaveResults saveValue(Transaction& transaction, const value& val);
 DeleteResults deleteValue(Transaction& transaction, uint256_t value_hash);
+// Returns the hashes of the values stored separately which the value
+// references, which are the tuples it contains
+DbResult<std::vector<uint256_t>> getValueChildren(const Transaction& transaction,
+                                                  uint256_t value_hash);
 
 #endif /* value_hpp */
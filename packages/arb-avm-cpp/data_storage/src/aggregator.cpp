@@ -38,6 +38,13 @@ constexpr auto request_key_size = request_key_prefix.size() + 32;
 constexpr auto block_hash_key_prefix = std::array<char, 1>{-55};
 constexpr auto block_hash_key_size = block_hash_key_prefix.size() + 32;
 
+constexpr auto tx_hash_key_prefix = std::array<char, 1>{-56};
+constexpr auto tx_hash_key_size = tx_hash_key_prefix.size() + 32;
+
+constexpr auto message_log_key_prefix = std::array<char, 1>{-57};
+constexpr auto message_log_key_size =
+    message_log_key_prefix.size() + sizeof(uint64_t);
+
 namespace {
 
 void commitTx(rocksdb::Transaction& tx) {
@@ -92,6 +99,22 @@ std::array<char, request_key_size> blockHashKey(const uint256_t& block_hash) {
 std::array<char, sizeof(uint64_t)> blockHashValue(uint64_t block_height) {
     return uint64Value(block_height);
 }
+
+std::array<char, tx_hash_key_size> txHashKey(const uint256_t& tx_hash) {
+    std::array<char, tx_hash_key_size> key;
+    auto it = std::copy(tx_hash_key_prefix.begin(), tx_hash_key_prefix.end(),
+                        key.begin());
+    to_big_endian(tx_hash, it);
+    return key;
+}
+
+std::array<char, message_log_key_size> messageLogKey(uint64_t message_index) {
+    std::array<char, message_log_key_size> key;
+    auto it = std::copy(message_log_key_prefix.begin(),
+                        message_log_key_prefix.end(), key.begin());
+    addUint64ToKey(message_index, it);
+    return key;
+}
 }  // namespace
 
 template <size_t N, const std::array<char, N>& key>
@@ -273,6 +296,40 @@ nonstd::optional<uint64_t> AggregatorStore::getPossibleRequestInfo(
     return returnIndex(*tx, requestKey(request_id));
 }
 
+void AggregatorStore::saveTxHash(const uint256_t& tx_hash,
+                                 uint64_t log_index) {
+    auto key = txHashKey(tx_hash);
+    auto value = requestValue(log_index);
+    auto s = data_storage->txn_db->Put(rocksdb::WriteOptions{}, vecToSlice(key),
+                                       vecToSlice(value));
+    if (!s.ok()) {
+        throw std::runtime_error("failed to save tx hash");
+    }
+}
+
+nonstd::optional<uint64_t> AggregatorStore::getPossibleTxHashInfo(
+    const uint256_t& tx_hash) const {
+    auto tx = data_storage->beginTransaction();
+    return returnIndex(*tx, txHashKey(tx_hash));
+}
+
+void AggregatorStore::saveMessageLog(uint64_t message_index,
+                                     uint64_t log_index) {
+    auto key = messageLogKey(message_index);
+    auto value = requestValue(log_index);
+    auto s = data_storage->txn_db->Put(rocksdb::WriteOptions{}, vecToSlice(key),
+                                       vecToSlice(value));
+    if (!s.ok()) {
+        throw std::runtime_error("failed to save message log");
+    }
+}
+
+nonstd::optional<uint64_t> AggregatorStore::getPossibleMessageLog(
+    uint64_t message_index) const {
+    auto tx = data_storage->beginTransaction();
+    return returnIndex(*tx, messageLogKey(message_index));
+}
+
 void AggregatorStore::saveBlockHash(const uint256_t& block_hash,
                                     uint64_t block_height) {
     auto key = blockHashKey(block_hash);
@@ -31,6 +31,11 @@
 #include <rocksdb/options.h>
 #include <rocksdb/utilities/transaction.h>
 
+#include "value/referencecount.hpp"
+
+#include <map>
+#include <set>
+
 namespace {
 constexpr auto initial_slice_label = "initial";
 }
@@ -204,3 +209,97 @@ DbResult<value> CheckpointStorage::getValue(uint256_t value_hash,
     auto tx = makeConstTransaction();
     return ::getValue(*tx, value_hash, value_cache);
 }
+
+UnreferencedRecords CheckpointStorage::getUnreferencedRecords(
+    std::vector<uint256_t> machine_hashes,
+    std::vector<uint256_t> value_hashes) const {
+    auto tx = makeConstTransaction();
+    std::string initial_raw;
+    auto s = tx->transaction->GetForUpdate(rocksdb::ReadOptions(),
+                                           rocksdb::Slice(initial_slice_label),
+                                           &initial_raw);
+    if (s.ok()) {
+        machine_hashes.push_back(intx::be::unsafe::load<uint256_t>(
+            reinterpret_cast<const unsigned char*>(initial_raw.data())));
+    }
+
+    // Mark everything reachable from the roots. Missing records are skipped
+    // since they are reported by checking each checkpoint
+    std::set<uint256_t> referenced;
+    for (const auto& machine_hash : machine_hashes) {
+        auto results = getMachineState(*tx, machine_hash);
+        if (!results.status.ok()) {
+            continue;
+        }
+        referenced.insert(machine_hash);
+        auto children = machineStateChildren(results.data);
+        value_hashes.insert(value_hashes.end(), children.begin(),
+                            children.end());
+    }
+    auto immediates = getCodeImmediateHashes(*tx);
+    value_hashes.insert(value_hashes.end(), immediates.begin(),
+                        immediates.end());
+    while (!value_hashes.empty()) {
+        auto value_hash = value_hashes.back();
+        value_hashes.pop_back();
+        if (!referenced.insert(value_hash).second) {
+            continue;
+        }
+        auto children = getValueChildren(*tx, value_hash);
+        if (!children.status.ok()) {
+            continue;
+        }
+        value_hashes.insert(value_hashes.end(), children.data.begin(),
+                            children.data.end());
+    }
+
+    // Values and machines are the only records keyed by a bare hash
+    std::map<uint256_t, bool> unreferenced;
+    std::set<uint256_t> unreferenced_children;
+    auto it =
+        std::unique_ptr<rocksdb::Iterator>(datastorage->txn_db->NewIterator(
+            rocksdb::ReadOptions(), datastorage->default_column.get()));
+    for (it->SeekToFirst(); it->Valid(); it->Next()) {
+        if (it->key().size() != 32) {
+            continue;
+        }
+        auto hash = intx::be::unsafe::load<uint256_t>(
+            reinterpret_cast<const unsigned char*>(it->key().data()));
+        if (referenced.find(hash) != referenced.end()) {
+            continue;
+        }
+        auto results = getRefCountedData(*tx->transaction, it->key());
+        if (!results.status.ok()) {
+            continue;
+        }
+        bool is_machine = isMachineState(results.stored_value);
+        unreferenced[hash] = is_machine;
+        if (is_machine) {
+            auto state = getMachineState(*tx, hash);
+            if (state.status.ok()) {
+                auto children = machineStateChildren(state.data);
+                unreferenced_children.insert(children.begin(), children.end());
+            }
+        } else {
+            auto children = getValueChildren(*tx, hash);
+            if (children.status.ok()) {
+                unreferenced_children.insert(children.data.begin(),
+                                             children.data.end());
+            }
+        }
+    }
+
+    UnreferencedRecords records;
+    for (const auto& item : unreferenced) {
+        if (unreferenced_children.find(item.first) !=
+            unreferenced_children.end()) {
+            continue;
+        }
+        if (item.second) {
+            records.machines.push_back(item.first);
+        } else {
+            records.values.push_back(item.first);
+        }
+    }
+    return records;
+}
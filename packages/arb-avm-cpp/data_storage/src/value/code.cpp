@@ -294,3 +294,28 @@ void saveCode(Transaction& transaction,
                            item.second, total_segment_counts[item.first], true);
     }
 }
+
+std::vector<uint256_t> getCodeImmediateHashes(const Transaction& transaction) {
+    std::vector<uint256_t> hashes;
+    auto prefix_slice = vecToSlice(segment_key_prefix);
+    auto it = std::unique_ptr<rocksdb::Iterator>(
+        transaction.datastorage->txn_db->NewIterator(
+            rocksdb::ReadOptions(),
+            transaction.datastorage->default_column.get()));
+    for (it->Seek(prefix_slice);
+         it->Valid() && it->key().starts_with(prefix_slice); it->Next()) {
+        if (it->key().size() != segment_key_size) {
+            continue;
+        }
+        auto results = getRefCountedData(*transaction.transaction, it->key());
+        if (!results.status.ok()) {
+            continue;
+        }
+        for (const auto& cp : extractRawCodeSegment(results.stored_value)) {
+            if (cp.immediateHash) {
+                hashes.push_back(*cp.immediateHash);
+            }
+        }
+    }
+    return hashes;
+}
@@ -31,6 +31,11 @@
 namespace {
 using iterator = std::vector<unsigned char>::const_iterator;
 
+// Size of a serialized MachineStateKeys. Serialized values are a number, a
+// code point stub or a tuple of up to 8 of them, and no combination of those
+// has this size
+constexpr size_t machine_state_size = 1 + 32 * 5 + 16 + (16 + 32) + 32;
+
 uint256_t extractUint256(iterator& iter) {
     auto ptr = reinterpret_cast<const char*>(&*iter);
     auto int_val = deserializeUint256t(ptr);
@@ -143,6 +148,15 @@ DeleteResults deleteMachine(Transaction& transaction, uint256_t machine_hash) {
     return delete_results;
 }
 
+bool isMachineState(const std::vector<unsigned char>& stored_value) {
+    return stored_value.size() == machine_state_size;
+}
+
+std::vector<uint256_t> machineStateChildren(const MachineStateKeys& state) {
+    return {state.static_hash, state.register_hash, state.datastack_hash,
+            state.auxstack_hash, state.staged_message_hash};
+}
+
 DbResult<MachineStateKeys> getMachineState(const Transaction& transaction,
                                            uint256_t machineHash) {
     std::vector<unsigned char> checkpoint_name;
@@ -494,3 +494,20 @@ DeleteResults deleteValue(Transaction& transaction, uint256_t value_hash) {
     std::map<uint64_t, uint64_t> segment_counts{};
     return deleteValueImpl(transaction, value_hash, segment_counts);
 }
+
+DbResult<std::vector<uint256_t>> getValueChildren(const Transaction& transaction,
+                                                  uint256_t value_hash) {
+    auto results = getStoredValue(transaction, ValueHash{value_hash});
+    if (!results.status.ok()) {
+        return {results.status, results.reference_count, {}};
+    }
+    std::vector<uint256_t> children;
+    std::map<uint64_t, uint64_t> segment_counts;
+    nonstd::visit(
+        [&](const auto& val) {
+            deleteParsedValue(val, children, segment_counts);
+        },
+        parseRecord(results.stored_value));
+    return {rocksdb::Status::OK(), results.reference_count,
+            std::move(children)};
+}
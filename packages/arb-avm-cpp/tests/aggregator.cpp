@@ -56,6 +56,25 @@ TEST_CASE("Aggregator tests") {
         REQUIRE(!store->getPossibleRequestInfo(8).has_value());
     }
 
+    SECTION("tx hashes") {
+        REQUIRE(!store->getPossibleTxHashInfo(10).has_value());
+        store->saveTxHash(10, 5);
+        auto txIndex = store->getPossibleTxHashInfo(10);
+        REQUIRE(txIndex.has_value());
+        REQUIRE(*txIndex == 5);
+        REQUIRE(!store->getPossibleRequestInfo(10).has_value());
+        REQUIRE(!store->getPossibleTxHashInfo(8).has_value());
+    }
+
+    SECTION("message logs") {
+        REQUIRE(!store->getPossibleMessageLog(3).has_value());
+        store->saveMessageLog(3, 7);
+        auto logIndex = store->getPossibleMessageLog(3);
+        REQUIRE(logIndex.has_value());
+        REQUIRE(*logIndex == 7);
+        REQUIRE(!store->getPossibleMessageLog(4).has_value());
+    }
+
     SECTION("blocks") {
         CHECK_THROWS(store->latestBlock());
         std::vector<char> data{1, 2, 3, 4};
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+
+pragma solidity ^0.5.11;
+
+library AccumulatorLib {
+    /**
+     * @notice Check a proof that a value was added to a hash chain accumulator such as the
+     * lastMessageHash or lastLogHash of an assertion, where each value is added with
+     * acc = keccak256(abi.encodePacked(acc, valueHash)). This is not a merkle proof, so its size
+     * grows with the number of values added after the proven one
+     * @param prefixAcc Accumulator covering every value before the proven one
+     * @param valueHash Hash of the proven value
+     * @param suffixHashes Hashes of the values added after the proven one, in order
+     * @param lastHash Final value of the accumulator
+     */
+    function verifyProof(
+        bytes32 prefixAcc,
+        bytes32 valueHash,
+        bytes32[] memory suffixHashes,
+        bytes32 lastHash
+    ) internal pure returns (bool) {
+        bytes32 acc = keccak256(abi.encodePacked(prefixAcc, valueHash));
+        for (uint256 i = 0; i < suffixHashes.length; i++) {
+            acc = keccak256(abi.encodePacked(acc, suffixHashes[i]));
+        }
+        return acc == lastHash;
+    }
+}
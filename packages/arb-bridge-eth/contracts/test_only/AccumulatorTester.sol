@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+
+pragma solidity ^0.5.11;
+
+import "../libraries/AccumulatorLib.sol";
+
+library AccumulatorTester {
+    function verifyProof(
+        bytes32 prefixAcc,
+        bytes32 valueHash,
+        bytes32[] memory suffixHashes,
+        bytes32 lastHash
+    ) public pure returns (bool) {
+        return AccumulatorLib.verifyProof(prefixAcc, valueHash, suffixHashes, lastHash);
+    }
+}
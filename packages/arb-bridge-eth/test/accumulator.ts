@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/* eslint-env node, mocha */
+
+import { ethers } from '@nomiclabs/buidler'
+import * as chai from 'chai'
+import chaiAsPromised from 'chai-as-promised'
+import { AccumulatorTester } from '../build/types/AccumulatorTester'
+
+chai.use(chaiAsPromised)
+
+const { assert } = chai
+
+let accumulatorTester: AccumulatorTester
+
+function accumulate(acc: string, valueHash: string): string {
+  return ethers.utils.solidityKeccak256(['bytes32', 'bytes32'], [acc, valueHash])
+}
+
+const initialAcc = ethers.constants.HashZero
+const valueHashes = [1, 2, 3, 4, 5].map(i =>
+  ethers.utils.keccak256(ethers.utils.hexZeroPad(ethers.utils.hexlify(i), 32))
+)
+const lastHash = valueHashes.reduce(accumulate, initialAcc)
+
+describe('Accumulator', () => {
+  before(async () => {
+    const AccumulatorTester = await ethers.getContractFactory(
+      'AccumulatorTester'
+    )
+    accumulatorTester = (await AccumulatorTester.deploy()) as AccumulatorTester
+    await accumulatorTester.deployed()
+  })
+
+  it('verifies a proof of each value', async () => {
+    for (let i = 0; i < valueHashes.length; i++) {
+      const prefixAcc = valueHashes.slice(0, i).reduce(accumulate, initialAcc)
+      const valid = await accumulatorTester.verifyProof(
+        prefixAcc,
+        valueHashes[i],
+        valueHashes.slice(i + 1),
+        lastHash
+      )
+      assert.isTrue(valid, `proof of value ${i} rejected`)
+    }
+  })
+
+  it('rejects a proof of the wrong value', async () => {
+    const valid = await accumulatorTester.verifyProof(
+      accumulate(initialAcc, valueHashes[0]),
+      valueHashes[2],
+      valueHashes.slice(2),
+      lastHash
+    )
+    assert.isFalse(valid)
+  })
+})
@@ -0,0 +1,428 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checkpointing
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+const archiveVersion = 1
+
+// archiveInfoName is the name of the first entry of an archive, which holds
+// its ArchiveInfo. It is followed by the files of the database under
+// archiveDBDir
+const archiveInfoName = "checkpoint.json"
+const archiveDBDir = "db/"
+
+// ArchiveInfo describes the checkpoint in an archive
+type ArchiveInfo struct {
+	Version     int               `json:"version"`
+	Rollup      ethcommon.Address `json:"rollup"`
+	BlockHeight uint64            `json:"blockHeight"`
+	BlockHash   ethcommon.Hash    `json:"blockHash"`
+	// Contents are the bytes saved by the owner of the checkpointer, which
+	// interprets them when verifying the archive
+	Contents hexutil.Bytes `json:"contents"`
+	// Machines are the machines referenced by the checkpoint
+	Machines []ethcommon.Hash `json:"machines"`
+}
+
+func (info *ArchiveInfo) BlockId() *common.BlockId {
+	return &common.BlockId{
+		Height:     common.NewTimeBlocks(new(big.Int).SetUint64(info.BlockHeight)),
+		HeaderHash: common.NewHashFromEth(info.BlockHash),
+	}
+}
+
+// ArchiveVerifier checks an archived checkpoint against the L1 chain before
+// it is exported or after it is imported. The checkpointer's database
+// contains the archived state while it is called
+type ArchiveVerifier func(info *ArchiveInfo, cp *IndexedCheckpointer) error
+
+// ExportArchive writes the newest checkpoint at or below maxHeight in the
+// canonical L1 chain to w as a gzipped tar archive. The archive contains the
+// whole database, including the aggregator store, so that importing it gives
+// the same state that restoring the checkpoint locally would. The checkpoint
+// is chosen by height alone rather than at a block where a node was
+// confirmed, so its state is generally ahead of the latest confirmed node and
+// verify must check it against the node confirmed as of the checkpoint's
+// block. The database is locked for the duration of the export, so the
+// process using it must be stopped first
+func ExportArchive(
+	ctx context.Context,
+	clnt arbbridge.ChainTimeGetter,
+	rollupAddr common.Address,
+	config Config,
+	maxHeight *common.TimeBlocks,
+	verify ArchiveVerifier,
+	w io.Writer,
+) (*ArchiveInfo, error) {
+	databasePath, err := config.databasePath(rollupAddr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(databasePath); err != nil {
+		return nil, err
+	}
+
+	cp, err := newIndexedCheckpointer(rollupAddr, config, false)
+	if err != nil {
+		return nil, err
+	}
+	defer cp.lock.Close()
+
+	info, err := func() (*ArchiveInfo, error) {
+		defer cp.db.CloseCheckpointStorage()
+		info, err := cp.findArchiveCheckpoint(ctx, clnt, rollupAddr, maxHeight)
+		if err != nil {
+			return nil, err
+		}
+		if err := verify(info, cp); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	// The database is closed and still locked, so its files are consistent
+	if err := writeArchive(w, info, databasePath); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (cp *IndexedCheckpointer) findArchiveCheckpoint(
+	ctx context.Context,
+	clnt arbbridge.ChainTimeGetter,
+	rollupAddr common.Address,
+	maxHeight *common.TimeBlocks,
+) (*ArchiveInfo, error) {
+	if cp.bs.IsBlockStoreEmpty() {
+		return nil, errNoCheckpoint
+	}
+	height := cp.bs.MaxBlockStoreHeight()
+	if maxHeight != nil && maxHeight.Cmp(height) < 0 {
+		height = maxHeight
+	}
+	lowestHeight := cp.bs.MinBlockStoreHeight()
+	for ; height.Cmp(lowestHeight) >= 0; height = common.NewTimeBlocks(new(big.Int).Sub(height.AsInt(), big.NewInt(1))) {
+		onchainId, err := clnt.BlockIdForHeight(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+		blockData, err := cp.bs.GetBlock(onchainId)
+		if err != nil {
+			continue
+		}
+		ckpWithMan := &CheckpointWithManifest{}
+		if err := proto.Unmarshal(blockData, ckpWithMan); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint at height %v: %v", height, err)
+		}
+		info := &ArchiveInfo{
+			Version:     archiveVersion,
+			Rollup:      rollupAddr.ToEthAddress(),
+			BlockHeight: onchainId.Height.AsInt().Uint64(),
+			BlockHash:   onchainId.HeaderHash.ToEthHash(),
+			Contents:    ckpWithMan.Contents,
+			Machines:    make([]ethcommon.Hash, 0),
+		}
+		if ckpWithMan.Manifest != nil {
+			for _, hbuf := range ckpWithMan.Manifest.Machines {
+				info.Machines = append(info.Machines, hbuf.Unmarshal().ToEthHash())
+			}
+		}
+		if err := cp.verifyArchiveState(info, ckpWithMan); err != nil {
+			return nil, fmt.Errorf("checkpoint at height %v is incomplete: %v", height, err)
+		}
+		return info, nil
+	}
+	return nil, errNoMatchingCheckpoint
+}
+
+// verifyArchiveState checks that every machine and value referenced by the
+// checkpoint can be loaded and that the machines have the expected hashes
+func (cp *IndexedCheckpointer) verifyArchiveState(info *ArchiveInfo, ckpWithMan *CheckpointWithManifest) error {
+	valueCache, err := cmachine.NewValueCache()
+	if err != nil {
+		return err
+	}
+	for _, machineHash := range info.Machines {
+		mach, err := cp.db.GetMachine(common.NewHashFromEth(machineHash), valueCache)
+		if err != nil {
+			return err
+		}
+		if mach.Hash() != common.NewHashFromEth(machineHash) {
+			return fmt.Errorf("machine %v has hash %v", machineHash.Hex(), mach.Hash())
+		}
+	}
+	if ckpWithMan != nil && ckpWithMan.Manifest != nil {
+		for _, hbuf := range ckpWithMan.Manifest.Values {
+			if _, err := cp.db.GetValue(hbuf.Unmarshal(), valueCache); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeArchive(w io.Writer, info *ArchiveInfo, databasePath string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	infoData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name: archiveInfoName,
+		Mode: 0600,
+		Size: int64(len(infoData)),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(infoData); err != nil {
+		return err
+	}
+
+	err = filepath.Walk(databasePath, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || fi.Name() == lockFileName {
+			return nil
+		}
+		relPath, err := filepath.Rel(databasePath, filePath)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name: archiveDBDir + filepath.ToSlash(relPath),
+			Mode: 0600,
+			Size: fi.Size(),
+		}); err != nil {
+			return err
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ImportArchive creates the checkpoint database for rollupAddr from an
+// archive written by ExportArchive. The database must not already exist.
+// Checkpoints newer than the archived one are removed so that the archived
+// checkpoint is the one restored when the database is next opened. If the
+// archive is invalid or verify fails, the database is removed again
+func ImportArchive(
+	rollupAddr common.Address,
+	config Config,
+	verify ArchiveVerifier,
+	r io.Reader,
+) (*ArchiveInfo, error) {
+	databasePath, err := config.databasePath(rollupAddr)
+	if err != nil {
+		return nil, err
+	}
+	if entries, err := ioutil.ReadDir(databasePath); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("checkpoint database %v already exists", databasePath)
+	}
+
+	lock, err := lockDatabase(databasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+
+	info, err := importArchive(rollupAddr, config, databasePath, lock, verify, r)
+	if err != nil {
+		if removeErr := removeDatabase(databasePath); removeErr != nil {
+			return nil, fmt.Errorf("%v (failed to remove partial import: %v)", err, removeErr)
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// removeDatabase deletes the files of a database while leaving its lock in
+// place until the caller releases it
+func removeDatabase(databasePath string) error {
+	entries, err := ioutil.ReadDir(databasePath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == lockFileName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(databasePath, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importArchive(
+	rollupAddr common.Address,
+	config Config,
+	databasePath string,
+	lock *os.File,
+	verify ArchiveVerifier,
+	r io.Reader,
+) (*ArchiveInfo, error) {
+	info, err := extractArchive(r, databasePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %v", info.Version)
+	}
+	if common.NewAddressFromEth(info.Rollup) != rollupAddr {
+		return nil, fmt.Errorf("archive is for rollup %v", info.Rollup.Hex())
+	}
+
+	cp, err := openIndexedCheckpointer(databasePath, config, lock)
+	if err != nil {
+		return nil, err
+	}
+	defer cp.db.CloseCheckpointStorage()
+
+	blockId := info.BlockId()
+	if _, err := cp.bs.GetBlock(blockId); err != nil {
+		return nil, fmt.Errorf("archive doesn't contain a checkpoint for block %v", blockId)
+	}
+	if err := cp.removeCheckpointsAfter(blockId); err != nil {
+		return nil, err
+	}
+	if err := cp.verifyArchiveState(info, nil); err != nil {
+		return nil, err
+	}
+	if err := verify(info, cp); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// removeCheckpointsAfter deletes every checkpoint except blockId at its
+// height or above
+func (cp *IndexedCheckpointer) removeCheckpointsAfter(blockId *common.BlockId) error {
+	maxHeight := cp.bs.MaxBlockStoreHeight()
+	for height := blockId.Height; height.Cmp(maxHeight) <= 0; height = common.NewTimeBlocks(new(big.Int).Add(height.AsInt(), big.NewInt(1))) {
+		for _, id := range cp.bs.BlocksAtHeight(height) {
+			if id.Equals(blockId) {
+				continue
+			}
+			if err := deleteCheckpointForKey(cp.bs, cp.db, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func extractArchive(r io.Reader, databasePath string) (*ArchiveInfo, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gr)
+
+	header, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if header.Name != archiveInfoName {
+		return nil, errors.New("archive doesn't start with checkpoint info")
+	}
+	info := &ArchiveInfo{}
+	if err := json.NewDecoder(tr).Decode(info); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint info: %v", err)
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return info, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected archive entry %v", header.Name)
+		}
+		relPath := path.Clean(strings.TrimPrefix(header.Name, archiveDBDir))
+		if !strings.HasPrefix(header.Name, archiveDBDir) ||
+			path.IsAbs(relPath) ||
+			relPath == ".." ||
+			strings.HasPrefix(relPath, "../") ||
+			relPath == lockFileName {
+			return nil, fmt.Errorf("invalid archive entry %v", header.Name)
+		}
+		filePath := filepath.Join(databasePath, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+			return nil, err
+		}
+		if err := extractFile(tr, filePath); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func extractFile(r io.Reader, filePath string) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
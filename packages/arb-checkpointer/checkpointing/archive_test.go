@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checkpointing
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+var importDBPath = "./testdb-import"
+
+func TestArchive(t *testing.T) {
+	var rollupAddr common.Address
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointContext := ckptcontext.NewCheckpointContext()
+	for _, wc := range []*writableCheckpoint{
+		{blockId: initialEntryBlockId, contents: checkpointData, ckpCtx: checkpointContext},
+		{blockId: laterEntryBlockId, contents: checkpointData2, ckpCtx: checkpointContext},
+	} {
+		if err := writeCheckpoint(cp.bs, cp.db, wc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp.db.CloseCheckpointStorage()
+	if err := cp.lock.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tgm := &TimeGetterMock{func(ctx context.Context, height *common.TimeBlocks) (*common.BlockId, error) {
+		if height.Cmp(laterEntryBlockId.Height) == 0 {
+			return laterEntryBlockId, nil
+		}
+		if height.Cmp(initialEntryBlockId.Height) == 0 {
+			return initialEntryBlockId, nil
+		}
+		return &common.BlockId{Height: height}, nil
+	}}
+	acceptArchive := func(*ArchiveInfo, *IndexedCheckpointer) error {
+		return nil
+	}
+
+	var archive bytes.Buffer
+	info, err := ExportArchive(context.Background(), tgm, rollupAddr, testConfig, initialEntryBlockId.Height, acceptArchive, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.BlockId().Equals(initialEntryBlockId) || !bytes.Equal(info.Contents, checkpointData) {
+		t.Error("exported wrong checkpoint", info.BlockId())
+	}
+
+	defer os.RemoveAll(importDBPath)
+	importConfig := Config{DatabasePath: importDBPath, MaxReorgHeight: maxReorgHeight}
+	if _, err := ImportArchive(rollupAddr, importConfig, func(*ArchiveInfo, *IndexedCheckpointer) error {
+		return errors.New("rejected")
+	}, bytes.NewReader(archive.Bytes())); err == nil {
+		t.Fatal("import should fail if verification fails")
+	}
+	if _, err := ImportArchive(common.Address{1}, importConfig, acceptArchive, bytes.NewReader(archive.Bytes())); err == nil {
+		t.Fatal("import should fail for a different rollup")
+	}
+	if _, err := ImportArchive(rollupAddr, importConfig, acceptArchive, bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportArchive(rollupAddr, importConfig, acceptArchive, bytes.NewReader(archive.Bytes())); err == nil {
+		t.Fatal("import should fail if the database exists")
+	}
+
+	imported, err := newIndexedCheckpointer(rollupAddr, importConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.db.CloseCheckpointStorage()
+	defer imported.lock.Close()
+
+	if err := imported.RestoreLatestState(context.Background(), tgm, func(data []byte, _ ckptcontext.RestoreContext, blockId *common.BlockId) error {
+		if !blockId.Equals(initialEntryBlockId) || !bytes.Equal(data, checkpointData) {
+			t.Error("restored wrong checkpoint", blockId)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchiveRejectsEscapingPaths(t *testing.T) {
+	for _, name := range []string{"db/../outside", "db/" + lockFileName, "outside"} {
+		var archive bytes.Buffer
+		gw := gzip.NewWriter(&archive)
+		tw := tar.NewWriter(gw)
+		for _, entry := range []struct {
+			name string
+			data string
+		}{{archiveInfoName, "{}"}, {name, "data"}} {
+			if err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.data))}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(entry.data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dir, err := ioutil.TempDir("", "archive")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := extractArchive(&archive, dir); err == nil {
+			t.Error("extracted invalid entry", name)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -22,7 +22,10 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/config"
 	"math/big"
+	"os"
+	"path/filepath"
 )
 
 type RollupCheckpointer interface {
@@ -36,8 +39,60 @@ type RollupCheckpointer interface {
 	MaxReorgHeight() *big.Int
 }
 
-const checkpointDatabasePathBase = "/tmp/arb-validator-checkpoint-"
+// DefaultCleanupInterval is the number of blocks between deletions of old
+// checkpoints if Config.CleanupInterval isn't set
+var DefaultCleanupInterval = common.NewTimeBlocksInt(25)
 
-func MakeCheckpointDatabasePath(rollupAddr common.Address) string {
-	return checkpointDatabasePathBase + rollupAddr.Hex()[2:]
+// Config sets where an IndexedCheckpointer keeps its database and how long
+// checkpoints are kept for
+type Config struct {
+	// DatabasePath is the location of the checkpoint database. If it is
+	// empty, the path from MakeCheckpointDatabasePath is used
+	DatabasePath string
+	// MaxReorgHeight is the number of blocks checkpoints are kept for
+	MaxReorgHeight *big.Int
+	// CleanupInterval is the number of blocks between deletions of
+	// checkpoints older than MaxReorgHeight. If it is nil,
+	// DefaultCleanupInterval is used
+	CleanupInterval *common.TimeBlocks
+}
+
+// NewConfig returns the checkpointer settings from a loaded configuration
+func NewConfig(cfg *config.Config) Config {
+	return Config{
+		DatabasePath:    cfg.CheckpointPath(),
+		MaxReorgHeight:  big.NewInt(cfg.Checkpoint.MaxReorgDepth),
+		CleanupInterval: common.NewTimeBlocksInt(cfg.Checkpoint.CleanupInterval),
+	}
+}
+
+func (c Config) databasePath(rollupAddr common.Address) (string, error) {
+	if c.DatabasePath != "" {
+		return c.DatabasePath, nil
+	}
+	return MakeCheckpointDatabasePath(rollupAddr)
+}
+
+// ResolveDatabasePath returns the directory holding the checkpoint database
+// of the given rollup, so that other state can be kept next to it
+func (c Config) ResolveDatabasePath(rollupAddr common.Address) (string, error) {
+	return c.databasePath(rollupAddr)
+}
+
+func (c Config) cleanupInterval() *common.TimeBlocks {
+	if c.CleanupInterval == nil {
+		return DefaultCleanupInterval
+	}
+	return c.CleanupInterval
+}
+
+// MakeCheckpointDatabasePath returns the default location of the checkpoint
+// database for the given rollup, in the .arbitrum folder of the user's home
+// directory so that it survives reboots
+func MakeCheckpointDatabasePath(rollupAddr common.Address) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".arbitrum", "checkpoint-"+rollupAddr.Hex()[2:]), nil
 }
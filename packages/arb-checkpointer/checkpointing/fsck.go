@@ -0,0 +1,243 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checkpointing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+var errNoConsistentCheckpoint = errors.New("cannot roll back because no consistent checkpoint exists")
+
+// CheckpointStatus is the result of checking one checkpoint in the block
+// store
+type CheckpointStatus struct {
+	BlockId *common.BlockId
+	// Canonical is false for checkpoints of blocks that are no longer part
+	// of the L1 chain. They are never restored
+	Canonical bool
+	// Err is set if the checkpoint couldn't be read from the block store
+	Err             error
+	MissingValues   []common.Hash
+	MissingMachines []common.Hash
+}
+
+// Consistent returns true if the checkpoint and everything it references
+// could be loaded
+func (s *CheckpointStatus) Consistent() bool {
+	return s.Err == nil && len(s.MissingValues) == 0 && len(s.MissingMachines) == 0
+}
+
+// FsckReport describes every checkpoint in a database ordered by height
+type FsckReport struct {
+	Checkpoints []*CheckpointStatus
+	// Restorable is the newest consistent checkpoint in the L1 chain, or
+	// nil if there is none
+	Restorable *common.BlockId
+	// RolledBack holds the checkpoints deleted by a repair
+	RolledBack []*common.BlockId
+	// OrphanedMachines and OrphanedValues are the records in the checkpoint
+	// storage that no manifest references. Records only referenced by other
+	// orphans are left out. They are only checked if every manifest could be
+	// read since the records of an unreadable one would be reported too
+	OrphanedMachines []common.Hash
+	OrphanedValues   []common.Hash
+}
+
+// NonCanonical returns the checkpoints of blocks that are no longer part of
+// the L1 chain
+func (r *FsckReport) NonCanonical() []*CheckpointStatus {
+	var nonCanonical []*CheckpointStatus
+	for _, status := range r.Checkpoints {
+		if !status.Canonical {
+			nonCanonical = append(nonCanonical, status)
+		}
+	}
+	return nonCanonical
+}
+
+// Inconsistent returns the checkpoints that can't be restored because they
+// or the values and machines they reference are missing
+func (r *FsckReport) Inconsistent() []*CheckpointStatus {
+	var inconsistent []*CheckpointStatus
+	for _, status := range r.Checkpoints {
+		if !status.Consistent() {
+			inconsistent = append(inconsistent, status)
+		}
+	}
+	return inconsistent
+}
+
+// Fsck checks that every checkpoint in the database for rollupAddr can be
+// read, that every value and machine its manifest references exists in the
+// checkpoint storage and that no value or machine is stored without being
+// referenced by a manifest. If repair is true, every checkpoint newer than the
+// newest consistent checkpoint in the L1 chain is deleted so that it is the
+// one restored when the database is next opened. The database is locked
+// while it is checked, so the process using it must be stopped first
+func Fsck(
+	ctx context.Context,
+	clnt arbbridge.ChainTimeGetter,
+	rollupAddr common.Address,
+	config Config,
+	repair bool,
+) (*FsckReport, error) {
+	databasePath, err := config.databasePath(rollupAddr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(databasePath); err != nil {
+		return nil, err
+	}
+
+	cp, err := newIndexedCheckpointer(rollupAddr, config, false)
+	if err != nil {
+		return nil, err
+	}
+	defer cp.lock.Close()
+	defer cp.db.CloseCheckpointStorage()
+
+	report, err := cp.fsck(ctx, clnt)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		if err := cp.rollBack(report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (cp *IndexedCheckpointer) fsck(ctx context.Context, clnt arbbridge.ChainTimeGetter) (*FsckReport, error) {
+	report := &FsckReport{}
+	if cp.bs.IsBlockStoreEmpty() {
+		return report, nil
+	}
+
+	valueCache, err := cmachine.NewValueCache()
+	if err != nil {
+		return nil, err
+	}
+	var machineHashes, valueHashes []common.Hash
+	manifestsReadable := true
+	maxHeight := cp.bs.MaxBlockStoreHeight()
+	for height := cp.bs.MinBlockStoreHeight(); height.Cmp(maxHeight) <= 0; height = common.NewTimeBlocks(new(big.Int).Add(height.AsInt(), big.NewInt(1))) {
+		blockIds := cp.bs.BlocksAtHeight(height)
+		if len(blockIds) == 0 {
+			continue
+		}
+		onchainId, err := clnt.BlockIdForHeight(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range blockIds {
+			status, manifest := checkCheckpoint(cp.bs, cp.db, id, valueCache)
+			status.Canonical = id.Equals(onchainId)
+			if status.Canonical && status.Consistent() {
+				report.Restorable = id
+			}
+			report.Checkpoints = append(report.Checkpoints, status)
+			if status.Err != nil {
+				manifestsReadable = false
+				continue
+			}
+			for _, hbuf := range manifest.GetMachines() {
+				machineHashes = append(machineHashes, hbuf.Unmarshal())
+			}
+			for _, hbuf := range manifest.GetValues() {
+				valueHashes = append(valueHashes, hbuf.Unmarshal())
+			}
+		}
+	}
+	if manifestsReadable {
+		report.OrphanedMachines, report.OrphanedValues = cp.db.UnreferencedRecords(machineHashes, valueHashes)
+	}
+	return report, nil
+}
+
+// checkCheckpoint returns the status of the checkpoint for id along with its
+// manifest
+func checkCheckpoint(
+	bs machine.BlockStore,
+	db machine.CheckpointStorage,
+	id *common.BlockId,
+	valueCache machine.ValueCache,
+) (*CheckpointStatus, *ckptcontext.CheckpointManifest) {
+	status := &CheckpointStatus{BlockId: id}
+	blockData, err := bs.GetBlock(id)
+	if err != nil {
+		status.Err = err
+		return status, nil
+	}
+	ckpWithMan := &CheckpointWithManifest{}
+	if err := proto.Unmarshal(blockData, ckpWithMan); err != nil {
+		status.Err = err
+		return status, nil
+	}
+	for _, hbuf := range ckpWithMan.Manifest.GetValues() {
+		h := hbuf.Unmarshal()
+		if _, err := db.GetValue(h, valueCache); err != nil {
+			status.MissingValues = append(status.MissingValues, h)
+		}
+	}
+	for _, hbuf := range ckpWithMan.Manifest.GetMachines() {
+		h := hbuf.Unmarshal()
+		mach, err := db.GetMachine(h, valueCache)
+		if err != nil || mach.Hash() != h {
+			status.MissingMachines = append(status.MissingMachines, h)
+		}
+	}
+	return status, ckpWithMan.Manifest
+}
+
+// rollBack deletes every checkpoint at or above the height of the
+// restorable checkpoint in report except for the restorable one itself
+func (cp *IndexedCheckpointer) rollBack(report *FsckReport) error {
+	if report.Restorable == nil {
+		if len(report.Checkpoints) == 0 {
+			return nil
+		}
+		return errNoConsistentCheckpoint
+	}
+	for _, status := range report.Checkpoints {
+		if status.BlockId.Height.Cmp(report.Restorable.Height) < 0 || status.BlockId.Equals(report.Restorable) {
+			continue
+		}
+		if status.Err != nil {
+			// The manifest is unreadable, so only the block can be removed
+			if err := cp.bs.DeleteBlock(status.BlockId); err != nil {
+				return fmt.Errorf("failed to delete checkpoint %v: %v", status.BlockId, err)
+			}
+		} else if err := deleteCheckpointForKey(cp.bs, cp.db, status.BlockId); err != nil {
+			return fmt.Errorf("failed to delete checkpoint %v: %v", status.BlockId, err)
+		}
+		report.RolledBack = append(report.RolledBack, status.BlockId)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checkpointing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func TestFsck(t *testing.T) {
+	var rollupAddr common.Address
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.db.CloseCheckpointStorage()
+
+	initialContext := ckptcontext.NewCheckpointContext()
+	initialContext.AddValue(value.NewInt64Value(1))
+	laterContext := ckptcontext.NewCheckpointContext()
+	laterContext.AddValue(value.NewInt64Value(2))
+	for _, wc := range []*writableCheckpoint{
+		{blockId: initialEntryBlockId, contents: checkpointData, ckpCtx: initialContext},
+		{blockId: laterEntryBlockId, contents: checkpointData2, ckpCtx: laterContext},
+		{blockId: laterEntryBlockId2, contents: checkpointData3, ckpCtx: initialContext},
+	} {
+		if err := writeCheckpoint(cp.bs, cp.db, wc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate a crash that lost the value of the latest checkpoint
+	cp.db.DeleteValue(value.NewInt64Value(2).Hash())
+	// and one that saved a value without writing its checkpoint
+	orphan := value.NewTuple2(value.NewInt64Value(3), value.NewInt64Value(4))
+	cp.db.SaveValue(orphan)
+
+	tgm := &TimeGetterMock{func(ctx context.Context, height *common.TimeBlocks) (*common.BlockId, error) {
+		if height.Cmp(laterEntryBlockId.Height) == 0 {
+			return laterEntryBlockId, nil
+		}
+		if height.Cmp(initialEntryBlockId.Height) == 0 {
+			return initialEntryBlockId, nil
+		}
+		return &common.BlockId{Height: height}, nil
+	}}
+
+	report, err := cp.fsck(context.Background(), tgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Checkpoints) != 3 {
+		t.Fatal("expected 3 checkpoints, got", len(report.Checkpoints))
+	}
+	if report.Restorable == nil || !report.Restorable.Equals(initialEntryBlockId) {
+		t.Error("wrong restorable checkpoint", report.Restorable)
+	}
+	nonCanonical := report.NonCanonical()
+	if len(nonCanonical) != 1 || !nonCanonical[0].BlockId.Equals(laterEntryBlockId2) {
+		t.Error("wrong non-canonical checkpoints", nonCanonical)
+	}
+	if len(report.OrphanedValues) != 1 || report.OrphanedValues[0] != orphan.Hash() {
+		t.Error("wrong orphaned values", report.OrphanedValues)
+	}
+	if len(report.OrphanedMachines) != 0 {
+		t.Error("wrong orphaned machines", report.OrphanedMachines)
+	}
+	inconsistent := report.Inconsistent()
+	if len(inconsistent) != 1 || !inconsistent[0].BlockId.Equals(laterEntryBlockId) || len(inconsistent[0].MissingValues) != 1 {
+		t.Error("wrong inconsistent checkpoints", inconsistent)
+	}
+
+	if err := cp.rollBack(report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RolledBack) != 2 {
+		t.Error("expected 2 checkpoints to be rolled back, got", len(report.RolledBack))
+	}
+	if cp.bs.MaxBlockStoreHeight().Cmp(initialEntryBlockId.Height) != 0 {
+		t.Error("newer checkpoints remain after roll back")
+	}
+	valueCache, err := cmachine.NewValueCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cp.db.GetValue(value.NewInt64Value(1).Hash(), valueCache); err != nil {
+		t.Error("roll back removed value of restorable checkpoint")
+	}
+
+	report, err = cp.fsck(context.Background(), tgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Inconsistent()) != 0 || len(report.NonCanonical()) != 0 {
+		t.Error("database still inconsistent after roll back")
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
@@ -33,6 +34,14 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
+)
+
+var (
+	metricsRegistry = metrics.NewRegistry("checkpointer")
+
+	writeTimer        = gethmetrics.NewRegisteredTimer("write", metricsRegistry)
+	writeErrorCounter = gethmetrics.NewRegisteredCounter("write/errors", metricsRegistry)
 )
 
 var errNoCheckpoint = errors.New("cannot restore because no checkpoint exists")
@@ -40,22 +49,25 @@ var errNoMatchingCheckpoint = errors.New("cannot restore because no matching che
 
 type IndexedCheckpointer struct {
 	*sync.Mutex
-	db                    *cmachine.CheckpointStorage
+	db                    machine.CheckpointStorage
 	bs                    machine.BlockStore
 	nextCheckpointToWrite *writableCheckpoint
 	maxReorgHeight        *big.Int
+	cleanupInterval       *common.TimeBlocks
+
+	// lock is held for as long as the process runs to stop other
+	// processes from opening the same database
+	lock *os.File
 }
 
 func NewIndexedCheckpointer(
 	rollupAddr common.Address,
-	databasePath string,
-	maxReorgHeight *big.Int,
+	config Config,
 	forceFreshStart bool,
 ) (*IndexedCheckpointer, error) {
 	ret, err := newIndexedCheckpointer(
 		rollupAddr,
-		databasePath,
-		new(big.Int).Set(maxReorgHeight),
+		config,
 		forceFreshStart,
 	)
 
@@ -63,41 +75,84 @@ func NewIndexedCheckpointer(
 		return nil, err
 	}
 
-	go ret.writeDaemon()
-	go cleanupDaemon(ret.bs, ret.db, maxReorgHeight)
+	ret.start()
 	return ret, nil
 }
 
+// NewIndexedCheckpointerWithStorage creates a checkpointer that writes to db
+// and bs rather than opening a database, such as the in-memory storage from
+// gomachine. Since there is no database, nothing is locked
+func NewIndexedCheckpointerWithStorage(
+	db machine.CheckpointStorage,
+	bs machine.BlockStore,
+	config Config,
+) *IndexedCheckpointer {
+	ret := newIndexedCheckpointerWithStorage(db, bs, config, nil)
+	ret.start()
+	return ret
+}
+
+func (cp *IndexedCheckpointer) start() {
+	go cp.writeDaemon()
+	go cleanupDaemon(cp.bs, cp.db, cp.maxReorgHeight, cp.cleanupInterval)
+}
+
 // newIndexedCheckpointerFactory creates the checkpointer, but doesn't
 // launch it's reading and writing threads. This is useful for deterministic
 // testing
 func newIndexedCheckpointer(
 	rollupAddr common.Address,
-	databasePath string,
-	maxReorgHeight *big.Int,
+	config Config,
 	forceFreshStart bool,
 ) (*IndexedCheckpointer, error) {
-	if databasePath == "" {
-		databasePath = MakeCheckpointDatabasePath(rollupAddr)
+	databasePath, err := config.databasePath(rollupAddr)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := lockDatabase(databasePath)
+	if err != nil {
+		return nil, err
 	}
 	if forceFreshStart {
 		// for testing only --  delete old database to get fresh start
-		if err := os.RemoveAll(databasePath); err != nil {
+		if err := removeDatabase(databasePath); err != nil {
+			_ = lock.Close()
 			return nil, err
 		}
 	}
+	cp, err := openIndexedCheckpointer(databasePath, config, lock)
+	if err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return cp, nil
+}
+
+// openIndexedCheckpointer opens the database at databasePath, which must
+// already be locked by lock
+func openIndexedCheckpointer(databasePath string, config Config, lock *os.File) (*IndexedCheckpointer, error) {
 	cCheckpointer, err := cmachine.NewCheckpoint(databasePath)
 	if err != nil {
 		return nil, err
 	}
+	return newIndexedCheckpointerWithStorage(cCheckpointer, cCheckpointer.GetBlockStore(), config, lock), nil
+}
 
+func newIndexedCheckpointerWithStorage(
+	db machine.CheckpointStorage,
+	bs machine.BlockStore,
+	config Config,
+	lock *os.File,
+) *IndexedCheckpointer {
 	return &IndexedCheckpointer{
 		new(sync.Mutex),
-		cCheckpointer,
-		cCheckpointer.GetBlockStore(),
+		db,
+		bs,
 		nil,
-		maxReorgHeight,
-	}, nil
+		new(big.Int).Set(config.MaxReorgHeight),
+		config.cleanupInterval(),
+		lock,
+	}
 }
 
 func (cp *IndexedCheckpointer) Initialize(arbitrumCodeFilePath string) error {
@@ -112,8 +167,20 @@ func (cp *IndexedCheckpointer) MaxReorgHeight() *big.Int {
 	return new(big.Int).Set(cp.maxReorgHeight)
 }
 
-func (cp *IndexedCheckpointer) GetAggregatorStore() *cmachine.AggregatorStore {
-	return cp.db.GetAggregatorStore()
+// aggregatorStorage is implemented by checkpoint storages which also keep an
+// aggregator store, such as the RocksDB backed and in-memory storages
+type aggregatorStorage interface {
+	GetAggregatorStore() machine.AggregatorStore
+}
+
+// GetAggregatorStore returns the aggregator store kept alongside the
+// checkpoints, or nil if the checkpoint storage doesn't keep one
+func (cp *IndexedCheckpointer) GetAggregatorStore() machine.AggregatorStore {
+	storage, ok := cp.db.(aggregatorStorage)
+	if !ok {
+		return nil
+	}
+	return storage.GetAggregatorStore()
 }
 
 // HasCheckpointedState checks whether the block store is empty, which is the table
@@ -216,8 +283,11 @@ func (cp *IndexedCheckpointer) writeDaemon() {
 		cp.nextCheckpointToWrite = nil
 		cp.Unlock()
 		if checkpoint != nil {
+			start := time.Now()
 			err := writeCheckpoint(cp.bs, cp.db, checkpoint)
+			writeTimer.UpdateSince(start)
 			if err != nil {
+				writeErrorCounter.Inc(1)
 				log.Println("Error writing checkpoint: {}", err)
 			}
 			checkpoint.errChan <- err
@@ -248,8 +318,8 @@ func writeCheckpoint(bs machine.BlockStore, db machine.CheckpointStorage, wc *wr
 	return nil
 }
 
-func cleanupDaemon(bs machine.BlockStore, db machine.CheckpointStorage, maxReorgHeight *big.Int) {
-	ticker := time.NewTicker(common.NewTimeBlocksInt(25).Duration())
+func cleanupDaemon(bs machine.BlockStore, db machine.CheckpointStorage, maxReorgHeight *big.Int, interval *common.TimeBlocks) {
+	ticker := time.NewTicker(interval.Duration())
 	defer ticker.Stop()
 	for {
 		<-ticker.C
@@ -28,7 +28,10 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 )
 
 var initialEntryBlockId = &common.BlockId{
@@ -62,6 +65,7 @@ var checkpointData3 = []byte{5, 3, 5}
 
 var dbPath = "./testdb"
 var maxReorgHeight = big.NewInt(100)
+var testConfig = Config{DatabasePath: dbPath, MaxReorgHeight: maxReorgHeight}
 
 type TimeGetterMock struct {
 	blockIdFunc func(ctx context.Context, height *common.TimeBlocks) (*common.BlockId, error)
@@ -85,7 +89,7 @@ func TestMain(m *testing.M) {
 
 func TestEmpty(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -98,7 +102,7 @@ func TestEmpty(t *testing.T) {
 
 func TestWriteCheckpoint(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -158,7 +162,7 @@ func TestWriteCheckpoint(t *testing.T) {
 
 func TestRestoreEmpty(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -173,7 +177,7 @@ func TestRestoreEmpty(t *testing.T) {
 
 func TestRestoreSingleCheckpoint(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,7 +229,7 @@ func TestRestoreSingleCheckpoint(t *testing.T) {
 
 func TestRestoreReorg(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -306,7 +310,7 @@ func TestRestoreReorg(t *testing.T) {
 
 func TestCleanup(t *testing.T) {
 	var rollupAddr common.Address
-	cp, err := newIndexedCheckpointer(rollupAddr, dbPath, maxReorgHeight, true)
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -355,3 +359,83 @@ func TestCleanup(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestDatabaseLocked(t *testing.T) {
+	var rollupAddr common.Address
+	cp, err := newIndexedCheckpointer(rollupAddr, testConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.db.CloseCheckpointStorage()
+	defer cp.lock.Close()
+
+	if _, err := newIndexedCheckpointer(rollupAddr, testConfig, false); err == nil {
+		t.Error("opened database which was already in use")
+	}
+}
+
+func TestInMemoryStorage(t *testing.T) {
+	db := gomachine.NewCheckpointStorage()
+	cp := newIndexedCheckpointerWithStorage(db, db.GetBlockStore(), testConfig, nil)
+	if err := cp.Initialize(arbos.Path()); err != nil {
+		t.Fatal(err)
+	}
+	mach, err := cp.GetInitialMachine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mach.ExecuteAssertion(1000, nil, 0)
+	val := value.NewTuple2(value.NewInt64Value(1), value.NewEmptyTuple())
+
+	checkpointContext := ckptcontext.NewCheckpointContext()
+	checkpointContext.AddMachine(mach)
+	checkpointContext.AddValue(val)
+	if err := writeCheckpoint(cp.bs, cp.db, &writableCheckpoint{
+		blockId:  initialEntryBlockId,
+		contents: checkpointData,
+		ckpCtx:   checkpointContext,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tgm := &TimeGetterMock{
+		func(ctx context.Context, height *common.TimeBlocks) (*common.BlockId, error) {
+			return initialEntryBlockId, nil
+		},
+	}
+	if err := cp.RestoreLatestState(context.Background(), tgm, func(data []byte, restoreContext ckptcontext.RestoreContext, _ *common.BlockId) error {
+		if !bytes.Equal(data, checkpointData) {
+			t.Error("incorrect checkpoint data restored")
+		}
+		restoredMach, err := restoreContext.GetMachine(mach.Hash())
+		if err != nil {
+			return err
+		}
+		if restoredMach.Hash() != mach.Hash() {
+			t.Error("restored machine has wrong hash")
+		}
+		restoredVal, err := restoreContext.GetValue(val.Hash())
+		if err != nil {
+			return err
+		}
+		if !value.Eq(restoredVal, val) {
+			t.Error("restored value is wrong")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := deleteCheckpointForKey(cp.bs, cp.db, initialEntryBlockId); err != nil {
+		t.Fatal(err)
+	}
+	if cp.HasCheckpointedState() {
+		t.Error("checkpoint still stored after delete")
+	}
+	if _, err := db.GetMachine(mach.Hash(), nil); err == nil {
+		t.Error("machine still stored after delete")
+	}
+	if _, err := db.GetValue(val.Hash(), nil); err == nil {
+		t.Error("value still stored after delete")
+	}
+}
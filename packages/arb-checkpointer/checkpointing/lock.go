@@ -0,0 +1,48 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checkpointing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const lockFileName = "ARB_LOCK"
+
+// lockDatabase takes an exclusive lock on the checkpoint database at
+// databasePath, creating its folder if needed. It fails if another
+// checkpointer, in this or another process, holds the lock. The lock is
+// released when the returned file is closed or the process exits
+func lockDatabase(databasePath string) (*os.File, error) {
+	if err := os.MkdirAll(databasePath, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(databasePath, lockFileName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("checkpoint database %v is already in use", databasePath)
+		}
+		return nil, fmt.Errorf("failed to lock checkpoint database %v: %v", databasePath, err)
+	}
+	return f, nil
+}
@@ -3,6 +3,7 @@ module github.com/offchainlabs/arbitrum/packages/arb-checkpointer
 go 1.13
 
 require (
+	github.com/ethereum/go-ethereum v1.9.24
 	github.com/golang/protobuf v1.4.3
 	github.com/offchainlabs/arbitrum/packages/arb-avm-cpp v0.7.3
 	github.com/offchainlabs/arbitrum/packages/arb-util v0.7.3
@@ -178,6 +178,60 @@ func LogStackToLogs(val value.Value) ([]Log, error) {
 	return logs, nil
 }
 
+// decodeLogStack reads a log stack with the same layout that LogStackToLogs
+// parses, copying each log's data straight out of the marshalled stack
+func decodeLogStack(d *value.Decoder) ([]Log, error) {
+	logs := make([]Log, 0)
+	for {
+		size, err := d.DecodeTupleHeader()
+		if err != nil {
+			return nil, errors2.Wrap(err, "log stack was not a stack")
+		}
+		if size == 0 {
+			// The top of the stack holds the first log
+			return logs, nil
+		}
+		if size != 2 {
+			return nil, errors.New("log stack was not a stack")
+		}
+		log, err := decodeLog(d)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+}
+
+func decodeLog(d *value.Decoder) (Log, error) {
+	size, err := d.DecodeTupleHeader()
+	if err != nil {
+		return Log{}, errors.New("log must be a tuple")
+	}
+	if size < 3 {
+		return Log{}, fmt.Errorf("log tuple must be at least size 3, but is size %v", size)
+	}
+	contractIDInt, err := decodeInt(d, "log contract id")
+	if err != nil {
+		return Log{}, err
+	}
+	contractIDBytes := contractIDInt.ToBytes()
+	var address common.Address
+	copy(address[:], contractIDBytes[12:])
+	logData, err := inbox.DecodeByteStack(d)
+	if err != nil {
+		return Log{}, err
+	}
+	topics := make([]common.Hash, 0, size-2)
+	for i := 2; i < size; i++ {
+		topicValInt, err := decodeInt(d, "log topic")
+		if err != nil {
+			return Log{}, err
+		}
+		topics = append(topics, topicValInt.ToBytes())
+	}
+	return Log{address, topics, logData}, nil
+}
+
 func LogsToLogStack(logs []Log) *value.TupleValue {
 	logValues := make([]value.Value, 0, len(logs))
 	for i := range logs {
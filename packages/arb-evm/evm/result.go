@@ -193,6 +193,92 @@ func NewIncomingRequestFromValue(val value.Value) (IncomingRequest, error) {
 	}, nil
 }
 
+// newIncomingRequestFromData reads an incoming request with the same layout
+// that NewIncomingRequestFromValue parses, copying the message data straight
+// out of the marshalled request
+func newIncomingRequestFromData(d *value.Decoder) (IncomingRequest, error) {
+	failRet := IncomingRequest{}
+	size, err := d.DecodeTupleHeader()
+	if err != nil {
+		return failRet, errors.New("val must be a tuple")
+	}
+	if size != 7 {
+		return failRet, fmt.Errorf("expected tuple of length 7, but recieved tuple of length %v", size)
+	}
+
+	kindInt, err := decodeInt(d, "inbox message kind")
+	if err != nil {
+		return failRet, err
+	}
+	blockNumberInt, err := decodeInt(d, "blockNumber")
+	if err != nil {
+		return failRet, err
+	}
+	timestampInt, err := decodeInt(d, "timestamp")
+	if err != nil {
+		return failRet, err
+	}
+	senderInt, err := decodeInt(d, "sender")
+	if err != nil {
+		return failRet, err
+	}
+	messageIDInt, err := decodeInt(d, "inboxSeqNum")
+	if err != nil {
+		return failRet, err
+	}
+	var messageID common.Hash
+	copy(messageID[:], math.U256Bytes(messageIDInt.BigInt()))
+
+	data, err := inbox.DecodeByteStack(d)
+	if err != nil {
+		return failRet, errors2.Wrap(err, "unmarshalling input data")
+	}
+
+	provenanceVal, err := d.Decode()
+	if err != nil {
+		return failRet, err
+	}
+	provenance, err := NewProvenanceFromValue(provenanceVal)
+	if err != nil {
+		return failRet, err
+	}
+
+	return IncomingRequest{
+		Kind:      inbox.Type(kindInt.BigInt().Uint64()),
+		Sender:    inbox.NewAddressFromInt(senderInt),
+		MessageID: messageID,
+		Data:      data,
+		ChainTime: inbox.ChainTime{
+			BlockNum:  common.NewTimeBlocks(blockNumberInt.BigInt()),
+			Timestamp: timestampInt.BigInt(),
+		},
+		Provenance: provenance,
+	}, nil
+}
+
+func decodeInt(d *value.Decoder, name string) (value.IntValue, error) {
+	val, err := d.Decode()
+	if err != nil {
+		return value.IntValue{}, err
+	}
+	intVal, ok := val.(value.IntValue)
+	if !ok {
+		return value.IntValue{}, fmt.Errorf("%v must be an int", name)
+	}
+	return intVal, nil
+}
+
+func decodeTupleSize(d *value.Decoder, size int, name string) error {
+	tupSize, err := d.DecodeTupleHeader()
+	if err != nil {
+		return fmt.Errorf("advise expected %v tuple of length %v: %v", name, size, err)
+	}
+	if tupSize != size {
+		return fmt.Errorf("advise expected %v tuple of length %v, but recieved length %v", name, size, tupSize)
+	}
+	return nil
+}
+
 func NewRandomIncomingRequest() IncomingRequest {
 	return IncomingRequest{
 		Kind:      inbox.Type(rand.Uint32()),
@@ -310,8 +396,9 @@ func (r *TxResult) ToEthReceipt(blockHash common.Hash) *types.Receipt {
 	}
 
 	evmLogs := r.EthLogs(blockHash)
+	// PostState is left empty so that the receipt is encoded with its status
+	// and receipts roots computed by clients match the block header
 	return &types.Receipt{
-		PostState:         []byte{0},
 		Status:            status,
 		CumulativeGasUsed: r.CumulativeGas.Uint64(),
 		Bloom:             types.BytesToBloom(types.LogsBloom(evmLogs)),
@@ -405,6 +492,71 @@ func parseTxResult(l1MsgVal value.Value, resultInfo value.Value, gasInfo value.V
 	}, nil
 }
 
+// parseTxResultData reads the fields of a transaction result that follow its
+// kind, with the same layout that parseTxResult parses
+func parseTxResultData(d *value.Decoder) (*TxResult, error) {
+	l1Msg, err := newIncomingRequestFromData(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decodeTupleSize(d, 3, "result info"); err != nil {
+		return nil, err
+	}
+	resultCodeInt, err := decodeInt(d, "resultCode")
+	if err != nil {
+		return nil, err
+	}
+	returnBytes, err := inbox.DecodeByteStack(d)
+	if err != nil {
+		return nil, errors2.Wrap(err, "umarshalling return data")
+	}
+	logs, err := decodeLogStack(d)
+	if err != nil {
+		return nil, errors2.Wrap(err, "unmarshaling logs")
+	}
+
+	if err := decodeTupleSize(d, 2, "gas info"); err != nil {
+		return nil, err
+	}
+	gasUsedInt, err := decodeInt(d, "gasUsed")
+	if err != nil {
+		return nil, err
+	}
+	gasPriceInt, err := decodeInt(d, "gasPrice")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decodeTupleSize(d, 3, "tx block data"); err != nil {
+		return nil, err
+	}
+	cumulativeGasInt, err := decodeInt(d, "cumulativeGas")
+	if err != nil {
+		return nil, err
+	}
+	txIndexInt, err := decodeInt(d, "txIndex")
+	if err != nil {
+		return nil, err
+	}
+	startLogIndexInt, err := decodeInt(d, "startLogIndex")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxResult{
+		IncomingRequest: l1Msg,
+		ResultCode:      ResultType(resultCodeInt.BigInt().Uint64()),
+		ReturnData:      returnBytes,
+		EVMLogs:         logs,
+		GasUsed:         gasUsedInt.BigInt(),
+		GasPrice:        gasPriceInt.BigInt(),
+		CumulativeGas:   cumulativeGasInt.BigInt(),
+		TxIndex:         txIndexInt.BigInt(),
+		StartLogIndex:   startLogIndexInt.BigInt(),
+	}, nil
+}
+
 type OutputStatistics struct {
 	GasUsed      *big.Int
 	TxCount      *big.Int
@@ -578,6 +730,44 @@ func NewResultFromValue(val value.Value) (Result, error) {
 	}
 }
 
+// NewResultFromData reads the next result from d. It accepts the marshalled
+// form of the values that NewResultFromValue parses, but copies the byte
+// stacks in transaction results straight out of the data instead of
+// unmarshalling them into values first. If it fails, d is left partway
+// through the result
+func NewResultFromData(d *value.Decoder) (Result, error) {
+	size, err := d.DecodeTupleHeader()
+	if err != nil || size == 0 {
+		return nil, errors.New("expected result to be nonempty tuple")
+	}
+	kindInt, err := decodeInt(d, "result kind")
+	if err != nil {
+		return nil, err
+	}
+
+	if kindInt.BigInt().Uint64() == 0 {
+		if size != 5 {
+			return nil, fmt.Errorf("tx result expected tuple of length 5, but recieved len %v", size)
+		}
+		return parseTxResultData(d)
+	} else if kindInt.BigInt().Uint64() == 1 {
+		if size != 6 {
+			return nil, fmt.Errorf("tx result expected tuple of length 6, but recieved len %v", size)
+		}
+		vals := make([]value.Value, 0, 5)
+		for i := 0; i < 5; i++ {
+			val, err := d.Decode()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+		return parseBlockResult(vals[0], vals[1], vals[2], vals[3], vals[4])
+	} else {
+		return nil, errors.New("unknown result kind")
+	}
+}
+
 func NewTxResultFromValue(val value.Value) (*TxResult, error) {
 	res, err := NewResultFromValue(val)
 	if err != nil {
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package evm
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func TestReceiptConsensusEncoding(t *testing.T) {
+	rand.Seed(43242)
+	res := &TxResult{
+		IncomingRequest: IncomingRequest{
+			Kind:      inbox.Type(0),
+			MessageID: common.RandHash(),
+			ChainTime: inbox.ChainTime{
+				BlockNum:  common.NewTimeBlocks(big.NewInt(10)),
+				Timestamp: big.NewInt(1000),
+			},
+		},
+		ResultCode:    ReturnCode,
+		EVMLogs:       []Log{NewRandomLog(2), NewRandomLog(3)},
+		GasUsed:       big.NewInt(21000),
+		GasPrice:      big.NewInt(0),
+		CumulativeGas: big.NewInt(50000),
+		TxIndex:       big.NewInt(1),
+		StartLogIndex: big.NewInt(4),
+	}
+	receipt := res.ToEthReceipt(common.RandHash())
+
+	// A client rebuilding the receipt from the RPC response only knows its
+	// status, cumulative gas and logs
+	expected := types.NewReceipt(nil, false, res.CumulativeGas.Uint64())
+	expected.Logs = receipt.Logs
+	expected.Bloom = types.CreateBloom(types.Receipts{expected})
+
+	encoded, err := rlp.EncodeToBytes(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEncoded, err := rlp.EncodeToBytes(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, expectedEncoded) {
+		t.Error("receipt consensus encoding doesn't match")
+	}
+}
+
+func TestReceiptFields(t *testing.T) {
+	rand.Seed(5421)
+	create := message.Transaction{
+		MaxGas:      big.NewInt(100000),
+		GasPriceBid: big.NewInt(0),
+		SequenceNum: big.NewInt(0),
+		Payment:     big.NewInt(0),
+		Data:        []byte{1},
+	}
+	contract := common.RandAddress()
+	res := NewRandomResult(2)
+	res.IncomingRequest.Kind = message.L2Type
+	res.IncomingRequest.Data = message.NewSafeL2Message(create).Data
+	res.ReturnData = append(make([]byte, 12), contract[:]...)
+	res.StartLogIndex = big.NewInt(3)
+	blockHash := common.RandHash()
+
+	receipt := res.ToEthReceipt(blockHash)
+	if receipt.Status != types.ReceiptStatusSuccessful || len(receipt.PostState) != 0 {
+		t.Error("receipt should be successful with no post state")
+	}
+	if receipt.CumulativeGasUsed != res.CumulativeGas.Uint64() || receipt.GasUsed != res.GasUsed.Uint64() {
+		t.Error("wrong gas used")
+	}
+	if receipt.TxHash != res.IncomingRequest.MessageID.ToEthHash() {
+		t.Error("wrong tx hash")
+	}
+	if receipt.ContractAddress != contract.ToEthAddress() {
+		t.Error("wrong contract address")
+	}
+	if receipt.BlockHash != blockHash.ToEthHash() ||
+		receipt.BlockNumber.Cmp(res.IncomingRequest.ChainTime.BlockNum.AsInt()) != 0 ||
+		receipt.TransactionIndex != uint(res.TxIndex.Uint64()) {
+		t.Error("wrong receipt location")
+	}
+	if len(receipt.Logs) != 2 || receipt.Logs[0].Index != 3 || receipt.Logs[1].Index != 4 {
+		t.Fatal("wrong logs")
+	}
+	for _, l := range receipt.Logs {
+		if l.BlockHash != blockHash.ToEthHash() || !receipt.Bloom.Test(l.Address.Bytes()) {
+			t.Error("log missing from receipt")
+		}
+	}
+
+	res.ResultCode = RevertCode
+	receipt = res.ToEthReceipt(blockHash)
+	if receipt.Status != types.ReceiptStatusFailed || receipt.ContractAddress != (ethcommon.Address{}) {
+		t.Error("failed receipt should have no contract address")
+	}
+}
+
+func randomOutputStatistics() *OutputStatistics {
+	return &OutputStatistics{
+		GasUsed:      common.RandBigInt(),
+		TxCount:      common.RandBigInt(),
+		EVMLogCount:  common.RandBigInt(),
+		AVMLogCount:  common.RandBigInt(),
+		AVMSendCount: common.RandBigInt(),
+	}
+}
+
+func randomTxResult(logCount int32) *TxResult {
+	res := NewRandomResult(logCount)
+	res.IncomingRequest.Provenance = Provenance{
+		L1SeqNum:        common.RandBigInt(),
+		ParentRequestId: common.RandHash(),
+		IndexInParent:   common.RandBigInt(),
+	}
+	return res
+}
+
+func marshalResults(t testing.TB, results []Result) []byte {
+	var buf bytes.Buffer
+	for _, res := range results {
+		if err := value.MarshalValue(res.AsValue(), &buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestResultFromData(t *testing.T) {
+	rand.Seed(8732)
+	results := []Result{
+		randomTxResult(0),
+		randomTxResult(3),
+		&BlockInfo{
+			BlockNum:   common.RandBigInt(),
+			Timestamp:  common.RandBigInt(),
+			GasLimit:   common.RandBigInt(),
+			BlockStats: randomOutputStatistics(),
+			ChainStats: randomOutputStatistics(),
+		},
+	}
+	data := marshalResults(t, results)
+	d := value.NewDecoder(data)
+	for _, res := range results {
+		valRes, err := NewResultFromValue(res.AsValue())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dataRes, err := NewResultFromData(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !value.Eq(valRes.AsValue(), dataRes.AsValue()) {
+			t.Error("result from data doesn't match result from value")
+		}
+		if txRes, ok := dataRes.(*TxResult); ok && len(txRes.EVMLogs) > 0 {
+			if !bytes.Equal(txRes.EVMLogs[0].Data, res.(*TxResult).EVMLogs[0].Data) {
+				t.Error("wrong log order")
+			}
+		}
+	}
+	if d.More() {
+		t.Error("results not fully consumed")
+	}
+
+	txData := marshalResults(t, results[1:2])
+	if _, err := NewResultFromData(value.NewDecoder(txData[:len(txData)-1])); err == nil {
+		t.Error("parsed truncated result")
+	}
+	if _, err := NewResultFromData(value.NewDecoder(marshalResults(t, results[2:]))); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkNewResultFromValue(b *testing.B) {
+	res := randomTxResult(5)
+	res.ReturnData = common.RandBytes(10000)
+	data := marshalResults(b, []Result{res})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val, err := value.NewDecoder(data).Decode()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := NewResultFromValue(val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewResultFromData(b *testing.B) {
+	res := randomTxResult(5)
+	res.ReturnData = common.RandBytes(10000)
+	data := marshalResults(b, []Result{res})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewResultFromData(value.NewDecoder(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -35,6 +35,7 @@ import (
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	errors2 "github.com/pkg/errors"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/batcher"
@@ -42,6 +43,7 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
 )
 
 type Server struct {
@@ -128,6 +130,35 @@ func (m *Server) GetRequestResult(requestId common.Hash) (value.Value, error) {
 	return m.db.GetRequest(requestId)
 }
 
+// GetTxHashResult returns the value output by the VM in response to the
+// request containing the Ethereum transaction with the given hash
+func (m *Server) GetTxHashResult(txHash common.Hash) (value.Value, error) {
+	return m.db.GetRequestByTxHash(txHash)
+}
+
+// GetMessageProof proves that the output message at index was included in an
+// assertion made on L1
+func (m *Server) GetMessageProof(index uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	return m.db.GetAssertedMessageProof(index)
+}
+
+// GetLogProof proves that the log at index was included in an assertion made
+// on L1
+func (m *Server) GetLogProof(index uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	return m.db.GetAssertedLogProof(index)
+}
+
+// GetRequestLogIndex returns the index of the log containing the result of
+// the request with the given Ethereum transaction hash or request id
+func (m *Server) GetRequestLogIndex(hash common.Hash) (*uint64, error) {
+	return m.db.GetRequestLogIndex(hash)
+}
+
+// GetWithdrawals returns the withdrawals sent or received by address
+func (m *Server) GetWithdrawals(address common.Address) ([]*txdb.Withdrawal, error) {
+	return m.db.GetWithdrawals(address)
+}
+
 // GetVMInfo returns current metadata about this VM
 func (m *Server) GetChainAddress() ethcommon.Address {
 	return m.chain.ToEthAddress()
@@ -189,6 +220,76 @@ func (m *Server) GetSnapshot(blockHeight uint64) (*snapshot.Snapshot, error) {
 	}), nil
 }
 
+// TraceRequest replays the request with the given request id or Ethereum
+// transaction hash starting from the closest cached snapshot before its block.
+// Requests earlier in the same block are applied first so that the replay sees
+// the same state as the original
+func (m *Server) TraceRequest(hash common.Hash) (*evm.TxResult, *snapshot.TraceResult, error) {
+	val, err := m.GetRequestResult(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if val == nil {
+		val, err = m.GetTxHashResult(hash)
+		if err != nil || val == nil {
+			return nil, nil, err
+		}
+	}
+	res, err := evm.NewTxResultFromValue(val)
+	if err != nil {
+		return nil, nil, err
+	}
+	requestId := res.IncomingRequest.MessageID
+
+	blockHeight := res.IncomingRequest.ChainTime.BlockNum.AsInt().Uint64()
+	if blockHeight == 0 {
+		return nil, nil, errors.New("can't trace request in the first block since no earlier state is available")
+	}
+	info, err := m.BlockInfoByNumber(blockHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info == nil {
+		return nil, nil, fmt.Errorf("block %v containing request not found", blockHeight)
+	}
+	snap, err := m.GetSnapshot(blockHeight - 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if snap == nil {
+		return nil, nil, fmt.Errorf("no snapshot available before block %v", blockHeight)
+	}
+	snap = snap.Clone()
+	snap.AdvanceTime(res.IncomingRequest.ChainTime)
+
+	blockResults, err := m.GetMachineBlockResults(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, prev := range blockResults {
+		if prev.IncomingRequest.MessageID == requestId {
+			break
+		}
+		msg, err := message.NestedMessage(prev.IncomingRequest.Data, prev.IncomingRequest.Kind)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := snap.ApplyMessage(msg, prev.IncomingRequest.Sender); err != nil {
+			return nil, nil, errors2.Wrapf(err, "failed to replay request %v", prev.IncomingRequest.MessageID)
+		}
+	}
+
+	msg, err := message.NestedMessage(res.IncomingRequest.Data, res.IncomingRequest.Kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	trace, err := snap.TraceMessage(msg, res.IncomingRequest.Sender)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, trace, nil
+}
+
 func (m *Server) LatestSnapshot() *snapshot.Snapshot {
 	return m.db.LatestSnapshot()
 }
@@ -201,6 +302,59 @@ func (m *Server) PendingSnapshot() *snapshot.Snapshot {
 	return pending
 }
 
+// PendingBlock returns a header for the block after the latest one along with
+// the transactions that the batcher has applied to the pending snapshot which
+// haven't been included in a block yet. It returns a nil header if the batcher
+// isn't tracking pending state
+func (m *Server) PendingBlock() (*types.Header, []*types.Transaction, error) {
+	txes := m.batch.PendingTransactions()
+	if txes == nil {
+		return nil, nil, nil
+	}
+	latest, err := m.BlockInfoByNumber(m.GetBlockCount())
+	if err != nil {
+		return nil, nil, err
+	}
+	if latest == nil {
+		return nil, nil, errors.New("latest block not found")
+	}
+
+	pendingTxes := make([]*types.Transaction, 0, len(txes))
+	for _, tx := range txes {
+		res, err := m.db.GetRequestByTxHash(common.NewHashFromEth(tx.Hash()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if res != nil {
+			// Already included in a block
+			continue
+		}
+		pendingTxes = append(pendingTxes, tx)
+	}
+
+	header := &types.Header{
+		ParentHash: latest.Header.Hash(),
+		Difficulty: big.NewInt(0),
+		Number:     new(big.Int).Add(latest.Header.Number, big.NewInt(1)),
+		GasLimit:   latest.Header.GasLimit,
+		Time:       latest.Header.Time,
+	}
+	return header, pendingTxes, nil
+}
+
+// TxPoolContent returns the transactions queued in the batcher grouped by
+// sender, split into those which are executable and those waiting on a nonce
+// gap to be filled
+func (m *Server) TxPoolContent() (map[ethcommon.Address][]*types.Transaction, map[ethcommon.Address][]*types.Transaction) {
+	return m.batch.PoolContent()
+}
+
+// InFlightBatches returns the batches the aggregator has submitted to L1
+// which haven't been confirmed yet
+func (m *Server) InFlightBatches() []batcher.InFlightBatch {
+	return m.batch.InFlightBatches()
+}
+
 func (m *Server) PendingTransactionCount(ctx context.Context, account common.Address) *uint64 {
 	return m.batch.PendingTransactionCount(ctx, account)
 }
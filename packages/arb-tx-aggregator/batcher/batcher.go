@@ -19,6 +19,7 @@ package batcher
 import (
 	"container/list"
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -34,12 +35,22 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/txdb"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
-	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
 )
 
 const maxBatchSize ethcommon.StorageSize = 120000
 
+// poolMaintenanceInterval is how often stale and already consumed
+// transactions are removed from the queue
+const poolMaintenanceInterval = time.Minute
+
+// minSendBackoff and maxSendBackoff bound how long the batcher waits before
+// trying to submit a batch again after submitting it failed
+const (
+	minSendBackoff = time.Second
+	maxSendBackoff = time.Minute
+)
+
 type txResponse int
 
 const (
@@ -56,8 +67,12 @@ type batch interface {
 	getAppliedTxes() []*types.Transaction
 	addIncludedTx(tx *types.Transaction) error
 	updateCurrentSnap(pendingSentBatches *list.List)
+	resetSnap(pendingSentBatches *list.List)
 	checkValidForQueue(tx *types.Transaction) error
 	getLatestSnap() *snapshot.Snapshot
+	// resetTxCounts forgets the next nonce cached for each account so that
+	// transactions which are queued again can be accepted
+	resetTxCounts()
 }
 
 type TransactionBatcher interface {
@@ -70,11 +85,17 @@ type TransactionBatcher interface {
 
 	// Return nil if no pending snapshot is available
 	PendingSnapshot() *snapshot.Snapshot
-}
 
-type pendingSentBatch struct {
-	txHash common.Hash
-	txes   []*types.Transaction
+	// Return the transactions included in the pending snapshot or nil if no
+	// pending snapshot is available
+	PendingTransactions() []*types.Transaction
+
+	// Return the transactions waiting to be added to a batch, split into
+	// those which are executable and those waiting on a nonce gap
+	PoolContent() (pending, queued map[ethcommon.Address][]*types.Transaction)
+
+	// Return the batches which have been submitted to L1 but not confirmed
+	InFlightBatches() []InFlightBatch
 }
 
 type Batcher struct {
@@ -83,9 +104,23 @@ type Batcher struct {
 	sync.Mutex
 
 	queuedTxes         *txQueues
+	popTx              txPopper
 	pendingBatch       batch
 	pendingSentBatches *list.List
 	newTxFeed          event.Feed
+
+	// abandonedBatches holds sent batches that were given up on while their
+	// L1 transactions could still be included. Their transactions are queued
+	// again once it is clear that they weren't included
+	abandonedBatches *list.List
+
+	// snapshots provides the address table used to compress batches. If
+	// nil, batches aren't compressed
+	snapshots snapshotSource
+
+	// statePath is the file the batcher's state is saved to. If empty, the
+	// state isn't saved
+	statePath string
 }
 
 func NewStatefulBatcher(
@@ -95,7 +130,9 @@ func NewStatefulBatcher(
 	receiptFetcher ethutils.ReceiptFetcher,
 	globalInbox arbbridge.GlobalInbox,
 	maxBatchTime time.Duration,
-) *Batcher {
+	ordering OrderingPolicy,
+	statePath string,
+) (*Batcher, error) {
 	signer := types.NewEIP155Signer(message.ChainAddressToID(rollupAddress))
 	return newBatcher(
 		ctx,
@@ -103,23 +140,39 @@ func NewStatefulBatcher(
 		receiptFetcher,
 		globalInbox,
 		maxBatchTime,
+		ordering,
+		statePath,
+		db,
 		newStatefulBatch(db, maxBatchSize, signer),
 	)
 }
 
+// NewStatelessBatcher creates a batcher which doesn't check transactions
+// against the chain's state. If db is set, its latest snapshot is used to
+// compress batches
 func NewStatelessBatcher(
 	ctx context.Context,
+	db *txdb.TxDB,
 	rollupAddress common.Address,
 	receiptFetcher ethutils.ReceiptFetcher,
 	globalInbox arbbridge.GlobalInboxSender,
 	maxBatchTime time.Duration,
-) *Batcher {
+	ordering OrderingPolicy,
+	statePath string,
+) (*Batcher, error) {
+	var snapshots snapshotSource
+	if db != nil {
+		snapshots = db
+	}
 	return newBatcher(
 		ctx,
 		rollupAddress,
 		receiptFetcher,
 		globalInbox,
 		maxBatchTime,
+		ordering,
+		statePath,
+		snapshots,
 		newStatelessBatch(maxBatchSize),
 	)
 }
@@ -130,28 +183,51 @@ func newBatcher(
 	receiptFetcher ethutils.ReceiptFetcher,
 	globalInbox arbbridge.GlobalInboxSender,
 	maxBatchTime time.Duration,
+	ordering OrderingPolicy,
+	statePath string,
+	snapshots snapshotSource,
 	pendingBatch batch,
-) *Batcher {
+) (*Batcher, error) {
 	server := &Batcher{
 		signer:             types.NewEIP155Signer(message.ChainAddressToID(rollupAddress)),
 		queuedTxes:         newTxQueues(),
+		popTx:              ordering.popper(),
 		pendingBatch:       pendingBatch,
 		pendingSentBatches: list.New(),
+		abandonedBatches:   list.New(),
+		statePath:          statePath,
+		snapshots:          snapshots,
+	}
+
+	if err := server.restoreState(); err != nil {
+		return nil, err
 	}
 
 	go func() {
 		lastBatch := time.Now()
+		// After a batch fails to submit, it isn't tried again until retryAt
+		var retryAt time.Time
+		var backoff time.Duration
 		ticker := time.NewTicker(time.Millisecond * 500)
 		defer ticker.Stop()
+		maintenanceTicker := time.NewTicker(poolMaintenanceInterval)
+		defer maintenanceTicker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 
+			case <-maintenanceTicker.C:
+				server.Lock()
+				server.maintainPool()
+				server.updateQueueMetrics()
+				server.Unlock()
+
 			case <-ticker.C:
 				server.Lock()
+				appliedCount := len(server.pendingBatch.getAppliedTxes())
 				for {
-					tx, accountIndex, cont := popRandomTx(server.pendingBatch, server.queuedTxes)
+					tx, accountIndex, cont := server.popTx(server.pendingBatch, server.queuedTxes)
 					if tx != nil {
 						err := server.pendingBatch.addIncludedTx(tx)
 						server.queuedTxes.maybeRemoveAccountAtIndex(accountIndex)
@@ -161,12 +237,27 @@ func newBatcher(
 						}
 					}
 					if server.pendingBatch.isFull() || (!cont && time.Since(lastBatch) > maxBatchTime) {
-						lastBatch = time.Now()
-						server.sendBatch(ctx, globalInbox)
+						if time.Now().Before(retryAt) {
+							// A full batch accepts no more transactions, so
+							// stop until the next tick
+							cont = false
+						} else if err := server.sendBatch(ctx, globalInbox); err != nil {
+							backoff = nextSendBackoff(backoff)
+							retryAt = time.Now().Add(backoff)
+							log.Error().Err(err).Dur("backoff", backoff).Msg("failed to submit batch")
+							cont = false
+						} else {
+							lastBatch = time.Now()
+							backoff = 0
+						}
 					}
 
 					if !cont {
 						// If we didn't fill the last batch, pause for more transactions
+						if len(server.pendingBatch.getAppliedTxes()) != appliedCount {
+							server.saveState()
+						}
+						server.updateQueueMetrics()
 						server.Unlock()
 						break
 					}
@@ -186,17 +277,37 @@ func newBatcher(
 
 			case <-ticker.C:
 				server.Lock()
+				server.checkAbandonedBatches(ctx, receiptFetcher, globalInbox)
 				// Note: this loop is the only place where items can be removed
 				// from pendingSentBatches, so pendingSentBatches.Front() is
 				// guaranteed not to change when the server lock is released
 				for server.pendingSentBatches.Len() > 0 {
 					batch := server.pendingSentBatches.Front().Value.(*pendingSentBatch)
-					txHash := batch.txHash.ToEthHash()
+					txHashes := append([]common.Hash{}, batch.txHashes...)
 					server.Unlock()
-					receipt, err := ethbridge.WaitForReceiptWithResultsSimple(ctx, receiptFetcher, txHash)
-					if err != nil || receipt.Status != 1 {
-						// batch failed
-						log.Fatal().Err(err).Msg("Error submitted batch")
+					receipt, err := waitForBatchReceipt(ctx, receiptFetcher, txHashes, batchReceiptTimeout)
+					server.Lock()
+					if err == errReceiptTimeout {
+						server.resendBatch(ctx, globalInbox, batch)
+						continue
+					}
+					if err != nil {
+						// Leave the batch in place and try again later
+						log.Warn().Err(err).Msg("failed to fetch batch receipt")
+						break
+					}
+
+					batchLatencyTimer.UpdateSince(batch.sentAt)
+					batchGasHistogram.Update(int64(receipt.GasUsed))
+
+					if receipt.Status != 1 {
+						batchFailedCounter.Inc(1)
+						log.Error().
+							Hex("txhash", receipt.TxHash.Bytes()).
+							Int("txcount", len(batch.txes)).
+							Msg("batch submission failed, requeueing transactions")
+						server.requeueFrontBatch()
+						continue
 					}
 
 					receiptJSON, err := receipt.MarshalJSON()
@@ -207,45 +318,263 @@ func newBatcher(
 					}
 
 					// batch succeeded
-					server.Lock()
 					server.pendingSentBatches.Remove(server.pendingSentBatches.Front())
+					server.saveState()
 				}
 				server.Unlock()
 			}
 		}
 	}()
-	return server
+	return server, nil
+}
+
+// addressTable returns the address table used to compress batches, or nil if
+// none is available. Addresses are only looked up in the state of the local
+// chain since entries added by transactions that haven't been included yet
+// may not exist when the batch is executed
+func (m *Batcher) addressTable() addressTable {
+	if m.snapshots == nil {
+		return nil
+	}
+	snap := m.snapshots.LatestSnapshot()
+	if snap == nil {
+		return nil
+	}
+	return snap
 }
 
-func (m *Batcher) sendBatch(ctx context.Context, inbox arbbridge.GlobalInboxSender) {
+// nextSendBackoff returns how long to wait before submitting a batch again
+// after it failed, given the wait before the previous attempt
+func nextSendBackoff(backoff time.Duration) time.Duration {
+	if backoff < minSendBackoff {
+		return minSendBackoff
+	}
+	backoff *= 2
+	if backoff > maxSendBackoff {
+		return maxSendBackoff
+	}
+	return backoff
+}
+
+// sendBatch must be called with the batcher locked. The lock is released while
+// the transactions are compressed since that requires calls into ArbOS. If
+// submitting the batch fails, it is kept as the pending batch and the error is
+// returned so that the caller can wait before trying again
+func (m *Batcher) sendBatch(ctx context.Context, inbox arbbridge.GlobalInboxSender) error {
 	txes := m.pendingBatch.getAppliedTxes()
 	if len(txes) == 0 {
-		return
+		return nil
 	}
-	batchTxes := make([]message.AbstractL2Message, 0, len(txes))
-	for _, tx := range txes {
-		batchTxes = append(batchTxes, message.NewCompressedECDSAFromEth(tx))
+	pendingBatch := m.pendingBatch
+	m.Unlock()
+	batchTxes, calldataSaved := compressTxes(m.addressTable(), txes)
+	m.Lock()
+	if m.pendingBatch != pendingBatch {
+		// The batch's transactions were requeued while it was being
+		// compressed
+		return nil
 	}
 	batchTx, err := message.NewTransactionBatchFromMessages(batchTxes)
 	if err != nil {
 		log.Fatal().Err(err).Msg("transaction aggregator failed")
 	}
-	log.Info().Int("txcount", len(batchTxes)).Msg("Submitting batch")
-	txHash, err := inbox.SendL2MessageNoWait(
-		ctx,
-		message.NewSafeL2Message(batchTx).AsData(),
-	)
-
+	log.Info().
+		Int("txcount", len(batchTxes)).
+		Int("calldatasaved", calldataSaved).
+		Msg("Submitting batch")
+	data := message.NewSafeL2Message(batchTx).AsData()
+	txHash, err := inbox.SendL2MessageNoWait(ctx, data)
 	if err != nil {
-		log.Fatal().Err(err).Msg("transaction aggregator failed")
-		return
+		// Keep the pending batch so that sending it is tried again
+		return err
 	}
 
+	batchTxesHistogram.Update(int64(len(txes)))
+	batchBytesHistogram.Update(int64(len(data)))
+	batchSavedCounter.Inc(int64(calldataSaved))
+
 	m.pendingBatch = m.pendingBatch.newFromExisting()
 	m.pendingSentBatches.PushBack(&pendingSentBatch{
-		txHash: txHash,
-		txes:   txes,
+		txHashes:      []common.Hash{txHash},
+		data:          data,
+		txes:          txes,
+		sentAt:        time.Now(),
+		calldataSaved: calldataSaved,
 	})
+	m.saveState()
+	return nil
+}
+
+// resendBatch replaces the L1 transaction of a batch which hasn't been
+// included in time with one paying a higher gas price. Once a batch has been
+// resent too many times, it and every later sent batch are abandoned
+func (m *Batcher) resendBatch(ctx context.Context, inbox arbbridge.GlobalInboxSender, batch *pendingSentBatch) {
+	if len(batch.txHashes) > maxBatchResends {
+		log.Error().
+			Int("txcount", len(batch.txes)).
+			Msg("batch wasn't included after resending, abandoning it")
+		m.abandonSentBatches(nil)
+		return
+	}
+	txHash, err := inbox.ResendL2MessageNoWait(ctx, batch.data, batch.latestTxHash())
+	if err != nil {
+		// One of the sent transactions may have been included in the meantime
+		// so keep waiting for a receipt
+		log.Warn().Err(err).Msg("failed to resend batch")
+		return
+	}
+	log.Info().
+		Hex("oldtxhash", batch.latestTxHash().Bytes()).
+		Hex("txhash", txHash.Bytes()).
+		Msg("resent batch with higher gas price")
+	batch.txHashes = append(batch.txHashes, txHash)
+	m.saveState()
+}
+
+// requeueFrontBatch removes the oldest sent batch after its L1 transaction
+// reverted and queues its transactions to be included again. Every later sent
+// batch may depend on the failed transactions, so they are abandoned
+func (m *Batcher) requeueFrontBatch() {
+	batch := m.pendingSentBatches.Remove(m.pendingSentBatches.Front()).(*pendingSentBatch)
+	m.abandonSentBatches(batch.txes)
+}
+
+// abandonSentBatches stops waiting on every sent batch. Their L1 transactions
+// may still be included, so they are watched by checkAbandonedBatches and
+// their transactions are only queued again once they were dropped. The
+// pending batch was built on top of the abandoned batches, so it is discarded
+// and its transactions are queued again along with requeued
+func (m *Batcher) abandonSentBatches(requeued []*types.Transaction) {
+	for m.pendingSentBatches.Len() > 0 {
+		m.abandonedBatches.PushBack(m.pendingSentBatches.Remove(m.pendingSentBatches.Front()))
+	}
+	txes := append(requeued, m.pendingBatch.getAppliedTxes()...)
+	m.pendingBatch = m.pendingBatch.newFromExisting()
+	m.pendingBatch.resetSnap(m.pendingSentBatches)
+	m.pendingBatch.resetTxCounts()
+	m.requeueTxes(txes)
+	m.saveState()
+}
+
+// checkAbandonedBatches must be called with the batcher locked. The lock is
+// released while each abandoned batch is checked. Batches whose L1
+// transactions were included are forgotten, and the transactions of batches
+// whose L1 transactions were dropped are queued again. If any of them were
+// also included by a later batch, they are removed from the queue once their
+// nonces have been used
+func (m *Batcher) checkAbandonedBatches(
+	ctx context.Context,
+	receiptFetcher ethutils.ReceiptFetcher,
+	inbox arbbridge.GlobalInboxSender,
+) {
+	// Note: this is the only place where items can be removed from
+	// abandonedBatches, so the list is guaranteed not to change other than
+	// by being appended to while the lock is released
+	for n := m.abandonedBatches.Front(); n != nil; {
+		batch := n.Value.(*pendingSentBatch)
+		txHashes := append([]common.Hash{}, batch.txHashes...)
+		nonce := batch.nonce
+		m.Unlock()
+		status, nonce, err := checkAbandonedBatch(ctx, receiptFetcher, inbox, txHashes, nonce)
+		m.Lock()
+		next := n.Next()
+		if batch.nonce == nil && nonce != nil {
+			batch.nonce = nonce
+			m.saveState()
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to check abandoned batch")
+		}
+		switch status {
+		case batchIncluded:
+			log.Info().
+				Int("txcount", len(batch.txes)).
+				Msg("abandoned batch was included")
+			m.abandonedBatches.Remove(n)
+			m.saveState()
+		case batchDropped:
+			log.Info().
+				Int("txcount", len(batch.txes)).
+				Msg("abandoned batch was dropped, requeueing transactions")
+			m.abandonedBatches.Remove(n)
+			m.requeueTxes(batch.txes)
+			m.saveState()
+		}
+		n = next
+	}
+}
+
+func (m *Batcher) requeueTxes(txes []*types.Transaction) {
+	for _, tx := range txes {
+		sender, err := types.Sender(m.signer, tx)
+		if err != nil {
+			continue
+		}
+		if err := m.queuedTxes.addTransaction(tx, sender); err != nil {
+			log.Warn().Err(err).Hex("txhash", tx.Hash().Bytes()).Msg("failed to requeue transaction")
+		}
+	}
+}
+
+// saveState must be called with the batcher locked
+func (m *Batcher) saveState() {
+	if m.statePath == "" {
+		return
+	}
+	state := &batcherState{
+		SentBatches: make([]savedSentBatch, 0, m.pendingSentBatches.Len()),
+		PendingTxes: m.pendingBatch.getAppliedTxes(),
+	}
+	for n := m.pendingSentBatches.Front(); n != nil; n = n.Next() {
+		state.SentBatches = append(state.SentBatches, n.Value.(*pendingSentBatch).saved())
+	}
+	for n := m.abandonedBatches.Front(); n != nil; n = n.Next() {
+		state.AbandonedBatches = append(state.AbandonedBatches, n.Value.(*pendingSentBatch).saved())
+	}
+	if err := state.save(m.statePath); err != nil {
+		log.Error().Err(err).Msg("failed to save batcher state")
+	}
+}
+
+// restoreState loads the batches that were in flight when the batcher was
+// last stopped and queues the transactions of the batch that wasn't sent yet
+func (m *Batcher) restoreState() error {
+	if m.statePath == "" {
+		return nil
+	}
+	state, err := loadBatcherState(m.statePath)
+	if err != nil {
+		return err
+	}
+	for _, batch := range state.SentBatches {
+		m.pendingSentBatches.PushBack(batch.restore())
+	}
+	for _, batch := range state.AbandonedBatches {
+		m.abandonedBatches.PushBack(batch.restore())
+	}
+	m.pendingBatch.resetSnap(m.pendingSentBatches)
+	m.requeueTxes(state.PendingTxes)
+	if len(state.SentBatches) > 0 || len(state.AbandonedBatches) > 0 || len(state.PendingTxes) > 0 {
+		log.Info().
+			Int("batches", len(state.SentBatches)).
+			Int("abandoned", len(state.AbandonedBatches)).
+			Int("txcount", len(state.PendingTxes)).
+			Msg("restored batcher state")
+	}
+	return nil
+}
+
+func (m *Batcher) InFlightBatches() []InFlightBatch {
+	m.Lock()
+	defer m.Unlock()
+	batches := make([]InFlightBatch, 0, m.abandonedBatches.Len()+m.pendingSentBatches.Len())
+	for n := m.abandonedBatches.Front(); n != nil; n = n.Next() {
+		batches = append(batches, n.Value.(*pendingSentBatch).inFlight(true))
+	}
+	for n := m.pendingSentBatches.Front(); n != nil; n = n.Next() {
+		batches = append(batches, n.Value.(*pendingSentBatch).inFlight(false))
+	}
+	return batches
 }
 
 func (m *Batcher) PendingSnapshot() *snapshot.Snapshot {
@@ -255,11 +584,59 @@ func (m *Batcher) PendingSnapshot() *snapshot.Snapshot {
 	return m.pendingBatch.getLatestSnap()
 }
 
+func (m *Batcher) PendingTransactions() []*types.Transaction {
+	m.Lock()
+	defer m.Unlock()
+	if m.pendingBatch.getLatestSnap() == nil {
+		return nil
+	}
+	txes := make([]*types.Transaction, 0)
+	for n := m.pendingSentBatches.Front(); n != nil; n = n.Next() {
+		txes = append(txes, n.Value.(*pendingSentBatch).txes...)
+	}
+	return append(txes, m.pendingBatch.getAppliedTxes()...)
+}
+
+// txCount returns the next nonce of account in the pending snapshot. This is
+// only available if the batcher is tracking state
+func (m *Batcher) txCount(account ethcommon.Address) (uint64, error) {
+	snap := m.pendingBatch.getLatestSnap()
+	if snap == nil {
+		return 0, errors.New("no pending snapshot available")
+	}
+	count, err := snap.GetTransactionCount(common.NewAddressFromEth(account))
+	if err != nil {
+		return 0, err
+	}
+	return count.Uint64(), nil
+}
+
+// maintainPool evicts the queued transactions of inactive accounts along with
+// transactions whose nonce has already been used
+func (m *Batcher) maintainPool() {
+	evicted := m.queuedTxes.evictStale(time.Now().Add(-queueLifetime))
+	if len(evicted) > 0 {
+		log.Info().Int("count", len(evicted)).Msg("evicted stale transactions from queue")
+	}
+	m.pendingBatch.updateCurrentSnap(m.pendingSentBatches)
+	consumed := m.queuedTxes.removeConsumed(m.txCount)
+	if len(consumed) > 0 {
+		log.Info().Int("count", len(consumed)).Msg("dropped queued transactions with consumed nonces")
+	}
+}
+
+func (m *Batcher) PoolContent() (map[ethcommon.Address][]*types.Transaction, map[ethcommon.Address][]*types.Transaction) {
+	m.Lock()
+	defer m.Unlock()
+	m.pendingBatch.updateCurrentSnap(m.pendingSentBatches)
+	return m.queuedTxes.content(m.txCount)
+}
+
 func (m *Batcher) PendingTransactionCount(_ context.Context, account common.Address) *uint64 {
 	m.Lock()
 	defer m.Unlock()
 	q, ok := m.queuedTxes.queues[account.ToEthAddress()]
-	if !ok {
+	if !ok || q.Empty() {
 		return nil
 	}
 	count := q.maxNonce + 1
@@ -275,8 +652,6 @@ func (m *Batcher) SendTransaction(_ context.Context, tx *types.Transaction) erro
 		return err
 	}
 
-	m.newTxFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{tx}})
-
 	txJSON, err := tx.MarshalJSON()
 	if err != nil {
 		log.Err(err).Msg("failed to marshal tx into json")
@@ -284,6 +659,18 @@ func (m *Batcher) SendTransaction(_ context.Context, tx *types.Transaction) erro
 		log.Info().RawJSON("tx", txJSON).Str("sender", sender.Hex()).Msg("user tx")
 	}
 
+	if err := m.queueTransaction(tx, sender); err != nil {
+		return err
+	}
+
+	// Subscribers are only told about the transaction once it was accepted.
+	// The feed is sent to without the lock held since it blocks until every
+	// subscriber has received the event
+	m.newTxFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{tx}})
+	return nil
+}
+
+func (m *Batcher) queueTransaction(tx *types.Transaction, sender ethcommon.Address) error {
 	m.Lock()
 	defer m.Unlock()
 
@@ -17,17 +17,23 @@
 package batcher
 
 import (
+	"container/list"
 	"context"
 	"crypto/ecdsa"
 	"errors"
+	"github.com/ethereum/go-ethereum"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"io/ioutil"
 	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -95,6 +101,18 @@ func (m *mock) TransactionReceipt(_ context.Context, txHash ethcommon.Hash) (*ty
 	}, nil
 }
 
+func (m *mock) ResendL2MessageNoWait(context.Context, []byte, common.Hash) (common.Hash, error) {
+	panic("not used")
+}
+
+func (m *mock) L2MessageNonce(context.Context, common.Hash) (uint64, error) {
+	panic("not used")
+}
+
+func (m *mock) MinedNonce(context.Context) (uint64, error) {
+	panic("not used")
+}
+
 func (m *mock) SendL2Message(context.Context, []byte) (arbbridge.MessageDeliveredEvent, error) {
 	panic("not used")
 }
@@ -144,13 +162,19 @@ func TestStatelessBatcher(t *testing.T) {
 	txes, txCounts := generateTxes(t, chain)
 	seenTxesChan := make(chan message.CompressedECDSATransaction, 1000)
 	mock := newMock(t, seenTxesChan, txes)
-	batcher := NewStatelessBatcher(
+	batcher, err := NewStatelessBatcher(
 		context.Background(),
+		nil,
 		chain,
 		mock,
 		mock,
 		time.Millisecond*200,
+		RandomOrdering,
+		"",
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	for _, tx := range txes {
 		if err := batcher.SendTransaction(context.Background(), tx); err != nil {
@@ -207,3 +231,435 @@ txFetchLoop:
 		}
 	}
 }
+
+func TestQueueReplacement(t *testing.T) {
+	chain := common.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := crypto.PubkeyToAddress(pk.PublicKey)
+	makeTx := func(nonce uint64, gasPrice int64) *types.Transaction {
+		tx := types.NewTransaction(nonce, ethcommon.Address{6}, big.NewInt(0), 1000, big.NewInt(gasPrice), nil)
+		signedTx, err := types.SignTx(tx, signer, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signedTx
+	}
+
+	queues := newTxQueues()
+	for i := uint64(0); i < 3; i++ {
+		if err := queues.addTransaction(makeTx(i, 100), sender); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queues.addTransaction(makeTx(1, 105), sender); err != core.ErrReplaceUnderpriced {
+		t.Fatal("expected underpriced replacement to be rejected, got", err)
+	}
+	replacement := makeTx(1, 110)
+	if err := queues.addTransaction(replacement, sender); err != nil {
+		t.Fatal(err)
+	}
+	if queues.queues[sender].txesByNonce[1] != replacement {
+		t.Error("replacement transaction wasn't queued")
+	}
+
+	removed := queues.removeConsumed(func(ethcommon.Address) (uint64, error) {
+		return 2, nil
+	})
+	if len(removed) != 2 {
+		t.Fatal("expected 2 consumed txes to be removed but got", len(removed))
+	}
+	if queues.queues[sender].Peek().Nonce() != 2 {
+		t.Error("unexpected nonce remaining in queue")
+	}
+
+	evicted := queues.evictStale(time.Now().Add(time.Second))
+	if len(evicted) != 1 || len(queues.accounts) != 0 || queues.size != 0 {
+		t.Error("expected queue to be fully evicted")
+	}
+}
+
+func TestQueueMaxNonce(t *testing.T) {
+	makeTx := func(nonce uint64) *types.Transaction {
+		return types.NewTransaction(nonce, ethcommon.Address{6}, big.NewInt(0), 1000, big.NewInt(0), nil)
+	}
+	queue := newTxQueue()
+	for _, nonce := range []uint64{2, 4, 3} {
+		if _, err := queue.addTransaction(makeTx(nonce), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queue.maxNonce != 4 {
+		t.Fatal("wrong max nonce", queue.maxNonce)
+	}
+
+	queue.removeBelowNonce(4)
+	if queue.maxNonce != 4 {
+		t.Error("max nonce changed while still queued", queue.maxNonce)
+	}
+	queue.Pop()
+	if !queue.Empty() || queue.maxNonce != 0 {
+		t.Error("max nonce not reset after emptying queue", queue.maxNonce)
+	}
+
+	if _, err := queue.addTransaction(makeTx(1), 0); err != nil {
+		t.Fatal(err)
+	}
+	if queue.maxNonce != 1 {
+		t.Error("stale max nonce", queue.maxNonce)
+	}
+}
+
+func TestOrderingPolicies(t *testing.T) {
+	chain := common.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	keys := make([]*ecdsa.PrivateKey, 0, 3)
+	for i := 0; i < 3; i++ {
+		pk, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, pk)
+	}
+
+	type txInfo struct {
+		key      int
+		nonce    uint64
+		gasPrice int64
+	}
+	// Transactions in arrival order
+	infos := []txInfo{
+		{key: 0, nonce: 0, gasPrice: 10},
+		{key: 1, nonce: 0, gasPrice: 30},
+		{key: 0, nonce: 1, gasPrice: 50},
+		{key: 2, nonce: 0, gasPrice: 20},
+		{key: 1, nonce: 1, gasPrice: 30},
+		{key: 2, nonce: 1, gasPrice: 40},
+	}
+	txes := make([]*types.Transaction, 0, len(infos))
+	for _, info := range infos {
+		tx := types.NewTransaction(info.nonce, ethcommon.Address{6}, big.NewInt(0), 1000, big.NewInt(info.gasPrice), nil)
+		signedTx, err := types.SignTx(tx, signer, keys[info.key])
+		if err != nil {
+			t.Fatal(err)
+		}
+		txes = append(txes, signedTx)
+	}
+
+	popAll := func(ordering OrderingPolicy) []*types.Transaction {
+		queues := newTxQueues()
+		for i, tx := range txes {
+			sender := crypto.PubkeyToAddress(keys[infos[i].key].PublicKey)
+			if err := queues.addTransaction(tx, sender); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b := newStatelessBatch(maxBatchSize)
+		popTx := ordering.popper()
+		var popped []*types.Transaction
+		for {
+			tx, index, cont := popTx(b, queues)
+			if tx == nil {
+				break
+			}
+			if err := b.addIncludedTx(tx); err != nil {
+				t.Fatal(err)
+			}
+			queues.maybeRemoveAccountAtIndex(index)
+			popped = append(popped, tx)
+			if !cont {
+				break
+			}
+		}
+		return popped
+	}
+
+	checkOrder := func(ordering OrderingPolicy, expected []int) {
+		for run := 0; run < 3; run++ {
+			popped := popAll(ordering)
+			if len(popped) != len(expected) {
+				t.Fatal(ordering, "popped", len(popped), "txes instead of", len(expected))
+			}
+			for i, txIndex := range expected {
+				if popped[i].Hash() != txes[txIndex].Hash() {
+					t.Fatal(ordering, "popped tx", i, "out of order")
+				}
+			}
+		}
+	}
+
+	// Only the head of each account's queue is considered, so the highest
+	// priced transaction is added last since it follows the lowest priced one
+	checkOrder(GasPriceOrdering, []int{1, 4, 3, 5, 0, 2})
+	checkOrder(FIFOOrdering, []int{0, 1, 2, 3, 4, 5})
+}
+
+func TestBatcherStateRoundTrip(t *testing.T) {
+	chain := common.RandAddress()
+	txes, _ := generateTxes(t, chain)
+	dir, err := ioutil.TempDir("", "batcher-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	sent := &pendingSentBatch{
+		txHashes: []common.Hash{common.RandHash(), common.RandHash()},
+		data:     []byte{1, 2, 3},
+		txes:     txes[:10],
+		sentAt:   time.Unix(1000, 0),
+	}
+	state := &batcherState{
+		SentBatches: []savedSentBatch{sent.saved()},
+		PendingTxes: txes[10:20],
+	}
+	if err := state.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadBatcherState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.SentBatches) != 1 || len(loaded.PendingTxes) != 10 {
+		t.Fatal("loaded wrong number of items")
+	}
+	restored := loaded.SentBatches[0].restore()
+	if restored.latestTxHash() != sent.latestTxHash() || len(restored.txHashes) != 2 {
+		t.Error("restored wrong tx hashes")
+	}
+	if !restored.sentAt.Equal(sent.sentAt) {
+		t.Error("restored wrong send time")
+	}
+	for i, tx := range restored.txes {
+		if tx.Hash() != sent.txes[i].Hash() {
+			t.Error("restored wrong transaction")
+		}
+	}
+	for i, tx := range loaded.PendingTxes {
+		if tx.Hash() != txes[10+i].Hash() {
+			t.Error("restored wrong pending transaction")
+		}
+	}
+
+	empty, err := loadBatcherState(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.SentBatches) != 0 || len(empty.PendingTxes) != 0 {
+		t.Error("expected empty state when no file exists")
+	}
+}
+
+// failingInbox fails to submit every batch
+type failingInbox struct {
+	*mock
+	sendCount int
+}
+
+func (f *failingInbox) SendL2MessageNoWait(context.Context, []byte) (common.Hash, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.sendCount++
+	return common.Hash{}, errors.New("failed to send")
+}
+
+func TestSendBatchBackoff(t *testing.T) {
+	backoff := time.Duration(0)
+	for i := 0; i < 10; i++ {
+		next := nextSendBackoff(backoff)
+		if next < minSendBackoff || next > maxSendBackoff || (backoff >= minSendBackoff && next < backoff) {
+			t.Fatal("bad backoff", next, "after", backoff)
+		}
+		backoff = next
+	}
+	if backoff != maxSendBackoff {
+		t.Error("backoff didn't reach its limit")
+	}
+
+	chain := common.RandAddress()
+	txes, _ := generateTxes(t, chain)
+	inbox := &failingInbox{mock: newMock(t, nil, txes)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batcher, err := NewStatelessBatcher(ctx, nil, chain, inbox, inbox, time.Millisecond*10, RandomOrdering, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range txes {
+		if err := batcher.SendTransaction(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-time.After(time.Millisecond * 2500)
+
+	inbox.Lock()
+	sendCount := inbox.sendCount
+	inbox.Unlock()
+	// The first attempt is followed by retries after one and two seconds
+	if sendCount == 0 || sendCount > 3 {
+		t.Error("batch submitted", sendCount, "times")
+	}
+	if len(batcher.InFlightBatches()) != 0 {
+		t.Error("failed batch recorded as sent")
+	}
+}
+
+// abandonInbox knows the nonce of some sent L1 transactions and the receipts
+// of those which were mined
+type abandonInbox struct {
+	*mock
+	nonces     map[common.Hash]uint64
+	receipts   map[common.Hash]*types.Receipt
+	minedNonce uint64
+}
+
+func (a *abandonInbox) L2MessageNonce(_ context.Context, txHash common.Hash) (uint64, error) {
+	nonce, ok := a.nonces[txHash]
+	if !ok {
+		return 0, errors.New("tx not found")
+	}
+	return nonce, nil
+}
+
+func (a *abandonInbox) MinedNonce(context.Context) (uint64, error) {
+	return a.minedNonce, nil
+}
+
+func (a *abandonInbox) TransactionReceipt(_ context.Context, txHash ethcommon.Hash) (*types.Receipt, error) {
+	receipt, ok := a.receipts[common.NewHashFromEth(txHash)]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func queuedTxCount(q *txQueues) int {
+	count := 0
+	for _, queue := range q.queues {
+		count += len(queue.sortedTxes())
+	}
+	return count
+}
+
+func TestAbandonedBatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chain := common.RandAddress()
+	txes, _ := generateTxes(t, chain)
+	b := &Batcher{
+		signer:             types.NewEIP155Signer(message.ChainAddressToID(chain)),
+		queuedTxes:         newTxQueues(),
+		popTx:              RandomOrdering.popper(),
+		pendingBatch:       newStatelessBatch(maxBatchSize),
+		pendingSentBatches: list.New(),
+		abandonedBatches:   list.New(),
+		statePath:          filepath.Join(dir, "batcher.json"),
+	}
+
+	failedHash := common.RandHash()
+	replacedHash := common.RandHash()
+	resentHash := common.RandHash()
+	droppedHash := common.RandHash()
+	b.pendingSentBatches.PushBack(&pendingSentBatch{txHashes: []common.Hash{failedHash}, txes: txes[0:2]})
+	b.pendingSentBatches.PushBack(&pendingSentBatch{txHashes: []common.Hash{replacedHash, resentHash}, txes: txes[2:4]})
+	b.pendingSentBatches.PushBack(&pendingSentBatch{txHashes: []common.Hash{droppedHash}, txes: txes[4:6]})
+	if err := b.pendingBatch.addIncludedTx(txes[6]); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := &abandonInbox{
+		mock: newMock(t, nil, txes),
+		// The node no longer knows the replaced transaction
+		nonces: map[common.Hash]uint64{failedHash: 0, resentHash: 1, droppedHash: 2},
+		receipts: map[common.Hash]*types.Receipt{
+			failedHash: {Status: 0, TxHash: failedHash.ToEthHash()},
+		},
+		minedNonce: 1,
+	}
+
+	ctx := context.Background()
+	b.Lock()
+	defer b.Unlock()
+
+	// Only the reverted batch and the pending batch are queued again
+	b.requeueFrontBatch()
+	if b.pendingSentBatches.Len() != 0 || b.abandonedBatches.Len() != 2 {
+		t.Fatal("later batches weren't abandoned")
+	}
+	if count := queuedTxCount(b.queuedTxes); count != 3 {
+		t.Fatal("wrong number of requeued transactions", count)
+	}
+
+	// Neither abandoned batch has been mined and their nonces are unused
+	b.checkAbandonedBatches(ctx, inbox, inbox)
+	if b.abandonedBatches.Len() != 2 {
+		t.Fatal("abandoned batch given up on too early")
+	}
+	if nonce := b.abandonedBatches.Front().Value.(*pendingSentBatch).nonce; nonce == nil || *nonce != 1 {
+		t.Error("nonce of abandoned batch not found from its resent transaction")
+	}
+
+	state, err := loadBatcherState(b.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.SentBatches) != 0 || len(state.AbandonedBatches) != 2 {
+		t.Fatal("abandoned batches weren't saved")
+	}
+	if state.AbandonedBatches[0].Nonce == nil || *state.AbandonedBatches[0].restore().nonce != 1 {
+		t.Error("nonce of abandoned batch wasn't saved")
+	}
+
+	// The resent transaction was mined, and the last batch's nonce was used
+	// by another transaction
+	inbox.receipts[resentHash] = &types.Receipt{Status: 1, TxHash: resentHash.ToEthHash()}
+	inbox.minedNonce = 3
+	b.checkAbandonedBatches(ctx, inbox, inbox)
+	if b.abandonedBatches.Len() != 0 {
+		t.Fatal("resolved batches still abandoned")
+	}
+	if count := queuedTxCount(b.queuedTxes); count != 5 {
+		t.Error("wrong number of requeued transactions", count)
+	}
+}
+
+func TestSendTransactionFeed(t *testing.T) {
+	chain := common.RandAddress()
+	txes, _ := generateTxes(t, chain)
+	b := &Batcher{
+		signer:             types.NewEIP155Signer(message.ChainAddressToID(chain)),
+		queuedTxes:         newTxQueues(),
+		popTx:              RandomOrdering.popper(),
+		pendingBatch:       newStatelessBatch(maxBatchSize),
+		pendingSentBatches: list.New(),
+		abandonedBatches:   list.New(),
+	}
+	events := make(chan core.NewTxsEvent, 10)
+	sub := b.SubscribeNewTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	ctx := context.Background()
+	if err := b.SendTransaction(ctx, txes[0]); err != nil {
+		t.Fatal(err)
+	}
+	// The transaction is already queued so it is rejected the second time
+	if err := b.SendTransaction(ctx, txes[0]); err != core.ErrAlreadyKnown {
+		t.Fatal("expected duplicate transaction to be rejected, got", err)
+	}
+	if len(events) != 1 {
+		t.Fatal("expected one event, got", len(events))
+	}
+	event := <-events
+	if len(event.Txs) != 1 || event.Txs[0].Hash() != txes[0].Hash() {
+		t.Error("event for wrong transaction")
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batcher
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rs/zerolog/log"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/snapshot"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+// addressTable is the subset of the ArbOS address table used for compressing
+// transactions. It is implemented by snapshot.Snapshot
+type addressTable interface {
+	AddressTableAddressExists(address common.Address) (bool, error)
+	AddressTableLookup(address common.Address) (*big.Int, error)
+}
+
+// snapshotSource provides the latest snapshot of the chain, which holds the
+// address table that transactions are compressed with. It is implemented by
+// txdb.TxDB
+type snapshotSource interface {
+	LatestSnapshot() *snapshot.Snapshot
+}
+
+// txCompressor encodes the transactions of a batch, replacing their
+// destination with its index in the ArbOS address table when that is
+// shorter.
+//
+// Addresses in the calldata and function table entries aren't compressed.
+// The compressed transaction format has no encoding for addresses inside the
+// calldata, and compressed transactions which refer to a function table entry
+// can't be parsed yet, so the rest of each transaction is always sent in full
+type txCompressor struct {
+	table addressTable
+
+	// indexes caches the result of looking up each address, with nil
+	// meaning that the address isn't in the table
+	indexes map[common.Address]*big.Int
+}
+
+// newTxCompressor creates a compressor which looks addresses up in table. If
+// table is nil, every transaction is encoded with full addresses
+func newTxCompressor(table addressTable) *txCompressor {
+	return &txCompressor{
+		table:   table,
+		indexes: make(map[common.Address]*big.Int),
+	}
+}
+
+func (c *txCompressor) lookupIndex(address common.Address) *big.Int {
+	if index, ok := c.indexes[address]; ok {
+		return index
+	}
+	var index *big.Int
+	exists, err := c.table.AddressTableAddressExists(address)
+	if err != nil {
+		log.Warn().Err(err).Hex("address", address.Bytes()).Msg("failed to check address table")
+	} else if exists {
+		index, err = c.table.AddressTableLookup(address)
+		if err != nil {
+			log.Warn().Err(err).Hex("address", address.Bytes()).Msg("failed to look up address")
+			index = nil
+		}
+	}
+	c.indexes[address] = index
+	return index
+}
+
+// compress returns the most compact encoding of tx along with the number of
+// bytes it saves compared to encoding it with full addresses
+func (c *txCompressor) compress(tx *types.Transaction) (message.CompressedECDSATransaction, int) {
+	compressed := message.NewCompressedECDSAFromEth(tx)
+	if c.table == nil || compressed.To == nil {
+		return compressed, 0
+	}
+	full, ok := compressed.To.(message.CompressedAddressFull)
+	if !ok {
+		return compressed, 0
+	}
+	index := c.lookupIndex(full.Address)
+	if index == nil {
+		return compressed, 0
+	}
+	fullData, err := full.Encode()
+	if err != nil {
+		return compressed, 0
+	}
+	indexAddress := message.CompressedAddressIndex{Int: index}
+	indexData, err := indexAddress.Encode()
+	if err != nil || len(indexData) >= len(fullData) {
+		return compressed, 0
+	}
+	compressed.To = indexAddress
+	return compressed, len(fullData) - len(indexData)
+}
+
+// compressTxes encodes txes for inclusion in a batch using table, which may
+// be nil, and returns the encoded transactions along with the number of bytes
+// of calldata saved
+func compressTxes(table addressTable, txes []*types.Transaction) ([]message.AbstractL2Message, int) {
+	compressor := newTxCompressor(table)
+	calldataSaved := 0
+	batchTxes := make([]message.AbstractL2Message, 0, len(txes))
+	for _, tx := range txes {
+		compressed, saved := compressor.compress(tx)
+		batchTxes = append(batchTxes, compressed)
+		calldataSaved += saved
+	}
+	return batchTxes, calldataSaved
+}
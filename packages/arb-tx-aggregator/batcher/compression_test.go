@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batcher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+type mockAddressTable struct {
+	indexes map[common.Address]*big.Int
+	lookups int
+}
+
+func (m *mockAddressTable) AddressTableAddressExists(address common.Address) (bool, error) {
+	m.lookups++
+	_, ok := m.indexes[address]
+	return ok, nil
+}
+
+func (m *mockAddressTable) AddressTableLookup(address common.Address) (*big.Int, error) {
+	return m.indexes[address], nil
+}
+
+func TestTxCompressor(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := common.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	signTx := func(to *common.Address) *types.Transaction {
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(0), []byte{1, 2, 3})
+		} else {
+			tx = types.NewTransaction(0, to.ToEthAddress(), big.NewInt(0), 100000, big.NewInt(0), []byte{1, 2, 3})
+		}
+		signedTx, err := types.SignTx(tx, signer, privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signedTx
+	}
+
+	known := common.RandAddress()
+	unknown := common.RandAddress()
+	table := &mockAddressTable{indexes: map[common.Address]*big.Int{known: big.NewInt(5)}}
+	compressor := newTxCompressor(table)
+
+	tx := signTx(&known)
+	compressed, saved := compressor.compress(tx)
+	index, ok := compressed.To.(message.CompressedAddressIndex)
+	if !ok || index.Cmp(big.NewInt(5)) != 0 {
+		t.Fatal("expected destination to be replaced by index, got", compressed.To)
+	}
+	fullData, err := message.NewCompressedECDSAFromEth(tx).AsData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressedData, err := compressed.AsData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != len(fullData)-len(compressedData) || saved <= 0 {
+		t.Error("wrong saved bytes", saved, len(fullData), len(compressedData))
+	}
+
+	// Lookups are cached for the rest of the batch
+	compressor.compress(signTx(&known))
+	if table.lookups != 1 {
+		t.Error("expected address to be looked up once, got", table.lookups)
+	}
+
+	for _, to := range []*common.Address{&unknown, nil} {
+		tx := signTx(to)
+		compressed, saved := compressor.compress(tx)
+		if saved != 0 {
+			t.Error("saved bytes without compressing", saved)
+		}
+		if _, ok := compressed.To.(message.CompressedAddressIndex); ok {
+			t.Error("unexpected index destination")
+		}
+	}
+
+	// Without an address table, transactions aren't compressed
+	compressed, saved = newTxCompressor(nil).compress(signTx(&known))
+	if _, ok := compressed.To.(message.CompressedAddressFull); !ok || saved != 0 {
+		t.Error("expected full destination without address table")
+	}
+}
+
+func TestCompressTxes(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := common.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	known := common.RandAddress()
+	table := &mockAddressTable{indexes: map[common.Address]*big.Int{known: big.NewInt(1)}}
+	txes := make([]*types.Transaction, 0, 3)
+	for i := uint64(0); i < 3; i++ {
+		tx := types.NewTransaction(i, known.ToEthAddress(), big.NewInt(0), 100000, big.NewInt(0), nil)
+		signedTx, err := types.SignTx(tx, signer, privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txes = append(txes, signedTx)
+	}
+
+	batchTxes, saved := compressTxes(table, txes)
+	if len(batchTxes) != len(txes) {
+		t.Fatal("wrong number of transactions", len(batchTxes))
+	}
+	if table.lookups != 1 {
+		t.Error("expected address to be looked up once per batch, got", table.lookups)
+	}
+	_, singleSaved := newTxCompressor(table).compress(txes[0])
+	if saved != 3*singleSaved || saved <= 0 {
+		t.Error("wrong saved bytes", saved, singleSaved)
+	}
+
+	if _, saved := compressTxes(nil, txes); saved != 0 {
+		t.Error("saved bytes without address table", saved)
+	}
+}
@@ -18,6 +18,7 @@ package batcher
 
 import (
 	"context"
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -47,15 +48,29 @@ func (b *Forwarder) PendingTransactionCount(ctx context.Context, account common.
 }
 
 func (b *Forwarder) SendTransaction(ctx context.Context, tx *types.Transaction) error {
-	txes := []*types.Transaction{tx}
-	b.newTxFeed.Send(core.NewTxsEvent{Txs: txes})
-	return b.client.SendTransaction(ctx, tx)
+	if err := b.client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.newTxFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{tx}})
+	return nil
 }
 
 func (b *Forwarder) PendingSnapshot() *snapshot.Snapshot {
 	return nil
 }
 
+func (b *Forwarder) PendingTransactions() []*types.Transaction {
+	return nil
+}
+
+func (b *Forwarder) PoolContent() (map[ethcommon.Address][]*types.Transaction, map[ethcommon.Address][]*types.Transaction) {
+	return nil, nil
+}
+
+func (b *Forwarder) InFlightBatches() []InFlightBatch {
+	return nil
+}
+
 func (b *Forwarder) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.newTxFeed.Subscribe(ch)
 }
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batcher
+
+import (
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
+)
+
+var (
+	metricsRegistry = metrics.NewRegistry("batcher")
+
+	queuedTxesGauge     = gethmetrics.NewRegisteredGauge("queue/txes", metricsRegistry)
+	queuedBytesGauge    = gethmetrics.NewRegisteredGauge("queue/bytes", metricsRegistry)
+	queuedAccountsGauge = gethmetrics.NewRegisteredGauge("queue/accounts", metricsRegistry)
+	// maxAccountTxesGauge is the number of transactions queued by the account
+	// with the most queued transactions
+	maxAccountTxesGauge = gethmetrics.NewRegisteredGauge("queue/maxaccounttxes", metricsRegistry)
+	batchTxesHistogram  = metrics.NewHistogram("batch/txes", metricsRegistry)
+	batchBytesHistogram = metrics.NewHistogram("batch/bytes", metricsRegistry)
+	batchSavedCounter   = gethmetrics.NewRegisteredCounter("batch/calldatasaved", metricsRegistry)
+	batchLatencyTimer   = gethmetrics.NewRegisteredTimer("batch/latency", metricsRegistry)
+	batchGasHistogram   = metrics.NewHistogram("batch/gasused", metricsRegistry)
+	batchFailedCounter  = gethmetrics.NewRegisteredCounter("batch/failed", metricsRegistry)
+)
+
+// updateQueueMetrics reports the size of the transaction queue. Accounts are
+// only counted rather than given a gauge each so that the number of metrics
+// doesn't grow with the number of senders. It must be called with the batcher
+// locked
+func (m *Batcher) updateQueueMetrics() {
+	count := 0
+	maxAccountTxes := 0
+	for _, queue := range m.queuedTxes.queues {
+		count += len(queue.txes)
+		if len(queue.txes) > maxAccountTxes {
+			maxAccountTxes = len(queue.txes)
+		}
+	}
+	queuedTxesGauge.Update(int64(count))
+	queuedBytesGauge.Update(int64(m.queuedTxes.size))
+	queuedAccountsGauge.Update(int64(len(m.queuedTxes.queues)))
+	maxAccountTxesGauge.Update(int64(maxAccountTxes))
+}
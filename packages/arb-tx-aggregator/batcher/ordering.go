@@ -0,0 +1,136 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OrderingPolicy determines the order in which queued transactions are added
+// to batches. Transactions from the same account are always added in nonce
+// order, so policies only choose between the heads of each account's queue
+type OrderingPolicy int
+
+const (
+	// RandomOrdering picks a random account for each slot in the batch
+	RandomOrdering OrderingPolicy = iota
+
+	// GasPriceOrdering picks the account whose next transaction has the
+	// highest gas price, breaking ties by arrival order
+	GasPriceOrdering
+
+	// FIFOOrdering picks the account whose next transaction arrived first
+	FIFOOrdering
+)
+
+func (o OrderingPolicy) String() string {
+	switch o {
+	case RandomOrdering:
+		return "random"
+	case GasPriceOrdering:
+		return "gasprice"
+	case FIFOOrdering:
+		return "fifo"
+	default:
+		return fmt.Sprintf("OrderingPolicy(%d)", int(o))
+	}
+}
+
+func ParseOrderingPolicy(name string) (OrderingPolicy, error) {
+	for _, policy := range []OrderingPolicy{RandomOrdering, GasPriceOrdering, FIFOOrdering} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown ordering policy %v", name)
+}
+
+// txPopper removes the next transaction to add to batch from queuedTxes. It
+// returns the transaction along with the index of its account, or nil if no
+// transaction could be added. The final return value is false if there were
+// no more transactions to try
+type txPopper func(b batch, queuedTxes *txQueues) (*types.Transaction, int, bool)
+
+func (o OrderingPolicy) popper() txPopper {
+	switch o {
+	case GasPriceOrdering:
+		return popHighestPriceTx
+	case FIFOOrdering:
+		return popOldestTx
+	default:
+		return popRandomTx
+	}
+}
+
+func popHighestPriceTx(b batch, queuedTxes *txQueues) (*types.Transaction, int, bool) {
+	return popOrderedTx(b, queuedTxes, func(a, b *txQueue) bool {
+		if cmp := a.Peek().GasPrice().Cmp(b.Peek().GasPrice()); cmp != 0 {
+			return cmp > 0
+		}
+		return a.PeekArrival() < b.PeekArrival()
+	})
+}
+
+func popOldestTx(b batch, queuedTxes *txQueues) (*types.Transaction, int, bool) {
+	return popOrderedTx(b, queuedTxes, func(a, b *txQueue) bool {
+		return a.PeekArrival() < b.PeekArrival()
+	})
+}
+
+// popOrderedTx tries the head transaction of each account in the order given
+// by less and removes the first one accepted by the batch
+func popOrderedTx(b batch, queuedTxes *txQueues, less func(a, b *txQueue) bool) (*types.Transaction, int, bool) {
+	for {
+		order := make([]int, len(queuedTxes.accounts))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool {
+			return less(
+				queuedTxes.queues[queuedTxes.accounts[order[i]]],
+				queuedTxes.queues[queuedTxes.accounts[order[j]]],
+			)
+		})
+
+		removed := false
+		for _, index := range order {
+			tx := queuedTxes.queues[queuedTxes.accounts[index]].Peek()
+			switch b.validateTx(tx) {
+			case REMOVE:
+				// Removing an account reorders the account list, so restart
+				queuedTxes.removeTxFromAccountAtIndex(index)
+				queuedTxes.maybeRemoveAccountAtIndex(index)
+				removed = true
+			case SKIP:
+			case FULL:
+				return nil, 0, true
+			case ACCEPT:
+				queuedTxes.removeTxFromAccountAtIndex(index)
+				return tx, index, true
+			}
+			if removed {
+				break
+			}
+		}
+		if !removed {
+			return nil, 0, false
+		}
+	}
+}
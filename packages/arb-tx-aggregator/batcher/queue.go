@@ -19,9 +19,14 @@ package batcher
 import (
 	"container/heap"
 	"errors"
+	"math/big"
+	"math/rand"
+	"sort"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
-	"math/rand"
 )
 
 // An TxHeap is a min-heap of transactions sorted by nonce.
@@ -43,10 +48,42 @@ func (h *TxHeap) Pop() interface{} {
 	return x
 }
 
+const (
+	// maxQueuedTxesPerAccount is the maximum number of transactions that can
+	// be queued from a single account
+	maxQueuedTxesPerAccount = 64
+
+	// maxQueuedAccountSize is the maximum total size of the transactions
+	// queued from a single account
+	maxQueuedAccountSize common.StorageSize = 1024 * 1024
+
+	// maxQueuedSize is the maximum total size of all queued transactions
+	maxQueuedSize common.StorageSize = 64 * 1024 * 1024
+
+	// priceBump is the minimum percentage that a replacement transaction must
+	// raise the gas price by
+	priceBump = 10
+
+	// queueLifetime is the maximum amount of time an account's queued
+	// transactions can go without any activity before they are evicted
+	queueLifetime = 3 * time.Hour
+)
+
+var (
+	errAccountQueueFull = errors.New("too many queued transactions from account")
+	errTxPoolFull       = errors.New("transaction pool is full")
+)
+
 type txQueue struct {
 	txes        TxHeap
 	txesByNonce map[uint64]*types.Transaction
 	maxNonce    uint64
+	size        common.StorageSize
+	lastActive  time.Time
+
+	// arrivals records the order in which each queued transaction was
+	// received across all queues
+	arrivals map[uint64]uint64
 }
 
 func newTxQueue() *txQueue {
@@ -54,21 +91,53 @@ func newTxQueue() *txQueue {
 		txes:        nil,
 		txesByNonce: make(map[uint64]*types.Transaction),
 		maxNonce:    0,
+		size:        0,
+		lastActive:  time.Now(),
+		arrivals:    make(map[uint64]uint64),
 	}
 }
 
-func (q *txQueue) addTransaction(tx *types.Transaction) error {
-	if _, ok := q.txesByNonce[tx.Nonce()]; ok {
-		return errors.New("transaction replacement not supported")
+// addTransaction adds tx to the queue, replacing the existing transaction
+// with the same nonce if there is one. It returns the transaction that was
+// replaced or nil
+func (q *txQueue) addTransaction(tx *types.Transaction, arrival uint64) (*types.Transaction, error) {
+	old, ok := q.txesByNonce[tx.Nonce()]
+	if ok {
+		if old.Hash() == tx.Hash() {
+			return nil, core.ErrAlreadyKnown
+		}
+		minPrice := new(big.Int).Mul(old.GasPrice(), big.NewInt(100+priceBump))
+		minPrice = minPrice.Div(minPrice, big.NewInt(100))
+		if tx.GasPrice().Cmp(minPrice) < 0 {
+			return nil, core.ErrReplaceUnderpriced
+		}
+		for i, queuedTx := range q.txes {
+			if queuedTx == old {
+				q.txes[i] = tx
+				break
+			}
+		}
+		q.txesByNonce[tx.Nonce()] = tx
+		q.arrivals[tx.Nonce()] = arrival
+		q.size += tx.Size() - old.Size()
+		q.lastActive = time.Now()
+		return old, nil
+	}
+
+	if len(q.txes) >= maxQueuedTxesPerAccount || q.size+tx.Size() > maxQueuedAccountSize {
+		return nil, errAccountQueueFull
 	}
 
 	q.txesByNonce[tx.Nonce()] = tx
+	q.arrivals[tx.Nonce()] = arrival
 	heap.Push(&q.txes, tx)
+	q.size += tx.Size()
+	q.lastActive = time.Now()
 
 	if tx.Nonce() > q.maxNonce {
 		q.maxNonce = tx.Nonce()
 	}
-	return nil
+	return nil, nil
 }
 
 func (q *txQueue) Empty() bool {
@@ -79,21 +148,69 @@ func (q *txQueue) Peek() *types.Transaction {
 	return q.txes[0]
 }
 
+// PeekArrival returns the arrival order of the transaction returned by Peek
+func (q *txQueue) PeekArrival() uint64 {
+	return q.arrivals[q.Peek().Nonce()]
+}
+
 func (q *txQueue) Pop() *types.Transaction {
 	tx := heap.Pop(&q.txes).(*types.Transaction)
 	delete(q.txesByNonce, tx.Nonce())
+	delete(q.arrivals, tx.Nonce())
+	q.size -= tx.Size()
+	q.lastActive = time.Now()
+	q.updateMaxNonce()
 	return tx
 }
 
+// removeBelowNonce removes all transactions with a nonce lower than nonce and
+// returns the removed transactions
+func (q *txQueue) removeBelowNonce(nonce uint64) []*types.Transaction {
+	var removed []*types.Transaction
+	for !q.Empty() && q.Peek().Nonce() < nonce {
+		tx := heap.Pop(&q.txes).(*types.Transaction)
+		delete(q.txesByNonce, tx.Nonce())
+		delete(q.arrivals, tx.Nonce())
+		q.size -= tx.Size()
+		removed = append(removed, tx)
+	}
+	q.updateMaxNonce()
+	return removed
+}
+
+// updateMaxNonce recomputes the highest queued nonce after transactions have
+// been removed from the queue
+func (q *txQueue) updateMaxNonce() {
+	q.maxNonce = 0
+	for nonce := range q.txesByNonce {
+		if nonce > q.maxNonce {
+			q.maxNonce = nonce
+		}
+	}
+}
+
+// sortedTxes returns the queued transactions ordered by nonce
+func (q *txQueue) sortedTxes() []*types.Transaction {
+	txes := make([]*types.Transaction, len(q.txes))
+	copy(txes, q.txes)
+	sort.Slice(txes, func(i, j int) bool {
+		return txes[i].Nonce() < txes[j].Nonce()
+	})
+	return txes
+}
+
 type txQueues struct {
 	queues   map[common.Address]*txQueue
 	accounts []common.Address
+	size     common.StorageSize
+	arrivals uint64
 }
 
 func newTxQueues() *txQueues {
 	return &txQueues{
 		queues:   make(map[common.Address]*txQueue),
 		accounts: nil,
+		size:     0,
 	}
 }
 
@@ -101,14 +218,29 @@ func (q *txQueues) addTransaction(tx *types.Transaction, sender common.Address)
 	queue, ok := q.queues[sender]
 	if !ok {
 		queue = newTxQueue()
+	}
+	if _, replacing := queue.txesByNonce[tx.Nonce()]; !replacing && q.size+tx.Size() > maxQueuedSize {
+		return errTxPoolFull
+	}
+	old, err := queue.addTransaction(tx, q.arrivals)
+	if err != nil {
+		return err
+	}
+	q.arrivals++
+	if !ok {
 		q.queues[sender] = queue
 		q.accounts = append(q.accounts, sender)
 	}
-	return queue.addTransaction(tx)
+	q.size += tx.Size()
+	if old != nil {
+		q.size -= old.Size()
+	}
+	return nil
 }
 
 func (q *txQueues) removeTxFromAccountAtIndex(i int) {
-	q.queues[q.accounts[i]].Pop()
+	tx := q.queues[q.accounts[i]].Pop()
+	q.size -= tx.Size()
 }
 
 func (q *txQueues) maybeRemoveAccountAtIndex(i int) {
@@ -120,6 +252,78 @@ func (q *txQueues) maybeRemoveAccountAtIndex(i int) {
 	}
 }
 
+func (q *txQueues) removeAccountAtIndex(i int) []*types.Transaction {
+	account := q.accounts[i]
+	queue := q.queues[account]
+	q.size -= queue.size
+	delete(q.queues, account)
+	q.accounts[i] = q.accounts[len(q.accounts)-1]
+	q.accounts = q.accounts[:len(q.accounts)-1]
+	return queue.txes
+}
+
+// evictStale removes the queues of all accounts which haven't had any
+// activity since the cutoff and returns the evicted transactions
+func (q *txQueues) evictStale(cutoff time.Time) []*types.Transaction {
+	var evicted []*types.Transaction
+	for i := len(q.accounts) - 1; i >= 0; i-- {
+		if q.queues[q.accounts[i]].lastActive.Before(cutoff) {
+			evicted = append(evicted, q.removeAccountAtIndex(i)...)
+		}
+	}
+	return evicted
+}
+
+// removeConsumed removes all transactions whose nonce is lower than the
+// sender's current transaction count and returns the removed transactions
+func (q *txQueues) removeConsumed(txCount func(account common.Address) (uint64, error)) []*types.Transaction {
+	var removed []*types.Transaction
+	for i := len(q.accounts) - 1; i >= 0; i-- {
+		account := q.accounts[i]
+		count, err := txCount(account)
+		if err != nil {
+			continue
+		}
+		queue := q.queues[account]
+		consumed := queue.removeBelowNonce(count)
+		for _, tx := range consumed {
+			q.size -= tx.Size()
+		}
+		removed = append(removed, consumed...)
+		q.maybeRemoveAccountAtIndex(i)
+	}
+	return removed
+}
+
+// content splits the queued transactions of each account into those which
+// are executable in sequence starting from the account's next nonce and those
+// which are waiting on a nonce gap to be filled
+func (q *txQueues) content(txCount func(account common.Address) (uint64, error)) (map[common.Address][]*types.Transaction, map[common.Address][]*types.Transaction) {
+	pending := make(map[common.Address][]*types.Transaction)
+	queued := make(map[common.Address][]*types.Transaction)
+	for account, queue := range q.queues {
+		txes := queue.sortedTxes()
+		if len(txes) == 0 {
+			continue
+		}
+		nextNonce, err := txCount(account)
+		if err != nil {
+			nextNonce = txes[0].Nonce()
+		}
+		i := 0
+		for ; i < len(txes) && txes[i].Nonce() == nextNonce; i++ {
+			nextNonce++
+		}
+		if i > 0 {
+			pending[account] = txes[:i]
+		}
+		if i < len(txes) {
+			queued[account] = txes[i:]
+		}
+	}
+	return pending, queued
+}
+
 func popRandomTx(b batch, queuedTxes *txQueues) (*types.Transaction, int, bool) {
 	queuedCount := int32(len(queuedTxes.accounts))
 	if queuedCount == 0 {
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
+)
+
+const (
+	// batchReceiptTimeout is how long to wait for a submitted batch to be
+	// included on L1 before resending it with a higher gas price
+	batchReceiptTimeout = 5 * time.Minute
+
+	// maxBatchResends is the number of times a batch is resent before its
+	// transactions are returned to the queue
+	maxBatchResends = 5
+)
+
+var errReceiptTimeout = errors.New("timed out waiting for batch receipt")
+
+type pendingSentBatch struct {
+	// txHashes contains the hash of each L1 transaction sent for this batch,
+	// with the most recent last. Any of them may end up being included
+	txHashes []common.Hash
+	data     []byte
+	txes     []*types.Transaction
+	sentAt   time.Time
+
+	// nonce is the L1 nonce shared by the transactions in txHashes. It is
+	// only looked up once the batch is abandoned and is nil until then
+	nonce *uint64
+
+	// calldataSaved is the number of bytes of L1 calldata saved by
+	// replacing addresses in the batch with address table indexes
+	calldataSaved int
+}
+
+func (b *pendingSentBatch) latestTxHash() common.Hash {
+	return b.txHashes[len(b.txHashes)-1]
+}
+
+// InFlightBatch describes a batch that was submitted to L1 but hasn't been
+// confirmed yet
+type InFlightBatch struct {
+	TxHashes []ethcommon.Hash
+	TxCount  int
+	SentAt   time.Time
+	// CalldataSaved is the number of bytes of L1 calldata saved by
+	// compressing the batch's transactions
+	CalldataSaved int
+	// Abandoned is set for batches that were given up on but whose L1
+	// transactions may still be included
+	Abandoned bool
+}
+
+func (b *pendingSentBatch) inFlight(abandoned bool) InFlightBatch {
+	txHashes := make([]ethcommon.Hash, 0, len(b.txHashes))
+	for _, txHash := range b.txHashes {
+		txHashes = append(txHashes, txHash.ToEthHash())
+	}
+	return InFlightBatch{
+		TxHashes:      txHashes,
+		TxCount:       len(b.txes),
+		SentAt:        b.sentAt,
+		CalldataSaved: b.calldataSaved,
+		Abandoned:     abandoned,
+	}
+}
+
+// waitForBatchReceipt polls for the receipt of any of the transactions sent
+// for a batch, returning errReceiptTimeout if none was found before timeout
+func waitForBatchReceipt(
+	ctx context.Context,
+	receiptFetcher ethutils.ReceiptFetcher,
+	txHashes []common.Hash,
+	timeout time.Duration,
+) (*types.Receipt, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		for _, txHash := range txHashes {
+			receipt, err := receiptFetcher.TransactionReceipt(timeoutCtx, txHash.ToEthHash())
+			if err == nil && receipt != nil {
+				return receipt, nil
+			}
+			if timeoutCtx.Err() != nil {
+				break
+			}
+			if err != nil && err.Error() != ethereum.NotFound.Error() {
+				return nil, err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timeoutCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, errReceiptTimeout
+		}
+	}
+}
+
+// findBatchReceipt returns the receipt of whichever of the transactions sent
+// for a batch was included, or nil if none of them has been included yet
+func findBatchReceipt(
+	ctx context.Context,
+	receiptFetcher ethutils.ReceiptFetcher,
+	txHashes []common.Hash,
+) (*types.Receipt, error) {
+	for _, txHash := range txHashes {
+		receipt, err := receiptFetcher.TransactionReceipt(ctx, txHash.ToEthHash())
+		if err == nil && receipt != nil {
+			return receipt, nil
+		}
+		if err != nil && err.Error() != ethereum.NotFound.Error() {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+type abandonedBatchStatus int
+
+const (
+	// batchUnresolved means none of the batch's L1 transactions has been
+	// included, but one of them still may be
+	batchUnresolved abandonedBatchStatus = iota
+	// batchIncluded means one of the batch's L1 transactions succeeded
+	batchIncluded
+	// batchDropped means none of the batch's L1 transactions can succeed,
+	// either because one of them reverted or because their nonce was used
+	// by another transaction
+	batchDropped
+)
+
+// checkAbandonedBatch finds out what became of the L1 transactions sent for a
+// batch that was given up on. nonce is the nonce of those transactions if it
+// is already known, and the nonce is returned so that it only has to be looked
+// up once
+func checkAbandonedBatch(
+	ctx context.Context,
+	receiptFetcher ethutils.ReceiptFetcher,
+	inbox arbbridge.GlobalInboxSender,
+	txHashes []common.Hash,
+	nonce *uint64,
+) (abandonedBatchStatus, *uint64, error) {
+	if nonce == nil {
+		var err error
+		for _, txHash := range txHashes {
+			var txNonce uint64
+			txNonce, err = inbox.L2MessageNonce(ctx, txHash)
+			if err == nil {
+				nonce = &txNonce
+				break
+			}
+		}
+		if nonce == nil {
+			return batchUnresolved, nil, err
+		}
+	}
+	// The mined nonce is fetched before the receipts so that a transaction
+	// from the batch which is mined in between isn't taken as dropped
+	minedNonce, err := inbox.MinedNonce(ctx)
+	if err != nil {
+		return batchUnresolved, nonce, err
+	}
+	receipt, err := findBatchReceipt(ctx, receiptFetcher, txHashes)
+	if err != nil {
+		return batchUnresolved, nonce, err
+	}
+	if receipt != nil {
+		if receipt.Status == 1 {
+			return batchIncluded, nonce, nil
+		}
+		return batchDropped, nonce, nil
+	}
+	if *nonce < minedNonce {
+		return batchDropped, nonce, nil
+	}
+	return batchUnresolved, nonce, nil
+}
+
+type savedSentBatch struct {
+	TxHashes      []ethcommon.Hash     `json:"txHashes"`
+	Data          hexutil.Bytes        `json:"data"`
+	Txes          []*types.Transaction `json:"txes"`
+	SentAt        time.Time            `json:"sentAt"`
+	CalldataSaved int                  `json:"calldataSaved"`
+	Nonce         *uint64              `json:"nonce,omitempty"`
+}
+
+// batcherState is the state the batcher saves so that transactions it has
+// accepted aren't lost if it is restarted
+type batcherState struct {
+	SentBatches      []savedSentBatch     `json:"sentBatches"`
+	AbandonedBatches []savedSentBatch     `json:"abandonedBatches,omitempty"`
+	PendingTxes      []*types.Transaction `json:"pendingTxes"`
+}
+
+func loadBatcherState(path string) (*batcherState, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &batcherState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &batcherState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *batcherState) save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash while saving doesn't
+	// corrupt the existing state
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (b *pendingSentBatch) saved() savedSentBatch {
+	txHashes := make([]ethcommon.Hash, 0, len(b.txHashes))
+	for _, txHash := range b.txHashes {
+		txHashes = append(txHashes, txHash.ToEthHash())
+	}
+	return savedSentBatch{
+		TxHashes:      txHashes,
+		Data:          b.data,
+		Txes:          b.txes,
+		SentAt:        b.sentAt,
+		CalldataSaved: b.calldataSaved,
+		Nonce:         b.nonce,
+	}
+}
+
+func (b savedSentBatch) restore() *pendingSentBatch {
+	txHashes := make([]common.Hash, 0, len(b.TxHashes))
+	for _, txHash := range b.TxHashes {
+		txHashes = append(txHashes, common.NewHashFromEth(txHash))
+	}
+	return &pendingSentBatch{
+		txHashes:      txHashes,
+		data:          b.Data,
+		txes:          b.Txes,
+		sentAt:        b.SentAt,
+		calldataSaved: b.CalldataSaved,
+		nonce:         b.Nonce,
+	}
+}
@@ -104,6 +104,10 @@ func (p *statefulBatch) getLatestSnap() *snapshot.Snapshot {
 	return p.snap
 }
 
+func (p *statefulBatch) resetTxCounts() {
+	p.txCounts = make(map[common.Address]uint64)
+}
+
 func (p *statefulBatch) addIncludedTx(tx *types.Transaction) error {
 	newSnap := p.snap.Clone()
 	newSnap, err := snapWithTx(newSnap, tx, p.signer)
@@ -153,32 +157,36 @@ func (p *statefulBatch) checkValidForQueue(tx *types.Transaction) error {
 }
 
 func (p *statefulBatch) updateCurrentSnap(pendingSentBatches *list.List) {
+	if p.snap.Height().Cmp(p.db.LatestSnapshot().Height()) < 0 {
+		p.resetSnap(pendingSentBatches)
+	}
+}
+
+// resetSnap rebuilds the pending snapshot from the latest snapshot, the
+// already broadcast transactions and the transactions in this batch
+func (p *statefulBatch) resetSnap(pendingSentBatches *list.List) {
 	snap := p.db.LatestSnapshot().Clone()
-	if p.snap.Height().Cmp(snap.Height()) < 0 {
-		// Add all of the already broadcast transactions to the snapshot
-		// If they were already included, they'll be ignored because they will
-		// have invalid sequence numbers
-		n := pendingSentBatches.Front()
-		for n != nil {
-			item := n.Value.(*pendingSentBatch)
-			for _, tx := range item.txes {
-				var err error
-				newSnap, err := snapWithTx(snap, tx, p.signer)
-				if err != nil {
-					continue
-				}
-				snap = newSnap
-			}
-			n = n.Next()
-		}
-		for _, tx := range p.appliedTxes {
-			var err error
+	// Add all of the already broadcast transactions to the snapshot
+	// If they were already included, they'll be ignored because they will
+	// have invalid sequence numbers
+	n := pendingSentBatches.Front()
+	for n != nil {
+		item := n.Value.(*pendingSentBatch)
+		for _, tx := range item.txes {
 			newSnap, err := snapWithTx(snap, tx, p.signer)
 			if err != nil {
 				continue
 			}
 			snap = newSnap
 		}
-		p.snap = snap
+		n = n.Next()
+	}
+	for _, tx := range p.appliedTxes {
+		newSnap, err := snapWithTx(snap, tx, p.signer)
+		if err != nil {
+			continue
+		}
+		snap = newSnap
 	}
+	p.snap = snap
 }
@@ -60,10 +60,18 @@ func (p *statelessBatch) updateCurrentSnap(*list.List) {
 
 }
 
+func (p *statelessBatch) resetSnap(*list.List) {
+
+}
+
 func (p *statelessBatch) getLatestSnap() *snapshot.Snapshot {
 	return nil
 }
 
+func (p *statelessBatch) resetTxCounts() {
+
+}
+
 func (p *statelessBatch) addIncludedTx(tx *types.Transaction) error {
 	p.appliedTxes = append(p.appliedTxes, tx)
 	p.sizeBytes += tx.Size()
@@ -0,0 +1,507 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/c-bata/go-prompt"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+// Values with longer string forms are shown by size and hash unless they are
+// printed individually
+const maxValueLength = 200
+
+const defaultStackCount = 10
+
+const runToEndSteps = 100000000000
+
+type App struct {
+	mach          *gomachine.Machine
+	inboxMessages []inbox.InboxMessage
+	avmLogs       []value.Value
+	avmSends      []value.Value
+	hasVector     bool
+
+	logs        []value.Value
+	sends       []value.Value
+	totalSteps  uint64
+	totalGas    uint64
+	breakpoints map[gomachine.CodePoint]bool
+}
+
+func newApp(codeFile string, vectorFile string) (*App, error) {
+	mach, err := gomachine.New(codeFile)
+	if err != nil {
+		return nil, err
+	}
+	a := &App{
+		mach:        mach,
+		breakpoints: make(map[gomachine.CodePoint]bool),
+	}
+	if vectorFile != "" {
+		data, err := ioutil.ReadFile(vectorFile)
+		if err != nil {
+			return nil, err
+		}
+		a.inboxMessages, a.avmLogs, a.avmSends, err = inbox.LoadTestVector(data)
+		if err != nil {
+			return nil, err
+		}
+		a.hasVector = true
+	}
+	return a, nil
+}
+
+func statusName(status machine.Status) string {
+	switch status {
+	case machine.Extensive:
+		return "running"
+	case machine.ErrorStop:
+		return "error"
+	case machine.Halt:
+		return "halted"
+	default:
+		return fmt.Sprintf("unknown status %v", status)
+	}
+}
+
+func formatValue(val value.Value) string {
+	str := fmt.Sprint(val)
+	if len(str) <= maxValueLength {
+		return str
+	}
+	if tup, ok := val.(*value.TupleValue); ok {
+		return fmt.Sprintf("Tuple[%v] %v", tup.Len(), tup.Hash())
+	}
+	return str[:maxValueLength] + "..."
+}
+
+func formatOperation(op value.Operation) string {
+	name := gomachine.OpcodeName(op.GetOp())
+	if imm, ok := op.(value.ImmediateOperation); ok {
+		return fmt.Sprintf("%v %v", name, formatValue(imm.Val))
+	}
+	return name
+}
+
+// run executes up to maxSteps instructions, feeding in the remaining test
+// vector messages, and reports why execution stopped
+func (a *App) run(maxSteps uint64) {
+	assertion, steps := a.mach.ExecuteDebugAssertion(maxSteps, a.inboxMessages, a.breakpoints, 0)
+	a.inboxMessages = a.inboxMessages[assertion.InboxMessagesConsumed:]
+	newLogs := assertion.ParseLogs()
+	newSends := assertion.ParseOutMessages()
+	a.logs = append(a.logs, newLogs...)
+	a.sends = append(a.sends, newSends...)
+	a.totalSteps += steps
+	a.totalGas += assertion.NumGas
+
+	fmt.Printf(
+		"ran %v steps using %v gas, consumed %v messages, produced %v logs and %v sends\n",
+		steps,
+		assertion.NumGas,
+		assertion.InboxMessagesConsumed,
+		len(newLogs),
+		len(newSends),
+	)
+
+	state := a.mach.State()
+	if steps < maxSteps {
+		if blocked := a.mach.IsBlocked(len(a.inboxMessages) > 0); blocked != nil {
+			fmt.Println("stopped:", blocked)
+		} else if a.breakpoints[state.PC] {
+			fmt.Println("stopped at breakpoint", state.PC)
+		} else {
+			fmt.Println("stopped by breakpoint instruction")
+		}
+	}
+	fmt.Println(state.PC, formatOperation(state.Operation))
+}
+
+func parseCount(args []string, defaultCount uint64) (uint64, error) {
+	if len(args) == 0 {
+		return defaultCount, nil
+	}
+	return strconv.ParseUint(args[0], 10, 64)
+}
+
+func parseCodePoint(args []string) (gomachine.CodePoint, error) {
+	var segment, pc uint64
+	var err error
+	switch len(args) {
+	case 1:
+		pc, err = strconv.ParseUint(args[0], 10, 64)
+	case 2:
+		segment, err = strconv.ParseUint(args[0], 10, 64)
+		if err == nil {
+			pc, err = strconv.ParseUint(args[1], 10, 64)
+		}
+	default:
+		return gomachine.CodePoint{}, errors.New("expected [segment] pc")
+	}
+	return gomachine.CodePoint{Segment: segment, PC: pc}, err
+}
+
+func (a *App) listBreakpoints() {
+	codePoints := make([]gomachine.CodePoint, 0, len(a.breakpoints))
+	for cp := range a.breakpoints {
+		codePoints = append(codePoints, cp)
+	}
+	sort.Slice(codePoints, func(i, j int) bool {
+		if codePoints[i].Segment != codePoints[j].Segment {
+			return codePoints[i].Segment < codePoints[j].Segment
+		}
+		// Offsets count down, so list breakpoints in execution order
+		return codePoints[i].PC > codePoints[j].PC
+	})
+	for _, cp := range codePoints {
+		fmt.Println(cp)
+	}
+}
+
+func printStack(name string, values []value.Value, count uint64) {
+	fmt.Printf("%v has %v items\n", name, len(values))
+	for i, val := range values {
+		if uint64(i) >= count {
+			fmt.Println("...")
+			break
+		}
+		fmt.Printf("%v: %v\n", i, formatValue(val))
+	}
+}
+
+type stateView struct {
+	Status          string   `json:"status"`
+	PC              string   `json:"pc"`
+	Operation       string   `json:"operation"`
+	CodePointHash   string   `json:"codePointHash"`
+	StackSize       int      `json:"stackSize"`
+	Stack           []string `json:"stack"`
+	StackHash       string   `json:"stackHash"`
+	AuxStackSize    int      `json:"auxStackSize"`
+	AuxStack        []string `json:"auxStack"`
+	AuxStackHash    string   `json:"auxStackHash"`
+	Register        string   `json:"register"`
+	Static          string   `json:"static"`
+	ArbGasRemaining string   `json:"arbGasRemaining"`
+	ErrPC           string   `json:"errPC"`
+	StagedMessage   string   `json:"stagedMessage"`
+	TotalSteps      uint64   `json:"totalSteps"`
+	TotalGas        uint64   `json:"totalGas"`
+	PendingMessages int      `json:"pendingMessages"`
+}
+
+func formatValues(values []value.Value) []string {
+	count := len(values)
+	if count > defaultStackCount {
+		count = defaultStackCount
+	}
+	ret := make([]string, 0, count)
+	for _, val := range values[:count] {
+		ret = append(ret, formatValue(val))
+	}
+	return ret
+}
+
+func (a *App) printState() error {
+	state := a.mach.State()
+	view := stateView{
+		Status:          statusName(state.Status),
+		PC:              state.PC.String(),
+		Operation:       formatOperation(state.Operation),
+		CodePointHash:   state.CodePointHash.String(),
+		StackSize:       len(state.Stack),
+		Stack:           formatValues(state.Stack),
+		StackHash:       state.StackHash.String(),
+		AuxStackSize:    len(state.AuxStack),
+		AuxStack:        formatValues(state.AuxStack),
+		AuxStackHash:    state.AuxStackHash.String(),
+		Register:        formatValue(state.Register),
+		Static:          formatValue(state.Static),
+		ArbGasRemaining: state.ArbGasRemaining.String(),
+		ErrPC:           state.ErrPC.String(),
+		StagedMessage:   formatValue(state.StagedMessage),
+		TotalSteps:      a.totalSteps,
+		TotalGas:        a.totalGas,
+		PendingMessages: len(a.inboxMessages),
+	}
+	data, err := json.MarshalIndent(view, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printValue prints a single value in full
+func (a *App) printValue(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected register, static, staged, stack or auxstack")
+	}
+	state := a.mach.State()
+	var stack []value.Value
+	switch args[0] {
+	case "register":
+		fmt.Println(state.Register)
+		return nil
+	case "static":
+		fmt.Println(state.Static)
+		return nil
+	case "staged":
+		fmt.Println(state.StagedMessage)
+		return nil
+	case "stack":
+		stack = state.Stack
+	case "auxstack":
+		stack = state.AuxStack
+	default:
+		return fmt.Errorf("unknown value %v", args[0])
+	}
+	if len(args) != 2 {
+		return errors.New("expected stack index")
+	}
+	index, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	if index >= uint64(len(stack)) {
+		return fmt.Errorf("%v only has %v items", args[0], len(stack))
+	}
+	fmt.Println(stack[index])
+	return nil
+}
+
+func decodeResult(val value.Value) (interface{}, value.Value) {
+	res, err := evm.NewResultFromValue(val)
+	if err != nil {
+		return val, val
+	}
+	return res, res.AsValue()
+}
+
+// diffLogs compares the logs produced so far against the logs in the test
+// vector, decoding both as EVM results where possible
+func (a *App) diffLogs() {
+	if !a.hasVector {
+		fmt.Println("no test vector loaded")
+		return
+	}
+	mismatches := 0
+	for i := 0; i < len(a.logs) || i < len(a.avmLogs); i++ {
+		if i >= len(a.logs) {
+			fmt.Printf("log %v: not produced yet\n", i)
+			mismatches++
+			continue
+		}
+		calcRes, calcVal := decodeResult(a.logs[i])
+		if i >= len(a.avmLogs) {
+			fmt.Printf("log %v: unexpected %v\n", i, calcRes)
+			mismatches++
+			continue
+		}
+		res, val := decodeResult(a.avmLogs[i])
+		if !value.Eq(calcVal, val) {
+			fmt.Printf("log %v: wrong\n", i)
+			fmt.Println("  calculated:", calcRes)
+			fmt.Println("  correct:   ", res)
+			mismatches++
+		}
+	}
+	for i := 0; i < len(a.sends) || i < len(a.avmSends); i++ {
+		switch {
+		case i >= len(a.sends):
+			fmt.Printf("send %v: not produced yet\n", i)
+		case i >= len(a.avmSends):
+			fmt.Printf("send %v: unexpected %v\n", i, formatValue(a.sends[i]))
+		case !value.Eq(a.sends[i], a.avmSends[i]):
+			fmt.Printf("send %v: wrong\n", i)
+		default:
+			continue
+		}
+		mismatches++
+	}
+	fmt.Printf(
+		"%v logs and %v sends produced, %v logs and %v sends expected, %v mismatches\n",
+		len(a.logs),
+		len(a.sends),
+		len(a.avmLogs),
+		len(a.avmSends),
+		mismatches,
+	)
+}
+
+func (a *App) printLogs(args []string) error {
+	if len(args) == 0 {
+		for i, l := range a.logs {
+			res, _ := decodeResult(l)
+			fmt.Printf("%v: %v\n", i, res)
+		}
+		return nil
+	}
+	index, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	if index >= uint64(len(a.logs)) {
+		return fmt.Errorf("only %v logs produced", len(a.logs))
+	}
+	res, _ := decodeResult(a.logs[index])
+	fmt.Println(res)
+	return nil
+}
+
+func (a *App) handle(command string, args []string) error {
+	switch command {
+	case "step", "s":
+		count, err := parseCount(args, 1)
+		if err != nil {
+			return err
+		}
+		a.run(count)
+	case "continue", "c":
+		count, err := parseCount(args, runToEndSteps)
+		if err != nil {
+			return err
+		}
+		a.run(count)
+	case "break", "b":
+		cp, err := parseCodePoint(args)
+		if err != nil {
+			return err
+		}
+		a.breakpoints[cp] = true
+	case "delete", "d":
+		cp, err := parseCodePoint(args)
+		if err != nil {
+			return err
+		}
+		delete(a.breakpoints, cp)
+	case "breakpoints":
+		a.listBreakpoints()
+	case "state":
+		return a.printState()
+	case "stack":
+		count, err := parseCount(args, defaultStackCount)
+		if err != nil {
+			return err
+		}
+		printStack("stack", a.mach.State().Stack, count)
+	case "auxstack":
+		count, err := parseCount(args, defaultStackCount)
+		if err != nil {
+			return err
+		}
+		printStack("auxstack", a.mach.State().AuxStack, count)
+	case "register":
+		fmt.Println(formatValue(a.mach.State().Register))
+	case "static":
+		fmt.Println(formatValue(a.mach.State().Static))
+	case "print", "p":
+		return a.printValue(args)
+	case "logs":
+		return a.printLogs(args)
+	case "diff":
+		a.diffLogs()
+	default:
+		return fmt.Errorf("unknown command %v", command)
+	}
+	return nil
+}
+
+func (a *App) Execute(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	if err := a.handle(fields[0], fields[1:]); err != nil {
+		fmt.Println("error:", err)
+	}
+}
+
+func completer(d prompt.Document) []prompt.Suggest {
+	s := []prompt.Suggest{
+		{Text: "step", Description: "run [n] instructions"},
+		{Text: "continue", Description: "run until a breakpoint or the machine blocks"},
+		{Text: "break", Description: "add a breakpoint at [segment] pc"},
+		{Text: "delete", Description: "remove the breakpoint at [segment] pc"},
+		{Text: "breakpoints", Description: "list breakpoints"},
+		{Text: "state", Description: "show the machine state"},
+		{Text: "stack", Description: "show the top [n] stack items"},
+		{Text: "auxstack", Description: "show the top [n] auxstack items"},
+		{Text: "register", Description: "show the register"},
+		{Text: "static", Description: "show the static value"},
+		{Text: "print", Description: "print register, static, staged, stack i or auxstack i in full"},
+		{Text: "logs", Description: "show produced logs decoded as results"},
+		{Text: "diff", Description: "compare produced logs and sends against the test vector"},
+	}
+	return prompt.FilterHasPrefix(s, d.GetWordBeforeCursor(), true)
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	codeFile := fs.String("mexe", arbos.Path(), "path to the .mexe program to debug")
+	commands := fs.String("commands", "", "semicolon separated commands to run instead of starting the prompt")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: arb-avm-debug [flags] [testvector.aoslog]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	a, err := newApp(*codeFile, fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Loaded", *codeFile)
+	if a.hasVector {
+		log.Println("Loaded test vector with", len(a.inboxMessages), "messages")
+	}
+
+	if *commands != "" {
+		for _, line := range strings.Split(*commands, ";") {
+			a.Execute(line)
+		}
+		return
+	}
+
+	handleExit := prompt.OptionSetExitCheckerOnInput(func(line string, breakline bool) bool { return breakline && line == "exit" })
+	p := prompt.New(a.Execute, completer, handleExit)
+	p.Run()
+}
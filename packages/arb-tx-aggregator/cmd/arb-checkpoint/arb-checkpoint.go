@@ -0,0 +1,237 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/machineobserver"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/config"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/utils"
+)
+
+const usage = "usage: arb-checkpoint (export|import|fsck) [--config=path] [<archive>] [%v]"
+
+// Exports the aggregator's checkpoint database to an archive, or creates it
+// from an archive, so that a new aggregator doesn't need to process the
+// rollup from its creation. fsck checks a checkpoint database and can roll it
+// back to its newest consistent checkpoint
+func main() {
+	// Enable line numbers in logging
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if len(os.Args) < 2 {
+		log.Fatalf(usage, utils.RollupArgsString)
+	}
+	switch os.Args[1] {
+	case "export":
+		if err := exportCheckpoint(); err != nil {
+			log.Fatal(err)
+		}
+	case "import":
+		if err := importCheckpoint(); err != nil {
+			log.Fatal(err)
+		}
+	case "fsck":
+		if err := fsckCheckpoint(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf(usage, utils.RollupArgsString)
+	}
+}
+
+// loadConfig parses the subcommand's arguments and returns the
+// configuration along with the first argCount positional arguments
+func loadConfig(fs *flag.FlagSet, argCount int) (*config.Config, []string, error) {
+	configFlags := config.AddFlags(fs)
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() < argCount {
+		return nil, nil, fmt.Errorf(usage, utils.RollupArgsString)
+	}
+	cfg, err := configFlags.Load(os.LookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.ApplyRollupArgs(fs.Args()[argCount:]); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return cfg, fs.Args()[:argCount], nil
+}
+
+func printArchiveInfo(info *checkpointing.ArchiveInfo) {
+	fmt.Println("Rollup:", info.Rollup.Hex())
+	fmt.Println("Block:", info.BlockHeight, info.BlockHash.Hex())
+	for _, machineHash := range info.Machines {
+		fmt.Println("Machine:", machineHash.Hex())
+	}
+}
+
+func exportCheckpoint() error {
+	ctx := context.Background()
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	height := fs.Int64(
+		"block",
+		0,
+		"export the newest checkpoint at or below this L1 block (defaults to checkpoint.max_reorg_depth blocks before the head)",
+	)
+	cfg, args, err := loadConfig(fs, 1)
+	if err != nil {
+		return err
+	}
+	archivePath := args[0]
+
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
+	if err != nil {
+		return err
+	}
+	client := ethbridge.NewEthClient(ethclint)
+
+	maxHeight := common.NewTimeBlocksInt(*height)
+	if *height == 0 {
+		head, err := client.BlockIdForHeight(ctx, nil)
+		if err != nil {
+			return err
+		}
+		maxHeight = common.NewTimeBlocks(new(big.Int).Sub(head.Height.AsInt(), big.NewInt(cfg.Checkpoint.MaxReorgDepth)))
+	}
+
+	f, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := machineobserver.ExportCheckpoint(ctx, client, cfg.RollupAddress(), checkpointing.NewConfig(cfg), maxHeight, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+		return err
+	}
+	fmt.Println("Exported checkpoint to", archivePath)
+	printArchiveInfo(info)
+	return nil
+}
+
+func importCheckpoint() error {
+	ctx := context.Background()
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cfg, args, err := loadConfig(fs, 1)
+	if err != nil {
+		return err
+	}
+	archivePath := args[0]
+
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
+	if err != nil {
+		return err
+	}
+	client := ethbridge.NewEthClient(ethclint)
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := machineobserver.ImportCheckpoint(ctx, client, cfg.RollupAddress(), checkpointing.NewConfig(cfg), f)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Imported checkpoint into", cfg.CheckpointPath())
+	printArchiveInfo(info)
+	return nil
+}
+
+func fsckCheckpoint() error {
+	ctx := context.Background()
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	repair := fs.Bool(
+		"repair",
+		false,
+		"delete every checkpoint newer than the newest consistent checkpoint",
+	)
+	cfg, _, err := loadConfig(fs, 0)
+	if err != nil {
+		return err
+	}
+
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
+	if err != nil {
+		return err
+	}
+	client := ethbridge.NewEthClient(ethclint)
+
+	report, err := checkpointing.Fsck(ctx, client, cfg.RollupAddress(), checkpointing.NewConfig(cfg), *repair)
+	if report != nil {
+		printFsckReport(report)
+	}
+	if err != nil {
+		return err
+	}
+	if !*repair && report.Restorable != nil && len(report.Inconsistent()) > 0 {
+		fmt.Println("Run with -repair to roll back to", report.Restorable)
+	}
+	return nil
+}
+
+func printFsckReport(report *checkpointing.FsckReport) {
+	fmt.Println("Checkpoints:", len(report.Checkpoints))
+	for _, status := range report.NonCanonical() {
+		fmt.Println("Not in L1 chain:", status.BlockId)
+	}
+	for _, h := range report.OrphanedMachines {
+		fmt.Println("Orphaned machine:", h)
+	}
+	for _, h := range report.OrphanedValues {
+		fmt.Println("Orphaned value:", h)
+	}
+	for _, status := range report.Inconsistent() {
+		if status.Err != nil {
+			fmt.Println("Unreadable:", status.BlockId, status.Err)
+			continue
+		}
+		fmt.Println("Incomplete:", status.BlockId)
+		for _, h := range status.MissingValues {
+			fmt.Println("  missing value", h)
+		}
+		for _, h := range status.MissingMachines {
+			fmt.Println("  missing machine", h)
+		}
+	}
+	if report.Restorable != nil {
+		fmt.Println("Newest consistent checkpoint:", report.Restorable)
+	} else if len(report.Checkpoints) > 0 {
+		fmt.Println("No consistent checkpoint in the L1 chain")
+	}
+	for _, blockId := range report.RolledBack {
+		fmt.Println("Rolled back:", blockId)
+	}
+}
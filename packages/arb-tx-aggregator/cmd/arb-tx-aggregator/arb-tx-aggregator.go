@@ -20,17 +20,22 @@ import (
 	"context"
 	"flag"
 	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/batcher"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/rpc"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/web3"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
 	"log"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	utils2 "github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/utils"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/config"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/utils"
 	//_ "net/http/pprof"
 )
@@ -41,17 +46,38 @@ func main() {
 
 	ctx := context.Background()
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
-	walletArgs := utils.AddWalletFlags(fs)
-	rpcVars := utils2.AddRPCFlags(fs)
-	keepPendingState := fs.Bool("pending", false, "enable pending state tracking")
-
-	maxBatchTime := fs.Int64(
-		"maxBatchTime",
-		10,
-		"maxBatchTime=NumSeconds",
+	configFlags := config.AddFlags(fs)
+	configFlags.Alias("password", "wallet.password", "password=pass")
+	configFlags.Alias("gasprice", "wallet.gas_price", "gasprice=FloatInGwei")
+	configFlags.Alias("pending", "aggregator.pending", "enable pending state tracking")
+	configFlags.Alias("maxBatchTime", "aggregator.max_batch_time", "maxBatchTime=NumSeconds")
+	configFlags.Alias("forward-url", "aggregator.forward_url", "url of another aggregator to send transactions through")
+	configFlags.Alias(
+		"backfill-tx-index",
+		"aggregator.backfill_tx_index",
+		"index the transaction hashes of blocks saved by an older version",
 	)
-
-	forwardTxURL := fs.String("forward-url", "", "url of another aggregator to send transactions through")
+	configFlags.Alias(
+		"exportable-checkpoints",
+		"aggregator.exportable_checkpoints",
+		"replay confirmed nodes to save checkpoints that arb-checkpoint export accepts",
+	)
+	configFlags.Alias(
+		"ordering",
+		"aggregator.ordering",
+		"order to add queued transactions to batches (random, gasprice or fifo)",
+	)
+	configFlags.Alias("cert", "rpc.cert_file", "path to certificate file (if using ssl)")
+	configFlags.Alias("privkey", "rpc.key_file", "path to private key file (if using ssl)")
+	configFlags.Alias("rpc-addr", "rpc.http_addr", "address to serve http rpc on (disabled if empty)")
+	configFlags.Alias("ws-addr", "rpc.ws_addr", "address to serve websocket rpc on (disabled if empty)")
+	configFlags.Alias("rpc-api", "rpc.http_api", "comma separated namespaces exposed over http rpc")
+	configFlags.Alias("ws-api", "rpc.ws_api", "comma separated namespaces exposed over websocket rpc")
+	configFlags.Alias("rpc-cors", "rpc.cors", "comma separated origins browsers may send rpc requests from")
+	configFlags.Alias("rpc-vhosts", "rpc.vhosts", "comma separated host names accepted by the http rpc")
+	configFlags.Alias("rpc-ratelimit", "rpc.rate_limit", "requests per second accepted from each ip (0 for unlimited)")
+	configFlags.Alias("rpc-ratelimit-burst", "rpc.rate_burst", "requests accepted at once from each ip when rate limited")
+	configFlags.Alias("metrics-addr", "metrics.addr", "address to serve prometheus metrics on (disabled if empty)")
 
 	//go http.ListenAndServe("localhost:6060", nil)
 
@@ -60,29 +86,44 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if fs.NArg() != 3 {
+	cfg, err := configFlags.Load(os.LookupEnv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.ApplyRollupArgs(fs.Args()); err != nil {
 		log.Fatalf(
-			"usage: arb-tx-aggregator [--maxBatchTime=NumSeconds] %v %v",
+			"usage: arb-tx-aggregator [--config=path] [--maxBatchTime=NumSeconds] %v %v",
 			utils.WalletArgsString,
 			utils.RollupArgsString,
 		)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+	log.Printf("Configuration:\n%v", cfg.Redacted())
+
+	rollupAddress := cfg.RollupAddress()
+
+	metrics.StartServer(cfg.Metrics.Addr)
 
-	rollupArgs := utils.ParseRollupCommand(fs, 0)
+	ordering, err := batcher.ParseOrderingPolicy(cfg.Aggregator.Ordering)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	ethclint, err := ethutils.NewRPCEthClient(rollupArgs.EthURL)
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Println("Launching aggregator for chain", rollupArgs.Address, "with chain id", message.ChainAddressToID(rollupArgs.Address))
+	log.Println("Launching aggregator for chain", rollupAddress, "with chain id", message.ChainAddressToID(rollupAddress))
 
 	var batcherMode rpc.BatcherMode
-	if *forwardTxURL != "" {
-		log.Println("Aggregator starting in forwarder mode sending transactions to", *forwardTxURL)
-		batcherMode = rpc.ForwarderBatcherMode{NodeURL: *forwardTxURL}
+	if cfg.Aggregator.ForwardURL != "" {
+		log.Println("Aggregator starting in forwarder mode sending transactions to", cfg.Aggregator.ForwardURL)
+		batcherMode = rpc.ForwarderBatcherMode{NodeURL: cfg.Aggregator.ForwardURL}
 	} else {
-		auth, err := utils.GetKeystore(rollupArgs.ValidatorFolder, walletArgs, fs)
+		auth, err := utils.GetKeystoreWithPassword(cfg.Rollup.Folder, cfg.Wallet.Password, cfg.Wallet.GasPrice)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -98,26 +139,25 @@ func main() {
 			log.Fatal(err)
 		}
 
-		if *keepPendingState {
-			batcherMode = rpc.StatefulBatcherMode{Auth: auth}
+		if cfg.Aggregator.Pending {
+			batcherMode = rpc.StatefulBatcherMode{Auth: auth, Ordering: ordering}
 		} else {
-			batcherMode = rpc.StatelessBatcherMode{Auth: auth}
+			batcherMode = rpc.StatelessBatcherMode{Auth: auth, Ordering: ordering}
 		}
 	}
 
-	contractFile := filepath.Join(rollupArgs.ValidatorFolder, "contract.mexe")
-	dbPath := filepath.Join(rollupArgs.ValidatorFolder, "checkpoint_db")
+	contractFile := filepath.Join(cfg.Rollup.Folder, "contract.mexe")
 
 	if err := rpc.LaunchAggregator(
 		ctx,
 		ethclint,
-		rollupArgs.Address,
+		rollupAddress,
 		contractFile,
-		dbPath,
-		"8547",
-		"8548",
-		rpcVars,
-		time.Duration(*maxBatchTime)*time.Second,
+		checkpointing.NewConfig(cfg),
+		utils2.NewRPCConfig(cfg.RPC, web3.PublicNamespaces),
+		time.Duration(cfg.Aggregator.MaxBatchTime)*time.Second,
+		cfg.Aggregator.BackfillTxIndex,
+		cfg.Aggregator.ExportableCheckpoints,
 		batcherMode,
 	); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package machineobserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/txdb"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+// eventFetchSize is the number of blocks of rollup events requested at once
+// when finding the confirmed node
+var eventFetchSize = big.NewInt(10000)
+
+// ExportCheckpoint writes the newest checkpoint of the observer's database
+// at or below maxHeight to w. The checkpoint isn't necessarily at a block
+// where a node was confirmed. Instead the machine of the latest node
+// confirmed at or before its block must be in the checkpoint, which requires
+// the observer to have run with exportable checkpoints. The observer must
+// not be running
+func ExportCheckpoint(
+	ctx context.Context,
+	clnt arbbridge.ArbClient,
+	rollupAddr common.Address,
+	checkpointConfig checkpointing.Config,
+	maxHeight *common.TimeBlocks,
+	w io.Writer,
+) (*checkpointing.ArchiveInfo, error) {
+	return checkpointing.ExportArchive(
+		ctx,
+		clnt,
+		rollupAddr,
+		checkpointConfig,
+		maxHeight,
+		archiveVerifier(ctx, clnt, rollupAddr),
+		w,
+	)
+}
+
+// ImportCheckpoint creates the observer's database from an archive written
+// by ExportCheckpoint after verifying it against L1. RunObserver then
+// resumes from the archived block
+func ImportCheckpoint(
+	ctx context.Context,
+	clnt arbbridge.ArbClient,
+	rollupAddr common.Address,
+	checkpointConfig checkpointing.Config,
+	r io.Reader,
+) (*checkpointing.ArchiveInfo, error) {
+	return checkpointing.ImportArchive(
+		rollupAddr,
+		checkpointConfig,
+		archiveVerifier(ctx, clnt, rollupAddr),
+		r,
+	)
+}
+
+// archiveVerifier checks that the archived block is part of the canonical L1
+// chain, that the checkpointed machines are in the archive and that the
+// archived state, including the machine of the confirmed node, agrees with
+// the latest node confirmed at that block
+func archiveVerifier(ctx context.Context, clnt arbbridge.ArbClient, rollupAddr common.Address) checkpointing.ArchiveVerifier {
+	return func(info *checkpointing.ArchiveInfo, cp *checkpointing.IndexedCheckpointer) error {
+		blockId := info.BlockId()
+		onchainId, err := clnt.BlockIdForHeight(ctx, blockId.Height)
+		if err != nil {
+			return err
+		}
+		if !onchainId.Equals(blockId) {
+			return fmt.Errorf("archived block %v isn't in the L1 chain", blockId)
+		}
+
+		machineHashes, err := txdb.CheckpointMachineHashes(info.Contents)
+		if err != nil {
+			return err
+		}
+		archived := make(map[common.Hash]bool)
+		for _, machineHash := range info.Machines {
+			archived[common.NewHashFromEth(machineHash)] = true
+		}
+		for _, machineHash := range machineHashes {
+			if !archived[machineHash] {
+				return fmt.Errorf("checkpointed machine %v isn't in the archive", machineHash)
+			}
+		}
+
+		confirmed, err := confirmedAssertion(ctx, clnt, rollupAddr, blockId.Height)
+		if err != nil {
+			return err
+		}
+		db := txdb.New(clnt, cp, cp.GetAggregatorStore(), rollupAddr)
+		return db.VerifyCheckpoint(info.Contents, confirmed)
+	}
+}
+
+// confirmedAssertion returns the assertion of the latest valid node
+// confirmed at or before height, or nil if none has been confirmed
+func confirmedAssertion(
+	ctx context.Context,
+	clnt arbbridge.ArbClient,
+	rollupAddr common.Address,
+	height *common.TimeBlocks,
+) (*arbbridge.AssertedEvent, error) {
+	rollupWatcher, err := clnt.NewRollupWatcher(rollupAddr)
+	if err != nil {
+		return nil, err
+	}
+	_, eventCreated, _, _, err := rollupWatcher.GetCreationInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assertions := make(map[common.Hash]arbbridge.AssertedEvent)
+	var confirmed *arbbridge.AssertedEvent
+	for start := eventCreated.BlockId.Height.AsInt(); start.Cmp(height.AsInt()) <= 0; start = new(big.Int).Add(start, eventFetchSize) {
+		end := new(big.Int).Add(start, eventFetchSize)
+		end = end.Sub(end, big.NewInt(1))
+		if end.Cmp(height.AsInt()) > 0 {
+			end = height.AsInt()
+		}
+		events, err := rollupWatcher.GetAllEvents(ctx, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			switch ev := ev.(type) {
+			case arbbridge.AssertedEvent:
+				assertions[ev.ValidLeafHash] = ev
+			case arbbridge.ConfirmedEvent:
+				if assertion, ok := assertions[ev.NodeHash]; ok {
+					confirmed = &assertion
+				}
+			}
+		}
+	}
+	return confirmed, nil
+}
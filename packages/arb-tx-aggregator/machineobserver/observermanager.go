@@ -23,15 +23,54 @@ import (
 	"math/big"
 	"time"
 
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
 	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/txdb"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/observer"
 )
 
-const defaultMaxReorgDepth = 100
+var (
+	metricsRegistry = metrics.NewRegistry("observer")
+
+	// lagGauge is the number of L1 blocks the observer is behind the head
+	lagGauge = gethmetrics.NewRegisteredGauge("lag", metricsRegistry)
+)
+
+// lagSampleInterval is how often the L1 head is fetched to report the lag
+// while following new blocks
+const lagSampleInterval = time.Second * 15
+
+func updateLag(processed *common.BlockId, head *common.BlockId) {
+	lagGauge.Update(new(big.Int).Sub(head.Height.AsInt(), processed.Height.AsInt()).Int64())
+}
+
+// sampleLag periodically reports how far the latest block processed by db is
+// behind the L1 head until ctx is done
+func sampleLag(ctx context.Context, clnt arbbridge.ChainTimeGetter, db *txdb.TxDB) {
+	ticker := time.NewTicker(lagSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			processed := db.LatestBlockId()
+			if processed == nil {
+				continue
+			}
+			// The lag is only reported, so failing to get the head isn't an
+			// error
+			if head, err := clnt.BlockIdForHeight(ctx, nil); err == nil {
+				updateLag(processed, head)
+			}
+		}
+	}
+}
 
 func ensureInitialized(
 	ctx context.Context,
@@ -108,7 +147,8 @@ func ensureInitialized(
 		}
 	}
 
-	if err := db.AddMessages(ctx, events, eventCreated.BlockId); err != nil {
+	// No assertions can be made in the block the chain was created
+	if err := db.AddMessages(ctx, events, nil, eventCreated.BlockId); err != nil {
 		return err
 	}
 
@@ -120,12 +160,13 @@ func RunObserver(
 	rollupAddr common.Address,
 	clnt arbbridge.ArbClient,
 	executablePath string,
-	dbPath string,
+	checkpointConfig checkpointing.Config,
+	backfillTxIndex bool,
+	exportableCheckpoints bool,
 ) (*txdb.TxDB, error) {
 	cp, err := checkpointing.NewIndexedCheckpointer(
 		rollupAddr,
-		dbPath,
-		big.NewInt(defaultMaxReorgDepth),
+		checkpointConfig,
 		false,
 	)
 	if err != nil {
@@ -149,11 +190,26 @@ func RunObserver(
 	}
 
 	db := txdb.New(clnt, cp, cp.GetAggregatorStore(), rollupAddr)
+	if exportableCheckpoints {
+		db.TrackConfirmedMachine()
+	}
 
 	if err := ensureInitialized(ctx, cp, db, clnt, rollupAddr); err != nil {
 		return nil, err
 	}
 
+	// The backfill writes to the same store as the observer, so it must finish
+	// before the observer starts
+	if backfillTxIndex {
+		log.Println("Backfilling transaction hash index")
+		if err := db.BackfillTxHashIndex(ctx); err != nil {
+			return nil, err
+		}
+		log.Println("Finished backfilling transaction hash index")
+	}
+
+	go sampleLag(ctx, clnt, db)
+
 	go func() {
 		for {
 			runCtx, cancelFunc := context.WithCancel(ctx)
@@ -194,14 +250,19 @@ func RunObserver(
 					if err != nil {
 						return errors2.Wrap(err, "Manager hit error doing fast catchup")
 					}
+					rollupEvents, err := rollupWatcher.GetAllEvents(runCtx, start, fetchEnd)
+					if err != nil {
+						return errors2.Wrap(err, "Manager hit error getting rollup events in fast catchup")
+					}
 
 					endBlock, err := clnt.BlockIdForHeight(ctx, common.NewTimeBlocks(fetchEnd))
 					if err != nil {
 						return errors2.Wrap(err, "error getting end block in fast catchup")
 					}
-					if err := db.AddMessages(runCtx, inboxDeliveredEvents, endBlock); err != nil {
+					if err := db.AddMessages(runCtx, inboxDeliveredEvents, rollupEvents, endBlock); err != nil {
 						return errors2.Wrap(err, "error adding messages to db")
 					}
+					updateLag(endBlock, currentOnChain)
 				}
 
 				latest := db.LatestBlockId()
@@ -222,7 +283,12 @@ func RunObserver(
 						return errors2.Wrapf(err, "manager hit error getting inbox events with block %v", blockId)
 					}
 
-					if err := db.AddMessages(runCtx, inboxEvents, blockId); err != nil {
+					rollupEvents, err := rollupWatcher.GetEvents(runCtx, blockId, timestamp)
+					if err != nil {
+						return errors2.Wrapf(err, "manager hit error getting rollup events with block %v", blockId)
+					}
+
+					if err := db.AddMessages(runCtx, inboxEvents, rollupEvents, blockId); err != nil {
 						return errors2.Wrap(err, "error adding messages to db")
 					}
 				}
@@ -13,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
@@ -139,7 +140,9 @@ func TestReorg(t *testing.T) {
 		rollupAddress,
 		arbbridge.NewStressTestClient(ethbridge.NewEthClient(l1Client), time.Second),
 		arbos.Path(),
-		dbPath,
+		checkpointing.Config{DatabasePath: dbPath, MaxReorgHeight: big.NewInt(100)},
+		false,
+		false,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -19,10 +19,13 @@ package rpc
 import (
 	"context"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/aggregator"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/batcher"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/machineobserver"
@@ -33,6 +36,27 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
 )
 
+// resolveBatcherStatePath returns the file that transactions accepted by the batcher
+// are saved in so that they aren't lost if the aggregator restarts. It is kept
+// next to the checkpoint database rather than inside it, so that it survives
+// the database being recreated and isn't included in exported archives. State
+// saved inside the database directory by older versions is moved out
+func resolveBatcherStatePath(checkpointConfig checkpointing.Config, rollupAddress common.Address) (string, error) {
+	databasePath, err := checkpointConfig.ResolveDatabasePath(rollupAddress)
+	if err != nil {
+		return "", err
+	}
+	statePath := filepath.Clean(databasePath) + "-batcher.json"
+	legacyPath := filepath.Join(databasePath, "batcher_state.json")
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		return statePath, err
+	}
+	if err := os.Rename(legacyPath, statePath); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return statePath, nil
+}
+
 type BatcherMode interface {
 	isBatcherMode()
 }
@@ -44,13 +68,15 @@ type ForwarderBatcherMode struct {
 func (b ForwarderBatcherMode) isBatcherMode() {}
 
 type StatefulBatcherMode struct {
-	Auth *bind.TransactOpts
+	Auth     *bind.TransactOpts
+	Ordering batcher.OrderingPolicy
 }
 
 func (b StatefulBatcherMode) isBatcherMode() {}
 
 type StatelessBatcherMode struct {
-	Auth *bind.TransactOpts
+	Auth     *bind.TransactOpts
+	Ordering batcher.OrderingPolicy
 }
 
 func (b StatelessBatcherMode) isBatcherMode() {}
@@ -60,15 +86,22 @@ func LaunchAggregator(
 	client ethutils.EthClient,
 	rollupAddress common.Address,
 	executable string,
-	dbPath string,
-	web3RPCPort string,
-	web3WSPort string,
-	flags utils2.RPCFlags,
+	checkpointConfig checkpointing.Config,
+	rpcConfig utils2.RPCConfig,
 	maxBatchTime time.Duration,
+	backfillTxIndex bool,
+	exportableCheckpoints bool,
 	batcherMode BatcherMode,
 ) error {
+	// The batcher's state is resolved first since starting the observer can
+	// recreate the checkpoint database
+	batcherStatePath, err := resolveBatcherStatePath(checkpointConfig, rollupAddress)
+	if err != nil {
+		return err
+	}
+
 	arbClient := ethbridge.NewEthClient(client)
-	db, err := machineobserver.RunObserver(ctx, rollupAddress, arbClient, executable, dbPath)
+	db, err := machineobserver.RunObserver(ctx, rollupAddress, arbClient, executable, checkpointConfig, backfillTxIndex, exportableCheckpoints)
 	if err != nil {
 		return err
 	}
@@ -95,32 +128,44 @@ func LaunchAggregator(
 		if err != nil {
 			return err
 		}
-		batch = batcher.NewStatelessBatcher(ctx, rollupAddress, client, globalInbox, maxBatchTime)
+		batch, err = batcher.NewStatelessBatcher(ctx, db, rollupAddress, client, globalInbox, maxBatchTime, batcherMode.Ordering, batcherStatePath)
+		if err != nil {
+			return err
+		}
 	case StatefulBatcherMode:
 		authClient := ethbridge.NewEthAuthClient(client, batcherMode.Auth)
 		globalInbox, err := authClient.NewGlobalInbox(inboxAddress, rollupAddress)
 		if err != nil {
 			return err
 		}
-		batch = batcher.NewStatefulBatcher(ctx, db, rollupAddress, client, globalInbox, maxBatchTime)
+		batch, err = batcher.NewStatefulBatcher(ctx, db, rollupAddress, client, globalInbox, maxBatchTime, batcherMode.Ordering, batcherStatePath)
+		if err != nil {
+			return err
+		}
 	}
 
 	srv := aggregator.NewServer(batch, rollupAddress, db)
 	errChan := make(chan error, 1)
 
-	web3Server, err := web3.GenerateWeb3Server(srv)
-	if err != nil {
-		return err
-	}
-
-	if web3RPCPort != "" {
+	// Each endpoint gets its own server so that it only exposes the
+	// namespaces allowed on it, but they all share one set of filters
+	filters := web3.NewFilters(srv)
+	if rpcConfig.HTTP.Addr != "" {
+		web3Server, err := web3.GenerateWeb3Server(srv, filters, rpcConfig.HTTP.Namespaces)
+		if err != nil {
+			return err
+		}
 		go func() {
-			errChan <- utils2.LaunchRPC(web3Server, web3RPCPort, flags)
+			errChan <- utils2.LaunchRPC(web3Server, rpcConfig)
 		}()
 	}
-	if web3WSPort != "" {
+	if rpcConfig.WS.Addr != "" {
+		web3Server, err := web3.GenerateWeb3Server(srv, filters, rpcConfig.WS.Namespaces)
+		if err != nil {
+			return err
+		}
 		go func() {
-			errChan <- utils2.LaunchWS(web3Server, web3WSPort, flags)
+			errChan <- utils2.LaunchWS(web3Server, rpcConfig)
 		}()
 	}
 
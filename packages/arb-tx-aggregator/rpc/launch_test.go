@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+func TestBatcherStatePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batcher-state-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	databasePath := filepath.Join(dir, "checkpoint")
+	if err := os.Mkdir(databasePath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	legacyPath := filepath.Join(databasePath, "batcher_state.json")
+	if err := ioutil.WriteFile(legacyPath, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := checkpointing.Config{DatabasePath: databasePath + "/"}
+	statePath, err := resolveBatcherStatePath(cfg, common.RandAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(statePath) != dir {
+		t.Error("batcher state saved in the checkpoint database", statePath)
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		t.Error("state saved by an older version wasn't moved", err)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("state saved by an older version left in the checkpoint database")
+	}
+
+	// Once moved, a new state in the database directory is ignored
+	if err := ioutil.WriteFile(legacyPath, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statePath2, err := resolveBatcherStatePath(cfg, common.RandAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(statePath2)
+	if err != nil || statePath2 != statePath || string(data) != "{}" {
+		t.Error("batcher state replaced after it was moved")
+	}
+}
@@ -222,6 +222,97 @@ func FunctionTableGetData(address common.Address, index *big.Int) []byte {
 	return makeFuncData(functionTableGetABI, address, index)
 }
 
+func unpackArbSysResult(funcABI abi.Method, res *evm.TxResult) ([]interface{}, error) {
+	vals, err := funcABI.Outputs.UnpackValues(res.ReturnData)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != len(funcABI.Outputs) {
+		return nil, errors.New("unexpected return param count")
+	}
+	return vals, nil
+}
+
+func parseIntResult(funcABI abi.Method, res *evm.TxResult) (*big.Int, error) {
+	vals, err := unpackArbSysResult(funcABI, res)
+	if err != nil {
+		return nil, err
+	}
+	val, ok := vals[0].(*big.Int)
+	if !ok {
+		return nil, errors.New("unexpected tx result")
+	}
+	return val, nil
+}
+
+func parseAddressTableAddressExistsResult(res *evm.TxResult) (bool, error) {
+	vals, err := unpackArbSysResult(addressTableAddressExistsABI, res)
+	if err != nil {
+		return false, err
+	}
+	exists, ok := vals[0].(bool)
+	if !ok {
+		return false, errors.New("unexpected tx result")
+	}
+	return exists, nil
+}
+
+func parseAddressTableLookupIndexResult(res *evm.TxResult) (common.Address, error) {
+	vals, err := unpackArbSysResult(addressTableLookupIndexABI, res)
+	if err != nil {
+		return common.Address{}, err
+	}
+	address, ok := vals[0].(ethcommon.Address)
+	if !ok {
+		return common.Address{}, errors.New("unexpected tx result")
+	}
+	return common.NewAddressFromEth(address), nil
+}
+
+func parseAddressTableCompressResult(res *evm.TxResult) ([]byte, error) {
+	vals, err := unpackArbSysResult(addressTableCompressABI, res)
+	if err != nil {
+		return nil, err
+	}
+	buf, ok := vals[0].([]byte)
+	if !ok {
+		return nil, errors.New("unexpected tx result")
+	}
+	return buf, nil
+}
+
+func parseAddressTableDecompressResult(res *evm.TxResult) (common.Address, *big.Int, error) {
+	vals, err := unpackArbSysResult(addressTableDecompressABI, res)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	address, ok := vals[0].(ethcommon.Address)
+	if !ok {
+		return common.Address{}, nil, errors.New("unexpected type for address")
+	}
+	offset, ok := vals[1].(*big.Int)
+	if !ok {
+		return common.Address{}, nil, errors.New("unexpected type for offset")
+	}
+	return common.NewAddressFromEth(address), offset, nil
+}
+
+func parseGetBLSPublicKeyResult(res *evm.TxResult) ([4]*big.Int, error) {
+	var key [4]*big.Int
+	vals, err := unpackArbSysResult(getBLSPublicKeyABI, res)
+	if err != nil {
+		return key, err
+	}
+	for i := range key {
+		val, ok := vals[i].(*big.Int)
+		if !ok {
+			return key, errors.New("unexpected type for key")
+		}
+		key[i] = val
+	}
+	return key, nil
+}
+
 func ParseFunctionTableGetDataResult(data []byte) (message.FunctionTableEntry, error) {
 	failRet := message.FunctionTableEntry{}
 	vals, err := functionTableGetABI.Outputs.UnpackValues(data)
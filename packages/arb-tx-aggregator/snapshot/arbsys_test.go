@@ -0,0 +1,117 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+func packedResult(t *testing.T, funcABI abi.Method, vals ...interface{}) *evm.TxResult {
+	t.Helper()
+	data, err := funcABI.Outputs.Pack(vals...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &evm.TxResult{ReturnData: data}
+}
+
+func TestParseArbSysResults(t *testing.T) {
+	size, err := parseIntResult(addressTableSizeABI, packedResult(t, addressTableSizeABI, big.NewInt(12)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Cmp(big.NewInt(12)) != 0 {
+		t.Error("wrong size", size)
+	}
+
+	exists, err := parseAddressTableAddressExistsResult(packedResult(t, addressTableAddressExistsABI, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("address should exist")
+	}
+
+	address := common.RandAddress()
+	lookedUp, err := parseAddressTableLookupIndexResult(packedResult(t, addressTableLookupIndexABI, address.ToEthAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookedUp != address {
+		t.Error("wrong address", lookedUp)
+	}
+
+	compressed, err := parseAddressTableCompressResult(packedResult(t, addressTableCompressABI, []byte{1, 2, 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(compressed, []byte{1, 2, 3}) {
+		t.Error("wrong compressed address", compressed)
+	}
+
+	decompressed, offset, err := parseAddressTableDecompressResult(
+		packedResult(t, addressTableDecompressABI, address.ToEthAddress(), big.NewInt(21)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressed != address || offset.Cmp(big.NewInt(21)) != 0 {
+		t.Error("wrong decompressed address", decompressed, offset)
+	}
+
+	key, err := parseGetBLSPublicKeyResult(
+		packedResult(t, getBLSPublicKeyABI, big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, val := range key {
+		if val.Cmp(big.NewInt(int64(i+1))) != 0 {
+			t.Error("wrong key component", i, val)
+		}
+	}
+
+	if _, err := parseAddressTableCompressResult(&evm.TxResult{ReturnData: []byte{1}}); err == nil {
+		t.Error("expected error for truncated result")
+	}
+}
+
+func TestParseFunctionTableGetDataResult(t *testing.T) {
+	res := packedResult(t, functionTableGetABI, big.NewInt(0x12345678), true, big.NewInt(50000))
+	entry, err := ParseFunctionTableGetDataResult(res.ReturnData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.FuncID != [4]byte{0x12, 0x34, 0x56, 0x78} {
+		t.Error("wrong func id", entry.FuncID)
+	}
+	if entry.Payable != 1 || entry.MaxGas.Cmp(big.NewInt(50000)) != 0 {
+		t.Error("wrong entry", entry)
+	}
+
+	res = packedResult(t, functionTableGetABI, big.NewInt(0x12), false, big.NewInt(0))
+	if _, err := ParseFunctionTableGetDataResult(res.ReturnData); err == nil {
+		t.Error("expected error for short func id")
+	}
+}
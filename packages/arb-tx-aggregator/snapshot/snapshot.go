@@ -26,6 +26,8 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-util/hashing"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 	"math/big"
 )
 
@@ -80,9 +82,14 @@ func (s *Snapshot) Height() *common.TimeBlocks {
 	return s.time.BlockNum
 }
 
+// NextRequestId returns the request id that an L2 message sent directly to the
+// inbox would get if it was the next message after this snapshot
+func (s *Snapshot) NextRequestId() common.Hash {
+	return hashing.SoliditySHA3(hashing.Uint256(s.chainId), hashing.Uint256(s.nextInboxSeqNum))
+}
+
 func (s *Snapshot) Call(msg message.Call, sender common.Address) (*evm.TxResult, error) {
-	targetHash := hashing.SoliditySHA3(hashing.Uint256(s.chainId), hashing.Uint256(s.nextInboxSeqNum))
-	return s.TryTx(message.NewSafeL2Message(msg), sender, targetHash)
+	return s.TryTx(message.NewSafeL2Message(msg), sender, s.NextRequestId())
 }
 
 func (s *Snapshot) TryTx(msg message.Message, sender common.Address, targetHash common.Hash) (*evm.TxResult, error) {
@@ -154,6 +161,94 @@ func (s *Snapshot) GetStorageAt(account common.Address, index *big.Int) (*big.In
 	return parseGetStorageAtResult(res)
 }
 
+// arbSysCall makes a call to ArbSys and fails unless the call succeeds
+func (s *Snapshot) arbSysCall(data []byte) (*evm.TxResult, error) {
+	res, err := s.BasicCall(data, common.NewAddressFromEth(arbos.ARB_SYS_ADDRESS))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValidResult(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Snapshot) AddressTableSize() (*big.Int, error) {
+	res, err := s.arbSysCall(AddressTableSizeData())
+	if err != nil {
+		return nil, err
+	}
+	return parseIntResult(addressTableSizeABI, res)
+}
+
+func (s *Snapshot) AddressTableAddressExists(address common.Address) (bool, error) {
+	res, err := s.arbSysCall(AddressTableAddressExistsData(address))
+	if err != nil {
+		return false, err
+	}
+	return parseAddressTableAddressExistsResult(res)
+}
+
+func (s *Snapshot) AddressTableLookup(address common.Address) (*big.Int, error) {
+	res, err := s.arbSysCall(AddressTableLookupData(address))
+	if err != nil {
+		return nil, err
+	}
+	return parseIntResult(addressTableLookupABI, res)
+}
+
+func (s *Snapshot) AddressTableLookupIndex(index *big.Int) (common.Address, error) {
+	res, err := s.arbSysCall(AddressTableLookupIndexData(index))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return parseAddressTableLookupIndexResult(res)
+}
+
+func (s *Snapshot) AddressTableCompress(address common.Address) ([]byte, error) {
+	res, err := s.arbSysCall(AddressTableCompressData(address))
+	if err != nil {
+		return nil, err
+	}
+	return parseAddressTableCompressResult(res)
+}
+
+// AddressTableDecompress decodes the compressed address starting at offset in
+// buf, returning the address and the offset after it
+func (s *Snapshot) AddressTableDecompress(buf []byte, offset *big.Int) (common.Address, *big.Int, error) {
+	res, err := s.arbSysCall(AddressTableDecompressData(buf, offset))
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return parseAddressTableDecompressResult(res)
+}
+
+// GetBLSPublicKey returns the x0, x1, y0 and y1 components of the BLS key
+// registered by address
+func (s *Snapshot) GetBLSPublicKey(address common.Address) ([4]*big.Int, error) {
+	res, err := s.arbSysCall(GetBLSPublicKeyData(address))
+	if err != nil {
+		return [4]*big.Int{}, err
+	}
+	return parseGetBLSPublicKeyResult(res)
+}
+
+func (s *Snapshot) FunctionTableSize(address common.Address) (*big.Int, error) {
+	res, err := s.arbSysCall(FunctionTableSizeData(address))
+	if err != nil {
+		return nil, err
+	}
+	return parseIntResult(functionTableSizeABI, res)
+}
+
+func (s *Snapshot) FunctionTableGet(address common.Address, index *big.Int) (message.FunctionTableEntry, error) {
+	res, err := s.arbSysCall(FunctionTableGetData(address, index))
+	if err != nil {
+		return message.FunctionTableEntry{}, err
+	}
+	return ParseFunctionTableGetDataResult(res.ReturnData)
+}
+
 func runTx(mach machine.Machine, msg inbox.InboxMessage, targetHash common.Hash) (*evm.TxResult, error) {
 	assertion, steps := mach.ExecuteAssertion(100000000, []inbox.InboxMessage{msg}, 0)
 
@@ -164,14 +259,17 @@ func runTx(mach machine.Machine, msg inbox.InboxMessage, targetHash common.Hash)
 		return nil, fmt.Errorf("can't produce solution since machine is blocked %v", br)
 	}
 
-	avmLogs := assertion.ParseLogs()
-	if len(avmLogs) == 0 {
+	results, err := parseResults(assertion)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
 		return nil, errors.New("no logs produced by tx")
 	}
 
-	res, err := evm.NewTxResultFromValue(avmLogs[len(avmLogs)-1])
-	if err != nil {
-		return nil, err
+	res, ok := results[len(results)-1].(*evm.TxResult)
+	if !ok {
+		return nil, errors.New("got block result but expected transaction")
 	}
 
 	if res.IncomingRequest.MessageID != targetHash {
@@ -180,3 +278,18 @@ func runTx(mach machine.Machine, msg inbox.InboxMessage, targetHash common.Hash)
 
 	return res, nil
 }
+
+// parseResults reads the results logged by an assertion straight from its
+// marshalled logs, which avoids building values for the byte stacks in them
+func parseResults(assertion *protocol.ExecutionAssertion) ([]evm.Result, error) {
+	d := value.NewDecoder(assertion.LogsData)
+	results := make([]evm.Result, 0, assertion.LogsCount)
+	for i := uint64(0); i < assertion.LogsCount; i++ {
+		res, err := evm.NewResultFromData(d)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+)
+
+// TraceResult contains the result of executing a message along with the
+// cost of the AVM execution which produced it
+type TraceResult struct {
+	Result *evm.TxResult
+	// Every transaction result produced by the message in the order they were
+	// produced. A message can produce several, such as a batch or a contract
+	// transaction that triggers other requests. The last one is Result
+	Results  []*evm.TxResult
+	ArbGas   uint64
+	AVMSteps uint64
+}
+
+// TraceMessage executes msg on a copy of the snapshot's machine and returns
+// the transaction results it produced. Unlike TryTx, the request id of the
+// result is not checked so this can be used to replay messages whose id
+// depended on their original position in the inbox
+func (s *Snapshot) TraceMessage(msg message.Message, sender common.Address) (*TraceResult, error) {
+	inboxMsg := message.NewInboxMessage(msg, sender, s.nextInboxSeqNum, s.time)
+	return traceTx(s.mach.Clone(), inboxMsg)
+}
+
+// ApplyMessage can only be called if the snapshot is uniquely owned
+// If an error is returned, s is unmodified
+func (s *Snapshot) ApplyMessage(msg message.Message, sender common.Address) (*TraceResult, error) {
+	mach := s.mach.Clone()
+	inboxMsg := message.NewInboxMessage(msg, sender, s.nextInboxSeqNum, s.time)
+	res, err := traceTx(mach, inboxMsg)
+	if err != nil {
+		return nil, err
+	}
+	s.mach = mach
+	s.nextInboxSeqNum = new(big.Int).Add(s.nextInboxSeqNum, big.NewInt(1))
+	return res, nil
+}
+
+func traceTx(mach machine.Machine, msg inbox.InboxMessage) (*TraceResult, error) {
+	assertion, steps := mach.ExecuteAssertion(100000000, []inbox.InboxMessage{msg}, 0)
+
+	if br := mach.IsBlocked(true); steps == 0 && br != nil {
+		return nil, fmt.Errorf("can't produce solution since machine is blocked %v", br)
+	}
+
+	return newTraceResult(assertion, steps)
+}
+
+func newTraceResult(assertion *protocol.ExecutionAssertion, steps uint64) (*TraceResult, error) {
+	avmResults, err := parseResults(assertion)
+	if err != nil {
+		return nil, err
+	}
+	var results []*evm.TxResult
+	for _, res := range avmResults {
+		if txRes, ok := res.(*evm.TxResult); ok {
+			results = append(results, txRes)
+		}
+	}
+	if len(results) == 0 {
+		return nil, errors.New("no tx result produced by message")
+	}
+	return &TraceResult{
+		Result:   results[len(results)-1],
+		Results:  results,
+		ArbGas:   assertion.NumGas,
+		AVMSteps: steps,
+	}, nil
+}
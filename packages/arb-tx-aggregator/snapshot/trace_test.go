@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func TestTraceResultKeepsEveryResult(t *testing.T) {
+	rand.Seed(4352)
+	results := []*evm.TxResult{evm.NewRandomResult(1), evm.NewRandomResult(0), evm.NewRandomResult(2)}
+	avmLogs := make([]value.Value, 0, len(results))
+	for i, res := range results {
+		res.IncomingRequest.Provenance = evm.Provenance{
+			L1SeqNum:      big.NewInt(5),
+			IndexInParent: big.NewInt(int64(i)),
+		}
+		avmLogs = append(avmLogs, res.AsValue())
+	}
+
+	assertion := protocol.NewExecutionAssertionFromValues(common.Hash{}, common.Hash{}, 100, 0, nil, avmLogs)
+	trace, err := newTraceResult(assertion, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.Results) != len(results) {
+		t.Fatal("wrong number of results", len(trace.Results))
+	}
+	for i, res := range trace.Results {
+		if res.IncomingRequest.MessageID != results[i].IncomingRequest.MessageID {
+			t.Error("result", i, "out of order")
+		}
+	}
+	if trace.Result != trace.Results[len(trace.Results)-1] {
+		t.Error("trace result isn't the last result")
+	}
+	if trace.ArbGas != 100 || trace.AVMSteps != 10 {
+		t.Error("wrong execution cost")
+	}
+
+	assertion = protocol.NewExecutionAssertionFromValues(common.Hash{}, common.Hash{}, 100, 0, nil, nil)
+	if _, err := newTraceResult(assertion, 10); err == nil {
+		t.Error("traced message which produced no result")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package txdb
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+func TestNewBlock(t *testing.T) {
+	rand.Seed(8723)
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := common.RandAddress()
+	txes := []*types.Transaction{
+		message.NewRandomSignedEthTx(chain, pk, 0),
+		message.NewRandomSignedEthTx(chain, pk, 1),
+	}
+	receipts := make([]*types.Receipt, 0, len(txes))
+	for i := range txes {
+		res := evm.NewRandomResult(int32(i + 1))
+		res.StartLogIndex = big.NewInt(0)
+		receipts = append(receipts, res.ToEthReceipt(common.Hash{}))
+	}
+
+	prev := &types.Header{Number: big.NewInt(4), Difficulty: big.NewInt(0)}
+	info := &evm.BlockInfo{
+		BlockNum:  big.NewInt(5),
+		Timestamp: big.NewInt(1000),
+		GasLimit:  big.NewInt(10000000),
+		BlockStats: &evm.OutputStatistics{
+			GasUsed: big.NewInt(60000),
+		},
+	}
+	l1BlockHash := common.RandHash()
+	root := common.RandHash().ToEthHash()
+	block := newBlock(prev, info, l1BlockHash, root, txes, receipts)
+	header := block.Header()
+
+	if header.ParentHash != prev.Hash() || header.Number.Cmp(info.BlockNum) != 0 {
+		t.Error("block isn't linked to its parent")
+	}
+	if header.Root != root {
+		t.Error("wrong state root")
+	}
+	if header.GasLimit != 10000000 || header.GasUsed != 60000 || header.Time != 1000 {
+		t.Error("wrong block stats")
+	}
+	if !bytes.Equal(header.Extra, l1BlockHash.Bytes()) {
+		t.Error("wrong l1 block hash")
+	}
+
+	// Clients verify blocks by recomputing these from the transactions and
+	// receipts they fetch
+	if header.TxHash != types.DeriveSha(types.Transactions(txes), new(trie.Trie)) {
+		t.Error("wrong transactions root")
+	}
+	if header.ReceiptHash != types.DeriveSha(types.Receipts(receipts), new(trie.Trie)) {
+		t.Error("wrong receipts root")
+	}
+	if header.Bloom != types.CreateBloom(receipts) {
+		t.Error("wrong logs bloom")
+	}
+
+	empty := newBlock(prev, info, l1BlockHash, ethcommon.Hash{}, nil, nil)
+	if empty.Root() != (ethcommon.Hash{}) || empty.TxHash() != types.EmptyRootHash {
+		t.Error("wrong roots for block without transactions")
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package txdb
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+// checkpointState is the state of the TxDB saved in each checkpoint. The
+// aggregator store is saved separately
+type checkpointState struct {
+	machineHash  common.Hash
+	lastInboxSeq *big.Int
+	confirmed    confirmedMachineState
+	progress     *rollupProgress
+}
+
+// checkpointVersion is the first byte of checkpoints which include the
+// machine of the confirmed node. Older checkpoints start with the machine hash
+// and the last inbox sequence number, optionally followed by the rollup
+// progress. All of those fields are multiples of 8 bytes long, so the lone
+// version byte makes the two layouts distinguishable by length
+const checkpointVersion = 1
+
+func marshalCheckpoint(
+	machineHash common.Hash,
+	lastInboxSeq *big.Int,
+	confirmed confirmedMachineState,
+	progress *rollupProgress,
+) []byte {
+	cpData := make([]byte, 65)
+	cpData[0] = checkpointVersion
+	copy(cpData[1:], machineHash[:])
+	copy(cpData[33:], math.U256Bytes(lastInboxSeq))
+	cpData = append(cpData, confirmed.marshal()...)
+	return append(cpData, progress.marshal()...)
+}
+
+func unmarshalCheckpoint(data []byte) (*checkpointState, error) {
+	if len(data)%8 == 0 {
+		return unmarshalLegacyCheckpoint(data)
+	}
+	if len(data) < 65 || data[0] != checkpointVersion {
+		return nil, errors.New("invalid checkpoint data")
+	}
+	var machineHash common.Hash
+	copy(machineHash[:], data[1:])
+	confirmed, progressData, err := unmarshalConfirmedMachineState(data[65:])
+	if err != nil {
+		return nil, err
+	}
+	progress, err := unmarshalRollupProgress(progressData)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointState{
+		machineHash:  machineHash,
+		lastInboxSeq: new(big.Int).SetBytes(data[33:65]),
+		confirmed:    confirmed,
+		progress:     progress,
+	}, nil
+}
+
+// unmarshalLegacyCheckpoint decodes a checkpoint saved before the machine of
+// the confirmed node was checkpointed. The confirmed machine is left unknown
+func unmarshalLegacyCheckpoint(data []byte) (*checkpointState, error) {
+	if len(data) < 64 {
+		return nil, errors.New("invalid checkpoint data")
+	}
+	var machineHash common.Hash
+	copy(machineHash[:], data)
+	progress, err := unmarshalRollupProgress(data[64:])
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointState{
+		machineHash:  machineHash,
+		lastInboxSeq: new(big.Int).SetBytes(data[32:64]),
+		confirmed:    confirmedMachineState{nodes: make(map[common.Hash]nodeAssertion)},
+		progress:     progress,
+	}, nil
+}
+
+// VerifyCheckpoint checks a checkpoint saved by a TxDB against the
+// assertion of the latest node confirmed on L1 at the checkpoint's block,
+// which is nil if no node had been confirmed. The checkpointed machine of
+// the confirmed node must have the hash the node asserted, or be the initial
+// machine if no node was confirmed. The checkpoint must also have the same
+// number of confirmed messages as the node and the aggregator store must
+// contain exactly the messages it committed to
+func (db *TxDB) VerifyCheckpoint(contents []byte, confirmed *arbbridge.AssertedEvent) error {
+	state, err := unmarshalCheckpoint(contents)
+	if err != nil {
+		return err
+	}
+	if state.confirmed.machineHash == (common.Hash{}) {
+		return errors.New("checkpoint doesn't include the machine of the confirmed node; the aggregator must run with -exportable-checkpoints to export it")
+	}
+	if confirmed != nil {
+		if state.confirmed.machineHash != confirmed.AfterMachineHash {
+			return fmt.Errorf(
+				"checkpointed machine %v doesn't match machine %v of node %v confirmed on chain",
+				state.confirmed.machineHash,
+				confirmed.AfterMachineHash,
+				confirmed.ValidLeafHash,
+			)
+		}
+	} else {
+		valueCache, err := cmachine.NewValueCache()
+		if err != nil {
+			return err
+		}
+		initialMach, err := db.checkpointer.GetInitialMachine(valueCache)
+		if err != nil {
+			return err
+		}
+		if state.confirmed.machineHash != initialMach.Hash() {
+			return fmt.Errorf(
+				"checkpointed machine %v isn't the initial machine %v although no node was confirmed",
+				state.confirmed.machineHash,
+				initialMach.Hash(),
+			)
+		}
+	}
+
+	confirmedCount := uint64(0)
+	if confirmed != nil {
+		confirmedCount = confirmed.BeforeMessageCount.Uint64() + confirmed.MessageCount
+	}
+	if state.progress.confirmedMessageCount != confirmedCount {
+		return fmt.Errorf(
+			"checkpoint has %v confirmed messages but %v were confirmed on chain",
+			state.progress.confirmedMessageCount,
+			confirmedCount,
+		)
+	}
+	if confirmed == nil || confirmed.MessageCount == 0 {
+		return nil
+	}
+	valid, err := db.assertionMessagesMatch(
+		confirmed.BeforeMessageCount.Uint64(),
+		confirmed.MessageCount,
+		confirmed.LastMessageHash,
+	)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("messages in checkpoint don't match node %v confirmed on chain", confirmed.ValidLeafHash)
+	}
+	return nil
+}
+
+// CheckpointMachineHashes returns the hashes of the machines saved in a
+// checkpoint by a TxDB, which are the TxDB's machine followed by the machine
+// of the latest confirmed node if the checkpoint includes it
+func CheckpointMachineHashes(contents []byte) ([]common.Hash, error) {
+	state, err := unmarshalCheckpoint(contents)
+	if err != nil {
+		return nil, err
+	}
+	if state.confirmed.machineHash == (common.Hash{}) {
+		return []common.Hash{state.machineHash}, nil
+	}
+	return []common.Hash{state.machineHash, state.confirmed.machineHash}, nil
+}
@@ -0,0 +1,188 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package txdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+// hashMachine is a machine which only knows its hash
+type hashMachine struct {
+	machine.Machine
+	hash common.Hash
+}
+
+func (m hashMachine) Hash() common.Hash {
+	return m.hash
+}
+
+// initialMachineCheckpointer only provides the initial machine
+type initialMachineCheckpointer struct {
+	checkpointing.RollupCheckpointer
+	mach machine.Machine
+}
+
+func (c initialMachineCheckpointer) GetInitialMachine(machine.ValueCache) (machine.Machine, error) {
+	return c.mach, nil
+}
+
+func TestCheckpointState(t *testing.T) {
+	p := newRollupProgress()
+	leaf := common.RandHash()
+	p.asserted(leaf, 4)
+	p.confirmed(leaf)
+	machineHash := common.RandHash()
+	confirmed := confirmedMachineState{
+		machineHash: common.RandHash(),
+		pendingHash: common.RandHash(),
+		nodes: map[common.Hash]nodeAssertion{
+			common.RandHash(): {
+				prevNode:             leaf,
+				numSteps:             100,
+				importedMessageCount: 2,
+				afterMachineHash:     common.RandHash(),
+			},
+		},
+	}
+
+	data := marshalCheckpoint(machineHash, big.NewInt(12), confirmed, p)
+	state, err := unmarshalCheckpoint(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.machineHash != machineHash || state.lastInboxSeq.Cmp(big.NewInt(12)) != 0 {
+		t.Error("wrong checkpoint state restored")
+	}
+	if state.confirmed.machineHash != confirmed.machineHash ||
+		state.confirmed.pendingHash != confirmed.pendingHash ||
+		len(state.confirmed.nodes) != 1 {
+		t.Error("wrong confirmed machine restored")
+	}
+	for leaf, assertion := range confirmed.nodes {
+		if state.confirmed.nodes[leaf] != assertion {
+			t.Error("wrong node assertion restored")
+		}
+	}
+	if state.progress.confirmedMessageCount != 4 {
+		t.Error("wrong progress restored")
+	}
+	hashes, err := CheckpointMachineHashes(data)
+	if err != nil || len(hashes) != 2 || hashes[0] != machineHash || hashes[1] != confirmed.machineHash {
+		t.Error("wrong machine hashes", hashes, err)
+	}
+	if _, err := unmarshalCheckpoint(data[:40]); err == nil {
+		t.Error("expected error for truncated checkpoint")
+	}
+	if _, err := unmarshalCheckpoint(data[:100]); err == nil {
+		t.Error("expected error for truncated confirmed machine")
+	}
+}
+
+func TestLegacyCheckpointState(t *testing.T) {
+	machineHash := common.RandHash()
+	p := newRollupProgress()
+	leaf := common.RandHash()
+	p.asserted(leaf, 4)
+	p.confirmed(leaf)
+
+	// Checkpoints were saved as the machine hash and last inbox sequence
+	// number, and later followed by the rollup progress
+	data := make([]byte, 64)
+	copy(data[:], machineHash[:])
+	copy(data[32:], math.U256Bytes(big.NewInt(12)))
+	for _, legacy := range [][]byte{data, append(data, p.marshal()...)} {
+		state, err := unmarshalCheckpoint(legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.machineHash != machineHash || state.lastInboxSeq.Cmp(big.NewInt(12)) != 0 {
+			t.Error("wrong legacy checkpoint state restored")
+		}
+		if state.confirmed.machineHash != (common.Hash{}) || len(state.confirmed.nodes) != 0 {
+			t.Error("legacy checkpoint has a confirmed machine")
+		}
+		confirmed, err := state.confirmed.restore(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confirmed.machineHash() != (common.Hash{}) {
+			t.Error("restored confirmed machine from legacy checkpoint")
+		}
+		hashes, err := CheckpointMachineHashes(legacy)
+		if err != nil || len(hashes) != 1 || hashes[0] != machineHash {
+			t.Error("wrong legacy machine hashes", hashes, err)
+		}
+	}
+
+	state, err := unmarshalCheckpoint(append(data, p.marshal()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.progress.confirmedMessageCount != 4 {
+		t.Error("wrong progress restored from legacy checkpoint")
+	}
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	initialHash := common.RandHash()
+	db := &TxDB{checkpointer: initialMachineCheckpointer{mach: hashMachine{hash: initialHash}}}
+
+	p := newRollupProgress()
+	leaf := common.RandHash()
+	p.asserted(leaf, 4)
+	p.confirmed(leaf)
+	initial := confirmedMachineState{machineHash: initialHash}
+
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), initial, newRollupProgress()), nil); err != nil {
+		t.Error(err)
+	}
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), initial, p), nil); err == nil {
+		t.Error("checkpoint with confirmed messages verified without confirmed node")
+	}
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), confirmedMachineState{}, newRollupProgress()), nil); err == nil {
+		t.Error("checkpoint verified without confirmed machine")
+	}
+	other := confirmedMachineState{machineHash: common.RandHash()}
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), other, newRollupProgress()), nil); err == nil {
+		t.Error("checkpoint verified with machine other than the initial machine")
+	}
+
+	node := &arbbridge.AssertedEvent{
+		AfterMachineHash:   common.RandHash(),
+		BeforeMessageCount: big.NewInt(4),
+	}
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), other, p), node); err == nil {
+		t.Error("checkpoint verified with wrong confirmed machine")
+	}
+	matching := confirmedMachineState{machineHash: node.AfterMachineHash}
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), matching, p), node); err != nil {
+		t.Error(err)
+	}
+	node.BeforeMessageCount = big.NewInt(1)
+	node.MessageCount = 4
+	if err := db.VerifyCheckpoint(marshalCheckpoint(common.RandHash(), big.NewInt(12), matching, p), node); err == nil {
+		t.Error("checkpoint verified with wrong confirmed message count")
+	}
+}
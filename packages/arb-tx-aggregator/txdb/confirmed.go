@@ -0,0 +1,262 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package txdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+)
+
+// confirmedMachine replays the assertions of the nodes confirmed on L1 to
+// keep the machine of the latest confirmed node. The TxDB's own machine also
+// runs the call server assertions used to produce blocks, so its hash never
+// matches a node's machine hash, while this one can be checked against it.
+// Without a machine, as when the TxDB isn't tracking it, nothing is recorded
+type confirmedMachine struct {
+	// mach is nil once replaying a confirmed node gave a different machine
+	// than the node asserted
+	mach machine.Machine
+	// Inbox messages delivered since the latest confirmed node, which the
+	// next confirmed nodes will import
+	pending      []inbox.InboxMessage
+	pendingStack *value.TupleValue
+	// Assertions of unconfirmed nodes, indexed by the node which will be
+	// confirmed if the assertion is valid
+	nodes map[common.Hash]nodeAssertion
+}
+
+// nodeAssertion is the part of an assertion needed to replay it
+type nodeAssertion struct {
+	prevNode             common.Hash
+	numSteps             uint64
+	importedMessageCount uint64
+	afterMachineHash     common.Hash
+}
+
+const nodeAssertionSize = 112
+
+func newConfirmedMachine(mach machine.Machine) *confirmedMachine {
+	return &confirmedMachine{
+		mach:         mach,
+		pendingStack: value.NewEmptyTuple(),
+		nodes:        make(map[common.Hash]nodeAssertion),
+	}
+}
+
+// machineHash returns the hash of the machine of the latest confirmed node,
+// or the zero hash if it isn't known
+func (c *confirmedMachine) machineHash() common.Hash {
+	if c.mach == nil {
+		return common.Hash{}
+	}
+	return c.mach.Hash()
+}
+
+func (c *confirmedMachine) addMessages(msgs []arbbridge.MessageDeliveredEvent) {
+	if c.mach == nil {
+		return
+	}
+	for _, msg := range msgs {
+		c.pending = append(c.pending, msg.Message)
+		c.pendingStack = value.NewTuple2(msg.Message.AsValue(), c.pendingStack)
+	}
+}
+
+func (c *confirmedMachine) setPending(msgs []inbox.InboxMessage) {
+	c.pending = append([]inbox.InboxMessage(nil), msgs...)
+	vals := make([]value.Value, 0, len(msgs))
+	for _, msg := range msgs {
+		vals = append(vals, msg.AsValue())
+	}
+	c.pendingStack = inbox.ListToStackValue(vals)
+}
+
+func (c *confirmedMachine) asserted(ev arbbridge.AssertedEvent) {
+	if c.mach == nil {
+		return
+	}
+	c.nodes[ev.ValidLeafHash] = nodeAssertion{
+		prevNode:             ev.PrevLeafHash,
+		numSteps:             ev.AssertionParams.NumSteps,
+		importedMessageCount: ev.AssertionParams.ImportedMessageCount.Uint64(),
+		afterMachineHash:     ev.AfterMachineHash,
+	}
+}
+
+// confirmed replays the assertion of node if it is a valid node. Confirming
+// an invalid node leaves the machine unchanged
+func (c *confirmedMachine) confirmed(node common.Hash) {
+	assertion, ok := c.nodes[node]
+	if !ok {
+		return
+	}
+	c.prune(node, assertion.prevNode)
+	if c.mach == nil {
+		return
+	}
+	if assertion.importedMessageCount > uint64(len(c.pending)) {
+		log.Println("Confirmed node", node, "imported", assertion.importedMessageCount, "messages but only", len(c.pending), "were delivered")
+		c.mach = nil
+		c.setPending(nil)
+		return
+	}
+	c.mach.ExecuteAssertion(assertion.numSteps, c.pending[:assertion.importedMessageCount], 0)
+	c.setPending(c.pending[assertion.importedMessageCount:])
+	if c.mach.Hash() != assertion.afterMachineHash {
+		log.Println("Replaying confirmed node", node, "gave machine", c.mach.Hash(), "but it asserted", assertion.afterMachineHash)
+		c.mach = nil
+		c.setPending(nil)
+	}
+}
+
+// prune forgets the assertions of node and of its siblings, which can no
+// longer be confirmed, along with the assertions built on the siblings. Nodes
+// abandoned by confirming an invalid node are kept since the invalid node's
+// parent isn't known
+func (c *confirmedMachine) prune(node common.Hash, prevNode common.Hash) {
+	stale := make(map[common.Hash]bool)
+	for leaf, assertion := range c.nodes {
+		if assertion.prevNode == prevNode && leaf != node {
+			stale[leaf] = true
+		}
+	}
+	delete(c.nodes, node)
+	for len(stale) > 0 {
+		children := make(map[common.Hash]bool)
+		for leaf, assertion := range c.nodes {
+			if stale[assertion.prevNode] {
+				children[leaf] = true
+			}
+		}
+		for leaf := range stale {
+			delete(c.nodes, leaf)
+		}
+		stale = children
+	}
+}
+
+// checkpoint adds the machine and pending messages to ctx and returns the
+// state which references them
+func (c *confirmedMachine) checkpoint(ctx *ckptcontext.CheckpointContext) confirmedMachineState {
+	var pendingHash common.Hash
+	if c.mach != nil {
+		ctx.AddMachine(c.mach)
+		ctx.AddValue(c.pendingStack)
+		pendingHash = c.pendingStack.Hash()
+	}
+	return confirmedMachineState{
+		machineHash: c.machineHash(),
+		pendingHash: pendingHash,
+		nodes:       c.nodes,
+	}
+}
+
+// confirmedMachineState is a confirmedMachine saved in a checkpoint
+type confirmedMachineState struct {
+	machineHash common.Hash
+	pendingHash common.Hash
+	nodes       map[common.Hash]nodeAssertion
+}
+
+// marshal encodes the state as the machine and pending message hashes, the
+// number of unconfirmed nodes and then each node and its assertion
+func (s confirmedMachineState) marshal() []byte {
+	data := make([]byte, 72, 72+len(s.nodes)*nodeAssertionSize)
+	copy(data[:32], s.machineHash[:])
+	copy(data[32:64], s.pendingHash[:])
+	binary.BigEndian.PutUint64(data[64:72], uint64(len(s.nodes)))
+	for leaf, assertion := range s.nodes {
+		var nodeData [nodeAssertionSize]byte
+		copy(nodeData[:32], leaf[:])
+		copy(nodeData[32:64], assertion.prevNode[:])
+		binary.BigEndian.PutUint64(nodeData[64:72], assertion.numSteps)
+		binary.BigEndian.PutUint64(nodeData[72:80], assertion.importedMessageCount)
+		copy(nodeData[80:112], assertion.afterMachineHash[:])
+		data = append(data, nodeData[:]...)
+	}
+	return data
+}
+
+// unmarshalConfirmedMachineState decodes a state from the start of data and
+// returns the rest of data
+func unmarshalConfirmedMachineState(data []byte) (confirmedMachineState, []byte, error) {
+	s := confirmedMachineState{nodes: make(map[common.Hash]nodeAssertion)}
+	if len(data) < 72 {
+		return s, nil, errors.New("invalid confirmed machine data")
+	}
+	copy(s.machineHash[:], data[:32])
+	copy(s.pendingHash[:], data[32:64])
+	nodeCount := binary.BigEndian.Uint64(data[64:72])
+	data = data[72:]
+	if nodeCount > uint64(len(data))/nodeAssertionSize {
+		return s, nil, errors.New("invalid confirmed machine data")
+	}
+	for i := uint64(0); i < nodeCount; i++ {
+		var leaf common.Hash
+		var assertion nodeAssertion
+		copy(leaf[:], data[:32])
+		copy(assertion.prevNode[:], data[32:64])
+		assertion.numSteps = binary.BigEndian.Uint64(data[64:72])
+		assertion.importedMessageCount = binary.BigEndian.Uint64(data[72:80])
+		copy(assertion.afterMachineHash[:], data[80:112])
+		s.nodes[leaf] = assertion
+		data = data[nodeAssertionSize:]
+	}
+	return s, data, nil
+}
+
+// restore loads the machine and pending messages referenced by the state
+func (s confirmedMachineState) restore(restoreCtx ckptcontext.RestoreContext) (*confirmedMachine, error) {
+	c := newConfirmedMachine(nil)
+	for leaf, assertion := range s.nodes {
+		c.nodes[leaf] = assertion
+	}
+	if s.machineHash == (common.Hash{}) {
+		return c, nil
+	}
+	mach, err := restoreCtx.GetMachine(s.machineHash)
+	if err != nil {
+		return nil, err
+	}
+	pendingVal, err := restoreCtx.GetValue(s.pendingHash)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := inbox.StackValueToList(pendingVal)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]inbox.InboxMessage, 0, len(vals))
+	for _, val := range vals {
+		msg, err := inbox.NewInboxMessageFromValue(val)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	c.mach = mach
+	c.setPending(msgs)
+	return c, nil
+}
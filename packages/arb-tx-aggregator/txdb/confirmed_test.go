@@ -0,0 +1,168 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package txdb
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/ckptcontext"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
+)
+
+// replayMachine records the messages it executes and takes the hash of the
+// last one as its own
+type replayMachine struct {
+	machine.Machine
+	hash     common.Hash
+	executed []inbox.InboxMessage
+}
+
+func (m *replayMachine) Hash() common.Hash {
+	return m.hash
+}
+
+func (m *replayMachine) Clone() machine.Machine {
+	ret := *m
+	ret.executed = append([]inbox.InboxMessage(nil), m.executed...)
+	return &ret
+}
+
+func (m *replayMachine) ExecuteAssertion(
+	maxSteps uint64,
+	messages []inbox.InboxMessage,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	for _, msg := range messages {
+		m.executed = append(m.executed, msg)
+		m.hash = msg.CommitmentHash()
+	}
+	return nil, maxSteps
+}
+
+func assertNode(c *confirmedMachine, prev common.Hash, imported int64, after common.Hash) common.Hash {
+	leaf := common.RandHash()
+	c.asserted(arbbridge.AssertedEvent{
+		PrevLeafHash: prev,
+		AssertionParams: &valprotocol.AssertionParams{
+			NumSteps:             10,
+			ImportedMessageCount: big.NewInt(imported),
+		},
+		AfterMachineHash: after,
+		ValidLeafHash:    leaf,
+	})
+	return leaf
+}
+
+func TestConfirmedMachine(t *testing.T) {
+	mach := &replayMachine{hash: common.RandHash()}
+	c := newConfirmedMachine(mach)
+
+	msgs := make([]arbbridge.MessageDeliveredEvent, 0)
+	for i := 0; i < 3; i++ {
+		msgs = append(msgs, arbbridge.MessageDeliveredEvent{Message: inbox.NewRandomInboxMessage()})
+	}
+	c.addMessages(msgs)
+
+	root := common.RandHash()
+	first := assertNode(c, root, 2, msgs[1].Message.CommitmentHash())
+	sibling := assertNode(c, root, 1, msgs[0].Message.CommitmentHash())
+	siblingChild := assertNode(c, sibling, 1, msgs[1].Message.CommitmentHash())
+	second := assertNode(c, first, 1, common.RandHash())
+
+	// Confirming an invalid node doesn't change the machine
+	c.confirmed(common.RandHash())
+	if len(mach.executed) != 0 || len(c.nodes) != 4 {
+		t.Fatal("confirming an invalid node replayed an assertion")
+	}
+
+	c.confirmed(first)
+	if len(mach.executed) != 2 || !mach.executed[1].Equals(msgs[1].Message) {
+		t.Fatal("confirmed node replayed wrong messages")
+	}
+	if c.machineHash() != msgs[1].Message.CommitmentHash() {
+		t.Error("wrong confirmed machine hash")
+	}
+	if len(c.pending) != 1 || !c.pending[0].Equals(msgs[2].Message) {
+		t.Error("wrong pending messages after confirmation")
+	}
+	if _, ok := c.nodes[sibling]; ok {
+		t.Error("sibling of confirmed node wasn't pruned")
+	}
+	if _, ok := c.nodes[siblingChild]; ok {
+		t.Error("child of pruned sibling wasn't pruned")
+	}
+	if _, ok := c.nodes[second]; !ok || len(c.nodes) != 1 {
+		t.Error("child of confirmed node was pruned")
+	}
+
+	ctx := ckptcontext.NewCheckpointContext()
+	state := c.checkpoint(ctx)
+	restored, err := state.restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.machineHash() != c.machineHash() ||
+		len(restored.pending) != 1 ||
+		!restored.pending[0].Equals(msgs[2].Message) ||
+		restored.nodes[second] != c.nodes[second] {
+		t.Error("wrong confirmed machine restored")
+	}
+
+	// The second node asserted a machine that replaying it doesn't give
+	c.confirmed(second)
+	if c.mach != nil || c.machineHash() != (common.Hash{}) {
+		t.Error("confirmed machine kept after replay mismatch")
+	}
+	c.addMessages(msgs)
+	if len(c.pending) != 0 {
+		t.Error("messages kept without a confirmed machine")
+	}
+	if state := c.checkpoint(ckptcontext.NewCheckpointContext()); state.machineHash != (common.Hash{}) {
+		t.Error("checkpointed unknown confirmed machine")
+	}
+}
+
+func TestConfirmedMachineMissingMessages(t *testing.T) {
+	c := newConfirmedMachine(&replayMachine{hash: common.RandHash()})
+	c.addMessages([]arbbridge.MessageDeliveredEvent{{Message: inbox.NewRandomInboxMessage()}})
+	node := assertNode(c, common.RandHash(), 2, common.RandHash())
+	c.confirmed(node)
+	if c.mach != nil {
+		t.Error("confirmed machine kept after importing undelivered messages")
+	}
+}
+
+func TestConfirmedMachineUntracked(t *testing.T) {
+	c := newConfirmedMachine(nil)
+	c.addMessages([]arbbridge.MessageDeliveredEvent{{Message: inbox.NewRandomInboxMessage()}})
+	node := assertNode(c, common.RandHash(), 1, common.RandHash())
+	if len(c.pending) != 0 || len(c.nodes) != 0 {
+		t.Error("untracked confirmed machine recorded events")
+	}
+	c.confirmed(node)
+	state := c.checkpoint(ckptcontext.NewCheckpointContext())
+	if state.machineHash != (common.Hash{}) || len(state.nodes) != 0 {
+		t.Error("checkpointed untracked confirmed machine")
+	}
+}
@@ -20,10 +20,10 @@ import (
 	"context"
 	"fmt"
 	ethcommon "github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
 	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
@@ -37,6 +37,7 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"log"
 	"math/big"
 	"sync"
@@ -44,6 +45,13 @@ import (
 
 var snapshotCacheSize = 100
 
+var (
+	metricsRegistry = metrics.NewRegistry("txdb")
+
+	arbGasCounter    = gethmetrics.NewRegisteredCounter("arbgas", metricsRegistry)
+	blockHeightGauge = gethmetrics.NewRegisteredGauge("height", metricsRegistry)
+)
+
 type TxDB struct {
 	View
 	mach         machine.Machine
@@ -63,12 +71,18 @@ type TxDB struct {
 	lastBlockProcessed *common.BlockId
 	lastInboxSeq       *big.Int
 	snapCache          *snapshotCache
+
+	withdrawals *withdrawalIndex
+	progress    *rollupProgress
+
+	trackConfirmed bool
+	confirmed      *confirmedMachine
 }
 
 func New(
 	clnt arbbridge.ChainTimeGetter,
 	checkpointer checkpointing.RollupCheckpointer,
-	as *cmachine.AggregatorStore,
+	as machine.AggregatorStore,
 	chain common.Address,
 ) *TxDB {
 	return &TxDB{
@@ -77,19 +91,40 @@ func New(
 		timeGetter:   clnt,
 		chain:        chain,
 		snapCache:    newSnapshotCache(snapshotCacheSize),
+		withdrawals:  newWithdrawalIndex(),
+		progress:     newRollupProgress(),
+		confirmed:    newConfirmedMachine(nil),
 	}
 }
 
+// TrackConfirmedMachine makes the TxDB keep the machine of the latest node
+// confirmed on L1 in its checkpoints so that they can be exported as archives
+// and verified against the node. The TxDB's own machine also runs the call
+// server assertions used to produce blocks, so it can't be checked against a
+// node. Keeping the confirmed machine means replaying every confirmed
+// assertion on a second machine and saving its state in each checkpoint,
+// which roughly doubles the execution and checkpoint storage cost, so only
+// aggregators which export archives should enable it. It must be called
+// before Load
+func (db *TxDB) TrackConfirmedMachine() {
+	db.trackConfirmed = true
+}
+
+// Load restores the TxDB from the newest checkpoint that can be restored or
+// starts fresh if there are no checkpoints. If checkpoints exist but none of
+// them can be restored, Load fails rather than throwing the sync away, so the
+// operator can repair the database with arb-checkpoint fsck
 func (db *TxDB) Load(ctx context.Context) error {
 	if db.checkpointer.HasCheckpointedState() {
-		err := db.restoreFromCheckpoint(ctx)
-		if err == nil {
-			return nil
+		if err := db.restoreFromCheckpoint(ctx); err != nil {
+			return fmt.Errorf(
+				"failed to restore from checkpoint: %v; stop the aggregator and run arb-checkpoint fsck -repair, or start with an empty checkpoint database to sync from scratch",
+				err,
+			)
 		}
-		log.Println("Error restoring from checkpoint:", err)
-		log.Println("Failed to restore from checkpoint, falling back to fresh start")
+		return db.indexWithdrawals()
 	}
-	// We failed to restore from a checkpoint
+	// There is no checkpoint to restore from
 	valueCache, err := cmachine.NewValueCache()
 	if err != nil {
 		return err
@@ -101,15 +136,22 @@ func (db *TxDB) Load(ctx context.Context) error {
 	}
 
 	db.mach = mach
+	if db.trackConfirmed {
+		db.confirmed = newConfirmedMachine(mach.Clone())
+	} else {
+		db.confirmed = newConfirmedMachine(nil)
+	}
 	db.callMut.Lock()
 	defer db.callMut.Unlock()
 	db.lastBlockProcessed = nil
 	db.lastInboxSeq = big.NewInt(0)
-	return nil
+	db.progress.restore(newRollupProgress())
+	db.withdrawals.reset()
+	return db.indexWithdrawals()
 }
 
 func (db *TxDB) AddInitialBlock(ctx context.Context, initialBlockHeight *big.Int) error {
-	return db.saveEmptyBlock(ctx, ethcommon.Hash{}, initialBlockHeight)
+	return db.saveEmptyBlock(ctx, nil, initialBlockHeight)
 }
 
 // addSnap must be called with callMut locked or during construction
@@ -126,15 +168,27 @@ func (db *TxDB) restoreFromCheckpoint(ctx context.Context) error {
 	var mach machine.Machine
 	var blockId *common.BlockId
 	var lastInboxSeq *big.Int
+	var progress *rollupProgress
+	var confirmed *confirmedMachine
 	if err := db.checkpointer.RestoreLatestState(ctx, db.timeGetter, func(chainObserverBytes []byte, restoreCtx ckptcontext.RestoreContext, restoreBlockId *common.BlockId) error {
-		var machineHash common.Hash
-		copy(machineHash[:], chainObserverBytes)
-		lastInboxSeq = new(big.Int).SetBytes(chainObserverBytes[32:])
-		var err error
-		mach, err = restoreCtx.GetMachine(machineHash)
+		state, err := unmarshalCheckpoint(chainObserverBytes)
 		if err != nil {
 			return err
 		}
+		mach, err = restoreCtx.GetMachine(state.machineHash)
+		if err != nil {
+			return err
+		}
+		if db.trackConfirmed {
+			confirmed, err = state.confirmed.restore(restoreCtx)
+			if err != nil {
+				return err
+			}
+		} else {
+			confirmed = newConfirmedMachine(nil)
+		}
+		lastInboxSeq = state.lastInboxSeq
+		progress = state.progress
 		blockId = restoreBlockId
 		return nil
 	}); err != nil {
@@ -198,7 +252,7 @@ func (db *TxDB) restoreFromCheckpoint(ctx context.Context) error {
 		}
 	}
 
-	if err := db.as.Reorg(
+	if err := db.reorgStore(
 		blockId.Height.AsInt().Uint64(),
 		block.ChainStats.AVMSendCount.Uint64(),
 		block.ChainStats.AVMLogCount.Uint64(),
@@ -207,6 +261,11 @@ func (db *TxDB) restoreFromCheckpoint(ctx context.Context) error {
 	}
 
 	db.mach = mach
+	db.confirmed = confirmed
+	if db.trackConfirmed && confirmed.mach == nil {
+		log.Println("Checkpoint doesn't include the machine of the confirmed node, so checkpoints can't be exported until the database is synced from scratch")
+	}
+	db.progress.restore(progress)
 	db.callMut.Lock()
 	defer db.callMut.Unlock()
 	db.lastBlockProcessed = blockId
@@ -215,7 +274,14 @@ func (db *TxDB) restoreFromCheckpoint(ctx context.Context) error {
 	return nil
 }
 
-func (db *TxDB) AddMessages(ctx context.Context, msgs []arbbridge.MessageDeliveredEvent, finishedBlock *common.BlockId) error {
+// AddMessages executes the messages delivered to the inbox and processes the
+// events emitted by the rollup contract up to and including finishedBlock
+func (db *TxDB) AddMessages(
+	ctx context.Context,
+	msgs []arbbridge.MessageDeliveredEvent,
+	rollupEvents []arbbridge.Event,
+	finishedBlock *common.BlockId,
+) error {
 	timestamp, err := db.timeGetter.TimestampForBlockHash(ctx, finishedBlock.HeaderHash)
 	db.blockProcFeed.Send(true)
 	defer db.blockProcFeed.Send(false)
@@ -224,6 +290,8 @@ func (db *TxDB) AddMessages(ctx context.Context, msgs []arbbridge.MessageDeliver
 		return err
 	}
 
+	db.confirmed.addMessages(msgs)
+
 	var lastBlock *evm.BlockInfo
 	for _, msg := range msgs {
 		// TODO: Give ExecuteAssertion the ability to run unbounded until it blocks
@@ -271,6 +339,7 @@ func (db *TxDB) AddMessages(ctx context.Context, msgs []arbbridge.MessageDeliver
 
 	db.callMut.Lock()
 	db.lastBlockProcessed = finishedBlock
+	blockHeightGauge.Update(finishedBlock.Height.AsInt().Int64())
 	lastInboxSeq := new(big.Int).Set(db.lastInboxSeq)
 
 	latestSnap := db.snapCache.latest()
@@ -283,13 +352,17 @@ func (db *TxDB) AddMessages(ctx context.Context, msgs []arbbridge.MessageDeliver
 		return err
 	}
 
+	// Rollup events are processed after the inbox messages so that the
+	// messages of new assertions have been computed locally
+	if err := db.processRollupEvents(rollupEvents); err != nil {
+		return err
+	}
+
 	if lastBlock != nil {
 		ctx := ckptcontext.NewCheckpointContext()
 		ctx.AddMachine(db.mach)
-		machHash := db.mach.Hash()
-		cpData := make([]byte, 64)
-		copy(cpData[:], machHash[:])
-		copy(cpData[32:], math.U256Bytes(lastInboxSeq))
+		confirmedData := db.confirmed.checkpoint(ctx)
+		cpData := marshalCheckpoint(db.mach.Hash(), lastInboxSeq, confirmedData, db.progress)
 		db.checkpointer.AsyncSaveCheckpoint(finishedBlock, cpData, ctx)
 	}
 	return nil
@@ -302,6 +375,7 @@ type processedAssertion struct {
 }
 
 func (db *TxDB) processAssertion(assertion *protocol.ExecutionAssertion) (processedAssertion, error) {
+	arbGasCounter.Inc(int64(assertion.NumGas))
 	blocks := make([]*evm.BlockInfo, 0)
 	avmLogs := assertion.ParseLogs()
 	for _, avmLog := range avmLogs {
@@ -326,7 +400,10 @@ func (db *TxDB) processAssertion(assertion *protocol.ExecutionAssertion) (proces
 	}, nil
 }
 
-func (db *TxDB) saveEmptyBlock(ctx context.Context, prev ethcommon.Hash, number *big.Int) error {
+// saveEmptyBlock saves a block containing no transactions after prev, which
+// is nil for the initial block. Since empty blocks don't change the state of
+// the chain, they share the state root of the previous block
+func (db *TxDB) saveEmptyBlock(ctx context.Context, prev *types.Header, number *big.Int) error {
 	blockId, err := db.timeGetter.BlockIdForHeight(ctx, common.NewTimeBlocks(number))
 	if err != nil {
 		return err
@@ -336,7 +413,6 @@ func (db *TxDB) saveEmptyBlock(ctx context.Context, prev ethcommon.Hash, number
 		return err
 	}
 	header := &types.Header{
-		ParentHash: prev,
 		Difficulty: big.NewInt(0),
 		Number:     new(big.Int).Set(number),
 		GasLimit:   10000000,
@@ -344,6 +420,10 @@ func (db *TxDB) saveEmptyBlock(ctx context.Context, prev ethcommon.Hash, number
 		Time:       time.Uint64(),
 		Extra:      blockId.HeaderHash.Bytes(),
 	}
+	if prev != nil {
+		header.ParentHash = prev.Hash()
+		header.Root = prev.Root
+	}
 	block := types.NewBlock(header, nil, nil, nil, new(trie.Trie))
 	if err := db.as.SaveEmptyBlock(block.Header()); err != nil {
 		return err
@@ -367,7 +447,7 @@ func (db *TxDB) fillEmptyBlocks(ctx context.Context, max *big.Int) error {
 		if prev == nil {
 			return fmt.Errorf("trying to add block %v, but prev header was not found", next)
 		}
-		if err := db.saveEmptyBlock(ctx, prev.Header.Hash(), next); err != nil {
+		if err := db.saveEmptyBlock(ctx, prev.Header, next); err != nil {
 			return err
 		}
 		next = next.Add(next, big.NewInt(1))
@@ -441,6 +521,15 @@ func (db *TxDB) GetLogs(_ context.Context, blockHash ethcommon.Hash) ([][]*types
 }
 
 func (db *TxDB) saveAssertion(ctx context.Context, processed processedAssertion) error {
+	logStart, err := db.as.LogCount()
+	if err != nil {
+		return err
+	}
+	msgStart, err := db.as.MessageCount()
+	if err != nil {
+		return err
+	}
+
 	for _, avmLog := range processed.avmLogs {
 		if err := db.as.SaveLog(avmLog); err != nil {
 			return err
@@ -453,6 +542,15 @@ func (db *TxDB) saveAssertion(ctx context.Context, processed processedAssertion)
 		}
 	}
 
+	if err := db.saveWithdrawals(processed, logStart, msgStart); err != nil {
+		return err
+	}
+
+	// The AVM only reports its state at assertion boundaries, so only the
+	// final block produced by an assertion has a state root. Blocks are
+	// marked with whether they have one when returned over RPC
+	stateRoot := processed.assertion.AfterMachineHash.Unmarshal().ToEthHash()
+
 	finalBlockIndex := len(processed.blocks) - 1
 	for blockIndex, info := range processed.blocks {
 		if err := db.fillEmptyBlocks(ctx, info.BlockNum); err != nil {
@@ -485,17 +583,11 @@ func (db *TxDB) saveAssertion(ctx context.Context, processed processedAssertion)
 		if prev == nil {
 			return fmt.Errorf("trying to add block %v, but prev header was not found", info.BlockNum.Uint64())
 		}
-		header := &types.Header{
-			ParentHash: prev.Header.Hash(),
-			Difficulty: big.NewInt(0),
-			Number:     new(big.Int).Set(info.BlockNum),
-			GasLimit:   info.GasLimit.Uint64(),
-			GasUsed:    info.BlockStats.GasUsed.Uint64(),
-			Time:       info.Timestamp.Uint64(),
-			Extra:      id.HeaderHash.Bytes(),
+		var root ethcommon.Hash
+		if blockIndex == finalBlockIndex {
+			root = stateRoot
 		}
-
-		block := types.NewBlock(header, ethTxes, nil, ethReceipts, new(trie.Trie))
+		block := newBlock(prev.Header, info, id.HeaderHash, root, ethTxes, ethReceipts)
 		avmLogIndex := info.ChainStats.AVMLogCount.Uint64() - 1
 		if err := db.as.SaveBlock(block.Header(), avmLogIndex); err != nil {
 			return err
@@ -526,6 +618,10 @@ func (db *TxDB) saveAssertion(ctx context.Context, processed processedAssertion)
 			}
 		}
 
+		if err := db.saveTxHashes(startLog, txResults, processedResults); err != nil {
+			return err
+		}
+
 		if err := db.as.SaveBlockHash(common.NewHashFromEth(block.Hash()), block.Number().Uint64()); err != nil {
 			return err
 		}
@@ -533,6 +629,92 @@ func (db *TxDB) saveAssertion(ctx context.Context, processed processedAssertion)
 	return nil
 }
 
+// newBlock builds the block following prev. l1BlockHash is the hash of the L1
+// block the block was produced in and root is its state root, which is empty
+// if the machine state at the end of the block isn't known
+func newBlock(
+	prev *types.Header,
+	info *evm.BlockInfo,
+	l1BlockHash common.Hash,
+	root ethcommon.Hash,
+	txes []*types.Transaction,
+	receipts []*types.Receipt,
+) *types.Block {
+	header := &types.Header{
+		ParentHash: prev.Hash(),
+		Root:       root,
+		Difficulty: big.NewInt(0),
+		Number:     new(big.Int).Set(info.BlockNum),
+		GasLimit:   info.GasLimit.Uint64(),
+		GasUsed:    info.BlockStats.GasUsed.Uint64(),
+		Time:       info.Timestamp.Uint64(),
+		Extra:      l1BlockHash.Bytes(),
+	}
+
+	// NewBlock derives the transactions root, receipts root and logs bloom
+	// from the block's transactions and receipts
+	return types.NewBlock(header, txes, nil, receipts, new(trie.Trie))
+}
+
+// saveTxHashes indexes the Ethereum transactions in a block by hash.
+// startLog is the index of the log of the first result in txResults
+func (db *TxDB) saveTxHashes(startLog uint64, txResults []*evm.TxResult, processedResults []*evm.ProcessedTx) error {
+	logIndices := make(map[*evm.TxResult]uint64, len(txResults))
+	for i, txRes := range txResults {
+		logIndices[txRes] = startLog + uint64(i)
+	}
+	for _, processed := range processedResults {
+		txHash := common.NewHashFromEth(processed.Tx.Hash())
+		if processed.Result.ResultCode == evm.BadSequenceCode {
+			// Like requests, don't let a replayed transaction overwrite the
+			// original one
+			if db.as.GetPossibleTxHashInfo(txHash) != nil {
+				continue
+			}
+		}
+		if err := db.as.SaveTxHash(txHash, logIndices[processed.Result]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillTxHashIndex indexes the Ethereum transactions in all blocks saved
+// before the transaction hash index existed. Entries which already exist are
+// rewritten, so it is safe to run on a database which is already indexed
+func (db *TxDB) BackfillTxHashIndex(ctx context.Context) error {
+	latest, err := db.as.LatestBlock()
+	if err != nil {
+		return err
+	}
+	latestHeight := latest.Height.AsInt().Uint64()
+	for height := uint64(0); height <= latestHeight; height++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		info, err := db.as.GetBlock(height)
+		if err != nil {
+			return err
+		}
+		if info == nil || info.BlockLog == nil {
+			continue
+		}
+		res, err := evm.NewBlockResultFromValue(info.BlockLog)
+		if err != nil {
+			return err
+		}
+		txResults, err := db.GetBlockResults(res)
+		if err != nil {
+			return err
+		}
+		processedResults := evm.FilterEthTxResults(txResults)
+		if err := db.saveTxHashes(res.FirstAVMLog().Uint64(), txResults, processedResults); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *TxDB) GetMessage(index uint64) (value.Value, error) {
 	return db.as.GetMessage(index)
 }
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package txdb
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+)
+
+// saveTestBlock saves a block at height containing a result for each of the
+// given transactions and returns the results
+func saveTestBlock(t *testing.T, as machine.AggregatorStore, height uint64, txes []*types.Transaction) []*evm.TxResult {
+	t.Helper()
+	logCount, err := as.LogCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make([]*evm.TxResult, 0, len(txes))
+	for _, tx := range txes {
+		msg, err := message.NewL2Message(message.SignedTransaction{Tx: tx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := evm.NewRandomResult(0)
+		res.IncomingRequest.Kind = message.L2Type
+		res.IncomingRequest.Data = msg.Data
+		res.IncomingRequest.Provenance = evm.Provenance{
+			L1SeqNum:      big.NewInt(0),
+			IndexInParent: big.NewInt(0),
+		}
+		if err := as.SaveLog(res.AsValue()); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+
+	txCount := big.NewInt(int64(len(txes)))
+	block := &evm.BlockInfo{
+		BlockNum:  new(big.Int).SetUint64(height),
+		Timestamp: big.NewInt(0),
+		GasLimit:  big.NewInt(0),
+		BlockStats: &evm.OutputStatistics{
+			GasUsed:      big.NewInt(0),
+			TxCount:      txCount,
+			EVMLogCount:  big.NewInt(0),
+			AVMLogCount:  txCount,
+			AVMSendCount: big.NewInt(0),
+		},
+		ChainStats: &evm.OutputStatistics{
+			GasUsed:      big.NewInt(0),
+			TxCount:      big.NewInt(0),
+			EVMLogCount:  big.NewInt(0),
+			AVMLogCount:  new(big.Int).SetUint64(logCount + uint64(len(txes)) + 1),
+			AVMSendCount: big.NewInt(0),
+		},
+	}
+	if err := as.SaveLog(block.AsValue()); err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: new(big.Int).SetUint64(height), Difficulty: big.NewInt(0)}
+	if err := as.SaveBlock(header, logCount+uint64(len(txes))); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestBackfillTxHashIndex(t *testing.T) {
+	rand.Seed(7453)
+	as := gomachine.NewAggregatorStore()
+	db := &TxDB{View: View{as: as}}
+
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := common.RandAddress()
+	var txes []*types.Transaction
+	var results []*evm.TxResult
+	for height := uint64(0); height < 3; height++ {
+		blockTxes := []*types.Transaction{
+			message.NewRandomSignedEthTx(chain, pk, 2*height),
+			message.NewRandomSignedEthTx(chain, pk, 2*height+1),
+		}
+		txes = append(txes, blockTxes...)
+		results = append(results, saveTestBlock(t, as, height, blockTxes)...)
+	}
+
+	for _, tx := range txes {
+		val, err := db.GetRequestByTxHash(common.NewHashFromEth(tx.Hash()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != nil {
+			t.Fatal("transaction indexed before backfill")
+		}
+	}
+
+	if err := db.BackfillTxHashIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i, tx := range txes {
+		val, err := db.GetRequestByTxHash(common.NewHashFromEth(tx.Hash()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val == nil {
+			t.Fatal("transaction", i, "not indexed")
+		}
+		res, err := evm.NewTxResultFromValue(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.IncomingRequest.MessageID != results[i].IncomingRequest.MessageID {
+			t.Error("transaction", i, "indexed to the wrong result")
+		}
+	}
+
+	// Running the backfill again leaves the index unchanged
+	if err := db.BackfillTxHashIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := db.GetRequestByTxHash(common.NewHashFromEth(txes[0].Hash())); err != nil || val == nil {
+		t.Error("transaction lost after second backfill", err)
+	}
+}
@@ -19,16 +19,17 @@ package txdb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
 	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
 )
 
 type View struct {
-	as *cmachine.AggregatorStore
+	as machine.AggregatorStore
 }
 
 func (txdb *View) GetMessage(index uint64) (value.Value, error) {
@@ -58,6 +59,107 @@ func (txdb *View) GetRequest(requestId common.Hash) (value.Value, error) {
 	return logVal, nil
 }
 
+// GetRequestByTxHash returns the result of the request containing the
+// Ethereum transaction with the given hash
+func (txdb *View) GetRequestByTxHash(txHash common.Hash) (value.Value, error) {
+	requestCandidate := txdb.as.GetPossibleTxHashInfo(txHash)
+	if requestCandidate == nil {
+		return nil, nil
+	}
+	logVal, err := txdb.as.GetLog(*requestCandidate)
+	if err != nil {
+		return nil, err
+	}
+	res, err := evm.NewTxResultFromValue(logVal)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := evm.GetTransaction(res)
+	if err != nil {
+		return nil, nil
+	}
+	if common.NewHashFromEth(processed.Tx.Hash()) != txHash {
+		return nil, nil
+	}
+	return logVal, nil
+}
+
+// GetRequestLogIndex returns the index of the log containing the result of
+// the request with the given Ethereum transaction hash or request id, or nil
+// if no such request has been processed
+func (txdb *View) GetRequestLogIndex(hash common.Hash) (*uint64, error) {
+	if logIndex := txdb.as.GetPossibleTxHashInfo(hash); logIndex != nil {
+		logVal, err := txdb.GetRequestByTxHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if logVal != nil {
+			return logIndex, nil
+		}
+	}
+	if logIndex := txdb.as.GetPossibleRequestInfo(hash); logIndex != nil {
+		logVal, err := txdb.GetRequest(hash)
+		if err != nil {
+			return nil, err
+		}
+		if logVal != nil {
+			return logIndex, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetMessageProof proves that the output message at index is included in the
+// messages accumulator of the assertion which output count messages starting
+// with the message at firstIndex
+func (txdb *View) GetMessageProof(index, firstIndex, count uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	return accumulatorProof(txdb.as.GetMessage, txdb.as.MessageCount, index, firstIndex, count)
+}
+
+// GetLogProof proves that the log at index is included in the logs
+// accumulator of the assertion which output count logs starting with the log
+// at firstIndex
+func (txdb *View) GetLogProof(index, firstIndex, count uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	return accumulatorProof(txdb.as.GetLog, txdb.as.LogCount, index, firstIndex, count)
+}
+
+func accumulatorProof(
+	getVal func(uint64) (value.Value, error),
+	getCount func() (uint64, error),
+	index uint64,
+	firstIndex uint64,
+	count uint64,
+) (*valprotocol.AccumulatorProof, value.Value, error) {
+	if index < firstIndex || index-firstIndex >= count {
+		return nil, nil, fmt.Errorf("index %v is not in assertion range [%v, %v)", index, firstIndex, firstIndex+count)
+	}
+	total, err := getCount()
+	if err != nil {
+		return nil, nil, err
+	}
+	if firstIndex+count > total {
+		return nil, nil, fmt.Errorf("assertion range ends at %v but only %v values are saved", firstIndex+count, total)
+	}
+	var provenVal value.Value
+	valHashes := make([]common.Hash, 0, count)
+	for i := firstIndex; i < firstIndex+count; i++ {
+		val, err := getVal(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == index {
+			provenVal = val
+		}
+		valHashes = append(valHashes, val.Hash())
+	}
+	// Each assertion's accumulators start from the zero hash
+	proof, err := valprotocol.NewAccumulatorProof(common.Hash{}, valHashes, index-firstIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, provenVal, nil
+}
+
 func (txdb *View) GetBlockWithHash(blockHash common.Hash) (*machine.BlockInfo, error) {
 	blockHeight := txdb.as.GetPossibleBlock(blockHash)
 	if blockHeight == nil {
@@ -109,7 +211,7 @@ func (txdb *View) FindLogs(
 		return logs, nil
 	}
 
-	for i := startHeight; i <= endHeight; i++ {
+	for i := startHeight; i < endHeight; i++ {
 		select {
 		case <-ctx.Done():
 			return nil, errors.New("call timed out")
@@ -144,12 +246,12 @@ func (txdb *View) FindLogs(
 				return nil, err
 			}
 
-			logIndex := uint64(0)
+			logIndex := res.StartLogIndex.Uint64()
 			for _, evmLog := range res.EVMLogs {
 				if evmLog.MatchesQuery(address, topics) {
 					logs = append(logs, evm.FullLog{
 						Log:     evmLog,
-						TxIndex: j,
+						TxIndex: res.TxIndex.Uint64(),
 						TxHash:  res.IncomingRequest.MessageID,
 						Index:   logIndex,
 						Block: &common.BlockId{
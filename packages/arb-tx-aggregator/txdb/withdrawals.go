@@ -0,0 +1,711 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package txdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/snapshot"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
+)
+
+type WithdrawalStatus int
+
+const (
+	// WithdrawalPending withdrawals have been processed by the aggregator but
+	// aren't included in any assertion on L1 yet
+	WithdrawalPending WithdrawalStatus = iota
+	// WithdrawalAsserted withdrawals are included in an assertion whose
+	// messages match the ones computed locally
+	WithdrawalAsserted
+	// WithdrawalConfirmed withdrawals are included in a confirmed assertion,
+	// so their funds can be claimed on L1
+	WithdrawalConfirmed
+)
+
+func (s WithdrawalStatus) String() string {
+	switch s {
+	case WithdrawalPending:
+		return "pending"
+	case WithdrawalAsserted:
+		return "asserted"
+	case WithdrawalConfirmed:
+		return "confirmed"
+	default:
+		return "unknown"
+	}
+}
+
+// Withdrawal is a transfer of funds from the chain to L1 made through ArbSys
+type Withdrawal struct {
+	// Index of the output message which carries the withdrawal
+	MessageIndex uint64
+	Kind         inbox.Type
+	Sender       common.Address
+	Dest         common.Address
+	// Token is empty for Eth withdrawals
+	Token common.Address
+	// Amount of Eth or ERC20 tokens withdrawn, or the id of the ERC721 token
+	Value *big.Int
+	// Request id of the transaction which made the withdrawal, or nil if it
+	// couldn't be determined
+	RequestId *common.Hash
+	Status    WithdrawalStatus
+}
+
+func newWithdrawalFromMessage(index uint64, msg message.OutMessage) (*Withdrawal, bool) {
+	w := &Withdrawal{
+		MessageIndex: index,
+		Kind:         msg.Kind,
+		Sender:       msg.Sender,
+	}
+	switch msg.Kind {
+	case message.EthType:
+		eth := message.NewEthFromData(msg.Data)
+		w.Dest = eth.Dest
+		w.Value = eth.Value
+	case message.ERC20Type:
+		erc20 := message.NewERC20FromData(msg.Data)
+		w.Dest = erc20.Dest
+		w.Token = erc20.Token
+		w.Value = erc20.Value
+	case message.ERC721Type:
+		erc721 := message.NewERC721FromData(msg.Data)
+		w.Dest = erc721.Dest
+		w.Token = erc721.Token
+		w.Value = erc721.ID
+	default:
+		return nil, false
+	}
+	return w, true
+}
+
+// withdrawalEvent is the subset of a withdrawal which is reported in the
+// ArbSys withdrawal events
+type withdrawalEvent struct {
+	kind  inbox.Type
+	dest  common.Address
+	token common.Address
+	value *big.Int
+}
+
+func parseWithdrawalEvent(evmLog evm.Log) (withdrawalEvent, bool) {
+	if evmLog.Address.ToEthAddress() != arbos.ARB_SYS_ADDRESS || len(evmLog.Topics) == 0 {
+		return withdrawalEvent{}, false
+	}
+	if ev, err := snapshot.ParseEthWithdrawalEvent(evmLog); err == nil {
+		return withdrawalEvent{
+			kind:  message.EthType,
+			dest:  common.NewAddressFromEth(ev.DestAddr),
+			value: ev.Amount,
+		}, true
+	}
+	if ev, err := snapshot.ParseERC20WithdrawalEvent(evmLog); err == nil {
+		return withdrawalEvent{
+			kind:  message.ERC20Type,
+			dest:  common.NewAddressFromEth(ev.DestAddr),
+			token: common.NewAddressFromEth(ev.TokenAddr),
+			value: ev.Amount,
+		}, true
+	}
+	if ev, err := snapshot.ParseERC721WithdrawalEvent(evmLog); err == nil {
+		return withdrawalEvent{
+			kind:  message.ERC721Type,
+			dest:  common.NewAddressFromEth(ev.DestAddr),
+			token: common.NewAddressFromEth(ev.TokenAddr),
+			value: ev.Id,
+		}, true
+	}
+	return withdrawalEvent{}, false
+}
+
+func (ev withdrawalEvent) matches(w *Withdrawal) bool {
+	return w != nil &&
+		ev.kind == w.Kind &&
+		ev.dest == w.Dest &&
+		ev.token == w.Token &&
+		ev.value.Cmp(w.Value) == 0
+}
+
+// matchWithdrawalMessages pairs the withdrawal events emitted by the
+// transaction results in avmLogs with the output messages they created,
+// returning a map from message offset to log offset. Withdrawals create their
+// messages in order, so each event is matched to the first following message
+// with the same contents
+func matchWithdrawalMessages(avmLogs []value.Value, withdrawals []*Withdrawal) map[int]int {
+	matches := make(map[int]int)
+	nextMsg := 0
+	for logOffset, avmLog := range avmLogs {
+		res, err := evm.NewResultFromValue(avmLog)
+		if err != nil {
+			continue
+		}
+		txRes, ok := res.(*evm.TxResult)
+		if !ok {
+			continue
+		}
+		for _, evmLog := range txRes.EVMLogs {
+			ev, ok := parseWithdrawalEvent(evmLog)
+			if !ok {
+				continue
+			}
+			msgOffset := nextMsg
+			for msgOffset < len(withdrawals) && !ev.matches(withdrawals[msgOffset]) {
+				msgOffset++
+			}
+			if msgOffset == len(withdrawals) {
+				log.Println("No output message found for withdrawal in request", txRes.IncomingRequest.MessageID)
+				continue
+			}
+			matches[msgOffset] = logOffset
+			nextMsg = msgOffset + 1
+		}
+	}
+	return matches
+}
+
+// withdrawalIndex maps addresses to the indexes of the output messages of
+// withdrawals they sent or received
+type withdrawalIndex struct {
+	mut       sync.Mutex
+	byAddress map[common.Address][]uint64
+	// Number of output messages which have been indexed
+	messageCount uint64
+}
+
+func newWithdrawalIndex() *withdrawalIndex {
+	return &withdrawalIndex{byAddress: make(map[common.Address][]uint64)}
+}
+
+func (wi *withdrawalIndex) reset() {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	wi.byAddress = make(map[common.Address][]uint64)
+	wi.messageCount = 0
+}
+
+func (wi *withdrawalIndex) add(w *Withdrawal) {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	// ArbOS doesn't report the sender of token withdrawals yet
+	if w.Sender != (common.Address{}) {
+		wi.addIndex(w.Sender, w.MessageIndex)
+	}
+	if w.Dest != w.Sender {
+		wi.addIndex(w.Dest, w.MessageIndex)
+	}
+}
+
+// addIndex must be called with mut locked
+func (wi *withdrawalIndex) addIndex(address common.Address, index uint64) {
+	indexes := wi.byAddress[address]
+	// Messages are indexed in order, so a message which was already indexed
+	// is never after the last index
+	if len(indexes) > 0 && indexes[len(indexes)-1] >= index {
+		return
+	}
+	wi.byAddress[address] = append(indexes, index)
+}
+
+// indexed records that every output message before messageCount has been
+// indexed
+func (wi *withdrawalIndex) indexed(messageCount uint64) {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	if messageCount > wi.messageCount {
+		wi.messageCount = messageCount
+	}
+}
+
+// indexedCount returns the number of output messages which have been indexed
+func (wi *withdrawalIndex) indexedCount() uint64 {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	return wi.messageCount
+}
+
+// removeFrom drops the withdrawals in output messages at or after
+// messageCount, which were removed by a reorg
+func (wi *withdrawalIndex) removeFrom(messageCount uint64) {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	for address, indexes := range wi.byAddress {
+		kept := sort.Search(len(indexes), func(i int) bool {
+			return indexes[i] >= messageCount
+		})
+		if kept == 0 {
+			delete(wi.byAddress, address)
+		} else {
+			wi.byAddress[address] = indexes[:kept]
+		}
+	}
+	if wi.messageCount > messageCount {
+		wi.messageCount = messageCount
+	}
+}
+
+func (wi *withdrawalIndex) get(address common.Address) []uint64 {
+	wi.mut.Lock()
+	defer wi.mut.Unlock()
+	indexes := make([]uint64, len(wi.byAddress[address]))
+	copy(indexes, wi.byAddress[address])
+	return indexes
+}
+
+// rollupProgress tracks how many of the chain's output messages have been
+// included in assertions and confirmed on L1
+type rollupProgress struct {
+	mut                   sync.Mutex
+	assertedMessageCount  uint64
+	confirmedMessageCount uint64
+	// Message count after each unconfirmed assertion, indexed by the node
+	// which will be confirmed if the assertion is valid
+	validLeaves map[common.Hash]uint64
+	// Outputs of the assertions which matched the local chain, in the order
+	// they were made
+	assertions []assertionRange
+}
+
+// assertionRange is the range of output messages and logs of an assertion
+// made on L1 along with the final values of its accumulators
+type assertionRange struct {
+	firstMessage    uint64
+	messageCount    uint64
+	lastMessageHash common.Hash
+	firstLog        uint64
+	logCount        uint64
+	lastLogHash     common.Hash
+}
+
+const assertionRangeSize = 96
+
+func (r assertionRange) marshal() []byte {
+	data := make([]byte, assertionRangeSize)
+	binary.BigEndian.PutUint64(data[:8], r.firstMessage)
+	binary.BigEndian.PutUint64(data[8:16], r.messageCount)
+	copy(data[16:48], r.lastMessageHash[:])
+	binary.BigEndian.PutUint64(data[48:56], r.firstLog)
+	binary.BigEndian.PutUint64(data[56:64], r.logCount)
+	copy(data[64:96], r.lastLogHash[:])
+	return data
+}
+
+func unmarshalAssertionRange(data []byte) assertionRange {
+	r := assertionRange{
+		firstMessage: binary.BigEndian.Uint64(data[:8]),
+		messageCount: binary.BigEndian.Uint64(data[8:16]),
+		firstLog:     binary.BigEndian.Uint64(data[48:56]),
+		logCount:     binary.BigEndian.Uint64(data[56:64]),
+	}
+	copy(r.lastMessageHash[:], data[16:48])
+	copy(r.lastLogHash[:], data[64:96])
+	return r
+}
+
+func newRollupProgress() *rollupProgress {
+	return &rollupProgress{validLeaves: make(map[common.Hash]uint64)}
+}
+
+// restore replaces the tracked progress with a copy of other's
+func (p *rollupProgress) restore(other *rollupProgress) {
+	other.mut.Lock()
+	defer other.mut.Unlock()
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.assertedMessageCount = other.assertedMessageCount
+	p.confirmedMessageCount = other.confirmedMessageCount
+	p.validLeaves = make(map[common.Hash]uint64, len(other.validLeaves))
+	for leaf, count := range other.validLeaves {
+		p.validLeaves[leaf] = count
+	}
+	p.assertions = make([]assertionRange, len(other.assertions))
+	copy(p.assertions, other.assertions)
+}
+
+func (p *rollupProgress) status(messageIndex uint64) WithdrawalStatus {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if messageIndex < p.confirmedMessageCount {
+		return WithdrawalConfirmed
+	}
+	if messageIndex < p.assertedMessageCount {
+		return WithdrawalAsserted
+	}
+	return WithdrawalPending
+}
+
+func (p *rollupProgress) asserted(validLeaf common.Hash, messageCount uint64) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.validLeaves[validLeaf] = messageCount
+	if messageCount > p.assertedMessageCount {
+		p.assertedMessageCount = messageCount
+	}
+}
+
+func (p *rollupProgress) confirmed(node common.Hash) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	messageCount, ok := p.validLeaves[node]
+	if !ok {
+		// An invalid node was confirmed or the assertion was never seen, so
+		// no new messages were confirmed
+		return
+	}
+	if messageCount > p.confirmedMessageCount {
+		p.confirmedMessageCount = messageCount
+	}
+	if p.confirmedMessageCount > p.assertedMessageCount {
+		p.assertedMessageCount = p.confirmedMessageCount
+	}
+	for leaf, count := range p.validLeaves {
+		if count <= p.confirmedMessageCount {
+			delete(p.validLeaves, leaf)
+		}
+	}
+}
+
+// addAssertion records the outputs of an assertion which matched the local
+// chain so that proofs of inclusion in it can be found later
+func (p *rollupProgress) addAssertion(r assertionRange) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for _, existing := range p.assertions {
+		if existing == r {
+			// Competing nodes made the same assertion
+			return
+		}
+	}
+	p.assertions = append(p.assertions, r)
+}
+
+// assertionWithMessage returns the most recent assertion which output the
+// message at index
+func (p *rollupProgress) assertionWithMessage(index uint64) (assertionRange, bool) {
+	return p.findAssertion(func(r assertionRange) bool {
+		return index >= r.firstMessage && index-r.firstMessage < r.messageCount
+	})
+}
+
+// assertionWithLog returns the most recent assertion which output the log at
+// index
+func (p *rollupProgress) assertionWithLog(index uint64) (assertionRange, bool) {
+	return p.findAssertion(func(r assertionRange) bool {
+		return index >= r.firstLog && index-r.firstLog < r.logCount
+	})
+}
+
+func (p *rollupProgress) findAssertion(contains func(assertionRange) bool) (assertionRange, bool) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for i := len(p.assertions) - 1; i >= 0; i-- {
+		if contains(p.assertions[i]) {
+			return p.assertions[i], true
+		}
+	}
+	return assertionRange{}, false
+}
+
+// marshal encodes the progress as the asserted and confirmed message counts,
+// the number of valid leaves followed by each leaf and its message count, and
+// then the recorded assertions
+func (p *rollupProgress) marshal() []byte {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	data := make([]byte, 24, 24+len(p.validLeaves)*40+len(p.assertions)*assertionRangeSize)
+	binary.BigEndian.PutUint64(data[:8], p.assertedMessageCount)
+	binary.BigEndian.PutUint64(data[8:16], p.confirmedMessageCount)
+	binary.BigEndian.PutUint64(data[16:24], uint64(len(p.validLeaves)))
+	for leaf, count := range p.validLeaves {
+		data = append(data, leaf[:]...)
+		var countData [8]byte
+		binary.BigEndian.PutUint64(countData[:], count)
+		data = append(data, countData[:]...)
+	}
+	for _, r := range p.assertions {
+		data = append(data, r.marshal()...)
+	}
+	return data
+}
+
+func unmarshalRollupProgress(data []byte) (*rollupProgress, error) {
+	p := newRollupProgress()
+	if len(data) == 0 {
+		// Checkpoint was saved before progress was tracked
+		return p, nil
+	}
+	if len(data) < 24 {
+		return nil, errors.New("invalid rollup progress data")
+	}
+	p.assertedMessageCount = binary.BigEndian.Uint64(data[:8])
+	p.confirmedMessageCount = binary.BigEndian.Uint64(data[8:16])
+	leafCount := binary.BigEndian.Uint64(data[16:24])
+	data = data[24:]
+	if leafCount > uint64(len(data))/40 || (uint64(len(data))-leafCount*40)%assertionRangeSize != 0 {
+		return nil, errors.New("invalid rollup progress data")
+	}
+	for i := uint64(0); i < leafCount; i++ {
+		var leaf common.Hash
+		copy(leaf[:], data[:32])
+		p.validLeaves[leaf] = binary.BigEndian.Uint64(data[32:40])
+		data = data[40:]
+	}
+	for ; len(data) > 0; data = data[assertionRangeSize:] {
+		p.assertions = append(p.assertions, unmarshalAssertionRange(data))
+	}
+	return p, nil
+}
+
+// saveWithdrawals records the withdrawals made by an assertion whose first
+// log and message have the indexes logStart and msgStart
+func (db *TxDB) saveWithdrawals(processed processedAssertion, logStart uint64, msgStart uint64) error {
+	outMessages := processed.assertion.ParseOutMessages()
+	withdrawals := make([]*Withdrawal, len(outMessages))
+	for i, msgVal := range outMessages {
+		msg, err := message.NewOutMessageFromValue(msgVal)
+		if err != nil {
+			log.Println("Error parsing output message", err)
+			continue
+		}
+		withdrawal, ok := newWithdrawalFromMessage(msgStart+uint64(i), msg)
+		if !ok {
+			continue
+		}
+		withdrawals[i] = withdrawal
+		db.withdrawals.add(withdrawal)
+	}
+	db.withdrawals.indexed(msgStart + uint64(len(outMessages)))
+
+	for msgOffset, logOffset := range matchWithdrawalMessages(processed.avmLogs, withdrawals) {
+		if err := db.as.SaveMessageLog(msgStart+uint64(msgOffset), logStart+uint64(logOffset)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexWithdrawals adds the withdrawals in the saved output messages which
+// haven't been indexed yet to the withdrawal index
+func (db *TxDB) indexWithdrawals() error {
+	messageCount, err := db.as.MessageCount()
+	if err != nil {
+		return err
+	}
+	for i := db.withdrawals.indexedCount(); i < messageCount; i++ {
+		msgVal, err := db.as.GetMessage(i)
+		if err != nil {
+			return err
+		}
+		msg, err := message.NewOutMessageFromValue(msgVal)
+		if err != nil {
+			continue
+		}
+		if withdrawal, ok := newWithdrawalFromMessage(i, msg); ok {
+			db.withdrawals.add(withdrawal)
+		}
+	}
+	db.withdrawals.indexed(messageCount)
+	return nil
+}
+
+// reorgStore rolls the aggregator store back to the given block height and
+// output counts and drops the withdrawals which were removed from it
+func (db *TxDB) reorgStore(height uint64, messageCount uint64, logCount uint64) error {
+	if err := db.as.Reorg(height, messageCount, logCount); err != nil {
+		return err
+	}
+	db.withdrawals.removeFrom(messageCount)
+	return nil
+}
+
+// processRollupEvents updates the status of withdrawals based on the
+// assertions made and confirmed on L1 and records the outputs of the
+// assertions which match the local chain
+func (db *TxDB) processRollupEvents(events []arbbridge.Event) error {
+	for _, ev := range events {
+		switch ev := ev.(type) {
+		case arbbridge.AssertedEvent:
+			db.confirmed.asserted(ev)
+			r := assertionRange{
+				firstMessage:    ev.BeforeMessageCount.Uint64(),
+				messageCount:    ev.MessageCount,
+				lastMessageHash: ev.LastMessageHash,
+				firstLog:        ev.BeforeLogCount.Uint64(),
+				logCount:        ev.LogCount,
+				lastLogHash:     ev.LastLogHash,
+			}
+			messageCount := r.firstMessage + r.messageCount
+			valid, err := db.assertionMessagesMatch(r.firstMessage, r.messageCount, r.lastMessageHash)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				log.Println("Ignoring assertion with messages", r.firstMessage, "to", messageCount, "which don't match the local chain")
+				continue
+			}
+			db.progress.asserted(ev.ValidLeafHash, messageCount)
+
+			valid, err = db.assertionLogsMatch(r.firstLog, r.logCount, r.lastLogHash)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				log.Println("Assertion with logs", r.firstLog, "to", r.firstLog+r.logCount, "doesn't match the local chain")
+				continue
+			}
+			if r.messageCount > 0 || r.logCount > 0 {
+				db.progress.addAssertion(r)
+			}
+		case arbbridge.ConfirmedEvent:
+			db.progress.confirmed(ev.NodeHash)
+			db.confirmed.confirmed(ev.NodeHash)
+		}
+	}
+	return nil
+}
+
+func (db *TxDB) assertionMessagesMatch(firstIndex uint64, count uint64, lastMessageHash common.Hash) (bool, error) {
+	return accumulatorMatches(db.GetMessageProof, db.as.MessageCount, firstIndex, count, lastMessageHash)
+}
+
+func (db *TxDB) assertionLogsMatch(firstIndex uint64, count uint64, lastLogHash common.Hash) (bool, error) {
+	return accumulatorMatches(db.GetLogProof, db.as.LogCount, firstIndex, count, lastLogHash)
+}
+
+func accumulatorMatches(
+	getProof func(index, firstIndex, count uint64) (*valprotocol.AccumulatorProof, value.Value, error),
+	getCount func() (uint64, error),
+	firstIndex uint64,
+	count uint64,
+	lastHash common.Hash,
+) (bool, error) {
+	if count == 0 {
+		return true, nil
+	}
+	total, err := getCount()
+	if err != nil {
+		return false, err
+	}
+	if firstIndex+count > total {
+		return false, nil
+	}
+	proof, _, err := getProof(firstIndex+count-1, firstIndex, count)
+	if err != nil {
+		return false, err
+	}
+	return proof.Accumulator() == lastHash, nil
+}
+
+// GetAssertedMessageProof proves that the output message at index was
+// included in the most recent assertion made on L1 which output it. The
+// accumulator of the proof is the assertion's LastMessageHash
+func (db *TxDB) GetAssertedMessageProof(index uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	r, ok := db.progress.assertionWithMessage(index)
+	if !ok {
+		return nil, nil, fmt.Errorf("output message %v isn't included in any assertion", index)
+	}
+	return verifiedProof(db.GetMessageProof, index, r.firstMessage, r.messageCount, r.lastMessageHash)
+}
+
+// GetAssertedLogProof proves that the log at index was included in the most
+// recent assertion made on L1 which output it. The accumulator of the proof
+// is the assertion's LastLogHash
+func (db *TxDB) GetAssertedLogProof(index uint64) (*valprotocol.AccumulatorProof, value.Value, error) {
+	r, ok := db.progress.assertionWithLog(index)
+	if !ok {
+		return nil, nil, fmt.Errorf("log %v isn't included in any assertion", index)
+	}
+	return verifiedProof(db.GetLogProof, index, r.firstLog, r.logCount, r.lastLogHash)
+}
+
+func verifiedProof(
+	getProof func(index, firstIndex, count uint64) (*valprotocol.AccumulatorProof, value.Value, error),
+	index uint64,
+	firstIndex uint64,
+	count uint64,
+	lastHash common.Hash,
+) (*valprotocol.AccumulatorProof, value.Value, error) {
+	proof, val, err := getProof(index, firstIndex, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !proof.Verify(val.Hash(), lastHash) {
+		return nil, nil, fmt.Errorf("saved values don't match assertion covering [%v, %v)", firstIndex, firstIndex+count)
+	}
+	return proof, val, nil
+}
+
+// GetWithdrawals returns the withdrawals sent or received by address in the
+// order they were made
+func (db *TxDB) GetWithdrawals(address common.Address) ([]*Withdrawal, error) {
+	messageCount, err := db.as.MessageCount()
+	if err != nil {
+		return nil, err
+	}
+	withdrawals := make([]*Withdrawal, 0)
+	seen := make(map[uint64]bool)
+	for _, index := range db.withdrawals.get(address) {
+		if index >= messageCount || seen[index] {
+			// The message was removed by a reorg or was already returned
+			continue
+		}
+		seen[index] = true
+		msgVal, err := db.as.GetMessage(index)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := message.NewOutMessageFromValue(msgVal)
+		if err != nil {
+			return nil, err
+		}
+		withdrawal, ok := newWithdrawalFromMessage(index, msg)
+		if !ok {
+			continue
+		}
+		if withdrawal.Sender != address && withdrawal.Dest != address {
+			// The message at index was replaced after a reorg
+			continue
+		}
+		if logIndex := db.as.GetPossibleMessageLog(index); logIndex != nil {
+			logVal, err := db.as.GetLog(*logIndex)
+			if err != nil {
+				return nil, err
+			}
+			res, err := evm.NewTxResultFromValue(logVal)
+			if err == nil {
+				requestId := res.IncomingRequest.MessageID
+				withdrawal.RequestId = &requestId
+			}
+		}
+		withdrawal.Status = db.progress.status(index)
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, nil
+}
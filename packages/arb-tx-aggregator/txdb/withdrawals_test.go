@@ -0,0 +1,239 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package txdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+)
+
+func TestRollupProgress(t *testing.T) {
+	p := newRollupProgress()
+	leaf1 := common.RandHash()
+	leaf2 := common.RandHash()
+	p.asserted(leaf1, 3)
+	p.asserted(leaf2, 5)
+
+	if p.status(2) != WithdrawalAsserted || p.status(4) != WithdrawalAsserted {
+		t.Error("asserted messages should be asserted")
+	}
+	if p.status(5) != WithdrawalPending {
+		t.Error("message after assertions should be pending")
+	}
+
+	// Confirming an unknown node doesn't confirm any messages
+	p.confirmed(common.RandHash())
+	if p.status(0) != WithdrawalAsserted {
+		t.Error("message confirmed by unknown node")
+	}
+
+	p.confirmed(leaf1)
+	if p.status(2) != WithdrawalConfirmed {
+		t.Error("message in confirmed assertion should be confirmed")
+	}
+	if p.status(3) != WithdrawalAsserted {
+		t.Error("message after confirmed assertion should be asserted")
+	}
+
+	restored, err := unmarshalRollupProgress(p.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.assertedMessageCount != 5 || restored.confirmedMessageCount != 3 {
+		t.Error("wrong counts after restore", restored.assertedMessageCount, restored.confirmedMessageCount)
+	}
+	if len(restored.validLeaves) != 1 || restored.validLeaves[leaf2] != 5 {
+		t.Error("wrong leaves after restore", restored.validLeaves)
+	}
+
+	empty, err := unmarshalRollupProgress(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.status(0) != WithdrawalPending {
+		t.Error("progress from old checkpoint should be empty")
+	}
+	if _, err := unmarshalRollupProgress([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for invalid progress data")
+	}
+}
+
+func TestRollupProgressAssertions(t *testing.T) {
+	p := newRollupProgress()
+	first := assertionRange{
+		firstMessage:    0,
+		messageCount:    2,
+		lastMessageHash: common.RandHash(),
+		firstLog:        0,
+		logCount:        3,
+		lastLogHash:     common.RandHash(),
+	}
+	second := assertionRange{
+		firstMessage: 2,
+		messageCount: 0,
+		firstLog:     3,
+		logCount:     2,
+		lastLogHash:  common.RandHash(),
+	}
+	p.addAssertion(first)
+	p.addAssertion(second)
+	p.addAssertion(first)
+	if len(p.assertions) != 2 {
+		t.Fatal("duplicate assertion recorded")
+	}
+
+	if r, ok := p.assertionWithMessage(1); !ok || r != first {
+		t.Error("wrong assertion for message 1")
+	}
+	if _, ok := p.assertionWithMessage(2); ok {
+		t.Error("found assertion for message which wasn't asserted")
+	}
+	if r, ok := p.assertionWithLog(3); !ok || r != second {
+		t.Error("wrong assertion for log 3")
+	}
+	if _, ok := p.assertionWithLog(5); ok {
+		t.Error("found assertion for log which wasn't asserted")
+	}
+
+	// A later assertion covering the same outputs is preferred
+	longer := first
+	longer.messageCount = 4
+	longer.lastMessageHash = common.RandHash()
+	p.addAssertion(longer)
+	if r, ok := p.assertionWithMessage(1); !ok || r != longer {
+		t.Error("expected most recent assertion for message 1")
+	}
+
+	p.asserted(common.RandHash(), 4)
+	restored, err := unmarshalRollupProgress(p.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.assertions) != 3 || restored.assertions[1] != second || restored.assertions[2] != longer {
+		t.Error("wrong assertions after restore", restored.assertions)
+	}
+	if len(restored.validLeaves) != 1 {
+		t.Error("wrong leaves after restore", restored.validLeaves)
+	}
+}
+
+func saveEthWithdrawal(t *testing.T, as machine.AggregatorStore, sender, dest common.Address) {
+	t.Helper()
+	msg := message.NewOutMessage(message.Eth{Dest: dest, Value: big.NewInt(10)}, sender)
+	if err := as.SaveMessage(msg.AsValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkWithdrawals(t *testing.T, db *TxDB, address common.Address, indexes []uint64) {
+	t.Helper()
+	withdrawals, err := db.GetWithdrawals(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawals) != len(indexes) {
+		t.Fatalf("expected %v withdrawals for %v but got %v", len(indexes), address, len(withdrawals))
+	}
+	for i, withdrawal := range withdrawals {
+		if withdrawal.MessageIndex != indexes[i] {
+			t.Error("wrong withdrawal", withdrawal.MessageIndex, "expected", indexes[i])
+		}
+		if withdrawal.Sender != address && withdrawal.Dest != address {
+			t.Error("withdrawal", withdrawal.MessageIndex, "doesn't involve", address)
+		}
+	}
+}
+
+func TestGetWithdrawalsReorg(t *testing.T) {
+	storage := gomachine.NewCheckpointStorage()
+	cp := checkpointing.NewIndexedCheckpointerWithStorage(
+		storage,
+		storage.GetBlockStore(),
+		checkpointing.Config{MaxReorgHeight: big.NewInt(10)},
+	)
+	as := cp.GetAggregatorStore()
+	db := New(nil, cp, as, common.RandAddress())
+
+	addr1 := common.RandAddress()
+	addr2 := common.RandAddress()
+	addr3 := common.RandAddress()
+	saveEthWithdrawal(t, as, addr1, addr2)
+	saveEthWithdrawal(t, as, addr1, addr1)
+	saveEthWithdrawal(t, as, addr3, addr2)
+	if err := db.indexWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	// Indexing again must not duplicate withdrawals
+	db.withdrawals.reset()
+	if err := db.indexWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.indexWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	checkWithdrawals(t, db, addr1, []uint64{0, 1})
+	checkWithdrawals(t, db, addr2, []uint64{0, 2})
+	checkWithdrawals(t, db, addr3, []uint64{2})
+
+	// Replace the last two messages with a withdrawal between other accounts
+	if err := db.reorgStore(0, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	addr4 := common.RandAddress()
+	saveEthWithdrawal(t, as, addr3, addr4)
+	if err := db.indexWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	checkWithdrawals(t, db, addr1, []uint64{0})
+	checkWithdrawals(t, db, addr2, []uint64{0})
+	checkWithdrawals(t, db, addr3, []uint64{1})
+	checkWithdrawals(t, db, addr4, []uint64{1})
+}
+
+func TestWithdrawalIndexRemoveFrom(t *testing.T) {
+	wi := newWithdrawalIndex()
+	addr1 := common.RandAddress()
+	addr2 := common.RandAddress()
+	wi.add(&Withdrawal{MessageIndex: 0, Sender: addr1, Dest: addr2})
+	wi.add(&Withdrawal{MessageIndex: 3, Sender: addr1, Dest: addr1})
+	wi.add(&Withdrawal{MessageIndex: 3, Sender: addr1, Dest: addr1})
+	wi.indexed(4)
+	if indexes := wi.get(addr1); len(indexes) != 2 {
+		t.Fatal("wrong indexes", indexes)
+	}
+
+	wi.removeFrom(3)
+	if indexes := wi.get(addr1); len(indexes) != 1 || indexes[0] != 0 {
+		t.Error("wrong indexes after reorg", indexes)
+	}
+	if indexes := wi.get(addr2); len(indexes) != 1 {
+		t.Error("wrong indexes after reorg", indexes)
+	}
+	if wi.indexedCount() != 3 {
+		t.Error("wrong indexed count after reorg", wi.indexedCount())
+	}
+	wi.removeFrom(0)
+	if len(wi.byAddress) != 0 {
+		t.Error("addresses without withdrawals left in index")
+	}
+}
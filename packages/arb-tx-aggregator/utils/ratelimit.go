@@ -0,0 +1,107 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package utils
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimitCleanupInterval is how often the buckets of ip addresses which
+// haven't made requests recently are dropped
+const rateLimitCleanupInterval = time.Minute
+
+// tokenBucket allows burst requests at once and refills at rate requests per
+// second
+type tokenBucket struct {
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// rateLimiter limits the rate of requests from each ip address
+type rateLimiter struct {
+	mut         sync.Mutex
+	rate        float64
+	burst       float64
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// allow reports whether a request from ip made at now is within the limit
+func (l *rateLimiter) allow(ip string, now time.Time) bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if now.Sub(l.lastCleanup) > rateLimitCleanupInterval {
+		for key, bucket := range l.buckets {
+			// A bucket that would have refilled can be recreated later
+			if now.Sub(bucket.lastUpdate).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastUpdate: now}
+		l.buckets[ip] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.lastUpdate).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.lastUpdate = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// newRateLimitHandler rejects requests from ip addresses which exceed the
+// rate limit. The ip is taken from the connection rather than from headers
+// which the client controls. For websocket connections only the initial
+// request is counted
+func newRateLimitHandler(rate float64, burst int, next http.Handler) http.Handler {
+	limiter := newRateLimiter(rate, burst)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !limiter.allow(ip, time.Now()) {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
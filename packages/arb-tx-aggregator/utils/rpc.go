@@ -17,62 +17,150 @@
 package utils
 
 import (
-	"flag"
-	"github.com/ethereum/go-ethereum/rpc"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/config"
 )
 
-type RPCFlags struct {
-	certFile *string
-	keyFile  *string
+// EndpointConfig configures one of the endpoints the aggregator serves its
+// API on
+type EndpointConfig struct {
+	// Addr is the address to listen on. The endpoint is disabled if it is
+	// empty
+	Addr string
+	// Namespaces are the rpc namespaces exposed on the endpoint
+	Namespaces []string
 }
 
-func AddRPCFlags(fs *flag.FlagSet) RPCFlags {
-	certFile := fs.String("cert", "", "path to certificate file (if using ssl)")
-	privkeyFile := fs.String("privkey", "", "path to private key file (if using ssl)")
+// RPCConfig configures the http and websocket endpoints of the aggregator
+type RPCConfig struct {
+	HTTP EndpointConfig
+	WS   EndpointConfig
+
+	// CORSOrigins are the origins browsers may send requests from
+	CORSOrigins []string
+	// VHosts are the host names accepted in the Host header of http
+	// requests, with * accepting any host
+	VHosts []string
+
+	// RateLimit is the number of requests per second accepted from each IP
+	// address, with 0 meaning unlimited. RateBurst requests may be made at
+	// once before the limit applies
+	RateLimit float64
+	RateBurst int
+
+	CertFile string
+	KeyFile  string
+}
 
-	return RPCFlags{
-		certFile: certFile,
-		keyFile:  privkeyFile,
+// NewRPCConfig converts the rpc section of the configuration file. Endpoints
+// without namespaces configured expose defaultAPI
+func NewRPCConfig(cfg config.RPCConfig, defaultAPI []string) RPCConfig {
+	httpAPI := cfg.HTTPAPI
+	if len(httpAPI) == 0 {
+		httpAPI = defaultAPI
+	}
+	wsAPI := cfg.WSAPI
+	if len(wsAPI) == 0 {
+		wsAPI = defaultAPI
+	}
+	return RPCConfig{
+		HTTP: EndpointConfig{
+			Addr:       cfg.HTTPAddr,
+			Namespaces: httpAPI,
+		},
+		WS: EndpointConfig{
+			Addr:       cfg.WSAddr,
+			Namespaces: wsAPI,
+		},
+		CORSOrigins: cfg.CORS,
+		VHosts:      cfg.VHosts,
+		RateLimit:   cfg.RateLimit,
+		RateBurst:   cfg.RateBurst,
+		CertFile:    cfg.CertFile,
+		KeyFile:     cfg.KeyFile,
 	}
 }
 
-func LaunchRPC(handler http.Handler, port string, flags RPCFlags) error {
+func LaunchRPC(handler http.Handler, config RPCConfig) error {
 	r := mux.NewRouter()
-	r.Handle("/", handler).Methods("GET", "POST", "OPTIONS")
-	return launchServer(r, port, flags)
+	r.Handle("/", newVHostHandler(config.VHosts, handler)).Methods("GET", "POST", "OPTIONS")
+	return launchServer(r, config.HTTP.Addr, config)
 }
 
-func LaunchWS(server *rpc.Server, port string, flags RPCFlags) error {
-	return launchServer(server.WebsocketHandler([]string{"0.0.0.0"}), port, flags)
+func LaunchWS(server *rpc.Server, config RPCConfig) error {
+	return launchServer(server.WebsocketHandler(config.CORSOrigins), config.WS.Addr, config)
 }
 
-func launchServer(handler http.Handler, port string, flags RPCFlags) error {
-	headersOk := handlers.AllowedHeaders(
-		[]string{"X-Requested-With", "Content-Type", "Authorization"},
-	)
-	originsOk := handlers.AllowedOrigins([]string{"*"})
-	methodsOk := handlers.AllowedMethods(
-		[]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"},
-	)
-	h := handlers.CORS(headersOk, originsOk, methodsOk)(handler)
-
-	if flags.certFile != nil && flags.keyFile != nil && *flags.certFile != "" && *flags.keyFile != "" {
-		log.Println("Launching rpc server over https with cert", *flags.certFile, "and key", *flags.keyFile)
+// vhostHandler rejects requests whose Host header isn't one of the allowed
+// virtual hosts, protecting against DNS rebinding
+type vhostHandler struct {
+	vhosts map[string]bool
+	next   http.Handler
+}
+
+func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool)
+	for _, vhost := range vhosts {
+		if vhost == "*" {
+			return next
+		}
+		allowed[strings.ToLower(vhost)] = true
+	}
+	return &vhostHandler{vhosts: allowed, next: next}
+}
+
+func (h *vhostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	// Requests without a host, and those addressed by ip, can't be the
+	// result of DNS rebinding
+	if host == "" || net.ParseIP(host) != nil || h.vhosts[strings.ToLower(host)] {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, "invalid host specified", http.StatusForbidden)
+}
+
+func launchServer(handler http.Handler, addr string, config RPCConfig) error {
+	h := handler
+	// With no origins, the CORS handler would allow any origin, so it's left
+	// out to disallow all cross origin requests instead
+	if len(config.CORSOrigins) > 0 {
+		headersOk := handlers.AllowedHeaders(
+			[]string{"X-Requested-With", "Content-Type", "Authorization"},
+		)
+		originsOk := handlers.AllowedOrigins(config.CORSOrigins)
+		methodsOk := handlers.AllowedMethods(
+			[]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"},
+		)
+		h = handlers.CORS(headersOk, originsOk, methodsOk)(h)
+	}
+	if config.RateLimit > 0 {
+		h = newRateLimitHandler(config.RateLimit, config.RateBurst, h)
+	}
+
+	if config.CertFile != "" && config.KeyFile != "" {
+		log.Println("Launching rpc server on", addr, "over https with cert", config.CertFile, "and key", config.KeyFile)
 		return http.ListenAndServeTLS(
-			":"+port,
-			*flags.certFile,
-			*flags.keyFile,
+			addr,
+			config.CertFile,
+			config.KeyFile,
 			h,
 		)
 	} else {
-		log.Println("Launching rpc server over http")
+		log.Println("Launching rpc server on", addr, "over http")
 		return http.ListenAndServe(
-			":"+port,
+			addr,
 			h,
 		)
 	}
@@ -0,0 +1,74 @@
+/*
+* Copyright 2020, Offchain Labs, Inc.
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*    http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !limiter.allow("1.2.3.4", now) {
+			t.Fatal("request within burst rejected", i)
+		}
+	}
+	if limiter.allow("1.2.3.4", now) {
+		t.Error("request over burst accepted")
+	}
+	if !limiter.allow("5.6.7.8", now) {
+		t.Error("limit applied to wrong ip")
+	}
+	// At 2 requests per second, one more request is allowed after half a
+	// second
+	now = now.Add(time.Millisecond * 500)
+	if !limiter.allow("1.2.3.4", now) {
+		t.Error("request rejected after refill")
+	}
+	if limiter.allow("1.2.3.4", now) {
+		t.Error("request accepted before refill")
+	}
+
+	// Idle buckets are dropped once they would have refilled
+	limiter.allow("1.2.3.4", now.Add(rateLimitCleanupInterval*2))
+	if len(limiter.buckets) != 1 {
+		t.Error("expected idle buckets to be removed, got", len(limiter.buckets))
+	}
+}
+
+func TestVHostHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := newVHostHandler([]string{"rpc.example.com"}, ok)
+	for host, expected := range map[string]int{
+		"rpc.example.com":      http.StatusOK,
+		"RPC.example.com:8547": http.StatusOK,
+		"127.0.0.1:8547":       http.StatusOK,
+		"evil.example.com":     http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != expected {
+			t.Errorf("host %v got status %v, expected %v", host, rec.Code, expected)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	errors2 "github.com/pkg/errors"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/aggregator"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
+)
+
+// Arb implements Arbitrum specific RPC methods
+type Arb struct {
+	srv *aggregator.Server
+}
+
+func NewArb(srv *aggregator.Server) *Arb {
+	return &Arb{srv: srv}
+}
+
+// BatchStatus returns the batches the aggregator has submitted to L1 which
+// haven't been confirmed yet
+func (a *Arb) BatchStatus() []*BatchStatusResult {
+	batches := a.srv.InFlightBatches()
+	results := make([]*BatchStatusResult, 0, len(batches))
+	for _, batch := range batches {
+		results = append(results, &BatchStatusResult{
+			L1TxHash:      batch.TxHashes[len(batch.TxHashes)-1],
+			L1TxHashes:    batch.TxHashes,
+			TxCount:       hexutil.Uint64(batch.TxCount),
+			SentAt:        hexutil.Uint64(batch.SentAt.Unix()),
+			Resends:       hexutil.Uint64(len(batch.TxHashes) - 1),
+			CalldataSaved: hexutil.Uint64(batch.CalldataSaved),
+			Abandoned:     batch.Abandoned,
+		})
+	}
+	return results
+}
+
+// GetOutputMessageProof proves that the output message at index was included
+// in an assertion made on L1. The returned accumulator matches the
+// LastMessageHash of the most recent assertion which output the message. The
+// proof is a hash chain proof, see AccumulatorProofResult
+func (a *Arb) GetOutputMessageProof(index hexutil.Uint64) (*AccumulatorProofResult, error) {
+	proof, val, err := a.srv.GetMessageProof(uint64(index))
+	if err != nil {
+		return nil, err
+	}
+	return newAccumulatorProofResult(uint64(index), proof, val)
+}
+
+// GetLogProof proves that the log at index was included in an assertion made
+// on L1. The returned accumulator matches the LastLogHash of the most recent
+// assertion which output the log. The proof is a hash chain proof, see
+// AccumulatorProofResult
+func (a *Arb) GetLogProof(index hexutil.Uint64) (*AccumulatorProofResult, error) {
+	proof, val, err := a.srv.GetLogProof(uint64(index))
+	if err != nil {
+		return nil, err
+	}
+	return newAccumulatorProofResult(uint64(index), proof, val)
+}
+
+// GetTransactionResultProof proves that the result log of the transaction
+// with the given hash was included in an assertion made on L1
+func (a *Arb) GetTransactionResultProof(txHash ethcommon.Hash) (*AccumulatorProofResult, error) {
+	logIndex, err := a.srv.GetRequestLogIndex(common.NewHashFromEth(txHash))
+	if err != nil {
+		return nil, err
+	}
+	if logIndex == nil {
+		return nil, errors.New("transaction not found")
+	}
+	return a.GetLogProof(hexutil.Uint64(*logIndex))
+}
+
+func newAccumulatorProofResult(index uint64, proof *valprotocol.AccumulatorProof, val value.Value) (*AccumulatorProofResult, error) {
+	var buf bytes.Buffer
+	if err := value.MarshalValue(val, &buf); err != nil {
+		return nil, err
+	}
+	suffixHashes := make([]ethcommon.Hash, 0, len(proof.SuffixHashes))
+	for _, valHash := range proof.SuffixHashes {
+		suffixHashes = append(suffixHashes, valHash.ToEthHash())
+	}
+	return &AccumulatorProofResult{
+		Index:        hexutil.Uint64(index),
+		Value:        buf.Bytes(),
+		ValueHash:    proof.ValueHash.ToEthHash(),
+		PrefixAcc:    proof.PrefixAcc.ToEthHash(),
+		SuffixHashes: suffixHashes,
+		Accumulator:  proof.Accumulator().ToEthHash(),
+	}, nil
+}
+
+// GetWithdrawals returns the withdrawals sent or received by address along
+// with their progress towards being claimable on L1
+func (a *Arb) GetWithdrawals(address ethcommon.Address) ([]*WithdrawalResult, error) {
+	withdrawals, err := a.srv.GetWithdrawals(common.NewAddressFromEth(address))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*WithdrawalResult, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		res := &WithdrawalResult{
+			MessageIndex: hexutil.Uint64(withdrawal.MessageIndex),
+			Sender:       withdrawal.Sender.ToEthAddress(),
+			Destination:  withdrawal.Dest.ToEthAddress(),
+			Status:       withdrawal.Status.String(),
+		}
+		switch withdrawal.Kind {
+		case message.EthType:
+			res.Type = "eth"
+			res.Amount = (*hexutil.Big)(withdrawal.Value)
+		case message.ERC20Type:
+			res.Type = "erc20"
+			res.Amount = (*hexutil.Big)(withdrawal.Value)
+		case message.ERC721Type:
+			res.Type = "erc721"
+			res.TokenId = (*hexutil.Big)(withdrawal.Value)
+		}
+		if withdrawal.Kind != message.EthType {
+			token := withdrawal.Token.ToEthAddress()
+			res.Token = &token
+		}
+		if withdrawal.RequestId != nil {
+			requestId := withdrawal.RequestId.ToEthHash()
+			res.RequestId = &requestId
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// AddressTableSize returns the number of addresses registered in the address
+// table
+func (a *Arb) AddressTableSize(blockNum *rpc.BlockNumber) (*hexutil.Big, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	size, err := snap.AddressTableSize()
+	if err != nil {
+		return nil, errors2.Wrap(err, "error getting address table size")
+	}
+	return (*hexutil.Big)(size), nil
+}
+
+func (a *Arb) AddressTableAddressExists(address ethcommon.Address, blockNum *rpc.BlockNumber) (bool, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return false, err
+	}
+	exists, err := snap.AddressTableAddressExists(common.NewAddressFromEth(address))
+	if err != nil {
+		return false, errors2.Wrap(err, "error checking address table")
+	}
+	return exists, nil
+}
+
+// AddressTableLookup returns the index of address in the address table. It
+// fails if the address isn't registered
+func (a *Arb) AddressTableLookup(address ethcommon.Address, blockNum *rpc.BlockNumber) (*hexutil.Big, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	index, err := snap.AddressTableLookup(common.NewAddressFromEth(address))
+	if err != nil {
+		return nil, errors2.Wrap(err, "error looking up address")
+	}
+	return (*hexutil.Big)(index), nil
+}
+
+// AddressTableLookupIndex returns the address registered at index in the
+// address table
+func (a *Arb) AddressTableLookupIndex(index *hexutil.Big, blockNum *rpc.BlockNumber) (ethcommon.Address, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return ethcommon.Address{}, err
+	}
+	address, err := snap.AddressTableLookupIndex((*big.Int)(index))
+	if err != nil {
+		return ethcommon.Address{}, errors2.Wrap(err, "error looking up address index")
+	}
+	return address.ToEthAddress(), nil
+}
+
+// AddressTableCompress returns the compressed encoding of address, which uses
+// its index in the address table if it is registered
+func (a *Arb) AddressTableCompress(address ethcommon.Address, blockNum *rpc.BlockNumber) (hexutil.Bytes, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := snap.AddressTableCompress(common.NewAddressFromEth(address))
+	if err != nil {
+		return nil, errors2.Wrap(err, "error compressing address")
+	}
+	return buf, nil
+}
+
+// AddressTableDecompress decodes the compressed address at offset in buf
+func (a *Arb) AddressTableDecompress(
+	buf hexutil.Bytes,
+	offset hexutil.Uint64,
+	blockNum *rpc.BlockNumber,
+) (*AddressTableDecompressResult, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	address, nextOffset, err := snap.AddressTableDecompress(buf, new(big.Int).SetUint64(uint64(offset)))
+	if err != nil {
+		return nil, errors2.Wrap(err, "error decompressing address")
+	}
+	return &AddressTableDecompressResult{
+		Address: address.ToEthAddress(),
+		Offset:  hexutil.Uint64(nextOffset.Uint64()),
+	}, nil
+}
+
+// maxFunctionTableEntries is the largest number of entries returned by a
+// single call to GetFunctionTable
+const maxFunctionTableEntries = 256
+
+// functionTableEnd returns the index after the last entry of the page of a
+// function table with size entries which starts at start and holds at most
+// count entries, or maxFunctionTableEntries if count isn't set
+func functionTableEnd(size uint64, start uint64, count *hexutil.Uint64) (uint64, error) {
+	if start > size {
+		return 0, fmt.Errorf("start %v is past the end of the function table of size %v", start, size)
+	}
+	limit := uint64(maxFunctionTableEntries)
+	if count != nil && uint64(*count) < limit {
+		limit = uint64(*count)
+	}
+	if size-start > limit {
+		return start + limit, nil
+	}
+	return size, nil
+}
+
+// FunctionTableSize returns the number of entries in the function table
+// uploaded by address
+func (a *Arb) FunctionTableSize(address ethcommon.Address, blockNum *rpc.BlockNumber) (hexutil.Uint64, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return 0, err
+	}
+	size, err := snap.FunctionTableSize(common.NewAddressFromEth(address))
+	if err != nil {
+		return 0, errors2.Wrap(err, "error getting function table size")
+	}
+	return hexutil.Uint64(size.Uint64()), nil
+}
+
+// GetFunctionTable returns up to count entries of the function table uploaded
+// by address starting with the entry at start. At most
+// maxFunctionTableEntries are returned, so larger tables must be fetched in
+// pages using FunctionTableSize
+func (a *Arb) GetFunctionTable(
+	address ethcommon.Address,
+	start hexutil.Uint64,
+	count *hexutil.Uint64,
+	blockNum *rpc.BlockNumber,
+) ([]*FunctionTableEntryResult, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	account := common.NewAddressFromEth(address)
+	size, err := snap.FunctionTableSize(account)
+	if err != nil {
+		return nil, errors2.Wrap(err, "error getting function table size")
+	}
+	end, err := functionTableEnd(size.Uint64(), uint64(start), count)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*FunctionTableEntryResult, 0, end-uint64(start))
+	for i := uint64(start); i < end; i++ {
+		entry, err := snap.FunctionTableGet(account, new(big.Int).SetUint64(i))
+		if err != nil {
+			return nil, errors2.Wrap(err, "error getting function table entry")
+		}
+		entries = append(entries, &FunctionTableEntryResult{
+			FuncID:  entry.FuncID[:],
+			Payable: entry.Payable != 0,
+			MaxGas:  (*hexutil.Big)(entry.MaxGas),
+		})
+	}
+	return entries, nil
+}
+
+// GetBLSPublicKey returns the BLS public key registered by address. It fails
+// if the address hasn't registered a key
+func (a *Arb) GetBLSPublicKey(address ethcommon.Address, blockNum *rpc.BlockNumber) (*BLSPublicKeyResult, error) {
+	snap, err := getSnapshot(a.srv, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	key, err := snap.GetBLSPublicKey(common.NewAddressFromEth(address))
+	if err != nil {
+		return nil, errors2.Wrap(err, "error getting BLS public key")
+	}
+	return &BLSPublicKeyResult{
+		X0: (*hexutil.Big)(key[0]),
+		X1: (*hexutil.Big)(key[1]),
+		Y0: (*hexutil.Big)(key[2]),
+		Y1: (*hexutil.Big)(key[3]),
+	}, nil
+}
+
+// GetOutputMessage returns the serialized output message at index
+func (a *Arb) GetOutputMessage(index hexutil.Uint64) (hexutil.Bytes, error) {
+	var reply evm.GetOutputMessageReply
+	if err := a.srv.GetOutputMessage(&evm.GetOutputMessageArgs{Index: uint64(index)}, &reply); err != nil {
+		return nil, err
+	}
+	return hexutil.Decode(reply.RawVal)
+}
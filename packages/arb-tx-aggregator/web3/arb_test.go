@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestFunctionTableEnd(t *testing.T) {
+	count := func(c uint64) *hexutil.Uint64 {
+		ret := hexutil.Uint64(c)
+		return &ret
+	}
+	tests := []struct {
+		size  uint64
+		start uint64
+		count *hexutil.Uint64
+		end   uint64
+	}{
+		{10, 0, nil, 10},
+		{10, 4, count(3), 7},
+		{10, 4, count(20), 10},
+		{10, 10, nil, 10},
+		{10, 0, count(0), 0},
+		{1000, 0, nil, maxFunctionTableEntries},
+		{1000, 900, nil, 1000},
+		{1000, 0, count(5000), maxFunctionTableEntries},
+	}
+	for _, test := range tests {
+		end, err := functionTableEnd(test.size, test.start, test.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end != test.end {
+			t.Errorf("page of table of size %v starting at %v ended at %v instead of %v", test.size, test.start, end, test.end)
+		}
+	}
+
+	if _, err := functionTableEnd(10, 11, nil); err == nil {
+		t.Error("expected error for start past the end of the table")
+	}
+}
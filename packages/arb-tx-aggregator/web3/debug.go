@@ -0,0 +1,177 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/aggregator"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/snapshot"
+	arbcommon "github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+type Debug struct {
+	srv *aggregator.Server
+	eth *Server
+}
+
+func NewDebug(srv *aggregator.Server) *Debug {
+	return &Debug{srv: srv, eth: NewServer(srv)}
+}
+
+// TraceTransaction replays the request with the given Ethereum transaction
+// hash or request id
+func (d *Debug) TraceTransaction(txHash common.Hash, config *TraceConfig) (*TraceResult, error) {
+	original, trace, err := d.srv.TraceRequest(arbcommon.NewHashFromEth(txHash))
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, errors.New("transaction not found")
+	}
+	if trace.Result.ResultCode != original.ResultCode {
+		return nil, fmt.Errorf(
+			"replay produced result code %v but original result was %v",
+			trace.Result.ResultCode,
+			original.ResultCode,
+		)
+	}
+	return newTraceResult(newCallFrames(trace), trace, config), nil
+}
+
+func (d *Debug) TraceCall(callArgs CallTxArgs, blockNum *rpc.BlockNumber, config *TraceConfig) (*TraceResult, error) {
+	snap, err := d.eth.getSnapshot(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	from, msg := buildCallMsg(callArgs)
+	msg = d.srv.AdjustGas(msg)
+	trace, err := snap.TraceMessage(message.NewSafeL2Message(msg), from)
+	if err != nil {
+		return nil, err
+	}
+
+	// A call can't be converted back into a transaction so its frame is built
+	// from the call arguments
+	frames := newCallFrames(trace)
+	frame := &CallFrame{
+		Type:  "CALL",
+		From:  from.ToEthAddress(),
+		To:    callArgs.To,
+		Value: (*hexutil.Big)(msg.Payment),
+		Gas:   hexutil.Uint64(msg.MaxGas.Uint64()),
+		Input: msg.Data,
+	}
+	if callArgs.To == nil {
+		frame.Type = "CREATE"
+	}
+	frame.setResult(trace.Result)
+	frames[len(frames)-1] = frame
+	return newTraceResult(frames, trace, config), nil
+}
+
+// newCallFrames returns a frame for every transaction result produced by the
+// traced message, in the order they were executed
+func newCallFrames(trace *snapshot.TraceResult) []*CallFrame {
+	frames := make([]*CallFrame, 0, len(trace.Results))
+	for _, res := range trace.Results {
+		frame := &CallFrame{
+			Type:  "CALL",
+			From:  res.IncomingRequest.Sender.ToEthAddress(),
+			Value: (*hexutil.Big)(big.NewInt(0)),
+			Input: res.IncomingRequest.Data,
+		}
+		if processed, err := evm.GetTransaction(res); err == nil {
+			frame.setTx(processed.Tx)
+		}
+		frame.setResult(res)
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func (f *CallFrame) setTx(tx *types.Transaction) {
+	f.To = tx.To()
+	if tx.To() == nil {
+		f.Type = "CREATE"
+	}
+	f.Value = (*hexutil.Big)(tx.Value())
+	f.Gas = hexutil.Uint64(tx.Gas())
+	f.Input = tx.Data()
+}
+
+// setResult fills in the outcome of the frame. The AVM doesn't report internal
+// EVM call boundaries so the result describes the whole transaction
+func (f *CallFrame) setResult(res *evm.TxResult) {
+	f.GasUsed = hexutil.Uint64(res.GasUsed.Uint64())
+	f.Output = res.ReturnData
+	f.Logs = res.EthLogs(arbcommon.Hash{})
+	if res.ResultCode == evm.ReturnCode {
+		return
+	}
+	f.Error = resultCodeDescription(res.ResultCode)
+	if res.ResultCode == evm.RevertCode {
+		if reason, err := abi.UnpackRevert(res.ReturnData); err == nil {
+			f.RevertReason = reason
+		}
+	}
+}
+
+func newTraceResult(frames []*CallFrame, trace *snapshot.TraceResult, config *TraceConfig) *TraceResult {
+	ret := &TraceResult{
+		ResultCode: hexutil.Uint64(trace.Result.ResultCode),
+		GasUsed:    hexutil.Uint64(trace.Result.GasUsed.Uint64()),
+		ArbGasUsed: hexutil.Uint64(trace.ArbGas),
+		Calls:      frames,
+	}
+	if config != nil && config.AVMSteps {
+		steps := hexutil.Uint64(trace.AVMSteps)
+		ret.AVMSteps = &steps
+	}
+	return ret
+}
+
+func resultCodeDescription(code evm.ResultType) string {
+	switch code {
+	case evm.ReturnCode:
+		return "success"
+	case evm.RevertCode:
+		return "execution reverted"
+	case evm.CongestionCode:
+		return "congestion"
+	case evm.InsufficientGasFundsCode:
+		return "insufficient funds for gas"
+	case evm.InsufficientTxFundsCode:
+		return "insufficient funds for transfer"
+	case evm.BadSequenceCode:
+		return "bad sequence number"
+	case evm.InvalidMessageFormatCode:
+		return "invalid message format"
+	default:
+		return fmt.Sprintf("unknown error (code %v)", int(code))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/snapshot"
+	arbcommon "github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+func TestTraceFrames(t *testing.T) {
+	rand.Seed(5243)
+	tx := message.Transaction{
+		MaxGas:      big.NewInt(100000),
+		GasPriceBid: big.NewInt(0),
+		SequenceNum: big.NewInt(2),
+		DestAddress: arbcommon.RandAddress(),
+		Payment:     big.NewInt(0),
+		Data:        []byte{1, 2, 3},
+	}
+	sent := evm.NewRandomResult(2)
+	sent.IncomingRequest.Kind = message.L2Type
+	sent.IncomingRequest.Data = message.NewSafeL2Message(tx).Data
+	sent.GasUsed = big.NewInt(50000)
+	sent.StartLogIndex = big.NewInt(0)
+
+	// Error(string) with the reason "no"
+	revertData := common.Hex2Bytes("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"6e6f000000000000000000000000000000000000000000000000000000000000")
+	reverted := evm.NewRandomResult(0)
+	reverted.IncomingRequest.Kind = message.L2Type
+	reverted.IncomingRequest.Data = message.NewSafeL2Message(tx).Data
+	reverted.ResultCode = evm.RevertCode
+	reverted.ReturnData = revertData
+	reverted.GasUsed = big.NewInt(30000)
+	reverted.StartLogIndex = big.NewInt(2)
+
+	trace := &snapshot.TraceResult{
+		Result:   reverted,
+		Results:  []*evm.TxResult{sent, reverted},
+		ArbGas:   1000,
+		AVMSteps: 20,
+	}
+	res := newTraceResult(newCallFrames(trace), trace, &TraceConfig{AVMSteps: true})
+	if len(res.Calls) != 2 {
+		t.Fatal("wrong number of frames", len(res.Calls))
+	}
+	ethTx := tx.AsEthTx()
+	for i, frame := range res.Calls {
+		if frame.To == nil || *frame.To != tx.DestAddress.ToEthAddress() {
+			t.Error("frame", i, "has wrong destination")
+		}
+		if frame.Value.ToInt().Cmp(ethTx.Value()) != 0 || uint64(frame.Gas) != ethTx.Gas() {
+			t.Error("frame", i, "has wrong transaction fields")
+		}
+	}
+	if res.Calls[0].Error != "" || res.Calls[0].GasUsed != 50000 || len(res.Calls[0].Logs) != 2 {
+		t.Error("wrong successful frame", res.Calls[0])
+	}
+	if res.Calls[1].Error != "execution reverted" || res.Calls[1].RevertReason != "no" {
+		t.Error("wrong reverted frame", res.Calls[1])
+	}
+	if res.ResultCode != hexutil.Uint64(evm.RevertCode) || res.GasUsed != 30000 || res.ArbGasUsed != 1000 {
+		t.Error("wrong trace result", res)
+	}
+	if res.AVMSteps == nil || *res.AVMSteps != 20 {
+		t.Error("wrong avm steps")
+	}
+}
+
+func TestTraceTransactionRejectsInvalidHash(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", &Debug{}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// Malformed hashes are rejected before the request is looked up
+	for _, txHash := range []string{"0x1234", "0x" + strings.Repeat("ab", 33), "hash"} {
+		var res TraceResult
+		err := client.Call(&res, "debug_traceTransaction", txHash)
+		if err == nil || !strings.Contains(err.Error(), "invalid argument") {
+			t.Error("expected invalid argument error for", txHash, "but got", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+)
+
+// revertError is returned when a call reverts. It matches the error returned
+// by geth so that clients can extract the revert reason in the same way
+type revertError struct {
+	error
+	reason string // revert reason hex encoded
+}
+
+func newRevertError(res *evm.TxResult) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(res.ReturnData); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(res.ReturnData),
+	}
+}
+
+// ErrorCode returns the JSON error code for a revert
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert reason
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
+// resultError converts an unsuccessful result into an error
+func resultError(res *evm.TxResult) error {
+	if res.ResultCode == evm.RevertCode {
+		return newRevertError(res)
+	}
+	return errors.New(resultCodeDescription(res.ResultCode))
+}
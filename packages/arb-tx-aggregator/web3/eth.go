@@ -100,6 +100,15 @@ func (s *Server) GetBlockTransactionCountByHash(blockHash common.Hash) (*hexutil
 }
 
 func (s *Server) GetBlockTransactionCountByNumber(blockNum *rpc.BlockNumber) (*hexutil.Big, error) {
+	if *blockNum == rpc.PendingBlockNumber {
+		header, txes, err := s.srv.PendingBlock()
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			return (*hexutil.Big)(big.NewInt(int64(len(txes)))), nil
+		}
+	}
 	height, err := s.blockNum(blockNum)
 	if err != nil {
 		return nil, err
@@ -140,16 +149,96 @@ func (s *Server) Call(callArgs CallTxArgs, blockNum *rpc.BlockNumber) (hexutil.B
 	if err != nil {
 		return nil, err
 	}
+	if res.ResultCode == evm.RevertCode {
+		return nil, newRevertError(res)
+	}
 	return res.ReturnData, nil
 }
 
+// EstimateGas binary searches for the lowest gas limit at which the
+// transaction succeeds against the pending state
 func (s *Server) EstimateGas(args CallTxArgs) (hexutil.Uint64, error) {
 	blockNum := rpc.PendingBlockNumber
-	res, err := s.executeCall(args, &blockNum)
+	snap, err := s.getSnapshot(&blockNum)
 	if err != nil {
 		return 0, err
 	}
-	return hexutil.Uint64(res.GasUsed.Uint64() + 1000000), nil
+	if snap == nil {
+		return 0, errors.New("no state available to estimate gas")
+	}
+	from, msg := buildCallMsg(args)
+
+	// The upper bound is the gas cap of the call, further limited by the
+	// amount of gas the sender can pay for
+	hi := s.srv.AdjustGas(msg).MaxGas.Uint64()
+	if msg.GasPriceBid.Sign() > 0 {
+		balance, err := snap.GetBalance(from)
+		if err != nil {
+			return 0, errors2.Wrap(err, "error getting balance")
+		}
+		available := new(big.Int).Sub(balance, msg.Payment)
+		if available.Sign() <= 0 {
+			return 0, errors.New("insufficient funds for transfer")
+		}
+		allowance := new(big.Int).Div(available, msg.GasPriceBid)
+		if allowance.IsUint64() && allowance.Uint64() < hi {
+			hi = allowance.Uint64()
+		}
+	}
+
+	nonce, err := snap.GetTransactionCount(from)
+	if err != nil {
+		return 0, errors2.Wrap(err, "error getting transaction count")
+	}
+	tx := message.Transaction{
+		GasPriceBid: msg.GasPriceBid,
+		SequenceNum: nonce,
+		DestAddress: msg.DestAddress,
+		Payment:     msg.Payment,
+		Data:        msg.Data,
+	}
+	requestId := snap.NextRequestId()
+	gas, err := searchGasLimit(hi, func(gas uint64) (*evm.TxResult, error) {
+		tx.MaxGas = new(big.Int).SetUint64(gas)
+		return snap.TryTx(message.NewSafeL2Message(tx), from, requestId)
+	})
+	return hexutil.Uint64(gas), err
+}
+
+// searchGasLimit returns the lowest gas limit no higher than hi at which
+// execute succeeds. Running out of gas shows up as a revert or as
+// insufficient gas funds, so only those results are taken to mean the limit
+// was too low and anything else fails the search
+func searchGasLimit(hi uint64, execute func(gas uint64) (*evm.TxResult, error)) (uint64, error) {
+	res, err := execute(hi)
+	if err != nil {
+		return 0, err
+	}
+	if res.ResultCode != evm.ReturnCode {
+		return 0, resultError(res)
+	}
+
+	// The transaction can't succeed with a limit lower than the gas it used
+	lo := uint64(0)
+	if used := res.GasUsed.Uint64(); used > 0 && used <= hi {
+		lo = used - 1
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		res, err := execute(mid)
+		if err != nil {
+			return 0, err
+		}
+		switch res.ResultCode {
+		case evm.ReturnCode:
+			hi = mid
+		case evm.RevertCode, evm.InsufficientGasFundsCode:
+			lo = mid
+		default:
+			return 0, resultError(res)
+		}
+	}
+	return hi, nil
 }
 
 func (s *Server) GetBlockByHash(blockHashRaw hexutil.Bytes, includeTxData bool) (*GetBlockResult, error) {
@@ -163,6 +252,15 @@ func (s *Server) GetBlockByHash(blockHashRaw hexutil.Bytes, includeTxData bool)
 }
 
 func (s *Server) GetBlockByNumber(blockNum *rpc.BlockNumber, includeTxData bool) (*GetBlockResult, error) {
+	if *blockNum == rpc.PendingBlockNumber {
+		header, txes, err := s.srv.PendingBlock()
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			return getPendingBlock(s.signer(), header, txes, includeTxData)
+		}
+	}
 	height, err := s.blockNum(blockNum)
 	if err != nil {
 		return nil, err
@@ -177,10 +275,18 @@ func (s *Server) GetBlockByNumber(blockNum *rpc.BlockNumber, includeTxData bool)
 func (s *Server) getTransactionInfoByHash(txHash hexutil.Bytes) (*evm.TxResult, *machine.BlockInfo, error) {
 	var requestId arbcommon.Hash
 	copy(requestId[:], txHash)
-	val, err := s.srv.GetRequestResult(requestId)
-	if err != nil || val == nil {
+	// The hash may be either the hash of an Ethereum transaction or the id of
+	// an L2 request which isn't an Ethereum transaction
+	val, err := s.srv.GetTxHashResult(requestId)
+	if err != nil {
 		return nil, nil, err
 	}
+	if val == nil {
+		val, err = s.srv.GetRequestResult(requestId)
+		if err != nil || val == nil {
+			return nil, nil, err
+		}
+	}
 
 	res, err := evm.NewTxResultFromValue(val)
 	if err != nil {
@@ -222,6 +328,18 @@ func (s *Server) GetTransactionByBlockHashAndIndex(blockHash common.Hash, index
 }
 
 func (s *Server) GetTransactionByBlockNumberAndIndex(blockNum *rpc.BlockNumber, index hexutil.Uint64) (*TransactionResult, error) {
+	if *blockNum == rpc.PendingBlockNumber {
+		header, txes, err := s.srv.PendingBlock()
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			if uint64(index) >= uint64(len(txes)) {
+				return nil, nil
+			}
+			return makePendingTransactionResult(s.signer(), txes[index])
+		}
+	}
 	height, err := s.blockNum(blockNum)
 	if err != nil {
 		return nil, err
@@ -336,6 +454,28 @@ func (s *Server) getBlock(block *machine.BlockInfo, includeTxData bool) (*GetBlo
 	return makeBlockResult(block.Header, transactions), nil
 }
 
+func getPendingBlock(signer types.Signer, header *types.Header, txes []*types.Transaction, includeTxData bool) (*GetBlockResult, error) {
+	var transactions interface{}
+	if includeTxData {
+		txResults := make([]*TransactionResult, 0, len(txes))
+		for _, tx := range txes {
+			res, err := makePendingTransactionResult(signer, tx)
+			if err != nil {
+				return nil, err
+			}
+			txResults = append(txResults, res)
+		}
+		transactions = txResults
+	} else {
+		txHashes := make([]hexutil.Bytes, 0, len(txes))
+		for _, tx := range txes {
+			txHashes = append(txHashes, tx.Hash().Bytes())
+		}
+		transactions = txHashes
+	}
+	return makeBlockResult(header, transactions), nil
+}
+
 func makeBlockResult(header *types.Header, transactions interface{}) *GetBlockResult {
 	size := uint64(0)
 	uncles := make([]hexutil.Bytes, 0)
@@ -360,6 +500,7 @@ func makeBlockResult(header *types.Header, transactions interface{}) *GetBlockRe
 		Timestamp:        (*hexutil.Uint64)(&header.Time),
 		Transactions:     transactions,
 		Uncles:           &uncles,
+		HasStateRoot:     header.Root != (common.Hash{}),
 	}
 }
 
@@ -407,6 +548,44 @@ func makeTransactionResult(processedTx *evm.ProcessedTx, blockHash *common.Hash)
 	}
 }
 
+// signer returns the signer used to check the signatures of transactions
+// sent to this chain
+func (s *Server) signer() types.Signer {
+	return types.NewEIP155Signer(message.ChainAddressToID(arbcommon.NewAddressFromEth(s.srv.GetChainAddress())))
+}
+
+// makePendingTransactionResult describes a transaction which has been
+// accepted by the batcher but hasn't yet been included in a block
+func makePendingTransactionResult(signer types.Signer, tx *types.Transaction) (*TransactionResult, error) {
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, errors2.Wrap(err, "invalid signature on pending transaction")
+	}
+	return newPendingTransactionResult(tx, from), nil
+}
+
+// newPendingTransactionResult describes a pending transaction whose sender
+// is already known
+func newPendingTransactionResult(tx *types.Transaction, from common.Address) *TransactionResult {
+	vVal, rVal, sVal := tx.RawSignatureValues()
+	l2Subtype := hexutil.Uint64(message.SignedTransactionType)
+	return &TransactionResult{
+		From:       from,
+		Gas:        hexutil.Uint64(tx.Gas()),
+		GasPrice:   (*hexutil.Big)(tx.GasPrice()),
+		Hash:       tx.Hash(),
+		Input:      tx.Data(),
+		Nonce:      hexutil.Uint64(tx.Nonce()),
+		To:         tx.To(),
+		Value:      (*hexutil.Big)(tx.Value()),
+		V:          (*hexutil.Big)(vVal),
+		R:          (*hexutil.Big)(rVal),
+		S:          (*hexutil.Big)(sVal),
+		ArbType:    hexutil.Uint64(message.L2Type),
+		ArbSubType: &l2Subtype,
+	}
+}
+
 func buildCallMsg(args CallTxArgs) (arbcommon.Address, message.Call) {
 	var from arbcommon.Address
 	if args.From != nil {
@@ -455,15 +634,19 @@ func (s *Server) executeCall(args CallTxArgs, blockNum *rpc.BlockNumber) (*evm.T
 }
 
 func (s *Server) getSnapshot(blockNum *rpc.BlockNumber) (*snapshot.Snapshot, error) {
+	return getSnapshot(s.srv, blockNum)
+}
+
+func getSnapshot(srv *aggregator.Server, blockNum *rpc.BlockNumber) (*snapshot.Snapshot, error) {
 	if blockNum == nil || *blockNum == rpc.PendingBlockNumber {
-		return s.srv.PendingSnapshot(), nil
+		return srv.PendingSnapshot(), nil
 	}
 
 	if *blockNum == rpc.LatestBlockNumber {
-		return s.srv.LatestSnapshot(), nil
+		return srv.LatestSnapshot(), nil
 	}
 
-	snap, err := s.srv.GetSnapshot(uint64(*blockNum))
+	snap, err := srv.GetSnapshot(uint64(*blockNum))
 	if err != nil {
 		return nil, err
 	}
@@ -474,8 +657,10 @@ func (s *Server) getSnapshot(blockNum *rpc.BlockNumber) (*snapshot.Snapshot, err
 	return nil, errors.New("unsupported block number")
 }
 
+// blockNum resolves the given block number to a height. The pending block is
+// treated as the latest block since only that has a height in the block store
 func (s *Server) blockNum(block *rpc.BlockNumber) (uint64, error) {
-	if *block == rpc.LatestBlockNumber {
+	if *block == rpc.LatestBlockNumber || *block == rpc.PendingBlockNumber {
 		return s.srv.GetBlockCount(), nil
 	} else if *block >= 0 {
 		return uint64(*block), nil
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
+	arbcommon "github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+// gasLimitExecutor succeeds once given at least needed gas and otherwise
+// fails with the given result code
+func gasLimitExecutor(needed uint64, failure evm.ResultType) func(gas uint64) (*evm.TxResult, error) {
+	return func(gas uint64) (*evm.TxResult, error) {
+		res := &evm.TxResult{ResultCode: evm.ReturnCode, GasUsed: big.NewInt(40000)}
+		if gas < needed {
+			res.ResultCode = failure
+		}
+		return res, nil
+	}
+}
+
+func TestSearchGasLimit(t *testing.T) {
+	for _, failure := range []evm.ResultType{evm.RevertCode, evm.InsufficientGasFundsCode} {
+		gas, err := searchGasLimit(1000000, gasLimitExecutor(53001, failure))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gas != 53001 {
+			t.Error("wrong gas limit", gas, "for failure", failure)
+		}
+	}
+
+	if _, err := searchGasLimit(50000, gasLimitExecutor(53001, evm.RevertCode)); err == nil {
+		t.Error("estimated gas above the limit")
+	} else if _, ok := err.(*revertError); !ok {
+		t.Error("expected revert error but got", err)
+	}
+}
+
+func TestSearchGasLimitStopsOnOtherFailures(t *testing.T) {
+	failed := false
+	execute := gasLimitExecutor(53001, evm.BadSequenceCode)
+	_, err := searchGasLimit(1000000, func(gas uint64) (*evm.TxResult, error) {
+		if failed {
+			t.Fatal("search continued after failure")
+		}
+		res, err := execute(gas)
+		failed = res.ResultCode != evm.ReturnCode
+		return res, err
+	})
+	if err == nil || err.Error() != "bad sequence number" {
+		t.Error("wrong error", err)
+	}
+
+	calls := 0
+	execErr := errors.New("machine blocked")
+	_, err = searchGasLimit(1000000, func(gas uint64) (*evm.TxResult, error) {
+		calls++
+		if calls > 1 {
+			return nil, execErr
+		}
+		return execute(gas)
+	})
+	if err != execErr || calls != 2 {
+		t.Error("search didn't return execution error", err)
+	}
+}
+
+// pendingTxes returns transactions from a single sender signed for chain, as
+// the batcher would hold them
+func pendingTxes(t *testing.T, chain arbcommon.Address, count int) ([]*types.Transaction, common.Address) {
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	txes := make([]*types.Transaction, 0, count)
+	for i := 0; i < count; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{6}, big.NewInt(0), 1000, big.NewInt(10), nil)
+		signedTx, err := types.SignTx(tx, signer, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txes = append(txes, signedTx)
+	}
+	return txes, crypto.PubkeyToAddress(pk.PublicKey)
+}
+
+func TestPendingBlock(t *testing.T) {
+	chain := arbcommon.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	txes, sender := pendingTxes(t, chain, 3)
+	header := &types.Header{Number: big.NewInt(5), Difficulty: big.NewInt(0)}
+
+	block, err := getPendingBlock(signer, header, txes, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txHashes, ok := block.Transactions.([]hexutil.Bytes)
+	if !ok || len(txHashes) != len(txes) {
+		t.Fatal("wrong pending block transactions", block.Transactions)
+	}
+	for i, tx := range txes {
+		if common.BytesToHash(txHashes[i]) != tx.Hash() {
+			t.Error("wrong transaction hash at index", i)
+		}
+	}
+
+	block, err = getPendingBlock(signer, header, txes, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txResults, ok := block.Transactions.([]*TransactionResult)
+	if !ok || len(txResults) != len(txes) {
+		t.Fatal("wrong pending block transactions", block.Transactions)
+	}
+	for i, tx := range txes {
+		if txResults[i].Hash != tx.Hash() || txResults[i].From != sender {
+			t.Error("wrong transaction at index", i)
+		}
+		if txResults[i].BlockHash != nil || txResults[i].TransactionIndex != nil {
+			t.Error("pending transaction has a position in a block")
+		}
+	}
+
+	// A transaction signed for another chain has no sender on this one
+	otherTxes, _ := pendingTxes(t, arbcommon.RandAddress(), 1)
+	if _, err := getPendingBlock(signer, header, append(txes, otherTxes...), true); err == nil {
+		t.Error("pending block included transaction with invalid signature")
+	}
+}
+
+func TestPendingTransactionResult(t *testing.T) {
+	chain := arbcommon.RandAddress()
+	signer := types.NewEIP155Signer(message.ChainAddressToID(chain))
+	txes, sender := pendingTxes(t, chain, 1)
+	res, err := makePendingTransactionResult(signer, txes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.From != sender || res.Hash != txes[0].Hash() || uint64(res.Nonce) != txes[0].Nonce() {
+		t.Error("wrong pending transaction result")
+	}
+
+	otherTxes, _ := pendingTxes(t, arbcommon.RandAddress(), 1)
+	if _, err := makePendingTransactionResult(signer, otherTxes[0]); err == nil {
+		t.Error("expected error for transaction with invalid signature")
+	}
+}
+
+func TestBlockResultStateRoot(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(5), Difficulty: big.NewInt(0)}
+	if res := makeBlockResult(header, nil); res.HasStateRoot {
+		t.Error("block without state root marked as having one")
+	}
+
+	header.Root = arbcommon.RandHash().ToEthHash()
+	res := makeBlockResult(header, nil)
+	if !res.HasStateRoot || common.BytesToHash(res.StateRoot) != header.Root {
+		t.Error("wrong state root for block at the end of an assertion")
+	}
+}
@@ -0,0 +1,374 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	arbcommon "github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+)
+
+// A filter is removed if it hasn't been polled within filterDeadline
+var filterDeadline = 5 * time.Minute
+
+// Expired filters are removed every filterCleanupInterval. Polling an expired
+// filter fails even if it hasn't been removed yet
+var filterCleanupInterval = filterDeadline / 10
+
+// maxLogBlockRange is the largest number of blocks a single log query may
+// cover
+var maxLogBlockRange uint64 = 10000
+
+// filterBackend is the part of aggregator.Server used to serve filters and
+// subscriptions
+type filterBackend interface {
+	GetBlockCount() uint64
+	BlockInfoByHash(hash arbcommon.Hash) (*machine.BlockInfo, error)
+	FindLogs(ctx context.Context, fromHeight, toHeight *uint64, addresses []common.Address, topics [][]common.Hash) ([]evm.FullLog, error)
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeChainHeadEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
+	SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription
+}
+
+type filterType int
+
+const (
+	blockFilter filterType = iota
+	logFilter
+	pendingTxFilter
+)
+
+type filter struct {
+	typ      filterType
+	deadline time.Time
+	crit     filters.FilterCriteria
+	hashes   []common.Hash
+	logs     []*types.Log
+}
+
+// Filters implements the log and filter api (eth_getLogs, eth_newFilter,
+// eth_getFilterChanges, etc) along with eth_subscribe on top of the TxDB
+// event feeds. A single Filters should be shared by every rpc endpoint so
+// that filters are tracked by one event loop
+type Filters struct {
+	srv filterBackend
+
+	mu      sync.Mutex
+	filters map[rpc.ID]*filter
+}
+
+func NewFilters(srv filterBackend) *Filters {
+	f := &Filters{
+		srv:     srv,
+		filters: make(map[rpc.ID]*filter),
+	}
+	go f.eventLoop()
+	return f
+}
+
+func (f *Filters) eventLoop() {
+	chainEvents := make(chan core.ChainEvent, 10)
+	logEvents := make(chan []*types.Log, 10)
+	removedLogEvents := make(chan core.RemovedLogsEvent, 10)
+	txEvents := make(chan core.NewTxsEvent, 10)
+
+	chainSub := f.srv.SubscribeChainEvent(chainEvents)
+	defer chainSub.Unsubscribe()
+	logSub := f.srv.SubscribeLogsEvent(logEvents)
+	defer logSub.Unsubscribe()
+	removedLogSub := f.srv.SubscribeRemovedLogsEvent(removedLogEvents)
+	defer removedLogSub.Unsubscribe()
+	txSub := f.srv.SubscribeNewTxsEvent(txEvents)
+	defer txSub.Unsubscribe()
+
+	ticker := time.NewTicker(filterCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev := <-chainEvents:
+			f.mu.Lock()
+			for _, fil := range f.filters {
+				if fil.typ == blockFilter {
+					fil.hashes = append(fil.hashes, ev.Hash)
+				}
+			}
+			f.mu.Unlock()
+		case logs := <-logEvents:
+			f.addLogs(logs)
+		case ev := <-removedLogEvents:
+			removed := make([]*types.Log, 0, len(ev.Logs))
+			for _, l := range ev.Logs {
+				removedLog := *l
+				removedLog.Removed = true
+				removed = append(removed, &removedLog)
+			}
+			f.addLogs(removed)
+		case ev := <-txEvents:
+			f.mu.Lock()
+			for _, fil := range f.filters {
+				if fil.typ == pendingTxFilter {
+					for _, tx := range ev.Txs {
+						fil.hashes = append(fil.hashes, tx.Hash())
+					}
+				}
+			}
+			f.mu.Unlock()
+		case <-ticker.C:
+			f.mu.Lock()
+			now := time.Now()
+			for id, fil := range f.filters {
+				if now.After(fil.deadline) {
+					delete(f.filters, id)
+				}
+			}
+			f.mu.Unlock()
+		case <-chainSub.Err():
+			return
+		case <-logSub.Err():
+			return
+		case <-removedLogSub.Err():
+			return
+		case <-txSub.Err():
+			return
+		}
+	}
+}
+
+func (f *Filters) addLogs(logs []*types.Log) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fil := range f.filters {
+		if fil.typ != logFilter {
+			continue
+		}
+		for _, l := range logs {
+			if logMatchesCriteria(l, fil.crit) {
+				fil.logs = append(fil.logs, l)
+			}
+		}
+	}
+}
+
+func (f *Filters) installFilter(fil *filter) rpc.ID {
+	id := rpc.NewID()
+	fil.deadline = time.Now().Add(filterDeadline)
+	f.mu.Lock()
+	f.filters[id] = fil
+	f.mu.Unlock()
+	return id
+}
+
+// NewBlockFilter creates a filter that collects the hashes of new blocks
+func (f *Filters) NewBlockFilter() rpc.ID {
+	return f.installFilter(&filter{typ: blockFilter})
+}
+
+// NewPendingTransactionFilter creates a filter that collects the hashes of
+// transactions submitted to the aggregator
+func (f *Filters) NewPendingTransactionFilter() rpc.ID {
+	return f.installFilter(&filter{typ: pendingTxFilter})
+}
+
+// NewFilter creates a filter that collects new logs matching the given
+// criteria. Logs removed due to a reorg are returned again with the removed
+// field set to true
+func (f *Filters) NewFilter(crit filters.FilterCriteria) (rpc.ID, error) {
+	if crit.FromBlock != nil && crit.ToBlock != nil &&
+		crit.FromBlock.Sign() >= 0 && crit.ToBlock.Sign() >= 0 &&
+		crit.FromBlock.Cmp(crit.ToBlock) > 0 {
+		return "", errors.New("invalid block range")
+	}
+	return f.installFilter(&filter{typ: logFilter, crit: crit}), nil
+}
+
+// liveFilter returns the filter with the given id unless it has expired. f.mu
+// must be held
+func (f *Filters) liveFilter(id rpc.ID) (*filter, bool) {
+	fil, found := f.filters[id]
+	if !found {
+		return nil, false
+	}
+	if time.Now().After(fil.deadline) {
+		delete(f.filters, id)
+		return nil, false
+	}
+	return fil, true
+}
+
+// UninstallFilter removes the filter with the given id, returning whether a
+// filter was found
+func (f *Filters) UninstallFilter(id rpc.ID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, found := f.filters[id]
+	delete(f.filters, id)
+	return found
+}
+
+// GetFilterChanges returns the items collected by the filter since the last
+// time it was polled. Block and pending transaction filters return a list of
+// hashes and log filters return a list of logs
+func (f *Filters) GetFilterChanges(id rpc.ID) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fil, found := f.liveFilter(id)
+	if !found {
+		return nil, errors.New("filter not found")
+	}
+	fil.deadline = time.Now().Add(filterDeadline)
+	switch fil.typ {
+	case logFilter:
+		logs := fil.logs
+		fil.logs = nil
+		return nonNilLogs(logs), nil
+	default:
+		hashes := fil.hashes
+		fil.hashes = nil
+		if hashes == nil {
+			hashes = []common.Hash{}
+		}
+		return hashes, nil
+	}
+}
+
+// GetFilterLogs returns all logs matching the criteria of the log filter with
+// the given id
+func (f *Filters) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	f.mu.Lock()
+	fil, found := f.liveFilter(id)
+	if found {
+		fil.deadline = time.Now().Add(filterDeadline)
+	}
+	f.mu.Unlock()
+	if !found || fil.typ != logFilter {
+		return nil, errors.New("filter not found")
+	}
+	return f.GetLogs(ctx, fil.crit)
+}
+
+// GetLogs returns all logs matching the given criteria
+func (f *Filters) GetLogs(ctx context.Context, crit filters.FilterCriteria) ([]*types.Log, error) {
+	var fromHeight, toHeight uint64
+	if crit.BlockHash != nil {
+		info, err := f.srv.BlockInfoByHash(arbcommon.NewHashFromEth(*crit.BlockHash))
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			return nil, errors.New("unknown block")
+		}
+		fromHeight = info.Header.Number.Uint64()
+		toHeight = fromHeight
+	} else {
+		latest := f.srv.GetBlockCount()
+		fromHeight = resolveFilterHeight(crit.FromBlock, latest)
+		toHeight = resolveFilterHeight(crit.ToBlock, latest)
+	}
+	if fromHeight > toHeight {
+		return nil, errors.New("invalid block range")
+	}
+	if toHeight-fromHeight >= maxLogBlockRange {
+		return nil, fmt.Errorf("block range is larger than the limit of %v blocks", maxLogBlockRange)
+	}
+
+	fullLogs, err := f.srv.FindLogs(ctx, &fromHeight, &toHeight, crit.Addresses, crit.Topics)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*types.Log, 0, len(fullLogs))
+	for _, l := range fullLogs {
+		logs = append(logs, l.ToEVMLog())
+	}
+	return logs, nil
+}
+
+// resolveFilterHeight converts a filter block number into a height with
+// unset, latest and pending all being treated as the latest block
+func resolveFilterHeight(blockNum *big.Int, latest uint64) uint64 {
+	if blockNum == nil || blockNum.Sign() < 0 {
+		return latest
+	}
+	return blockNum.Uint64()
+}
+
+func logMatchesCriteria(l *types.Log, crit filters.FilterCriteria) bool {
+	if crit.BlockHash != nil && l.BlockHash != *crit.BlockHash {
+		return false
+	}
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 && crit.FromBlock.Uint64() > l.BlockNumber {
+		return false
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Uint64() < l.BlockNumber {
+		return false
+	}
+	if len(crit.Addresses) > 0 {
+		match := false
+		for _, addr := range crit.Addresses {
+			if l.Address == addr {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	if len(crit.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, topicGroup := range crit.Topics {
+		if len(topicGroup) == 0 {
+			continue
+		}
+		match := false
+		for _, topic := range topicGroup {
+			if l.Topics[i] == topic {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+func nonNilLogs(logs []*types.Log) []*types.Log {
+	if logs == nil {
+		return []*types.Log{}
+	}
+	return logs
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-evm/evm"
+	arbcommon "github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+)
+
+type logQuery struct {
+	from uint64
+	to   uint64
+}
+
+type fakeFilterBackend struct {
+	blockCount uint64
+	blocks     map[arbcommon.Hash]*machine.BlockInfo
+	queries    []logQuery
+	chainFeed  event.Feed
+	headFeed   event.Feed
+	logsFeed   event.Feed
+	rmLogsFeed event.Feed
+	newTxsFeed event.Feed
+}
+
+func newFakeFilterBackend() *fakeFilterBackend {
+	return &fakeFilterBackend{blocks: make(map[arbcommon.Hash]*machine.BlockInfo)}
+}
+
+func (b *fakeFilterBackend) GetBlockCount() uint64 {
+	return b.blockCount
+}
+
+func (b *fakeFilterBackend) BlockInfoByHash(hash arbcommon.Hash) (*machine.BlockInfo, error) {
+	return b.blocks[hash], nil
+}
+
+func (b *fakeFilterBackend) FindLogs(_ context.Context, fromHeight, toHeight *uint64, _ []common.Address, _ [][]common.Hash) ([]evm.FullLog, error) {
+	b.queries = append(b.queries, logQuery{from: *fromHeight, to: *toHeight})
+	return nil, nil
+}
+
+func (b *fakeFilterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.chainFeed.Subscribe(ch)
+}
+
+func (b *fakeFilterBackend) SubscribeChainHeadEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.headFeed.Subscribe(ch)
+}
+
+func (b *fakeFilterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
+	return b.logsFeed.Subscribe(ch)
+}
+
+func (b *fakeFilterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
+	return b.rmLogsFeed.Subscribe(ch)
+}
+
+func (b *fakeFilterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
+	return b.newTxsFeed.Subscribe(ch)
+}
+
+// send delivers ev once something has subscribed to the feed
+func send(t *testing.T, feed *event.Feed, ev interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for feed.Send(ev) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("nothing subscribed to feed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForChanges polls the filter until it returns a non-empty result
+func waitForChanges(t *testing.T, f *Filters, id rpc.ID) interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		changes, err := f.GetFilterChanges(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch changes := changes.(type) {
+		case []common.Hash:
+			if len(changes) > 0 {
+				return changes
+			}
+		case []*types.Log:
+			if len(changes) > 0 {
+				return changes
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("filter received no changes")
+	return nil
+}
+
+func testBlock(height int64) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(height)})
+}
+
+func TestBlockFilter(t *testing.T) {
+	backend := newFakeFilterBackend()
+	f := NewFilters(backend)
+	id := f.NewBlockFilter()
+
+	block := testBlock(5)
+	send(t, &backend.chainFeed, core.ChainEvent{Block: block, Hash: block.Hash()})
+	hashes := waitForChanges(t, f, id).([]common.Hash)
+	if len(hashes) != 1 || hashes[0] != block.Hash() {
+		t.Fatal("wrong block hashes", hashes)
+	}
+
+	changes, err := f.GetFilterChanges(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.([]common.Hash)) != 0 {
+		t.Error("filter returned changes twice")
+	}
+	if !f.UninstallFilter(id) {
+		t.Error("failed to uninstall filter")
+	}
+	if _, err := f.GetFilterChanges(id); err == nil {
+		t.Error("polled uninstalled filter")
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	backend := newFakeFilterBackend()
+	f := NewFilters(backend)
+	addr := common.Address{1}
+	topic := common.Hash{2}
+	id, err := f.NewFilter(filters.FilterCriteria{
+		Addresses: []common.Address{addr},
+		Topics:    [][]common.Hash{{topic}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matching := &types.Log{Address: addr, Topics: []common.Hash{topic}, BlockNumber: 3}
+	wrongAddress := &types.Log{Address: common.Address{3}, Topics: []common.Hash{topic}}
+	wrongTopic := &types.Log{Address: addr, Topics: []common.Hash{{4}}}
+	send(t, &backend.logsFeed, []*types.Log{wrongAddress, matching, wrongTopic})
+	logs := waitForChanges(t, f, id).([]*types.Log)
+	if len(logs) != 1 || logs[0] != matching {
+		t.Fatal("wrong logs", logs)
+	}
+
+	send(t, &backend.rmLogsFeed, core.RemovedLogsEvent{Logs: []*types.Log{matching}})
+	logs = waitForChanges(t, f, id).([]*types.Log)
+	if len(logs) != 1 || !logs[0].Removed || logs[0].BlockNumber != 3 {
+		t.Fatal("wrong removed logs", logs)
+	}
+	if matching.Removed {
+		t.Error("removing log modified the original")
+	}
+}
+
+func TestPendingTransactionFilter(t *testing.T) {
+	backend := newFakeFilterBackend()
+	f := NewFilters(backend)
+	id := f.NewPendingTransactionFilter()
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	send(t, &backend.newTxsFeed, core.NewTxsEvent{Txs: []*types.Transaction{tx}})
+	hashes := waitForChanges(t, f, id).([]common.Hash)
+	if len(hashes) != 1 || hashes[0] != tx.Hash() {
+		t.Fatal("wrong transaction hashes", hashes)
+	}
+}
+
+func TestFilterExpiry(t *testing.T) {
+	oldDeadline := filterDeadline
+	filterDeadline = 10 * time.Millisecond
+	defer func() {
+		filterDeadline = oldDeadline
+	}()
+
+	f := NewFilters(newFakeFilterBackend())
+	polled := f.NewBlockFilter()
+	expired := f.NewBlockFilter()
+	for i := 0; i < 4; i++ {
+		time.Sleep(5 * time.Millisecond)
+		if _, err := f.GetFilterChanges(polled); err != nil {
+			t.Fatal("polled filter expired", err)
+		}
+	}
+	if _, err := f.GetFilterChanges(expired); err == nil {
+		t.Error("polled expired filter")
+	}
+}
+
+func TestGetLogsRange(t *testing.T) {
+	backend := newFakeFilterBackend()
+	backend.blockCount = 2 * maxLogBlockRange
+	f := NewFilters(backend)
+	ctx := context.Background()
+
+	if _, err := f.GetLogs(ctx, filters.FilterCriteria{FromBlock: big.NewInt(10)}); err == nil {
+		t.Error("queried more blocks than the limit")
+	}
+	if _, err := f.GetLogs(ctx, filters.FilterCriteria{FromBlock: big.NewInt(10), ToBlock: big.NewInt(5)}); err == nil {
+		t.Error("queried backwards block range")
+	}
+	if len(backend.queries) != 0 {
+		t.Fatal("invalid query reached the backend")
+	}
+
+	from := backend.blockCount - maxLogBlockRange + 1
+	if _, err := f.GetLogs(ctx, filters.FilterCriteria{FromBlock: new(big.Int).SetUint64(from)}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.queries[0] != (logQuery{from: from, to: backend.blockCount}) {
+		t.Error("wrong query", backend.queries[0])
+	}
+
+	blockHash := common.Hash{7}
+	backend.blocks[arbcommon.NewHashFromEth(blockHash)] = &machine.BlockInfo{Header: &types.Header{Number: big.NewInt(42)}}
+	if _, err := f.GetLogs(ctx, filters.FilterCriteria{BlockHash: &blockHash}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.queries[1] != (logQuery{from: 42, to: 42}) {
+		t.Error("wrong block hash query", backend.queries[1])
+	}
+	unknown := common.Hash{8}
+	if _, err := f.GetLogs(ctx, filters.FilterCriteria{BlockHash: &unknown}); err == nil {
+		t.Error("queried unknown block")
+	}
+}
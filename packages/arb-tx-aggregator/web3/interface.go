@@ -27,6 +27,15 @@ type GetBlockResult struct {
 	Timestamp        *hexutil.Uint64   `json:"timestamp"`
 	Transactions     interface{}       `json:"transactions"`
 	Uncles           *[]hexutil.Bytes  `json:"uncles"`
+
+	// Arbitrum Specific Fields
+
+	// StateRoot is the hash of the AVM machine state rather than the root of
+	// an Ethereum state trie, so it can't be used to verify account or
+	// storage proofs. The machine state is only known at the end of an
+	// assertion, so StateRoot is zero and HasStateRoot is false for every
+	// other block. Empty blocks carry the state root of the previous block
+	HasStateRoot bool `json:"hasStateRoot"`
 }
 
 type CallTxArgs struct {
@@ -81,3 +90,103 @@ type TransactionResult struct {
 	ArbType         hexutil.Uint64  `json:"arbType"`
 	ArbSubType      *hexutil.Uint64 `json:"arbSubType"`
 }
+
+type TraceConfig struct {
+	// Include the number of AVM steps executed in the result
+	AVMSteps bool `json:"avmSteps"`
+}
+
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to"`
+	Value        *hexutil.Big    `json:"value"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Logs         []*types.Log    `json:"logs"`
+}
+
+type TraceResult struct {
+	ResultCode hexutil.Uint64  `json:"returnCode"`
+	GasUsed    hexutil.Uint64  `json:"gasUsed"`
+	ArbGasUsed hexutil.Uint64  `json:"arbGasUsed"`
+	AVMSteps   *hexutil.Uint64 `json:"avmSteps,omitempty"`
+	Calls      []*CallFrame    `json:"calls"`
+}
+
+type BatchStatusResult struct {
+	// Hash of the most recently sent L1 transaction for the batch
+	L1TxHash common.Hash `json:"l1TxHash"`
+	// Hashes of every L1 transaction sent for the batch, any of which may be
+	// included
+	L1TxHashes []common.Hash  `json:"l1TxHashes"`
+	TxCount    hexutil.Uint64 `json:"txCount"`
+	SentAt     hexutil.Uint64 `json:"sentAt"`
+	Resends    hexutil.Uint64 `json:"resends"`
+	// Bytes of L1 calldata saved by replacing addresses with their index in
+	// the address table
+	CalldataSaved hexutil.Uint64 `json:"calldataSaved"`
+	// Set if the batch was given up on. Its transactions are queued again
+	// once none of its L1 transactions can be included anymore
+	Abandoned bool `json:"abandoned"`
+}
+
+// AccumulatorProofResult proves that a value is included in the messages or
+// logs accumulator of an assertion. Starting from PrefixAcc, hashing in
+// ValueHash and then each of SuffixHashes with
+// keccak256(abi.encodePacked(acc, hash)) gives Accumulator, which must match
+// the assertion's LastMessageHash or LastLogHash. This is a hash chain proof
+// rather than a merkle proof: it can be checked on L1 with
+// AccumulatorLib.verifyProof, but not with MerkleLib, and it grows with the
+// number of values output after the proven one
+type AccumulatorProofResult struct {
+	// Index of the value among all messages or logs output by the chain
+	Index hexutil.Uint64 `json:"index"`
+	// Serialized value which is proven
+	Value        hexutil.Bytes `json:"value"`
+	ValueHash    common.Hash   `json:"valueHash"`
+	PrefixAcc    common.Hash   `json:"prefixAcc"`
+	SuffixHashes []common.Hash `json:"suffixHashes"`
+	Accumulator  common.Hash   `json:"accumulator"`
+}
+
+type WithdrawalResult struct {
+	// Index of the output message which carries the withdrawal
+	MessageIndex hexutil.Uint64  `json:"messageIndex"`
+	Type         string          `json:"type"`
+	Sender       common.Address  `json:"sender"`
+	Destination  common.Address  `json:"destination"`
+	Token        *common.Address `json:"token"`
+	// Amount is set for Eth and ERC20 withdrawals and TokenId for ERC721
+	// withdrawals
+	Amount  *hexutil.Big `json:"amount,omitempty"`
+	TokenId *hexutil.Big `json:"tokenId,omitempty"`
+	// Request id of the transaction which made the withdrawal
+	RequestId *common.Hash `json:"requestId"`
+	// One of pending, asserted or confirmed. Confirmed withdrawals can be
+	// claimed on L1
+	Status string `json:"status"`
+}
+
+type AddressTableDecompressResult struct {
+	Address common.Address `json:"address"`
+	// Offset in the buffer after the decompressed address
+	Offset hexutil.Uint64 `json:"offset"`
+}
+
+type FunctionTableEntryResult struct {
+	FuncID  hexutil.Bytes `json:"funcId"`
+	Payable bool          `json:"payable"`
+	MaxGas  *hexutil.Big  `json:"maxGas"`
+}
+
+type BLSPublicKeyResult struct {
+	X0 *hexutil.Big `json:"x0"`
+	X1 *hexutil.Big `json:"x1"`
+	Y0 *hexutil.Big `json:"y0"`
+	Y1 *hexutil.Big `json:"y1"`
+}
@@ -17,31 +17,55 @@
 package web3
 
 import (
-	"github.com/ethereum/go-ethereum/eth/filters"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/offchainlabs/arbitrum/packages/arb-evm/message"
 	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/aggregator"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 )
 
-func GenerateWeb3Server(server *aggregator.Server) (*rpc.Server, error) {
-	s := rpc.NewServer()
+// Namespaces lists every namespace the web3 server can expose
+var Namespaces = []string{"eth", "net", "web3", "debug", "arb", "txpool"}
 
-	if err := s.RegisterName("eth", NewServer(server)); err != nil {
-		return nil, err
-	}
+// PublicNamespaces lists the namespaces which are safe to expose publicly
+var PublicNamespaces = []string{"eth", "net", "web3", "arb", "txpool"}
 
-	if err := s.RegisterName("eth", filters.NewPublicFilterAPI(server, false)); err != nil {
-		return nil, err
+func namespaceServices(server *aggregator.Server, filters *Filters, namespace string) ([]interface{}, error) {
+	switch namespace {
+	case "eth":
+		return []interface{}{NewServer(server), filters}, nil
+	case "net":
+		net := &Net{chainId: message.ChainAddressToID(common.NewAddressFromEth(server.GetChainAddress())).Uint64()}
+		return []interface{}{net}, nil
+	case "web3":
+		return []interface{}{&Web3{}}, nil
+	case "debug":
+		return []interface{}{NewDebug(server)}, nil
+	case "arb":
+		return []interface{}{NewArb(server)}, nil
+	case "txpool":
+		return []interface{}{NewTxPool(server)}, nil
+	default:
+		return nil, fmt.Errorf("unknown rpc namespace %v", namespace)
 	}
+}
 
-	net := &Net{chainId: message.ChainAddressToID(common.NewAddressFromEth(server.GetChainAddress())).Uint64()}
-	if err := s.RegisterName("net", net); err != nil {
-		return nil, err
-	}
+// GenerateWeb3Server creates a server exposing only the given namespaces.
+// Servers for different endpoints should share the same filters
+func GenerateWeb3Server(server *aggregator.Server, filters *Filters, namespaces []string) (*rpc.Server, error) {
+	s := rpc.NewServer()
 
-	if err := s.RegisterName("web3", &Web3{}); err != nil {
-		return nil, err
+	for _, namespace := range namespaces {
+		services, err := namespaceServices(server, filters, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			if err := s.RegisterName(namespace, service); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return s, nil
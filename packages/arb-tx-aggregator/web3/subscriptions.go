@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// NewHeads sends a notification containing the header of each new chain head.
+// Like geth, blocks that are superseded before they become the head aren't
+// sent
+func (f *Filters) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	headEvents := make(chan core.ChainEvent, 10)
+	headSub := f.srv.SubscribeChainHeadEvent(headEvents)
+
+	go func() {
+		defer headSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-headEvents:
+				_ = notifier.Notify(rpcSub.ID, ev.Block.Header())
+			case <-headSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Logs sends a notification for each new log matching the given criteria.
+// If a log is removed due to an L1 reorg, it is sent again with the removed
+// field set to true
+func (f *Filters) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	logEvents := make(chan []*types.Log, 10)
+	logSub := f.srv.SubscribeLogsEvent(logEvents)
+	removedLogEvents := make(chan core.RemovedLogsEvent, 10)
+	removedLogSub := f.srv.SubscribeRemovedLogsEvent(removedLogEvents)
+
+	notifyLogs := func(logs []*types.Log, removed bool) {
+		for _, l := range logs {
+			if !logMatchesCriteria(l, crit) {
+				continue
+			}
+			if removed {
+				removedLog := *l
+				removedLog.Removed = true
+				l = &removedLog
+			}
+			_ = notifier.Notify(rpcSub.ID, l)
+		}
+	}
+
+	go func() {
+		defer logSub.Unsubscribe()
+		defer removedLogSub.Unsubscribe()
+		for {
+			select {
+			case logs := <-logEvents:
+				notifyLogs(logs, false)
+			case ev := <-removedLogEvents:
+				notifyLogs(ev.Logs, true)
+			case <-logSub.Err():
+				return
+			case <-removedLogSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// NewPendingTransactions sends a notification containing the hash of each
+// transaction submitted to the aggregator
+func (f *Filters) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	txEvents := make(chan core.NewTxsEvent, 10)
+	txSub := f.srv.SubscribeNewTxsEvent(txEvents)
+
+	go func() {
+		defer txSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-txEvents:
+				for _, tx := range ev.Txs {
+					_ = notifier.Notify(rpcSub.ID, tx.Hash())
+				}
+			case <-txSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func dialFilters(t *testing.T, backend *fakeFilterBackend) (*rpc.Client, func()) {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", NewFilters(backend)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	return client, func() {
+		client.Close()
+		server.Stop()
+	}
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	backend := newFakeFilterBackend()
+	client, closeClient := dialFilters(t, backend)
+	defer closeClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	heads := make(chan *types.Header, 10)
+	sub, err := client.EthSubscribe(ctx, heads, "newHeads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// Only chain head events are sent as new heads
+	send(t, &backend.headFeed, core.ChainEvent{Block: testBlock(7), Hash: testBlock(7).Hash()})
+	backend.chainFeed.Send(core.ChainEvent{Block: testBlock(6), Hash: testBlock(6).Hash()})
+	select {
+	case head := <-heads:
+		if head.Number.Int64() != 7 || head.Hash() != testBlock(7).Hash() {
+			t.Error("wrong head", head.Number)
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-ctx.Done():
+		t.Fatal("no head received")
+	}
+	select {
+	case head := <-heads:
+		t.Error("received non-head block", head.Number)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribeLogs(t *testing.T) {
+	backend := newFakeFilterBackend()
+	client, closeClient := dialFilters(t, backend)
+	defer closeClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addr := common.Address{5}
+	logs := make(chan types.Log, 10)
+	sub, err := client.EthSubscribe(ctx, logs, "logs", map[string]interface{}{
+		"address": addr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	matching := &types.Log{Address: addr, Topics: []common.Hash{}, BlockNumber: 4, Data: []byte{1}}
+	other := &types.Log{Address: common.Address{6}, Topics: []common.Hash{}}
+	send(t, &backend.logsFeed, []*types.Log{other, matching})
+	send(t, &backend.rmLogsFeed, core.RemovedLogsEvent{Logs: []*types.Log{matching, other}})
+
+	for _, removed := range []bool{false, true} {
+		select {
+		case l := <-logs:
+			if l.Address != addr || l.BlockNumber != 4 || l.Removed != removed {
+				t.Errorf("wrong log %+v", l)
+			}
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatal("log not received")
+		}
+	}
+	select {
+	case l := <-logs:
+		t.Errorf("received log that doesn't match %+v", l)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribeNewPendingTransactions(t *testing.T) {
+	backend := newFakeFilterBackend()
+	client, closeClient := dialFilters(t, backend)
+	defer closeClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hashes := make(chan common.Hash, 10)
+	sub, err := client.EthSubscribe(ctx, hashes, "newPendingTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	tx := types.NewTransaction(3, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	send(t, &backend.newTxsFeed, core.NewTxsEvent{Txs: []*types.Transaction{tx}})
+	select {
+	case hash := <-hashes:
+		if hash != tx.Hash() {
+			t.Error("wrong transaction hash", hash)
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-ctx.Done():
+		t.Fatal("transaction not received")
+	}
+}
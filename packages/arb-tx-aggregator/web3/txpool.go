@@ -0,0 +1,101 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package web3
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-tx-aggregator/aggregator"
+)
+
+// TxPool exposes the transactions queued in the aggregator which haven't
+// been added to a batch yet
+type TxPool struct {
+	srv *aggregator.Server
+}
+
+func NewTxPool(srv *aggregator.Server) *TxPool {
+	return &TxPool{srv: srv}
+}
+
+// Content returns the queued transactions grouped by sender and nonce
+func (t *TxPool) Content() map[string]map[string]map[string]*TransactionResult {
+	pending, queued := t.srv.TxPoolContent()
+	format := func(txesByAccount map[common.Address][]*types.Transaction) map[string]map[string]*TransactionResult {
+		ret := make(map[string]map[string]*TransactionResult)
+		for account, txes := range txesByAccount {
+			dump := make(map[string]*TransactionResult)
+			for _, tx := range txes {
+				dump[fmt.Sprintf("%d", tx.Nonce())] = newPendingTransactionResult(tx, account)
+			}
+			ret[account.Hex()] = dump
+		}
+		return ret
+	}
+	return map[string]map[string]map[string]*TransactionResult{
+		"pending": format(pending),
+		"queued":  format(queued),
+	}
+}
+
+// Status returns the number of executable and non-executable queued
+// transactions
+func (t *TxPool) Status() map[string]hexutil.Uint {
+	pending, queued := t.srv.TxPoolContent()
+	count := func(txesByAccount map[common.Address][]*types.Transaction) hexutil.Uint {
+		total := 0
+		for _, txes := range txesByAccount {
+			total += len(txes)
+		}
+		return hexutil.Uint(total)
+	}
+	return map[string]hexutil.Uint{
+		"pending": count(pending),
+		"queued":  count(queued),
+	}
+}
+
+// Inspect returns a textual summary of each queued transaction
+func (t *TxPool) Inspect() map[string]map[string]map[string]string {
+	pending, queued := t.srv.TxPoolContent()
+	format := func(txesByAccount map[common.Address][]*types.Transaction) map[string]map[string]string {
+		ret := make(map[string]map[string]string)
+		for account, txes := range txesByAccount {
+			dump := make(map[string]string)
+			for _, tx := range txes {
+				dump[fmt.Sprintf("%d", tx.Nonce())] = inspectTx(tx)
+			}
+			ret[account.Hex()] = dump
+		}
+		return ret
+	}
+	return map[string]map[string]map[string]string{
+		"pending": format(pending),
+		"queued":  format(queued),
+	}
+}
+
+func inspectTx(tx *types.Transaction) string {
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v wei + %v gas × %v wei", to.Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+	}
+	return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+}
@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+type storedBlock struct {
+	header *types.Header
+	// logIndex is nil for empty blocks
+	logIndex *uint64
+}
+
+// AggregatorStore is an in-memory implementation of machine.AggregatorStore.
+// Like the RocksDB backed store, a reorg only resets the log and message
+// counts and the latest block height, so entries past them are overwritten
+// as new ones are saved
+type AggregatorStore struct {
+	sync.Mutex
+	logs         []value.Value
+	logCount     uint64
+	messages     []value.Value
+	messageCount uint64
+	blocks       map[uint64]storedBlock
+	maxBlock     *uint64
+	requests     map[common.Hash]uint64
+	txHashes     map[common.Hash]uint64
+	messageLogs  map[uint64]uint64
+	blockHashes  map[common.Hash]uint64
+}
+
+func NewAggregatorStore() *AggregatorStore {
+	return &AggregatorStore{
+		blocks:      make(map[uint64]storedBlock),
+		requests:    make(map[common.Hash]uint64),
+		txHashes:    make(map[common.Hash]uint64),
+		messageLogs: make(map[uint64]uint64),
+		blockHashes: make(map[common.Hash]uint64),
+	}
+}
+
+func (as *AggregatorStore) LogCount() (uint64, error) {
+	as.Lock()
+	defer as.Unlock()
+	return as.logCount, nil
+}
+
+func (as *AggregatorStore) SaveLog(val value.Value) error {
+	as.Lock()
+	defer as.Unlock()
+	as.logs = append(as.logs[:as.logCount], val)
+	as.logCount++
+	return nil
+}
+
+func (as *AggregatorStore) GetLog(index uint64) (value.Value, error) {
+	as.Lock()
+	defer as.Unlock()
+	return as.getLog(index)
+}
+
+func (as *AggregatorStore) getLog(index uint64) (value.Value, error) {
+	if index >= as.logCount {
+		return nil, errors.New("failed to get log")
+	}
+	return as.logs[index], nil
+}
+
+func (as *AggregatorStore) MessageCount() (uint64, error) {
+	as.Lock()
+	defer as.Unlock()
+	return as.messageCount, nil
+}
+
+func (as *AggregatorStore) SaveMessage(val value.Value) error {
+	as.Lock()
+	defer as.Unlock()
+	as.messages = append(as.messages[:as.messageCount], val)
+	as.messageCount++
+	return nil
+}
+
+func (as *AggregatorStore) GetMessage(index uint64) (value.Value, error) {
+	as.Lock()
+	defer as.Unlock()
+	if index >= as.messageCount {
+		return nil, errors.New("failed to get l2message")
+	}
+	return as.messages[index], nil
+}
+
+func (as *AggregatorStore) LatestBlock() (*common.BlockId, error) {
+	as.Lock()
+	defer as.Unlock()
+	if as.maxBlock == nil {
+		return nil, errors.New("failed to load block count")
+	}
+	block, ok := as.blocks[*as.maxBlock]
+	if !ok {
+		return nil, fmt.Errorf("no block saved at height %v", *as.maxBlock)
+	}
+	return &common.BlockId{
+		Height:     common.NewTimeBlocks(new(big.Int).SetUint64(*as.maxBlock)),
+		HeaderHash: common.NewHashFromEth(block.header.Hash()),
+	}, nil
+}
+
+func (as *AggregatorStore) SaveBlock(header *types.Header, logIndex uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	as.saveBlock(header, &logIndex)
+	return nil
+}
+
+func (as *AggregatorStore) SaveEmptyBlock(header *types.Header) error {
+	as.Lock()
+	defer as.Unlock()
+	as.saveBlock(header, nil)
+	return nil
+}
+
+func (as *AggregatorStore) saveBlock(header *types.Header, logIndex *uint64) {
+	height := header.Number.Uint64()
+	as.blocks[height] = storedBlock{header: types.CopyHeader(header), logIndex: logIndex}
+	as.maxBlock = &height
+}
+
+func (as *AggregatorStore) GetBlock(height uint64) (*machine.BlockInfo, error) {
+	as.Lock()
+	defer as.Unlock()
+	if as.maxBlock == nil || height > *as.maxBlock {
+		return nil, nil
+	}
+	block, ok := as.blocks[height]
+	if !ok {
+		return nil, nil
+	}
+	info := &machine.BlockInfo{
+		Header: types.CopyHeader(block.header),
+	}
+	if block.logIndex != nil {
+		avmLog, err := as.getLog(*block.logIndex)
+		if err != nil {
+			return nil, err
+		}
+		info.BlockLog = avmLog
+	}
+	return info, nil
+}
+
+func (as *AggregatorStore) Reorg(height uint64, messageCount uint64, logCount uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	if messageCount > uint64(len(as.messages)) || logCount > uint64(len(as.logs)) {
+		return errors.New("failed to restore block")
+	}
+	as.messageCount = messageCount
+	as.logCount = logCount
+	as.maxBlock = &height
+	return nil
+}
+
+func (as *AggregatorStore) GetPossibleRequestInfo(requestId common.Hash) *uint64 {
+	as.Lock()
+	defer as.Unlock()
+	index, ok := as.requests[requestId]
+	if !ok {
+		return nil
+	}
+	return &index
+}
+
+func (as *AggregatorStore) SaveRequest(requestId common.Hash, logIndex uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	as.requests[requestId] = logIndex
+	return nil
+}
+
+func (as *AggregatorStore) GetPossibleTxHashInfo(txHash common.Hash) *uint64 {
+	as.Lock()
+	defer as.Unlock()
+	index, ok := as.txHashes[txHash]
+	if !ok {
+		return nil
+	}
+	return &index
+}
+
+func (as *AggregatorStore) SaveTxHash(txHash common.Hash, logIndex uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	as.txHashes[txHash] = logIndex
+	return nil
+}
+
+func (as *AggregatorStore) GetPossibleMessageLog(messageIndex uint64) *uint64 {
+	as.Lock()
+	defer as.Unlock()
+	index, ok := as.messageLogs[messageIndex]
+	if !ok {
+		return nil
+	}
+	return &index
+}
+
+func (as *AggregatorStore) SaveMessageLog(messageIndex uint64, logIndex uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	as.messageLogs[messageIndex] = logIndex
+	return nil
+}
+
+func (as *AggregatorStore) GetPossibleBlock(blockHash common.Hash) *uint64 {
+	as.Lock()
+	defer as.Unlock()
+	index, ok := as.blockHashes[blockHash]
+	if !ok {
+		return nil
+	}
+	return &index
+}
+
+func (as *AggregatorStore) SaveBlockHash(blockHash common.Hash, blockHeight uint64) error {
+	as.Lock()
+	defer as.Unlock()
+	as.blockHashes[blockHash] = blockHeight
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/math"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+type blockKey [64]byte
+
+func newBlockKey(id *common.BlockId) blockKey {
+	var key blockKey
+	copy(key[:32], math.PaddedBigBytes(id.Height.AsInt(), 32))
+	copy(key[32:], id.HeaderHash[:])
+	return key
+}
+
+func (k blockKey) blockId() *common.BlockId {
+	var hash common.Hash
+	copy(hash[:], k[32:])
+	return &common.BlockId{
+		Height:     common.NewTimeBlocks(new(big.Int).SetBytes(k[:32])),
+		HeaderHash: hash,
+	}
+}
+
+// BlockStore is an in-memory implementation of machine.BlockStore. Like the
+// RocksDB backed store, blocks are ordered by height and then by hash
+type BlockStore struct {
+	sync.Mutex
+	blocks map[blockKey][]byte
+}
+
+func NewBlockStore() *BlockStore {
+	return &BlockStore{blocks: make(map[blockKey][]byte)}
+}
+
+func (bs *BlockStore) PutBlock(id *common.BlockId, data []byte) error {
+	bs.Lock()
+	defer bs.Unlock()
+	bs.blocks[newBlockKey(id)] = append([]byte{}, data...)
+	return nil
+}
+
+func (bs *BlockStore) DeleteBlock(id *common.BlockId) error {
+	bs.Lock()
+	defer bs.Unlock()
+	delete(bs.blocks, newBlockKey(id))
+	return nil
+}
+
+func (bs *BlockStore) GetBlock(id *common.BlockId) ([]byte, error) {
+	bs.Lock()
+	defer bs.Unlock()
+	data, ok := bs.blocks[newBlockKey(id)]
+	if !ok {
+		return nil, errors.New("block not found in block store")
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (bs *BlockStore) BlocksAtHeight(height *common.TimeBlocks) []*common.BlockId {
+	bs.Lock()
+	defer bs.Unlock()
+	prefix := math.PaddedBigBytes(height.AsInt(), 32)
+	var keys []blockKey
+	for key := range bs.blocks {
+		if bytes.Equal(key[:32], prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys)
+	var ret []*common.BlockId
+	for _, key := range keys {
+		ret = append(ret, key.blockId())
+	}
+	return ret
+}
+
+func (bs *BlockStore) IsBlockStoreEmpty() bool {
+	bs.Lock()
+	defer bs.Unlock()
+	return len(bs.blocks) == 0
+}
+
+func (bs *BlockStore) MaxBlockStoreHeight() *common.TimeBlocks {
+	bs.Lock()
+	defer bs.Unlock()
+	keys := bs.sortedKeys()
+	if len(keys) == 0 {
+		return common.NewTimeBlocksInt(0)
+	}
+	return keys[len(keys)-1].blockId().Height
+}
+
+func (bs *BlockStore) MinBlockStoreHeight() *common.TimeBlocks {
+	bs.Lock()
+	defer bs.Unlock()
+	keys := bs.sortedKeys()
+	if len(keys) == 0 {
+		return common.NewTimeBlocksInt(0)
+	}
+	return keys[0].blockId().Height
+}
+
+func (bs *BlockStore) sortedKeys() []blockKey {
+	keys := make([]blockKey, 0, len(bs.blocks))
+	for key := range bs.blocks {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+func sortKeys(keys []blockKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+}
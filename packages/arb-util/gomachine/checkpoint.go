@@ -0,0 +1,458 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+type storedValue struct {
+	val      value.Value
+	refCount uint32
+}
+
+// machineStateKeys holds a saved machine with its values replaced by the
+// hashes they are stored under
+type machineStateKeys struct {
+	code              *code
+	status            machine.Status
+	staticHash        common.Hash
+	registerHash      common.Hash
+	datastackHash     common.Hash
+	auxstackHash      common.Hash
+	arbGasRemaining   *big.Int
+	pc                codePointRef
+	errpc             value.CodePointStub
+	stagedMessageHash common.Hash
+	refCount          uint32
+}
+
+// valueHashes returns the hashes of the values the machine holds references to
+func (keys *machineStateKeys) valueHashes() []common.Hash {
+	return []common.Hash{
+		keys.staticHash,
+		keys.registerHash,
+		keys.datastackHash,
+		keys.auxstackHash,
+		keys.stagedMessageHash,
+	}
+}
+
+// CheckpointStorage is an in-memory implementation of
+// machine.CheckpointStorage for tests and light nodes. Values and machines
+// are reference counted the same way as in the RocksDB backed storage: a
+// tuple holds a reference to each tuple inside it, saving a value that is
+// already stored only increments its count and a value is removed along with
+// its references once its count drops to zero
+type CheckpointStorage struct {
+	sync.Mutex
+	values          map[common.Hash]*storedValue
+	machines        map[common.Hash]*machineStateKeys
+	data            map[string][]byte
+	initialHash     *common.Hash
+	blockStore      *BlockStore
+	aggregatorStore *AggregatorStore
+}
+
+func NewCheckpointStorage() *CheckpointStorage {
+	return &CheckpointStorage{
+		values:          make(map[common.Hash]*storedValue),
+		machines:        make(map[common.Hash]*machineStateKeys),
+		data:            make(map[string][]byte),
+		blockStore:      NewBlockStore(),
+		aggregatorStore: NewAggregatorStore(),
+	}
+}
+
+func (cs *CheckpointStorage) Initialize(contractPath string) error {
+	mach, err := New(contractPath)
+	if err != nil {
+		return err
+	}
+	cs.Lock()
+	defer cs.Unlock()
+	if !cs.saveMachine(mach) {
+		return errors.New("failed to initialize storage")
+	}
+	initialHash := mach.Hash()
+	cs.initialHash = &initialHash
+	return nil
+}
+
+func (cs *CheckpointStorage) Initialized() bool {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.initialHash != nil
+}
+
+func (cs *CheckpointStorage) CloseCheckpointStorage() bool {
+	return true
+}
+
+func (cs *CheckpointStorage) GetInitialMachine(machine.ValueCache) (machine.Machine, error) {
+	cs.Lock()
+	defer cs.Unlock()
+	if cs.initialHash == nil {
+		return nil, errors.New("error getting initial machine from checkpointstorage")
+	}
+	return cs.getMachine(*cs.initialHash)
+}
+
+func (cs *CheckpointStorage) GetMachine(machineHash common.Hash, _ machine.ValueCache) (machine.Machine, error) {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.getMachine(machineHash)
+}
+
+func (cs *CheckpointStorage) DeleteCheckpoint(machineHash common.Hash) bool {
+	cs.Lock()
+	defer cs.Unlock()
+	keys, ok := cs.machines[machineHash]
+	if !ok {
+		return false
+	}
+	keys.refCount--
+	if keys.refCount > 0 {
+		return true
+	}
+	delete(cs.machines, machineHash)
+	for _, hash := range keys.valueHashes() {
+		cs.deleteValue(hash)
+	}
+	return true
+}
+
+func (cs *CheckpointStorage) SaveValue(val value.Value) bool {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.saveValue(val)
+}
+
+func (cs *CheckpointStorage) GetValue(hashValue common.Hash, _ machine.ValueCache) (value.Value, error) {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.getValue(hashValue)
+}
+
+func (cs *CheckpointStorage) DeleteValue(hashValue common.Hash) bool {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.deleteValue(hashValue)
+}
+
+func (cs *CheckpointStorage) SaveData(key []byte, data []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	cs.Lock()
+	defer cs.Unlock()
+	cs.data[string(key)] = append([]byte{}, data...)
+	return true
+}
+
+func (cs *CheckpointStorage) GetData(key []byte) ([]byte, error) {
+	cs.Lock()
+	defer cs.Unlock()
+	data, ok := cs.data[string(key)]
+	if !ok {
+		return nil, &machine.DataNotFoundError{Key: key}
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (cs *CheckpointStorage) DeleteData(key []byte) bool {
+	cs.Lock()
+	defer cs.Unlock()
+	delete(cs.data, string(key))
+	return true
+}
+
+// UnreferencedRecords returns the stored machines and values which can't be
+// reached from the given machines and values or from the initial machine.
+// Records only referenced by other unreferenced records are left out since
+// deleting the top level records removes them as well
+func (cs *CheckpointStorage) UnreferencedRecords(
+	machineHashes []common.Hash,
+	valueHashes []common.Hash,
+) ([]common.Hash, []common.Hash) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	roots := append([]common.Hash{}, machineHashes...)
+	if cs.initialHash != nil {
+		roots = append(roots, *cs.initialHash)
+	}
+	referenced := make(map[common.Hash]bool)
+	itemsToVisit := append([]common.Hash{}, valueHashes...)
+	for _, hash := range roots {
+		if keys, ok := cs.machines[hash]; ok {
+			referenced[hash] = true
+			itemsToVisit = append(itemsToVisit, keys.valueHashes()...)
+		}
+	}
+	for len(itemsToVisit) > 0 {
+		next := itemsToVisit[len(itemsToVisit)-1]
+		itemsToVisit = itemsToVisit[:len(itemsToVisit)-1]
+		if referenced[next] {
+			continue
+		}
+		if stored, ok := cs.values[next]; ok {
+			referenced[next] = true
+			itemsToVisit = append(itemsToVisit, tupleChildHashes(stored.val)...)
+		}
+	}
+
+	children := make(map[common.Hash]bool)
+	for hash, keys := range cs.machines {
+		if !referenced[hash] {
+			for _, child := range keys.valueHashes() {
+				children[child] = true
+			}
+		}
+	}
+	for hash, stored := range cs.values {
+		if !referenced[hash] {
+			for _, child := range tupleChildHashes(stored.val) {
+				children[child] = true
+			}
+		}
+	}
+
+	var machines, values []common.Hash
+	for hash := range cs.machines {
+		if !referenced[hash] {
+			machines = append(machines, hash)
+		}
+	}
+	for hash := range cs.values {
+		if !referenced[hash] && !children[hash] {
+			values = append(values, hash)
+		}
+	}
+	sortHashes(machines)
+	sortHashes(values)
+	return machines, values
+}
+
+func sortHashes(hashes []common.Hash) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+}
+
+func (cs *CheckpointStorage) GetBlockStore() machine.BlockStore {
+	return cs.blockStore
+}
+
+func (cs *CheckpointStorage) GetAggregatorStore() machine.AggregatorStore {
+	return cs.aggregatorStore
+}
+
+// saveValue stores val and every tuple inside it that isn't already stored
+// and adds a reference to those that are
+func (cs *CheckpointStorage) saveValue(val value.Value) bool {
+	itemsToSave := []value.Value{val}
+	for len(itemsToSave) > 0 {
+		next := itemsToSave[len(itemsToSave)-1]
+		itemsToSave = itemsToSave[:len(itemsToSave)-1]
+		if _, ok := next.(value.HashPreImage); ok {
+			// Only complete values can be stored
+			return false
+		}
+		hash := next.Hash()
+		if stored, ok := cs.values[hash]; ok {
+			stored.refCount++
+			continue
+		}
+		cs.values[hash] = &storedValue{val: next, refCount: 1}
+		if tup, ok := next.(*value.TupleValue); ok {
+			for _, item := range tup.Contents() {
+				if _, ok := item.(*value.TupleValue); ok {
+					itemsToSave = append(itemsToSave, item)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// getValue returns the value stored under hash if it and every tuple it
+// references are still stored
+func (cs *CheckpointStorage) getValue(hash common.Hash) (value.Value, error) {
+	stored, ok := cs.values[hash]
+	if !ok {
+		return nil, &machine.ValueNotFoundError{HashValue: hash}
+	}
+	checked := make(map[common.Hash]bool)
+	itemsToCheck := []value.Value{stored.val}
+	for len(itemsToCheck) > 0 {
+		next := itemsToCheck[len(itemsToCheck)-1]
+		itemsToCheck = itemsToCheck[:len(itemsToCheck)-1]
+		tup, ok := next.(*value.TupleValue)
+		if !ok {
+			continue
+		}
+		for _, item := range tup.Contents() {
+			if _, ok := item.(*value.TupleValue); !ok {
+				continue
+			}
+			itemHash := item.Hash()
+			if checked[itemHash] {
+				continue
+			}
+			if _, ok := cs.values[itemHash]; !ok {
+				return nil, &machine.ValueNotFoundError{HashValue: itemHash}
+			}
+			checked[itemHash] = true
+			itemsToCheck = append(itemsToCheck, item)
+		}
+	}
+	return stored.val, nil
+}
+
+// deleteValue removes a reference to the value stored under hash, deleting
+// the value and the references it holds once none remain
+func (cs *CheckpointStorage) deleteValue(hash common.Hash) bool {
+	if _, ok := cs.values[hash]; !ok {
+		return false
+	}
+	itemsToDelete := []common.Hash{hash}
+	for len(itemsToDelete) > 0 {
+		next := itemsToDelete[len(itemsToDelete)-1]
+		itemsToDelete = itemsToDelete[:len(itemsToDelete)-1]
+		stored, ok := cs.values[next]
+		if !ok {
+			continue
+		}
+		stored.refCount--
+		if stored.refCount > 0 {
+			continue
+		}
+		delete(cs.values, next)
+		itemsToDelete = append(itemsToDelete, tupleChildHashes(stored.val)...)
+	}
+	return true
+}
+
+// tupleChildHashes returns the hashes of the tuples inside val, which a stored
+// tuple holds references to
+func tupleChildHashes(val value.Value) []common.Hash {
+	tup, ok := val.(*value.TupleValue)
+	if !ok {
+		return nil
+	}
+	var hashes []common.Hash
+	for _, item := range tup.Contents() {
+		if _, ok := item.(*value.TupleValue); ok {
+			hashes = append(hashes, item.Hash())
+		}
+	}
+	return hashes
+}
+
+func (cs *CheckpointStorage) saveMachine(m *Machine) bool {
+	machineHash := m.Hash()
+	if keys, ok := cs.machines[machineHash]; ok {
+		keys.refCount++
+		return true
+	}
+	datastack := m.stack.tupleRepresentation()
+	auxstack := m.auxstack.tupleRepresentation()
+	for _, val := range []value.Value{m.staticVal, m.registerVal, datastack, auxstack, m.stagedMessage} {
+		if !cs.saveValue(val) {
+			return false
+		}
+	}
+	cs.machines[machineHash] = &machineStateKeys{
+		code:              m.code,
+		status:            m.status,
+		staticHash:        m.staticVal.Hash(),
+		registerHash:      m.registerVal.Hash(),
+		datastackHash:     datastack.Hash(),
+		auxstackHash:      auxstack.Hash(),
+		arbGasRemaining:   m.arbGasRemaining,
+		pc:                m.pc,
+		errpc:             m.errpc,
+		stagedMessageHash: m.stagedMessage.Hash(),
+		refCount:          1,
+	}
+	return true
+}
+
+func (cs *CheckpointStorage) getMachine(machineHash common.Hash) (*Machine, error) {
+	keys, ok := cs.machines[machineHash]
+	if !ok {
+		return nil, &machine.MachineNotFoundError{HashValue: machineHash}
+	}
+	staticVal, err := cs.getValue(keys.staticHash)
+	if err != nil {
+		return nil, err
+	}
+	registerVal, err := cs.getValue(keys.registerHash)
+	if err != nil {
+		return nil, err
+	}
+	stack, err := cs.getStack(keys.datastackHash)
+	if err != nil {
+		return nil, err
+	}
+	auxstack, err := cs.getStack(keys.auxstackHash)
+	if err != nil {
+		return nil, err
+	}
+	stagedMessageVal, err := cs.getValue(keys.stagedMessageHash)
+	if err != nil {
+		return nil, err
+	}
+	stagedMessage, ok := stagedMessageVal.(*value.TupleValue)
+	if !ok {
+		return nil, errors.New("failed to load machine staged message")
+	}
+	return &Machine{
+		code:            keys.code,
+		registerVal:     registerVal,
+		staticVal:       staticVal,
+		stack:           stack,
+		auxstack:        auxstack,
+		arbGasRemaining: keys.arbGasRemaining,
+		status:          keys.status,
+		pc:              keys.pc,
+		errpc:           keys.errpc,
+		stagedMessage:   stagedMessage,
+		context:         &assertionContext{},
+	}, nil
+}
+
+func (cs *CheckpointStorage) getStack(hash common.Hash) (*datastack, error) {
+	val, err := cs.getValue(hash)
+	if err != nil {
+		return nil, err
+	}
+	tup, ok := val.(*value.TupleValue)
+	if !ok {
+		return nil, errors.New("failed to load machine stack")
+	}
+	return newDatastackFromTuple(tup)
+}
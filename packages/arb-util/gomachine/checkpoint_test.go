@@ -0,0 +1,296 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func TestValueRefCounts(t *testing.T) {
+	cs := NewCheckpointStorage()
+	inner := value.NewTuple2(value.NewInt64Value(1), value.NewInt64Value(2))
+	outer := value.NewTuple2(inner, inner)
+
+	if !cs.SaveValue(outer) {
+		t.Fatal("failed to save value")
+	}
+	if !cs.SaveValue(inner) {
+		t.Fatal("failed to save value")
+	}
+	if cs.values[inner.Hash()].refCount != 3 {
+		t.Error("wrong inner reference count", cs.values[inner.Hash()].refCount)
+	}
+
+	// The inner tuple is still referenced by the outer tuple and its own save
+	if !cs.DeleteValue(inner.Hash()) {
+		t.Fatal("failed to delete value")
+	}
+	if _, err := cs.GetValue(outer.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !cs.DeleteValue(outer.Hash()) {
+		t.Fatal("failed to delete value")
+	}
+	if _, err := cs.GetValue(outer.Hash(), nil); err == nil {
+		t.Error("deleted value still stored")
+	}
+	if _, err := cs.GetValue(inner.Hash(), nil); err == nil {
+		t.Error("value referenced by deleted value still stored")
+	}
+	if cs.DeleteValue(inner.Hash()) {
+		t.Error("deleted missing value")
+	}
+}
+
+func TestMissingReference(t *testing.T) {
+	cs := NewCheckpointStorage()
+	inner := value.NewTuple2(value.NewInt64Value(1), value.NewInt64Value(2))
+	outer := value.NewTuple2(inner, value.NewInt64Value(3))
+	cs.SaveValue(outer)
+	cs.DeleteValue(inner.Hash())
+	if _, err := cs.GetValue(outer.Hash(), nil); err == nil {
+		t.Error("got value with missing reference")
+	}
+}
+
+func TestCheckpointMachine(t *testing.T) {
+	cs := NewCheckpointStorage()
+	if cs.Initialized() {
+		t.Fatal("storage initialized before initialize")
+	}
+	if err := cs.Initialize(arbos.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if !cs.Initialized() {
+		t.Fatal("storage not initialized")
+	}
+	mach, err := cs.GetInitialMachine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mach.ExecuteAssertion(1000, nil, 0)
+	if !mach.Checkpoint(cs) {
+		t.Fatal("failed to checkpoint machine")
+	}
+	restored, err := cs.GetMachine(mach.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Hash() != mach.Hash() {
+		t.Fatal("restored machine has wrong hash")
+	}
+
+	// Both machines should continue identically
+	a1, _ := mach.ExecuteAssertion(1000, nil, 0)
+	a2, _ := restored.ExecuteAssertion(1000, nil, 0)
+	if !a1.Equals(a2) {
+		t.Error("restored machine executed differently")
+	}
+
+	if !cs.DeleteCheckpoint(a1.BeforeMachineHash.Unmarshal()) {
+		t.Fatal("failed to delete checkpoint")
+	}
+	if _, err := cs.GetMachine(a1.BeforeMachineHash.Unmarshal(), nil); err == nil {
+		t.Error("deleted machine still stored")
+	}
+	if _, err := cs.GetInitialMachine(nil); err != nil {
+		t.Error("initial machine deleted with checkpoint", err)
+	}
+}
+
+func TestUnreferencedRecords(t *testing.T) {
+	cs := NewCheckpointStorage()
+	if err := cs.Initialize(arbos.Path()); err != nil {
+		t.Fatal(err)
+	}
+	mach, err := cs.GetInitialMachine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mach.ExecuteAssertion(1000, nil, 0)
+	if !mach.Checkpoint(cs) {
+		t.Fatal("failed to checkpoint machine")
+	}
+
+	inner := value.NewTuple2(value.NewInt64Value(1), value.NewInt64Value(2))
+	referenced := value.NewTuple2(inner, value.NewInt64Value(3))
+	orphan := value.NewTuple2(value.NewTuple2(value.NewInt64Value(4), value.NewInt64Value(5)), inner)
+	cs.SaveValue(referenced)
+	cs.SaveValue(orphan)
+
+	machines, values := cs.UnreferencedRecords(nil, []common.Hash{referenced.Hash()})
+	if len(machines) != 1 || machines[0] != mach.Hash() {
+		t.Error("unreferenced machine not reported", machines)
+	}
+	if len(values) != 1 || values[0] != orphan.Hash() {
+		t.Error("wrong unreferenced values", values)
+	}
+
+	machines, values = cs.UnreferencedRecords(
+		[]common.Hash{mach.Hash()},
+		[]common.Hash{referenced.Hash(), orphan.Hash()},
+	)
+	if len(machines) != 0 || len(values) != 0 {
+		t.Error("referenced records reported", machines, values)
+	}
+}
+
+func TestData(t *testing.T) {
+	cs := NewCheckpointStorage()
+	key := []byte{1, 2, 3}
+	if cs.SaveData(nil, []byte{4}) {
+		t.Error("saved data with empty key")
+	}
+	if !cs.SaveData(key, []byte{4, 5}) {
+		t.Fatal("failed to save data")
+	}
+	data, err := cs.GetData(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{4, 5}) {
+		t.Error("wrong data", data)
+	}
+	cs.DeleteData(key)
+	if _, err := cs.GetData(key); err == nil {
+		t.Error("deleted data still stored")
+	}
+}
+
+func TestBlockStore(t *testing.T) {
+	bs := NewBlockStore()
+	if !bs.IsBlockStoreEmpty() {
+		t.Fatal("new block store isn't empty")
+	}
+	ids := []*common.BlockId{
+		{Height: common.NewTimeBlocksInt(300), HeaderHash: common.Hash{2}},
+		{Height: common.NewTimeBlocksInt(5), HeaderHash: common.Hash{9}},
+		{Height: common.NewTimeBlocksInt(300), HeaderHash: common.Hash{1}},
+	}
+	for i, id := range ids {
+		if err := bs.PutBlock(id, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bs.MinBlockStoreHeight().AsInt().Int64() != 5 {
+		t.Error("wrong min height", bs.MinBlockStoreHeight())
+	}
+	if bs.MaxBlockStoreHeight().AsInt().Int64() != 300 {
+		t.Error("wrong max height", bs.MaxBlockStoreHeight())
+	}
+	atHeight := bs.BlocksAtHeight(common.NewTimeBlocksInt(300))
+	if len(atHeight) != 2 || !atHeight[0].Equals(ids[2]) || !atHeight[1].Equals(ids[0]) {
+		t.Error("wrong blocks at height", atHeight)
+	}
+	data, err := bs.GetBlock(ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1}) {
+		t.Error("wrong block data", data)
+	}
+	if err := bs.DeleteBlock(ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.GetBlock(ids[1]); err == nil {
+		t.Error("deleted block still stored")
+	}
+	if bs.MinBlockStoreHeight().AsInt().Int64() != 300 {
+		t.Error("wrong min height after delete", bs.MinBlockStoreHeight())
+	}
+}
+
+func TestAggregatorStore(t *testing.T) {
+	as := NewAggregatorStore()
+	if _, err := as.LatestBlock(); err == nil {
+		t.Error("got latest block from empty store")
+	}
+	for i := int64(0); i < 3; i++ {
+		if err := as.SaveLog(value.NewInt64Value(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := as.SaveEmptyBlock(&types.Header{Number: big.NewInt(0)}); err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(1)}
+	if err := as.SaveBlock(header, 2); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := as.LatestBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Height.AsInt().Uint64() != 1 || latest.HeaderHash != common.NewHashFromEth(header.Hash()) {
+		t.Error("wrong latest block", latest)
+	}
+	info, err := as.GetBlock(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || !value.Eq(info.BlockLog, value.NewInt64Value(2)) {
+		t.Error("wrong block log", info)
+	}
+	if info, err := as.GetBlock(0); err != nil || info == nil || info.BlockLog != nil {
+		t.Error("wrong empty block", info, err)
+	}
+	if info, err := as.GetBlock(2); err != nil || info != nil {
+		t.Error("got block past latest", info, err)
+	}
+
+	// After a reorg, new logs replace the removed ones and blocks past the
+	// new latest one are hidden
+	if err := as.Reorg(0, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := as.GetBlock(1); err != nil || info != nil {
+		t.Error("got block removed by reorg", info, err)
+	}
+	if err := as.SaveLog(value.NewInt64Value(5)); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := as.LogCount(); err != nil || count != 2 {
+		t.Error("wrong log count after reorg", count, err)
+	}
+	if val, err := as.GetLog(1); err != nil || !value.Eq(val, value.NewInt64Value(5)) {
+		t.Error("wrong log after reorg", val, err)
+	}
+	if _, err := as.GetLog(2); err == nil {
+		t.Error("got log removed by reorg")
+	}
+
+	txHash := common.RandHash()
+	if as.GetPossibleTxHashInfo(txHash) != nil {
+		t.Error("got index for unsaved tx")
+	}
+	if err := as.SaveTxHash(txHash, 1); err != nil {
+		t.Fatal(err)
+	}
+	if index := as.GetPossibleTxHashInfo(txHash); index == nil || *index != 1 {
+		t.Error("wrong tx index", index)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"sync"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+var errCodePoint = value.CodePointValue{Op: value.BasicOperation{Op: 0}}
+var errCodePointHash = errCodePoint.Hash()
+
+type codePointRef struct {
+	segment uint64
+	pc      uint64
+}
+
+// codeSegment holds code points in reverse execution order. Index 0 is always
+// the error code point and running an instruction moves to the next lower
+// index
+type codeSegment struct {
+	id     uint64
+	code   []value.CodePointValue
+	hashes []common.Hash
+}
+
+func newCodeSegment(id uint64) *codeSegment {
+	return &codeSegment{
+		id:     id,
+		code:   []value.CodePointValue{errCodePoint},
+		hashes: []common.Hash{errCodePointHash},
+	}
+}
+
+func (s *codeSegment) addOperation(op value.Operation) value.CodePointStub {
+	var prevHash common.Hash
+	if len(s.hashes) > 0 {
+		prevHash = s.hashes[len(s.hashes)-1]
+	}
+	cp := value.CodePointValue{Op: op, NextHash: prevHash}
+	s.code = append(s.code, cp)
+	s.hashes = append(s.hashes, cp.Hash())
+	return s.codePointStub(uint64(len(s.code) - 1))
+}
+
+func (s *codeSegment) codePointStub(pc uint64) value.CodePointStub {
+	return value.NewCodePointStub(s.id, pc, s.hashes[pc])
+}
+
+// code is shared by a machine and all of its clones just like the C++ Code
+// object so that segments created by one of them are visible to all
+type code struct {
+	sync.Mutex
+	segments       map[uint64]*codeSegment
+	nextSegmentNum uint64
+}
+
+func newCode(segment *codeSegment) *code {
+	return &code{
+		segments:       map[uint64]*codeSegment{segment.id: segment},
+		nextSegmentNum: segment.id + 1,
+	}
+}
+
+// loadCodePoint returns the code point that ref points to. References that
+// don't exist in the code load the error code point
+func (c *code) loadCodePoint(ref codePointRef) (value.CodePointValue, common.Hash) {
+	c.Lock()
+	defer c.Unlock()
+	segment, ok := c.segments[ref.segment]
+	if !ok || ref.pc >= uint64(len(segment.code)) {
+		return errCodePoint, errCodePointHash
+	}
+	return segment.code[ref.pc], segment.hashes[ref.pc]
+}
+
+func (c *code) initialCodePointRef() codePointRef {
+	c.Lock()
+	defer c.Unlock()
+	return codePointRef{segment: 0, pc: uint64(len(c.segments[0].code) - 1)}
+}
+
+func (c *code) addSegment() value.CodePointStub {
+	c.Lock()
+	defer c.Unlock()
+	segment := newCodeSegment(c.nextSegmentNum)
+	c.nextSegmentNum++
+	c.segments[segment.id] = segment
+	return segment.codePointStub(0)
+}
+
+// addOperation creates a code point running op and then continuing at ref
+func (c *code) addOperation(ref codePointRef, op value.Operation) value.CodePointStub {
+	c.Lock()
+	defer c.Unlock()
+	segment, ok := c.segments[ref.segment]
+	if !ok {
+		segment = newCodeSegment(ref.segment)
+		c.segments[ref.segment] = segment
+	}
+	if ref.pc == uint64(len(segment.code)-1) {
+		return segment.addOperation(op)
+	}
+	// The segment was already extended elsewhere so a copy is needed. Like
+	// the C++ getSubset, the copy ends before ref.pc
+	end := ref.pc
+	if end > uint64(len(segment.code)) {
+		end = uint64(len(segment.code))
+	}
+	newSegment := &codeSegment{
+		id:     c.nextSegmentNum,
+		code:   append([]value.CodePointValue{}, segment.code[:end]...),
+		hashes: append([]common.Hash{}, segment.hashes[:end]...),
+	}
+	c.nextSegmentNum++
+	c.segments[newSegment.id] = newSegment
+	return newSegment.addOperation(op)
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+var emptyTuplePreImage = value.NewEmptyTuple().GetPreImage()
+
+// datastack is a stack of values hashed as a linked list of 2-tuples. The
+// hash pre-images of the bottom of the stack are cached and dropped as the
+// stack is modified
+type datastack struct {
+	values []value.Value
+	hashes []value.HashPreImage
+}
+
+func (s *datastack) clone() *datastack {
+	return &datastack{
+		values: append([]value.Value{}, s.values...),
+		hashes: append([]value.HashPreImage{}, s.hashes...),
+	}
+}
+
+func (s *datastack) size() uint64 {
+	return uint64(len(s.values))
+}
+
+func (s *datastack) push(val value.Value) {
+	s.values = append(s.values, val)
+}
+
+func (s *datastack) invalidate(count int) {
+	if len(s.hashes) > count {
+		s.hashes = s.hashes[:count]
+	}
+}
+
+func (s *datastack) pop() (value.Value, error) {
+	if len(s.values) == 0 {
+		return nil, errStackTooSmall
+	}
+	val := s.values[len(s.values)-1]
+	s.values[len(s.values)-1] = nil
+	s.values = s.values[:len(s.values)-1]
+	s.invalidate(len(s.values))
+	return val, nil
+}
+
+// get returns the value index items below the top of the stack
+func (s *datastack) get(index int) (value.Value, error) {
+	if index >= len(s.values) {
+		return nil, errStackTooSmall
+	}
+	return s.values[len(s.values)-1-index], nil
+}
+
+func (s *datastack) set(index int, val value.Value) {
+	pos := len(s.values) - 1 - index
+	s.values[pos] = val
+	s.invalidate(pos)
+}
+
+func (s *datastack) prepForMod(count int) error {
+	if count > len(s.values) {
+		return errStackTooSmall
+	}
+	return nil
+}
+
+func (s *datastack) getHashPreImage() value.HashPreImage {
+	if len(s.values) == 0 {
+		return emptyTuplePreImage
+	}
+	for len(s.hashes) < len(s.values) {
+		prev := emptyTuplePreImage
+		if len(s.hashes) > 0 {
+			prev = s.hashes[len(s.hashes)-1]
+		}
+		tup := value.NewTuple2(s.values[len(s.hashes)], prev)
+		s.hashes = append(s.hashes, tup.GetPreImage())
+	}
+	return s.hashes[len(s.hashes)-1]
+}
+
+// tupleRepresentation returns the stack as the linked list of 2-tuples that
+// its hash is calculated from
+func (s *datastack) tupleRepresentation() *value.TupleValue {
+	rep := value.NewEmptyTuple()
+	for _, val := range s.values {
+		rep = value.NewTuple2(val, rep)
+	}
+	return rep
+}
+
+func newDatastackFromTuple(rep *value.TupleValue) (*datastack, error) {
+	var reversed []value.Value
+	for rep.Len() != 0 {
+		if rep.Len() != 2 {
+			return nil, fmt.Errorf("invalid stack representation %v", rep)
+		}
+		contents := rep.Contents()
+		reversed = append(reversed, contents[0])
+		next, ok := contents[1].(*value.TupleValue)
+		if !ok {
+			return nil, fmt.Errorf("invalid stack representation %v", rep)
+		}
+		rep = next
+	}
+	values := make([]value.Value, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		values = append(values, reversed[i])
+	}
+	return &datastack{values: values}, nil
+}
+
+// marshalForProof pops a value for each level in stackInfo and returns the
+// hash pre-image of the rest of the stack along with the popped values
+// serialized from the deepest to the most shallow
+func (s *datastack) marshalForProof(stackInfo []marshalLevel, c *code) (value.HashPreImage, []byte, error) {
+	if len(stackInfo) > len(s.values) {
+		return value.HashPreImage{}, nil, errStackTooSmall
+	}
+	restCount := len(s.values) - len(stackInfo)
+	hashCount := len(s.hashes)
+	if hashCount > restCount {
+		hashCount = restCount
+	}
+	rest := &datastack{
+		values: s.values[:restCount],
+		hashes: s.hashes[:hashCount:hashCount],
+	}
+	var buf bytes.Buffer
+	for i := len(stackInfo) - 1; i >= 0; i-- {
+		val := s.values[len(s.values)-1-i]
+		if err := marshalForProof(val, stackInfo[i], &buf, c); err != nil {
+			return value.HashPreImage{}, nil, err
+		}
+	}
+	return rest.getHashPreImage(), buf.Bytes(), nil
+}
+
+func (s *datastack) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := len(s.values) - 1; i >= 0; i-- {
+		buf.WriteString(fmt.Sprint(s.values[i]))
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+// CodePoint identifies an instruction by its code segment and its offset in
+// that segment. Offsets count down as execution proceeds
+type CodePoint struct {
+	Segment uint64
+	PC      uint64
+}
+
+func (cp CodePoint) String() string {
+	return fmt.Sprintf("(%v, %v)", cp.Segment, cp.PC)
+}
+
+func (ref codePointRef) codePoint() CodePoint {
+	return CodePoint{Segment: ref.segment, PC: ref.pc}
+}
+
+// State is a snapshot of a machine for inspection. Stacks are listed from
+// the top down
+type State struct {
+	Status          machine.Status
+	PC              CodePoint
+	Operation       value.Operation
+	CodePointHash   common.Hash
+	Stack           []value.Value
+	StackHash       common.Hash
+	AuxStack        []value.Value
+	AuxStackHash    common.Hash
+	Register        value.Value
+	Static          value.Value
+	ArbGasRemaining *big.Int
+	ErrPC           CodePoint
+	StagedMessage   value.Value
+}
+
+func stackValues(s *datastack) []value.Value {
+	values := make([]value.Value, 0, len(s.values))
+	for i := len(s.values) - 1; i >= 0; i-- {
+		values = append(values, s.values[i])
+	}
+	return values
+}
+
+func (m *Machine) State() *State {
+	currentCodePoint, currentHash := m.code.loadCodePoint(m.pc)
+	return &State{
+		Status:          m.status,
+		PC:              m.pc.codePoint(),
+		Operation:       currentCodePoint.Op,
+		CodePointHash:   currentHash,
+		Stack:           stackValues(m.stack),
+		StackHash:       m.stack.getHashPreImage().Hash(),
+		AuxStack:        stackValues(m.auxstack),
+		AuxStackHash:    m.auxstack.getHashPreImage().Hash(),
+		Register:        m.registerVal,
+		Static:          m.staticVal,
+		ArbGasRemaining: new(big.Int).Set(m.arbGasRemaining),
+		ErrPC:           CodePoint{Segment: m.errpc.Segment, PC: m.errpc.PC},
+		StagedMessage:   m.stagedMessage,
+	}
+}
+
+// ExecuteDebugAssertion works like ExecuteAssertion, but it also stops before
+// running an instruction at one of the breakpoints. The instruction that
+// execution starts from is always run so that execution can continue from a
+// breakpoint
+func (m *Machine) ExecuteDebugAssertion(
+	maxSteps uint64,
+	inboxMessages []inbox.InboxMessage,
+	breakpoints map[CodePoint]bool,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	return m.executeAssertion(maxSteps, inboxMessages, value.NewEmptyTuple(), false, nil, breakpoints, maxWallTime)
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
+)
+
+func TestExecuteDebugAssertion(t *testing.T) {
+	mach, err := New(arbos.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Step a copy of the machine one instruction at a time to find a code
+	// point that is first reached after a few steps
+	trace := []CodePoint{mach.State().PC}
+	stepper := mach.Clone().(*Machine)
+	for i := 0; i < 20; i++ {
+		if _, steps := stepper.ExecuteAssertion(1, nil, 0); steps != 1 {
+			t.Fatal("machine blocked while stepping")
+		}
+		trace = append(trace, stepper.State().PC)
+	}
+	breakpointStep := 0
+	for i := 5; i < len(trace)-1 && breakpointStep == 0; i++ {
+		breakpointStep = i
+		for _, pc := range trace[:i] {
+			if pc == trace[i] {
+				breakpointStep = 0
+			}
+		}
+	}
+	if breakpointStep == 0 {
+		t.Fatal("no code point first reached within the trace")
+	}
+	breakpoints := map[CodePoint]bool{trace[breakpointStep]: true}
+
+	_, steps := mach.ExecuteDebugAssertion(1000, nil, breakpoints, 0)
+	if steps != uint64(breakpointStep) {
+		t.Errorf("stopped after %v steps, expected %v", steps, breakpointStep)
+	}
+	if mach.State().PC != trace[breakpointStep] {
+		t.Error("stopped at", mach.State().PC, "instead of breakpoint", trace[breakpointStep])
+	}
+
+	// Continuing runs the instruction at the breakpoint rather than stopping
+	// on it again
+	_, steps = mach.ExecuteDebugAssertion(1, nil, breakpoints, 0)
+	if steps != 1 {
+		t.Error("didn't continue past breakpoint, ran", steps, "steps")
+	}
+	if mach.State().PC != trace[breakpointStep+1] {
+		t.Error("continued to", mach.State().PC, "instead of", trace[breakpointStep+1])
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+)
+
+const maxECPairingPoints = 30
+const ecPairingGasCost = 500000
+
+var secp256k1N = crypto.S256().Params().N
+
+type g1Point struct {
+	x, y *big.Int
+}
+
+type g2Point struct {
+	x0, x1, y0, y1 *big.Int
+}
+
+func newG1(p g1Point) (*bn256.G1, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, math.PaddedBigBytes(p.x, 32)...)
+	buf = append(buf, math.PaddedBigBytes(p.y, 32)...)
+	g1 := new(bn256.G1)
+	if _, err := g1.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return g1, nil
+}
+
+// newG2 parses a point whose coordinates are given as (real, imaginary)
+// pairs. bn256 expects the imaginary part first. Unlike libff, bn256 also
+// rejects points that aren't in the G2 subgroup
+func newG2(p g2Point) (*bn256.G2, error) {
+	buf := make([]byte, 0, 128)
+	buf = append(buf, math.PaddedBigBytes(p.x1, 32)...)
+	buf = append(buf, math.PaddedBigBytes(p.x0, 32)...)
+	buf = append(buf, math.PaddedBigBytes(p.y1, 32)...)
+	buf = append(buf, math.PaddedBigBytes(p.y0, 32)...)
+	g2 := new(bn256.G2)
+	if _, err := g2.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	return g2, nil
+}
+
+// toG1Point returns the affine coordinates of p. Like libff, the point at
+// infinity is returned as (0, 1)
+func toG1Point(p *bn256.G1) g1Point {
+	data := p.Marshal()
+	x := new(big.Int).SetBytes(data[:32])
+	y := new(big.Int).SetBytes(data[32:])
+	if x.Sign() == 0 && y.Sign() == 0 {
+		y.SetInt64(1)
+	}
+	return g1Point{x: x, y: y}
+}
+
+func ecadd(a g1Point, b g1Point) (g1Point, error) {
+	pa, err := newG1(a)
+	if err != nil {
+		return g1Point{}, err
+	}
+	pb, err := newG1(b)
+	if err != nil {
+		return g1Point{}, err
+	}
+	return toG1Point(new(bn256.G1).Add(pa, pb)), nil
+}
+
+func ecmul(a g1Point, factor *big.Int) (g1Point, error) {
+	pa, err := newG1(a)
+	if err != nil {
+		return g1Point{}, err
+	}
+	return toG1Point(new(bn256.G1).ScalarMult(pa, factor)), nil
+}
+
+type pairingInput struct {
+	g1 g1Point
+	g2 g2Point
+}
+
+func ecpairing(input []pairingInput) (bool, error) {
+	g1s := make([]*bn256.G1, 0, len(input))
+	g2s := make([]*bn256.G2, 0, len(input))
+	for _, item := range input {
+		g1, err := newG1(item.g1)
+		if err != nil {
+			return false, err
+		}
+		g2, err := newG2(item.g2)
+		if err != nil {
+			return false, err
+		}
+		g1s = append(g1s, g1)
+		g2s = append(g2s, g2)
+	}
+	return bn256.PairingCheck(g1s, g2s), nil
+}
+
+// ecrecover returns the address that signed message as an integer or 0 if
+// the signature is invalid
+func ecrecover(r, s, recovery, message *big.Int) *big.Int {
+	if recovery.Cmp(big.NewInt(1)) > 0 {
+		return big.NewInt(0)
+	}
+	// libsecp256k1 rejects signatures with r or s out of range
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(secp256k1N) >= 0 || s.Cmp(secp256k1N) >= 0 {
+		return big.NewInt(0)
+	}
+	sig := make([]byte, 0, 65)
+	sig = append(sig, math.PaddedBigBytes(r, 32)...)
+	sig = append(sig, math.PaddedBigBytes(s, 32)...)
+	sig = append(sig, byte(recovery.Uint64()))
+	pubkey, err := crypto.Ecrecover(math.PaddedBigBytes(message, 32), sig)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	hash := crypto.Keccak256(pubkey[1:])
+	return new(big.Int).SetBytes(hash[12:])
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+type jsonCodePoint struct {
+	Internal uint64
+}
+
+type jsonValue struct {
+	Int       *string
+	Tuple     []jsonValue
+	CodePoint *jsonCodePoint
+}
+
+type jsonOperation struct {
+	Opcode    json.RawMessage `json:"opcode"`
+	Immediate *jsonValue      `json:"immediate"`
+}
+
+type jsonExecutable struct {
+	Code      []jsonOperation `json:"code"`
+	StaticVal jsonValue       `json:"static_val"`
+}
+
+func (op jsonOperation) opcode() (value.Opcode, error) {
+	var opcode value.Opcode
+	if err := json.Unmarshal(op.Opcode, &opcode); err == nil {
+		return opcode, nil
+	}
+	var nested struct {
+		AVMOpcode *value.Opcode
+	}
+	if err := json.Unmarshal(op.Opcode, &nested); err != nil {
+		return 0, err
+	}
+	if nested.AVMOpcode == nil {
+		return 0, fmt.Errorf("invalid opcode %s", op.Opcode)
+	}
+	return *nested.AVMOpcode, nil
+}
+
+// toValue converts a value in a .mexe file. Code points are given as an
+// offset from the end of the code and must refer to an operation that has
+// already been added to segment
+func (v jsonValue) toValue(opCount uint64, segment *codeSegment) (value.Value, error) {
+	switch {
+	case v.Int != nil:
+		val, ok := new(big.Int).SetString(*v.Int, 16)
+		if !ok || val.Sign() < 0 || val.BitLen() > 256 {
+			return nil, fmt.Errorf("invalid int %v", *v.Int)
+		}
+		return value.NewIntValue(val), nil
+	case v.Tuple != nil:
+		if len(v.Tuple) > value.MaxTupleSize {
+			return nil, errors.New("tuple must contain array of size less than 9")
+		}
+		vals := make([]value.Value, 0, len(v.Tuple))
+		for _, item := range v.Tuple {
+			val, err := item.toValue(opCount, segment)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+		return value.NewTupleFromSlice(vals)
+	case v.CodePoint != nil:
+		pc := uint64(0)
+		// Special handle python compiler's marker for error code point
+		if v.CodePoint.Internal != math.MaxUint64 {
+			pc = opCount - v.CodePoint.Internal
+		}
+		if pc >= uint64(len(segment.code)) {
+			return nil, fmt.Errorf("code point %v refers to code that isn't loaded", pc)
+		}
+		return segment.codePointStub(pc), nil
+	default:
+		return nil, errors.New("invalid value type")
+	}
+}
+
+// loadExecutable parses a .mexe file the same way as the C++ loadExecutable
+func loadExecutable(data []byte) (*codeSegment, value.Value, error) {
+	var executable jsonExecutable
+	if err := json.Unmarshal(data, &executable); err != nil {
+		return nil, nil, err
+	}
+	if executable.Code == nil {
+		return nil, nil, errors.New("expected code to be array")
+	}
+	opCount := uint64(len(executable.Code))
+	segment := newCodeSegment(0)
+	for i := len(executable.Code) - 1; i >= 0; i-- {
+		jsonOp := executable.Code[i]
+		opcode, err := jsonOp.opcode()
+		if err != nil {
+			return nil, nil, err
+		}
+		if jsonOp.Immediate == nil {
+			segment.addOperation(value.BasicOperation{Op: opcode})
+			continue
+		}
+		imm, err := jsonOp.Immediate.toValue(opCount, segment)
+		if err != nil {
+			return nil, nil, err
+		}
+		segment.addOperation(value.ImmediateOperation{Op: opcode, Val: imm})
+	}
+	staticVal, err := executable.StaticVal.toValue(opCount, segment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return segment, staticVal, nil
+}
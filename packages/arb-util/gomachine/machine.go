@@ -0,0 +1,396 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gomachine is a pure Go implementation of the AVM that matches the
+// behavior of the C++ machine in arb-avm-cpp step for step, including
+// machine hashes, gas accounting and one step proofs
+package gomachine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/math"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/hashing"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+var maxArbGasRemaining = math.MaxBig256
+
+type assertionContext struct {
+	inboxMessages         []*value.TupleValue
+	inboxMessagesConsumed uint64
+	sideloadValue         *value.TupleValue
+	blockingSideload      bool
+	fakeInboxPeekValue    value.Value
+	breakpoints           map[CodePoint]bool
+	numSteps              uint64
+	numGas                uint64
+	outMessages           []value.Value
+	logs                  []value.Value
+	debugPrints           []value.Value
+}
+
+func (ctx *assertionContext) inboxEmpty() bool {
+	return ctx.inboxMessagesConsumed == uint64(len(ctx.inboxMessages))
+}
+
+func (ctx *assertionContext) popInbox() *value.TupleValue {
+	msg := ctx.inboxMessages[ctx.inboxMessagesConsumed]
+	ctx.inboxMessagesConsumed++
+	return msg
+}
+
+type Machine struct {
+	code            *code
+	registerVal     value.Value
+	staticVal       value.Value
+	stack           *datastack
+	auxstack        *datastack
+	arbGasRemaining *big.Int
+	status          machine.Status
+	pc              codePointRef
+	errpc           value.CodePointStub
+	stagedMessage   *value.TupleValue
+	context         *assertionContext
+}
+
+func New(codeFile string) (*Machine, error) {
+	data, err := ioutil.ReadFile(codeFile)
+	if err != nil {
+		return nil, fmt.Errorf("error creating machine from file %s: %v", codeFile, err)
+	}
+	return NewFromExecutable(data)
+}
+
+// NewFromExecutable creates a machine from the contents of a .mexe file
+func NewFromExecutable(data []byte) (*Machine, error) {
+	segment, staticVal, err := loadExecutable(data)
+	if err != nil {
+		return nil, err
+	}
+	c := newCode(segment)
+	return &Machine{
+		code:            c,
+		registerVal:     value.NewEmptyTuple(),
+		staticVal:       staticVal,
+		stack:           &datastack{},
+		auxstack:        &datastack{},
+		arbGasRemaining: maxArbGasRemaining,
+		status:          machine.Extensive,
+		pc:              c.initialCodePointRef(),
+		errpc:           segment.codePointStub(0),
+		stagedMessage:   value.NewEmptyTuple(),
+		context:         &assertionContext{},
+	}, nil
+}
+
+func (m *Machine) Hash() common.Hash {
+	switch m.status {
+	case machine.Halt:
+		return common.Hash{}
+	case machine.ErrorStop:
+		return common.Hash{31: 1}
+	}
+	_, codePointHash := m.code.loadCodePoint(m.pc)
+	return hashing.SoliditySHA3(
+		hashing.Bytes32(codePointHash),
+		hashing.Bytes32(m.stack.getHashPreImage().Hash()),
+		hashing.Bytes32(m.auxstack.getHashPreImage().Hash()),
+		hashing.Bytes32(m.registerVal.Hash()),
+		hashing.Bytes32(m.staticVal.Hash()),
+		hashing.Uint256(m.arbGasRemaining),
+		hashing.Bytes32(m.errpc.Hash()),
+		hashing.Bytes32(m.stagedMessage.Hash()),
+	)
+}
+
+// Clone returns a copy of the machine. Like the C++ machine, clones share
+// their code so code segments added by one of them are visible to all
+func (m *Machine) Clone() machine.Machine {
+	return &Machine{
+		code:            m.code,
+		registerVal:     m.registerVal,
+		staticVal:       m.staticVal,
+		stack:           m.stack.clone(),
+		auxstack:        m.auxstack.clone(),
+		arbGasRemaining: m.arbGasRemaining,
+		status:          m.status,
+		pc:              m.pc,
+		errpc:           m.errpc,
+		stagedMessage:   m.stagedMessage,
+		context:         &assertionContext{},
+	}
+}
+
+func (m *Machine) String() string {
+	var buf bytes.Buffer
+	currentCodePoint, currentHash := m.code.loadCodePoint(m.pc)
+	_, errHandlerHash := m.code.loadCodePoint(codePointRef{segment: m.errpc.Segment, pc: m.errpc.PC})
+	fmt.Fprintln(&buf, "status", m.status)
+	fmt.Fprintf(&buf, "pc (%v, %v)\n", m.pc.segment, m.pc.pc)
+	fmt.Fprintln(&buf, "data stack:", m.stack)
+	fmt.Fprintf(&buf, "operation %v(%v)\n", OpcodeName(currentCodePoint.Op.GetOp()), currentCodePoint.Op)
+	fmt.Fprintln(&buf, "codePointHash", currentHash.String())
+	fmt.Fprintln(&buf, "stackHash", m.stack.getHashPreImage().Hash().String())
+	fmt.Fprintln(&buf, "auxStackHash", m.auxstack.getHashPreImage().Hash().String())
+	fmt.Fprintln(&buf, "registerHash", m.registerVal.Hash().String())
+	fmt.Fprintln(&buf, "staticHash", m.staticVal.Hash().String())
+	fmt.Fprintln(&buf, "arb_gas_remaining", m.arbGasRemaining)
+	fmt.Fprintf(&buf, "err handler (%v, %v)\n", m.errpc.Segment, m.errpc.PC)
+	fmt.Fprintln(&buf, "errHandlerHash", errHandlerHash.String())
+	return buf.String()
+}
+
+func (m *Machine) PrintState() {
+	fmt.Print(m)
+}
+
+func (m *Machine) CurrentStatus() machine.Status {
+	return m.status
+}
+
+func (m *Machine) IsBlocked(newMessages bool) machine.BlockReason {
+	switch m.status {
+	case machine.ErrorStop:
+		return machine.ErrorBlocked{}
+	case machine.Halt:
+		return machine.HaltBlocked{}
+	}
+	instruction, _ := m.code.loadCodePoint(m.pc)
+	op := instruction.Op.GetOp()
+	if (op == INBOX || op == INBOXPEEK) && !newMessages {
+		return machine.InboxBlocked{}
+	}
+	return nil
+}
+
+func (m *Machine) ExecuteAssertion(
+	maxSteps uint64,
+	inboxMessages []inbox.InboxMessage,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	return m.executeAssertion(maxSteps, inboxMessages, value.NewEmptyTuple(), false, nil, nil, maxWallTime)
+}
+
+func (m *Machine) ExecuteCallServerAssertion(
+	maxSteps uint64,
+	inboxMessages []inbox.InboxMessage,
+	fakeInboxPeekValue value.Value,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	return m.executeAssertion(maxSteps, inboxMessages, value.NewEmptyTuple(), false, fakeInboxPeekValue, nil, maxWallTime)
+}
+
+func (m *Machine) ExecuteSideloadedAssertion(
+	maxSteps uint64,
+	inboxMessages []inbox.InboxMessage,
+	sideloadValue *value.TupleValue,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	return m.executeAssertion(maxSteps, inboxMessages, sideloadValue, true, nil, nil, maxWallTime)
+}
+
+// Checkpoint saves the machine to storage, which must be the in-memory
+// CheckpointStorage since the database backed storage only saves C++ machines
+func (m *Machine) Checkpoint(storage machine.CheckpointStorage) bool {
+	cs, ok := storage.(*CheckpointStorage)
+	if !ok {
+		return false
+	}
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.saveMachine(m)
+}
+
+func valuesToRaw(values []value.Value) []byte {
+	var buf bytes.Buffer
+	for _, val := range values {
+		// Error can only occur with writes and bytes.Buffer is safe
+		_ = value.MarshalValue(val, &buf)
+	}
+	return buf.Bytes()
+}
+
+func inboxMessageValues(inboxMessages []inbox.InboxMessage) ([]*value.TupleValue, error) {
+	messages := make([]*value.TupleValue, 0, len(inboxMessages))
+	for _, msg := range inboxMessages {
+		tup, ok := msg.AsValue().(*value.TupleValue)
+		if !ok || tup.Len() < 2 {
+			return nil, errors.New("invalid message format")
+		}
+		if _, ok := tup.Contents()[1].(value.IntValue); !ok {
+			return nil, errors.New("invalid message format")
+		}
+		messages = append(messages, tup)
+	}
+	return messages, nil
+}
+
+func (m *Machine) executeAssertion(
+	maxSteps uint64,
+	inboxMessages []inbox.InboxMessage,
+	sideloadValue *value.TupleValue,
+	blockingSideload bool,
+	fakeInboxPeekValue value.Value,
+	breakpoints map[CodePoint]bool,
+	maxWallTime time.Duration,
+) (*protocol.ExecutionAssertion, uint64) {
+	beforeHash := m.Hash()
+	messages, err := inboxMessageValues(inboxMessages)
+	if err != nil {
+		log.Println("Failed to make assertion", err)
+		return protocol.NewExecutionAssertion(beforeHash, beforeHash, 0, 0, nil, 0, nil, 0), 0
+	}
+	m.context = &assertionContext{
+		inboxMessages:      messages,
+		sideloadValue:      sideloadValue,
+		blockingSideload:   blockingSideload,
+		fakeInboxPeekValue: fakeInboxPeekValue,
+		breakpoints:        breakpoints,
+	}
+	m.run(maxSteps, time.Duration(uint64(maxWallTime.Seconds()))*time.Second)
+
+	ctx := m.context
+	m.context = &assertionContext{}
+	if len(ctx.debugPrints) > 0 {
+		log.Println("Produced assertion containing debug prints")
+		for _, d := range ctx.debugPrints {
+			log.Println("DebugPrint:", d)
+		}
+	}
+	return protocol.NewExecutionAssertion(
+		beforeHash,
+		m.Hash(),
+		ctx.numGas,
+		ctx.inboxMessagesConsumed,
+		valuesToRaw(ctx.outMessages),
+		uint64(len(ctx.outMessages)),
+		valuesToRaw(ctx.logs),
+		uint64(len(ctx.logs)),
+	), ctx.numSteps
+}
+
+func (m *Machine) run(stepCount uint64, wallLimit time.Duration) {
+	startTime := time.Now()
+	for m.context.numSteps < stepCount {
+		if m.context.numSteps > 0 && m.context.breakpoints[m.pc.codePoint()] {
+			break
+		}
+		if blockReason := m.runOne(); blockReason != nil {
+			break
+		}
+		if wallLimit != 0 && m.context.numSteps%10000 == 0 && time.Since(startTime) >= wallLimit {
+			break
+		}
+	}
+}
+
+// runOne executes the current instruction and returns the reason the machine
+// is blocked, or nil if the instruction ran
+func (m *Machine) runOne() machine.BlockReason {
+	switch m.status {
+	case machine.ErrorStop:
+		return machine.ErrorBlocked{}
+	case machine.Halt:
+		return machine.HaltBlocked{}
+	}
+
+	instruction, _ := m.code.loadCodePoint(m.pc)
+	blockReason := m.runInstruction(instruction.Op)
+	if blockReason == nil {
+		m.context.numSteps++
+	}
+
+	// If we're in the error state, jump to the error handler if one is set
+	if m.status == machine.ErrorStop && m.errpc.Hash() != errCodePointHash {
+		m.pc = codePointRef{segment: m.errpc.Segment, pc: m.errpc.PC}
+		m.status = machine.Extensive
+	}
+	return blockReason
+}
+
+func (m *Machine) runInstruction(op value.Operation) machine.BlockReason {
+	// Always push the immediate to the stack if we're not blocked
+	imm, hasImmediate := op.(value.ImmediateOperation)
+	if hasImmediate {
+		m.stack.push(imm.Val)
+	}
+
+	opcode := op.GetOp()
+	info, valid := opcodeSet[opcode]
+	if !valid {
+		// The opcode is invalid, execute by transitioning to the error state
+		m.status = machine.ErrorStop
+		return nil
+	}
+
+	gasCost := m.nextGasCost(opcode, info)
+	if m.arbGasRemaining.Cmp(new(big.Int).SetUint64(gasCost)) < 0 {
+		// If there's insufficient gas remaining, execute by transitioning
+		// to the error state with remaining gas set to max
+		m.arbGasRemaining = maxArbGasRemaining
+		m.status = machine.ErrorStop
+		return nil
+	}
+	m.arbGasRemaining = new(big.Int).Sub(m.arbGasRemaining, new(big.Int).SetUint64(gasCost))
+
+	// save stack size for stack cleanup in case of error
+	startStackSize := m.stack.size()
+	blockReason, err := m.runOp(opcode)
+	if err != nil {
+		m.status = machine.ErrorStop
+	}
+
+	if blockReason != nil {
+		// Get rid of the immediate and reset the gas if the machine was
+		// actually blocked
+		m.arbGasRemaining = new(big.Int).Add(m.arbGasRemaining, new(big.Int).SetUint64(gasCost))
+		if hasImmediate {
+			_, _ = m.stack.pop()
+		}
+		return blockReason
+	}
+
+	m.context.numGas += gasCost
+
+	if m.status == machine.ErrorStop {
+		// Clear stack to base for instruction
+		stackItems := uint64(len(info.stackPops))
+		for m.stack.size() > 0 && startStackSize-m.stack.size() < stackItems {
+			_, _ = m.stack.pop()
+		}
+	}
+	return nil
+}
+
+func (m *Machine) nextGasCost(opcode value.Opcode, info opcodeInfo) uint64 {
+	gasCost := info.gasCost
+	if opcode == ECPAIRING {
+		gasCost += m.ecPairingVariableGasCost()
+	}
+	return gasCost
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/arbos"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/inbox"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func packagesDir(t *testing.T) string {
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("failed to get filename")
+	}
+	return filepath.Join(filepath.Dir(filename), "../..")
+}
+
+func testFiles(t *testing.T, dir string, extension string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filenames := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), extension) {
+			filenames = append(filenames, filepath.Join(dir, file.Name()))
+		}
+	}
+	return filenames
+}
+
+func TestKeccakF(t *testing.T) {
+	// Absorb a single padded empty block with rate 136 to get keccak256("")
+	var state [25]uint64
+	state[0] ^= 0x01
+	state[16] ^= 0x80 << 56
+	keccakF1600(&state)
+	var digest [32]byte
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(digest[i*8:], state[i])
+	}
+	if !bytes.Equal(digest[:], crypto.Keccak256()) {
+		t.Errorf("wrong keccak256 hash %x", digest)
+	}
+}
+
+func TestSha256F(t *testing.T) {
+	digest := [8]uint32{
+		0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+		0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+	}
+	var block [64]byte
+	copy(block[:], "abc")
+	block[3] = 0x80
+	block[63] = 24
+	sha256Block(&digest, &block)
+	var result [32]byte
+	for i, word := range digest {
+		binary.BigEndian.PutUint32(result[i*4:], word)
+	}
+	if result != sha256.Sum256([]byte("abc")) {
+		t.Errorf("wrong sha256 hash %x", result)
+	}
+}
+
+func TestOpCodeCases(t *testing.T) {
+	testDir := filepath.Join(packagesDir(t), "arb-avm-cpp/tests/machine-cases")
+	for _, testFile := range testFiles(t, testDir, ".mexe") {
+		t.Run(filepath.Base(testFile), func(t *testing.T) {
+			mach, err := New(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Keep running past breakpoints until the machine stops
+			totalSteps := uint64(0)
+			for mach.IsBlocked(false) == nil && totalSteps < 100000 {
+				_, steps := mach.ExecuteAssertion(100000, nil, 0)
+				totalSteps += steps
+			}
+			if mach.CurrentStatus() != machine.Halt {
+				mach.PrintState()
+				t.Errorf("machine ended in state %v after %v steps", mach.CurrentStatus(), totalSteps)
+			}
+		})
+	}
+}
+
+type proofAssertion struct {
+	NumGas            uint64
+	BeforeMachineHash common.Hash
+	AfterMachineHash  common.Hash
+}
+
+type proofData struct {
+	Assertion proofAssertion
+	Proof     []byte
+	Message   *inbox.InboxMessage
+}
+
+// TestProofCases replays the one step proofs generated by the C++ machine
+// and checks that every step matches
+func TestProofCases(t *testing.T) {
+	packages := packagesDir(t)
+	proofDir := filepath.Join(packages, "arb-bridge-eth/test/proofs")
+	testDir := filepath.Join(packages, "arb-avm-cpp/tests/machine-cases")
+	for _, proofFile := range testFiles(t, proofDir, ".mexe-proofs.json") {
+		name := strings.TrimSuffix(filepath.Base(proofFile), "-proofs.json")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(proofFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var proofs []proofData
+			if err := json.Unmarshal(data, &proofs); err != nil {
+				t.Fatal(err)
+			}
+			codeFile := filepath.Join(testDir, name)
+			if _, err := os.Stat(codeFile); os.IsNotExist(err) {
+				t.Skip("no machine for proofs")
+			}
+			mach, err := New(codeFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, proof := range proofs {
+				proofBytes, err := mach.MarshalForProof()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(proofBytes, proof.Proof) {
+					mach.PrintState()
+					t.Fatalf("wrong proof at step %v", i)
+				}
+				var messages []inbox.InboxMessage
+				if proof.Message != nil {
+					messages = append(messages, *proof.Message)
+				}
+				a, steps := mach.ExecuteAssertion(1, messages, 0)
+				if steps != 1 {
+					t.Fatalf("ran %v steps at step %v", steps, i)
+				}
+				if a.BeforeMachineHash.Unmarshal() != proof.Assertion.BeforeMachineHash {
+					t.Fatalf("wrong before hash at step %v", i)
+				}
+				if a.AfterMachineHash.Unmarshal() != proof.Assertion.AfterMachineHash {
+					mach.PrintState()
+					t.Fatalf("wrong after hash at step %v", i)
+				}
+				if a.NumGas != proof.Assertion.NumGas {
+					t.Fatalf("wrong gas %v at step %v, expected %v", a.NumGas, i, proof.Assertion.NumGas)
+				}
+			}
+		})
+	}
+}
+
+func TestArbOSCases(t *testing.T) {
+	testDir := filepath.Join(packagesDir(t), "arb-avm-cpp/tests/arbos-cases")
+	for _, testFile := range testFiles(t, testDir, ".aoslog") {
+		t.Run(filepath.Base(testFile), func(t *testing.T) {
+			data, err := ioutil.ReadFile(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inboxMessages, avmLogs, avmSends, err := inbox.LoadTestVector(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mach, err := New(arbos.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertion, _ := mach.ExecuteAssertion(100000000000, inboxMessages, 0)
+			calcLogs := assertion.ParseLogs()
+			calcSends := assertion.ParseOutMessages()
+			if len(calcLogs) != len(avmLogs) {
+				t.Fatalf("wrong log count %v, expected %v", len(calcLogs), len(avmLogs))
+			}
+			if len(calcSends) != len(avmSends) {
+				t.Fatalf("wrong send count %v, expected %v", len(calcSends), len(avmSends))
+			}
+			for i := range calcLogs {
+				if !value.Eq(calcLogs[i], avmLogs[i]) {
+					t.Error("wrong log", i)
+				}
+			}
+			for i := range calcSends {
+				if !value.Eq(calcSends[i], avmSends[i]) {
+					t.Error("wrong send", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneSharesCode(t *testing.T) {
+	mach, err := New(arbos.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := mach.Clone()
+	if mach.Hash() != clone.Hash() {
+		t.Fatal("clone has different hash")
+	}
+	stub := mach.code.addSegment()
+	if _, hash := clone.(*Machine).code.loadCodePoint(codePointRef{segment: stub.Segment}); hash != stub.Hash() {
+		t.Error("clone doesn't see new code segment")
+	}
+}
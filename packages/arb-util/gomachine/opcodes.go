@@ -0,0 +1,221 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+const (
+	ADD           value.Opcode = 0x01
+	MUL           value.Opcode = 0x02
+	SUB           value.Opcode = 0x03
+	DIV           value.Opcode = 0x04
+	SDIV          value.Opcode = 0x05
+	MOD           value.Opcode = 0x06
+	SMOD          value.Opcode = 0x07
+	ADDMOD        value.Opcode = 0x08
+	MULMOD        value.Opcode = 0x09
+	EXP           value.Opcode = 0x0a
+	SIGNEXTEND    value.Opcode = 0x0b
+	LT            value.Opcode = 0x10
+	GT            value.Opcode = 0x11
+	SLT           value.Opcode = 0x12
+	SGT           value.Opcode = 0x13
+	EQ            value.Opcode = 0x14
+	ISZERO        value.Opcode = 0x15
+	AND           value.Opcode = 0x16
+	OR            value.Opcode = 0x17
+	XOR           value.Opcode = 0x18
+	NOT           value.Opcode = 0x19
+	BYTE          value.Opcode = 0x1a
+	SHL           value.Opcode = 0x1b
+	SHR           value.Opcode = 0x1c
+	SAR           value.Opcode = 0x1d
+	HASH          value.Opcode = 0x20
+	TYPE          value.Opcode = 0x21
+	ETHHASH2      value.Opcode = 0x22
+	KECCAKF       value.Opcode = 0x23
+	SHA256F       value.Opcode = 0x24
+	POP           value.Opcode = 0x30
+	SPUSH         value.Opcode = 0x31
+	RPUSH         value.Opcode = 0x32
+	RSET          value.Opcode = 0x33
+	JUMP          value.Opcode = 0x34
+	CJUMP         value.Opcode = 0x35
+	STACKEMPTY    value.Opcode = 0x36
+	PCPUSH        value.Opcode = 0x37
+	AUXPUSH       value.Opcode = 0x38
+	AUXPOP        value.Opcode = 0x39
+	AUXSTACKEMPTY value.Opcode = 0x3a
+	NOP           value.Opcode = 0x3b
+	ERRPUSH       value.Opcode = 0x3c
+	ERRSET        value.Opcode = 0x3d
+	DUP0          value.Opcode = 0x40
+	DUP1          value.Opcode = 0x41
+	DUP2          value.Opcode = 0x42
+	SWAP1         value.Opcode = 0x43
+	SWAP2         value.Opcode = 0x44
+	TGET          value.Opcode = 0x50
+	TSET          value.Opcode = 0x51
+	TLEN          value.Opcode = 0x52
+	XGET          value.Opcode = 0x53
+	XSET          value.Opcode = 0x54
+	BREAKPOINT    value.Opcode = 0x60
+	LOG           value.Opcode = 0x61
+	SEND          value.Opcode = 0x70
+	INBOXPEEK     value.Opcode = 0x71
+	INBOX         value.Opcode = 0x72
+	ERROR         value.Opcode = 0x73
+	HALT          value.Opcode = 0x74
+	SETGAS        value.Opcode = 0x75
+	PUSHGAS       value.Opcode = 0x76
+	ERRCODEPOINT  value.Opcode = 0x77
+	PUSHINSN      value.Opcode = 0x78
+	PUSHINSNIMM   value.Opcode = 0x79
+	SIDELOAD      value.Opcode = 0x7b
+	ECRECOVER     value.Opcode = 0x80
+	ECADD         value.Opcode = 0x81
+	ECMUL         value.Opcode = 0x82
+	ECPAIRING     value.Opcode = 0x83
+	DEBUGPRINT    value.Opcode = 0x90
+)
+
+type marshalLevel int
+
+const (
+	stubLevel marshalLevel = iota
+	singleLevel
+	fullLevel
+)
+
+func childNestLevel(level marshalLevel) marshalLevel {
+	if level == fullLevel {
+		return fullLevel
+	}
+	return stubLevel
+}
+
+type opcodeInfo struct {
+	name         string
+	gasCost      uint64
+	stackPops    []marshalLevel
+	auxStackPops []marshalLevel
+}
+
+var (
+	none      = []marshalLevel{}
+	stub1     = []marshalLevel{stubLevel}
+	stub2     = []marshalLevel{stubLevel, stubLevel}
+	stub3     = []marshalLevel{stubLevel, stubLevel, stubLevel}
+	single1   = []marshalLevel{singleLevel}
+	single2   = []marshalLevel{singleLevel, singleLevel}
+	single3   = []marshalLevel{singleLevel, singleLevel, singleLevel}
+	single4   = []marshalLevel{singleLevel, singleLevel, singleLevel, singleLevel}
+	opcodeSet = map[value.Opcode]opcodeInfo{
+		ADD:        {"add", 3, single2, none},
+		MUL:        {"mul", 3, single2, none},
+		SUB:        {"sub", 3, single2, none},
+		DIV:        {"div", 4, single2, none},
+		SDIV:       {"sdiv", 7, single2, none},
+		MOD:        {"mod", 4, single2, none},
+		SMOD:       {"smod", 7, single2, none},
+		ADDMOD:     {"addmod", 4, single3, none},
+		MULMOD:     {"mulmod", 4, single3, none},
+		EXP:        {"exp", 25, single2, none},
+		SIGNEXTEND: {"signextend", 7, single2, none},
+
+		LT:     {"lt", 2, single2, none},
+		GT:     {"gt", 2, single2, none},
+		SLT:    {"slt", 2, single2, none},
+		SGT:    {"sgt", 2, single2, none},
+		EQ:     {"eq", 2, stub2, none},
+		ISZERO: {"iszero", 1, single1, none},
+		AND:    {"and", 2, single2, none},
+		OR:     {"or", 2, single2, none},
+		XOR:    {"xor", 2, single2, none},
+		NOT:    {"not", 1, single1, none},
+		BYTE:   {"byte", 4, single2, none},
+		SHL:    {"shl", 4, single2, none},
+		SHR:    {"shr", 4, single2, none},
+		SAR:    {"sar", 4, single2, none},
+
+		HASH:     {"hash", 7, stub1, none},
+		TYPE:     {"type", 3, single1, none},
+		ETHHASH2: {"ethhash2", 8, single2, none},
+		KECCAKF:  {"keccakf", 600, single1, none},
+		SHA256F:  {"sha256f", 250, single3, none},
+
+		POP:           {"pop", 1, stub1, none},
+		SPUSH:         {"spush", 1, none, none},
+		RPUSH:         {"rpush", 1, none, none},
+		RSET:          {"rset", 2, stub1, none},
+		JUMP:          {"jump", 4, stub1, none},
+		CJUMP:         {"cjump", 4, single2, none},
+		STACKEMPTY:    {"stackempty", 2, none, none},
+		PCPUSH:        {"pcpush", 1, none, none},
+		AUXPUSH:       {"auxpush", 1, stub1, none},
+		AUXPOP:        {"auxpop", 1, none, stub1},
+		AUXSTACKEMPTY: {"auxstackempty", 2, none, none},
+		NOP:           {"nop", 1, none, none},
+		ERRPUSH:       {"errpush", 1, none, none},
+		ERRSET:        {"errset", 1, single1, none},
+
+		DUP0:  {"dup0", 1, stub1, none},
+		DUP1:  {"dup1", 1, stub2, none},
+		DUP2:  {"dup2", 1, stub3, none},
+		SWAP1: {"swap1", 1, stub2, none},
+		SWAP2: {"swap2", 1, stub3, none},
+
+		TGET: {"tget", 2, single2, none},
+		TSET: {"tset", 40, []marshalLevel{singleLevel, singleLevel, stubLevel}, none},
+		TLEN: {"tlen", 2, single1, none},
+		XGET: {"xget", 3, single1, single1},
+		XSET: {"xset", 41, []marshalLevel{singleLevel, stubLevel}, single1},
+
+		BREAKPOINT: {"breakpoint", 100, none, none},
+		LOG:        {"log", 100, stub1, none},
+
+		SEND:         {"send", 100, []marshalLevel{fullLevel}, none},
+		INBOXPEEK:    {"inboxpeek", 40, single1, none},
+		INBOX:        {"inbox", 40, none, none},
+		ERROR:        {"error", 5, none, none},
+		HALT:         {"halt", 10, none, none},
+		SETGAS:       {"setgas", 0, single1, none},
+		PUSHGAS:      {"pushgas", 1, none, none},
+		ERRCODEPOINT: {"errcodepoint", 25, none, none},
+		PUSHINSN:     {"pushinsn", 25, single2, none},
+		PUSHINSNIMM:  {"pushinsnimm", 25, []marshalLevel{singleLevel, stubLevel, singleLevel}, none},
+		SIDELOAD:     {"sideload", 10, none, none},
+		DEBUGPRINT:   {"debugprint", 1, none, none},
+
+		ECRECOVER: {"ecrecover", 20000, single4, none},
+		ECADD:     {"ecadd", 3500, single4, none},
+		ECMUL:     {"ecmul", 82000, single3, none},
+		ECPAIRING: {"ecpairing", 1000, []marshalLevel{fullLevel}, none},
+	}
+)
+
+// OpcodeName returns the name of op or "unhandled opcode" if it isn't a
+// valid AVM opcode
+func OpcodeName(op value.Opcode) string {
+	info, ok := opcodeSet[op]
+	if !ok {
+		return "unhandled opcode"
+	}
+	return info.name
+}
@@ -0,0 +1,1010 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+// Errors returned by operations move the machine into the error state just
+// like the exceptions thrown by the C++ operations
+var (
+	errStackTooSmall  = errors.New("stack too small")
+	errBadPopType     = errors.New("bad pop type")
+	errIntOutOfBounds = errors.New("int out of bounds")
+	errBadTupleIndex  = errors.New("bad tuple index")
+)
+
+const sendSizeLimit = 10000
+
+var (
+	tt255   = math.BigPow(2, 255)
+	tt256m1 = new(big.Int).Sub(math.BigPow(2, 256), big.NewInt(1))
+)
+
+func assumeInt(val value.Value) (*big.Int, error) {
+	intVal, ok := val.(value.IntValue)
+	if !ok {
+		return nil, errBadPopType
+	}
+	return intVal.BigInt(), nil
+}
+
+func assumeInt64(val *big.Int) (uint64, error) {
+	if !val.IsUint64() {
+		return 0, errIntOutOfBounds
+	}
+	return val.Uint64(), nil
+}
+
+func assumeTuple(val value.Value) (*value.TupleValue, error) {
+	tup, ok := val.(*value.TupleValue)
+	if !ok {
+		return nil, errBadPopType
+	}
+	return tup, nil
+}
+
+func getElement(tup *value.TupleValue, index uint64) (value.Value, error) {
+	if index >= uint64(tup.Len()) {
+		return nil, errBadTupleIndex
+	}
+	return tup.Contents()[index], nil
+}
+
+func setElement(tup *value.TupleValue, index uint64, val value.Value) (*value.TupleValue, error) {
+	if index >= uint64(tup.Len()) {
+		return nil, errBadTupleIndex
+	}
+	contents := append([]value.Value{}, tup.Contents()...)
+	contents[index] = val
+	return value.NewTupleFromSlice(contents)
+}
+
+func toSigned(x *big.Int) *big.Int {
+	if x.Cmp(tt255) < 0 {
+		return x
+	}
+	return x.Sub(x, math.BigPow(2, 256))
+}
+
+func intValue(x *big.Int) value.IntValue {
+	return value.NewIntValue(math.U256(x))
+}
+
+func boolValue(b bool) value.IntValue {
+	if b {
+		return value.NewInt64Value(1)
+	}
+	return value.NewInt64Value(0)
+}
+
+// valuesEqual compares values the same way as the C++ value variant. Values
+// of different kinds are never equal
+func valuesEqual(a value.Value, b value.Value) bool {
+	switch a := a.(type) {
+	case value.IntValue:
+		b, ok := b.(value.IntValue)
+		return ok && a.Equal(b)
+	case *value.TupleValue:
+		b, ok := b.(*value.TupleValue)
+		return ok && a.Len() == b.Len() && a.Hash() == b.Hash()
+	case value.CodePointStub:
+		b, ok := b.(value.CodePointStub)
+		return ok && a.Hash() == b.Hash()
+	case value.HashPreImage:
+		b, ok := b.(value.HashPreImage)
+		return ok && a.Hash() == b.Hash()
+	default:
+		return false
+	}
+}
+
+func (m *Machine) incrPC() {
+	m.pc.pc--
+}
+
+// intArgs returns copies of the count integers on the top of the stack
+func (m *Machine) intArgs(count int) ([]*big.Int, error) {
+	if err := m.stack.prepForMod(count); err != nil {
+		return nil, err
+	}
+	args := make([]*big.Int, 0, count)
+	for i := 0; i < count; i++ {
+		val, _ := m.stack.get(i)
+		arg, err := assumeInt(val)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// replaceTop pops count values and pushes val in their place
+func (m *Machine) replaceTop(count int, val value.Value) {
+	m.stack.set(count-1, val)
+	for i := 1; i < count; i++ {
+		_, _ = m.stack.pop()
+	}
+}
+
+func (m *Machine) unaryOp(f func(a *big.Int) *big.Int) error {
+	args, err := m.intArgs(1)
+	if err != nil {
+		return err
+	}
+	m.replaceTop(1, intValue(f(args[0])))
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) binaryOp(f func(a, b *big.Int) *big.Int) error {
+	args, err := m.intArgs(2)
+	if err != nil {
+		return err
+	}
+	m.replaceTop(2, intValue(f(args[0], args[1])))
+	m.incrPC()
+	return nil
+}
+
+// divOp runs a division style operation which moves to the error state
+// after popping its first argument if the divisor is zero
+func (m *Machine) divOp(count int, f func(args []*big.Int) *big.Int) error {
+	args, err := m.intArgs(count)
+	if err != nil {
+		return err
+	}
+	if args[count-1].Sign() == 0 {
+		m.status = machine.ErrorStop
+		_, _ = m.stack.pop()
+		if count == 3 {
+			_, _ = m.stack.pop()
+		}
+	} else {
+		m.replaceTop(count, intValue(f(args)))
+	}
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) runOp(opcode value.Opcode) (machine.BlockReason, error) {
+	switch opcode {
+	case ADD:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.Add(a, b) })
+	case MUL:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.Mul(a, b) })
+	case SUB:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.Sub(a, b) })
+	case DIV:
+		return nil, m.divOp(2, func(args []*big.Int) *big.Int {
+			return args[0].Div(args[0], args[1])
+		})
+	case SDIV:
+		return nil, m.divOp(2, func(args []*big.Int) *big.Int {
+			return new(big.Int).Quo(toSigned(args[0]), toSigned(args[1]))
+		})
+	case MOD:
+		return nil, m.divOp(2, func(args []*big.Int) *big.Int {
+			return args[0].Mod(args[0], args[1])
+		})
+	case SMOD:
+		return nil, m.divOp(2, func(args []*big.Int) *big.Int {
+			return new(big.Int).Rem(toSigned(args[0]), toSigned(args[1]))
+		})
+	case ADDMOD:
+		return nil, m.divOp(3, func(args []*big.Int) *big.Int {
+			sum := args[0].Add(args[0], args[1])
+			return sum.Mod(sum, args[2])
+		})
+	case MULMOD:
+		return nil, m.divOp(3, func(args []*big.Int) *big.Int {
+			product := args[0].Mul(args[0], args[1])
+			return product.Mod(product, args[2])
+		})
+	case EXP:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			return a.Exp(a, b, math.BigPow(2, 256))
+		})
+	case SIGNEXTEND:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			if a.Cmp(big.NewInt(31)) >= 0 {
+				return b
+			}
+			signBit := uint(a.Uint64())*8 + 7
+			valueMask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), signBit), big.NewInt(1))
+			if b.Bit(int(signBit)) == 1 {
+				return b.Or(b, new(big.Int).Not(valueMask))
+			}
+			return b.And(b, valueMask)
+		})
+
+	case LT:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return boolValue(a.Cmp(b) < 0).BigInt() })
+	case GT:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return boolValue(a.Cmp(b) > 0).BigInt() })
+	case SLT:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			return boolValue(toSigned(a).Cmp(toSigned(b)) < 0).BigInt()
+		})
+	case SGT:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			return boolValue(toSigned(a).Cmp(toSigned(b)) > 0).BigInt()
+		})
+	case EQ:
+		if err := m.stack.prepForMod(2); err != nil {
+			return nil, err
+		}
+		a, _ := m.stack.get(0)
+		b, _ := m.stack.get(1)
+		m.replaceTop(2, boolValue(valuesEqual(a, b)))
+		m.incrPC()
+		return nil, nil
+	case ISZERO:
+		return nil, m.unaryOp(func(a *big.Int) *big.Int { return boolValue(a.Sign() == 0).BigInt() })
+	case AND:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.And(a, b) })
+	case OR:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.Or(a, b) })
+	case XOR:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int { return a.Xor(a, b) })
+	case NOT:
+		return nil, m.unaryOp(func(a *big.Int) *big.Int { return a.Xor(a, tt256m1) })
+	case BYTE:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			if a.Cmp(big.NewInt(32)) >= 0 {
+				return big.NewInt(0)
+			}
+			return big.NewInt(int64(math.PaddedBigBytes(b, 32)[a.Uint64()]))
+		})
+	case SHL:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			if a.Cmp(big.NewInt(256)) >= 0 {
+				return big.NewInt(0)
+			}
+			return b.Lsh(b, uint(a.Uint64()))
+		})
+	case SHR:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			if a.Cmp(big.NewInt(256)) >= 0 {
+				return big.NewInt(0)
+			}
+			return b.Rsh(b, uint(a.Uint64()))
+		})
+	case SAR:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			if a.Cmp(big.NewInt(256)) >= 0 {
+				if b.Cmp(tt255) >= 0 {
+					return tt256m1
+				}
+				return big.NewInt(0)
+			}
+			return toSigned(b).Rsh(b, uint(a.Uint64()))
+		})
+
+	case HASH:
+		if err := m.stack.prepForMod(1); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		hash := val.Hash()
+		m.stack.set(0, value.NewIntValue(new(big.Int).SetBytes(hash[:])))
+		m.incrPC()
+		return nil, nil
+	case TYPE:
+		if err := m.stack.prepForMod(1); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		switch val.(type) {
+		case value.IntValue:
+			m.stack.set(0, value.NewInt64Value(int64(value.TypeCodeInt)))
+		case value.CodePointStub:
+			m.stack.set(0, value.NewInt64Value(int64(value.TypeCodeCodePoint)))
+		case *value.TupleValue:
+			m.stack.set(0, value.NewInt64Value(int64(value.TypeCodeTuple)))
+		}
+		m.incrPC()
+		return nil, nil
+	case ETHHASH2:
+		return nil, m.binaryOp(func(a, b *big.Int) *big.Int {
+			return new(big.Int).SetBytes(crypto.Keccak256(math.PaddedBigBytes(a, 32), math.PaddedBigBytes(b, 32)))
+		})
+	case KECCAKF:
+		return nil, m.keccakF()
+	case SHA256F:
+		return nil, m.sha256F()
+
+	case POP:
+		if _, err := m.stack.pop(); err != nil {
+			return nil, err
+		}
+		m.incrPC()
+		return nil, nil
+	case SPUSH:
+		m.stack.push(m.staticVal)
+		m.incrPC()
+		return nil, nil
+	case RPUSH:
+		m.stack.push(m.registerVal)
+		m.incrPC()
+		return nil, nil
+	case RSET:
+		val, err := m.stack.pop()
+		if err != nil {
+			return nil, err
+		}
+		m.registerVal = val
+		m.incrPC()
+		return nil, nil
+	case JUMP:
+		if err := m.stack.prepForMod(1); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		target, ok := val.(value.CodePointStub)
+		if !ok {
+			return nil, errBadPopType
+		}
+		m.pc = codePointRef{segment: target.Segment, pc: target.PC}
+		_, _ = m.stack.pop()
+		return nil, nil
+	case CJUMP:
+		if err := m.stack.prepForMod(2); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		target, ok := val.(value.CodePointStub)
+		if !ok {
+			return nil, errBadPopType
+		}
+		condVal, _ := m.stack.get(1)
+		cond, err := assumeInt(condVal)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			m.pc = codePointRef{segment: target.Segment, pc: target.PC}
+		} else {
+			m.incrPC()
+		}
+		_, _ = m.stack.pop()
+		_, _ = m.stack.pop()
+		return nil, nil
+	case STACKEMPTY:
+		m.stack.push(boolValue(m.stack.size() == 0))
+		m.incrPC()
+		return nil, nil
+	case PCPUSH:
+		_, hash := m.code.loadCodePoint(m.pc)
+		m.stack.push(value.NewCodePointStub(m.pc.segment, m.pc.pc, hash))
+		m.incrPC()
+		return nil, nil
+	case AUXPUSH:
+		val, err := m.stack.pop()
+		if err != nil {
+			return nil, err
+		}
+		m.auxstack.push(val)
+		m.incrPC()
+		return nil, nil
+	case AUXPOP:
+		val, err := m.auxstack.pop()
+		if err != nil {
+			return nil, err
+		}
+		m.stack.push(val)
+		m.incrPC()
+		return nil, nil
+	case AUXSTACKEMPTY:
+		m.stack.push(boolValue(m.auxstack.size() == 0))
+		m.incrPC()
+		return nil, nil
+	case NOP:
+		m.incrPC()
+		return nil, nil
+	case ERRPUSH:
+		m.stack.push(m.errpc)
+		m.incrPC()
+		return nil, nil
+	case ERRSET:
+		val, err := m.stack.pop()
+		if err != nil {
+			return nil, err
+		}
+		if errpc, ok := val.(value.CodePointStub); ok {
+			m.errpc = errpc
+		} else {
+			m.status = machine.ErrorStop
+		}
+		m.incrPC()
+		return nil, nil
+
+	case DUP0, DUP1, DUP2:
+		val, err := m.stack.get(int(opcode - DUP0))
+		if err != nil {
+			return nil, err
+		}
+		m.stack.push(val)
+		m.incrPC()
+		return nil, nil
+	case SWAP1, SWAP2:
+		depth := int(opcode-SWAP1) + 1
+		if err := m.stack.prepForMod(depth + 1); err != nil {
+			return nil, err
+		}
+		top, _ := m.stack.get(0)
+		other, _ := m.stack.get(depth)
+		m.stack.set(0, other)
+		m.stack.set(depth, top)
+		m.incrPC()
+		return nil, nil
+
+	case TGET:
+		return nil, m.tget()
+	case TSET:
+		return nil, m.tset()
+	case TLEN:
+		if err := m.stack.prepForMod(1); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		tup, err := assumeTuple(val)
+		if err != nil {
+			return nil, err
+		}
+		m.stack.set(0, value.NewInt64Value(tup.Len()))
+		m.incrPC()
+		return nil, nil
+	case XGET:
+		return nil, m.xget()
+	case XSET:
+		return nil, m.xset()
+
+	case BREAKPOINT:
+		m.incrPC()
+		return machine.BreakpointBlocked{}, nil
+	case LOG:
+		val, err := m.stack.pop()
+		if err != nil {
+			return nil, err
+		}
+		m.context.logs = append(m.context.logs, val)
+		m.incrPC()
+		return nil, nil
+	case DEBUGPRINT:
+		val, err := m.stack.pop()
+		if err != nil {
+			return nil, err
+		}
+		m.context.debugPrints = append(m.context.debugPrints, val)
+		m.incrPC()
+		return nil, nil
+
+	case SEND:
+		if err := m.stack.prepForMod(1); err != nil {
+			return nil, err
+		}
+		val, _ := m.stack.get(0)
+		if val.Size() > sendSizeLimit {
+			// The send fails without advancing so it will be retried
+			log.Println("Send failure: over size limit")
+			return nil, nil
+		}
+		m.context.outMessages = append(m.context.outMessages, val)
+		_, _ = m.stack.pop()
+		m.incrPC()
+		return nil, nil
+	case INBOXPEEK:
+		return m.inboxPeek()
+	case INBOX:
+		return m.inbox(), nil
+	case ERROR:
+		m.status = machine.ErrorStop
+		return nil, nil
+	case HALT:
+		m.status = machine.Halt
+		return nil, nil
+	case SETGAS:
+		args, err := m.intArgs(1)
+		if err != nil {
+			return nil, err
+		}
+		m.arbGasRemaining = args[0]
+		_, _ = m.stack.pop()
+		m.incrPC()
+		return nil, nil
+	case PUSHGAS:
+		m.stack.push(value.NewIntValue(m.arbGasRemaining))
+		m.incrPC()
+		return nil, nil
+	case ERRCODEPOINT:
+		m.stack.push(m.code.addSegment())
+		m.incrPC()
+		return nil, nil
+	case PUSHINSN:
+		return nil, m.pushInsn(false)
+	case PUSHINSNIMM:
+		return nil, m.pushInsn(true)
+	case SIDELOAD:
+		m.sideload()
+		return nil, nil
+
+	case ECRECOVER:
+		return nil, m.ecRecover()
+	case ECADD:
+		return nil, m.ecAdd()
+	case ECMUL:
+		return nil, m.ecMul()
+	case ECPAIRING:
+		return nil, m.ecPairing()
+	default:
+		m.status = machine.ErrorStop
+		return nil, nil
+	}
+}
+
+func (m *Machine) keccakF() error {
+	if err := m.stack.prepForMod(1); err != nil {
+		return err
+	}
+	val, _ := m.stack.get(0)
+	tup, err := assumeTuple(val)
+	if err != nil {
+		return err
+	}
+	if tup.Len() != 7 {
+		return errBadPopType
+	}
+	// Each of the first six elements holds four little endian lanes and the
+	// last element holds the final lane
+	var state [25]uint64
+	for i, item := range tup.Contents() {
+		intVal, err := assumeInt(item)
+		if err != nil {
+			return err
+		}
+		data := math.PaddedBigBytes(intVal, 32)
+		if i == 6 {
+			state[24] = binary.BigEndian.Uint64(data[24:])
+			continue
+		}
+		for j := 0; j < 4; j++ {
+			state[i*4+j] = binary.BigEndian.Uint64(data[32-(j+1)*8:])
+		}
+	}
+
+	keccakF1600(&state)
+
+	contents := make([]value.Value, 0, 7)
+	for i := 0; i < 6; i++ {
+		data := make([]byte, 32)
+		for j := 0; j < 4; j++ {
+			binary.BigEndian.PutUint64(data[32-(j+1)*8:], state[i*4+j])
+		}
+		contents = append(contents, value.NewIntValue(new(big.Int).SetBytes(data)))
+	}
+	contents = append(contents, value.NewIntValue(new(big.Int).SetUint64(state[24])))
+	newTup, err := value.NewTupleFromSlice(contents)
+	if err != nil {
+		return err
+	}
+	m.stack.set(0, newTup)
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) sha256F() error {
+	args, err := m.intArgs(3)
+	if err != nil {
+		return err
+	}
+	var digest [8]uint32
+	digestData := math.PaddedBigBytes(args[0], 32)
+	for i := range digest {
+		digest[i] = binary.BigEndian.Uint32(digestData[i*4:])
+	}
+	var block [64]byte
+	copy(block[:32], math.PaddedBigBytes(args[1], 32))
+	copy(block[32:], math.PaddedBigBytes(args[2], 32))
+
+	sha256Block(&digest, &block)
+
+	for i, word := range digest {
+		binary.BigEndian.PutUint32(digestData[i*4:], word)
+	}
+	m.replaceTop(3, value.NewIntValue(new(big.Int).SetBytes(digestData)))
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) tget() error {
+	if err := m.stack.prepForMod(2); err != nil {
+		return err
+	}
+	indexVal, _ := m.stack.get(0)
+	bigIndex, err := assumeInt(indexVal)
+	if err != nil {
+		return err
+	}
+	index, err := assumeInt64(bigIndex)
+	if err != nil {
+		return err
+	}
+	tupVal, _ := m.stack.get(1)
+	tup, err := assumeTuple(tupVal)
+	if err != nil {
+		return err
+	}
+	val, err := getElement(tup, index)
+	if err != nil {
+		return err
+	}
+	m.replaceTop(2, val)
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) tset() error {
+	if err := m.stack.prepForMod(3); err != nil {
+		return err
+	}
+	indexVal, _ := m.stack.get(0)
+	bigIndex, err := assumeInt(indexVal)
+	if err != nil {
+		return err
+	}
+	index, err := assumeInt64(bigIndex)
+	if err != nil {
+		return err
+	}
+	tupVal, _ := m.stack.get(1)
+	tup, err := assumeTuple(tupVal)
+	if err != nil {
+		return err
+	}
+	val, _ := m.stack.get(2)
+	newTup, err := setElement(tup, index, val)
+	if err != nil {
+		return err
+	}
+	m.replaceTop(3, newTup)
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) xget() error {
+	if err := m.stack.prepForMod(1); err != nil {
+		return err
+	}
+	indexVal, _ := m.stack.get(0)
+	bigIndex, err := assumeInt(indexVal)
+	if err != nil {
+		return err
+	}
+	index, err := assumeInt64(bigIndex)
+	if err != nil {
+		return err
+	}
+	tupVal, err := m.auxstack.get(0)
+	if err != nil {
+		return err
+	}
+	tup, err := assumeTuple(tupVal)
+	if err != nil {
+		return err
+	}
+	val, err := getElement(tup, index)
+	if err != nil {
+		return err
+	}
+	m.stack.set(0, val)
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) xset() error {
+	if err := m.stack.prepForMod(2); err != nil {
+		return err
+	}
+	if err := m.auxstack.prepForMod(1); err != nil {
+		return err
+	}
+	indexVal, _ := m.stack.get(0)
+	bigIndex, err := assumeInt(indexVal)
+	if err != nil {
+		return err
+	}
+	index, err := assumeInt64(bigIndex)
+	if err != nil {
+		return err
+	}
+	tupVal, _ := m.auxstack.get(0)
+	tup, err := assumeTuple(tupVal)
+	if err != nil {
+		return err
+	}
+	val, _ := m.stack.get(1)
+	newTup, err := setElement(tup, index, val)
+	if err != nil {
+		return err
+	}
+	m.auxstack.set(0, newTup)
+	_, _ = m.stack.pop()
+	_, _ = m.stack.pop()
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) inboxPeek() (machine.BlockReason, error) {
+	if err := m.stack.prepForMod(1); err != nil {
+		return nil, err
+	}
+	val, _ := m.stack.get(0)
+	hasStagedMessage := m.stagedMessage.Len() != 0
+	if !hasStagedMessage && m.context.inboxEmpty() {
+		if m.context.fakeInboxPeekValue == nil {
+			return machine.InboxBlocked{}, nil
+		}
+
+		// When fakeInboxPeekValue is set we're in callserver mode. Use that
+		// value as the message value
+		m.stack.set(0, boolValue(valuesEqual(val, m.context.fakeInboxPeekValue)))
+		m.incrPC()
+		return nil, nil
+	}
+	if !hasStagedMessage {
+		m.stagedMessage = m.context.popInbox()
+	}
+	blockNum, err := getElement(m.stagedMessage, 1)
+	if err != nil {
+		return nil, err
+	}
+	m.stack.set(0, boolValue(valuesEqual(val, blockNum)))
+	m.incrPC()
+	return nil, nil
+}
+
+func (m *Machine) inbox() machine.BlockReason {
+	hasStagedMessage := m.stagedMessage.Len() != 0
+	if !hasStagedMessage && m.context.inboxEmpty() {
+		return machine.InboxBlocked{}
+	}
+	if hasStagedMessage {
+		m.stack.push(m.stagedMessage)
+		m.stagedMessage = value.NewEmptyTuple()
+	} else {
+		m.stack.push(m.context.popInbox())
+	}
+	m.incrPC()
+	return nil
+}
+
+// sideload pushes the sideloaded value. In a sideloaded assertion that has
+// already run, the C++ machine ignores the resulting block and leaves the
+// machine on the sideload instruction, which is replicated here
+func (m *Machine) sideload() {
+	if m.context.sideloadValue != nil && m.context.sideloadValue.Len() != 0 {
+		m.stack.push(m.context.sideloadValue)
+		m.context.sideloadValue = value.NewEmptyTuple()
+	} else {
+		if m.context.numSteps != 0 && m.context.blockingSideload {
+			return
+		}
+		m.stack.push(value.NewEmptyTuple())
+	}
+	m.incrPC()
+}
+
+func (m *Machine) pushInsn(immediate bool) error {
+	targetIndex := 1
+	if immediate {
+		targetIndex = 2
+	}
+	if err := m.stack.prepForMod(targetIndex + 1); err != nil {
+		return err
+	}
+	targetVal, _ := m.stack.get(targetIndex)
+	target, ok := targetVal.(value.CodePointStub)
+	if !ok {
+		m.status = machine.ErrorStop
+		return nil
+	}
+	opVal, _ := m.stack.get(0)
+	opInt, err := assumeInt(opVal)
+	if err != nil {
+		return err
+	}
+	opcode := value.Opcode(opInt.Uint64())
+	var op value.Operation = value.BasicOperation{Op: opcode}
+	if immediate {
+		imm, _ := m.stack.get(1)
+		op = value.ImmediateOperation{Op: opcode, Val: imm}
+	}
+	stub := m.code.addOperation(codePointRef{segment: target.Segment, pc: target.PC}, op)
+	m.replaceTop(targetIndex+1, stub)
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) ecRecover() error {
+	if err := m.stack.prepForMod(4); err != nil {
+		return err
+	}
+	result, err := m.parseSignature()
+	if err != nil {
+		return err
+	}
+	m.replaceTop(4, value.NewIntValue(result))
+	m.incrPC()
+	return nil
+}
+
+// parseSignature checks its arguments in the same order as the C++ machine
+// since an invalid recovery id returns 0 even if the other arguments aren't
+// integers
+func (m *Machine) parseSignature() (*big.Int, error) {
+	recoveryVal, _ := m.stack.get(2)
+	recovery, err := assumeInt(recoveryVal)
+	if err != nil {
+		return nil, err
+	}
+	if recovery.Cmp(big.NewInt(1)) > 0 {
+		return big.NewInt(0), nil
+	}
+	rVal, _ := m.stack.get(0)
+	r, err := assumeInt(rVal)
+	if err != nil {
+		return nil, err
+	}
+	sVal, _ := m.stack.get(1)
+	s, err := assumeInt(sVal)
+	if err != nil {
+		return nil, err
+	}
+	messageVal, _ := m.stack.get(3)
+	message, err := assumeInt(messageVal)
+	if err != nil {
+		return nil, err
+	}
+	return ecrecover(r, s, recovery, message), nil
+}
+
+func (m *Machine) ecAdd() error {
+	args, err := m.intArgs(4)
+	if err != nil {
+		return err
+	}
+	ans, err := ecadd(g1Point{args[0], args[1]}, g1Point{args[2], args[3]})
+	if err != nil {
+		m.status = machine.ErrorStop
+		return nil
+	}
+	m.stack.set(2, value.NewIntValue(ans.x))
+	m.stack.set(3, value.NewIntValue(ans.y))
+	_, _ = m.stack.pop()
+	_, _ = m.stack.pop()
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) ecMul() error {
+	args, err := m.intArgs(3)
+	if err != nil {
+		return err
+	}
+	ans, err := ecmul(g1Point{args[0], args[1]}, args[2])
+	if err != nil {
+		m.status = machine.ErrorStop
+		return nil
+	}
+	m.stack.set(1, value.NewIntValue(ans.x))
+	m.stack.set(2, value.NewIntValue(ans.y))
+	_, _ = m.stack.pop()
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) ecPairing() error {
+	if err := m.stack.prepForMod(1); err != nil {
+		return err
+	}
+	listVal, _ := m.stack.get(0)
+	list, err := assumeTuple(listVal)
+	if err != nil {
+		return err
+	}
+	var points []pairingInput
+	for i := 0; i < maxECPairingPoints; i++ {
+		if list.Len() == 0 {
+			break
+		}
+		if list.Len() != 2 {
+			return errBadPopType
+		}
+		contents := list.Contents()
+		next, err := assumeTuple(contents[0])
+		if err != nil {
+			return err
+		}
+		list, err = assumeTuple(contents[1])
+		if err != nil {
+			return err
+		}
+		if next.Len() != 6 {
+			return errBadPopType
+		}
+		coords := make([]*big.Int, 0, 6)
+		for _, item := range next.Contents() {
+			coord, err := assumeInt(item)
+			if err != nil {
+				return err
+			}
+			coords = append(coords, coord)
+		}
+		points = append(points, pairingInput{
+			g1: g1Point{coords[0], coords[1]},
+			g2: g2Point{coords[2], coords[3], coords[4], coords[5]},
+		})
+	}
+	if list.Len() != 0 {
+		return errBadPopType
+	}
+
+	res, err := ecpairing(points)
+	if err != nil {
+		m.status = machine.ErrorStop
+		return nil
+	}
+	m.stack.set(0, boolValue(res))
+	m.incrPC()
+	return nil
+}
+
+func (m *Machine) ecPairingVariableGasCost() uint64 {
+	// The fixed cost of the the pairing opcode is applied elsewhere
+	gasCost := uint64(0)
+	val, err := m.stack.get(0)
+	if err != nil {
+		return gasCost
+	}
+	list, ok := val.(*value.TupleValue)
+	if !ok {
+		return gasCost
+	}
+	for i := 0; i < maxECPairingPoints; i++ {
+		if list.Len() != 2 {
+			break
+		}
+		list, ok = list.Contents()[1].(*value.TupleValue)
+		if !ok {
+			break
+		}
+		gasCost += ecPairingGasCost
+	}
+	return gasCost
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gomachine
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func marshalOperationForProof(op value.Operation, level marshalLevel, buf *bytes.Buffer, c *code) error {
+	switch op := op.(type) {
+	case value.ImmediateOperation:
+		buf.WriteByte(1)
+		buf.WriteByte(byte(op.Op))
+		return marshalForProof(op.Val, level, buf, c)
+	case value.BasicOperation:
+		buf.WriteByte(0)
+		buf.WriteByte(byte(op.Op))
+		return nil
+	default:
+		return fmt.Errorf("bad operation type: %T", op)
+	}
+}
+
+// marshalForProof serializes val for the one step proof. Tuples below the
+// requested level are replaced by their hash pre-image and code point stubs
+// are replaced by the code point they reference
+func marshalForProof(val value.Value, level marshalLevel, buf *bytes.Buffer, c *code) error {
+	switch val := val.(type) {
+	case value.IntValue:
+		buf.WriteByte(value.TypeCodeInt)
+		return val.Marshal(buf)
+	case value.HashPreImage:
+		buf.WriteByte(value.TypeCodeHashPreImage)
+		return val.Marshal(buf)
+	case *value.TupleValue:
+		if level == stubLevel {
+			buf.WriteByte(value.TypeCodeHashPreImage)
+			return val.GetPreImage().Marshal(buf)
+		}
+		buf.WriteByte(val.TypeCode())
+		for _, item := range val.Contents() {
+			if err := marshalForProof(item, childNestLevel(level), buf, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case value.CodePointStub:
+		cp, _ := c.loadCodePoint(codePointRef{segment: val.Segment, pc: val.PC})
+		buf.WriteByte(value.TypeCodeCodePoint)
+		if err := marshalOperationForProof(cp.Op, childNestLevel(level), buf, c); err != nil {
+			return err
+		}
+		buf.Write(cp.NextHash[:])
+		return nil
+	default:
+		return fmt.Errorf("can't marshal %T for proof", val)
+	}
+}
+
+func (m *Machine) marshalState(
+	buf *bytes.Buffer,
+	nextCodePointHash common.Hash,
+	stackPreImage value.HashPreImage,
+	auxStackPreImage value.HashPreImage,
+) error {
+	buf.Write(nextCodePointHash[:])
+	if err := stackPreImage.Marshal(buf); err != nil {
+		return err
+	}
+	if err := auxStackPreImage.Marshal(buf); err != nil {
+		return err
+	}
+	if err := marshalForProof(m.registerVal, stubLevel, buf, m.code); err != nil {
+		return err
+	}
+	if err := marshalForProof(m.staticVal, stubLevel, buf, m.code); err != nil {
+		return err
+	}
+	if err := value.NewIntValue(m.arbGasRemaining).Marshal(buf); err != nil {
+		return err
+	}
+	errpcHash := m.errpc.Hash()
+	buf.Write(errpcHash[:])
+	return marshalForProof(m.stagedMessage, singleLevel, buf, m.code)
+}
+
+func (m *Machine) MarshalState() ([]byte, error) {
+	var buf bytes.Buffer
+	_, hash := m.code.loadCodePoint(m.pc)
+	err := m.marshalState(&buf, hash, m.stack.getHashPreImage(), m.auxstack.getHashPreImage())
+	return buf.Bytes(), err
+}
+
+func (m *Machine) MarshalForProof() ([]byte, error) {
+	currentInstruction, _ := m.code.loadCodePoint(m.pc)
+	opcode := currentInstruction.Op.GetOp()
+	info := opcodeSet[opcode]
+	stackPops := info.stackPops
+	auxStackPops := info.auxStackPops
+
+	stackPopCount := len(stackPops)
+	immediateLevel := stubLevel
+	imm, hasImmediate := currentInstruction.Op.(value.ImmediateOperation)
+	if hasImmediate {
+		if len(stackPops) == 0 {
+			stackPopCount++
+		} else {
+			immediateLevel = stackPops[0]
+			stackPops = stackPops[1:]
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(byte(stackPopCount))
+	buf.WriteByte(byte(len(auxStackPops)))
+
+	stackPreImage, stackProof, err := m.stack.marshalForProof(stackPops, m.code)
+	if err != nil {
+		return nil, err
+	}
+	auxStackPreImage, auxStackProof, err := m.auxstack.marshalForProof(auxStackPops, m.code)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(stackProof)
+	if hasImmediate {
+		if err := marshalForProof(imm.Val, immediateLevel, &buf, m.code); err != nil {
+			return nil, err
+		}
+	}
+	buf.Write(auxStackProof)
+	if err := m.marshalState(&buf, currentInstruction.NextHash, stackPreImage, auxStackPreImage); err != nil {
+		return nil, err
+	}
+	if hasImmediate {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	buf.WriteByte(byte(opcode))
+	return buf.Bytes(), nil
+}
@@ -48,6 +48,7 @@ func bytesToValues(val []byte) []value.Value {
 
 var errInt = errors.New("expected int value")
 var errTupleSize2 = errors.New("expected 2-tuple value")
+var errByteStackLength = errors.New("byte stack length is longer than its data")
 
 func StackValueToList(val value.Value) ([]value.Value, error) {
 	tupVal, ok := val.(*value.TupleValue)
@@ -124,9 +125,28 @@ func ByteStackToHex(val value.Value) ([]byte, error) {
 	for _, chunk := range byteChunks {
 		buf.Write(chunk[:])
 	}
+	if intLength > uint64(buf.Len()) {
+		return nil, errByteStackLength
+	}
 	return buf.Bytes()[:intLength], nil
 }
 
+// DecodeByteStack reads the next value from d as a byte stack. Well formed
+// byte stacks are copied straight out of the marshalled data, and anything
+// else is decoded into a value and read with ByteStackToHex, so it accepts the
+// same values as ByteStackToHex does
+func DecodeByteStack(d *value.Decoder) ([]byte, error) {
+	data, err := d.DecodeByteStack()
+	if err == nil {
+		return data, nil
+	}
+	val, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return ByteStackToHex(val)
+}
+
 func BytesToByteStack(val []byte) *value.TupleValue {
 	chunks := bytesToValues(val)
 	ret := ListToStackValue(chunks)
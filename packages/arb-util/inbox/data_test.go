@@ -18,6 +18,7 @@ package inbox
 
 import (
 	"bytes"
+	"math/big"
 	"math/rand"
 	"testing"
 
@@ -108,3 +109,112 @@ func TestByteStackToHexFailures(t *testing.T) {
 		t.Error("should fail when second value contains non ints in the stack")
 	}
 }
+
+func marshalValue(t testing.TB, val value.Value) []byte {
+	var buf bytes.Buffer
+	if err := value.MarshalValue(val, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeByteStack(t *testing.T) {
+	for _, length := range []int{0, 1, 31, 32, 33, 100, 1000} {
+		data := make([]byte, length)
+		rand.Read(data)
+		d := value.NewDecoder(marshalValue(t, BytesToByteStack(data)))
+		data2, err := d.DecodeByteStack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, data2) {
+			t.Error("data changed in conversion with length", length)
+		}
+		if d.More() {
+			t.Error("byte stack not fully consumed with length", length)
+		}
+	}
+}
+
+func TestDecodeByteStackFailures(t *testing.T) {
+	intVal := value.NewInt64Value(0)
+	listVal := ListToStackValue([]value.Value{value.NewEmptyTuple()})
+	// Static slices correct size, so errors can be ignored
+	tooLong, _ := value.NewTupleFromSlice([]value.Value{value.NewInt64Value(33), ListToStackValue([]value.Value{intVal})})
+	nonInt, _ := value.NewTupleFromSlice([]value.Value{intVal, listVal})
+	for _, val := range []value.Value{intVal, tooLong, nonInt} {
+		d := value.NewDecoder(marshalValue(t, val))
+		if _, err := d.DecodeByteStack(); err == nil {
+			t.Error("decoded invalid byte stack", val)
+		}
+		if _, err := d.Decode(); err != nil {
+			t.Error("failed to decode value after byte stack failure", err)
+		}
+	}
+
+	for _, val := range []value.Value{intVal, tooLong, nonInt} {
+		if _, err := ByteStackToHex(val); err == nil {
+			t.Error("converted invalid byte stack", val)
+		}
+		d := value.NewDecoder(marshalValue(t, val))
+		if _, err := DecodeByteStack(d); err == nil {
+			t.Error("decoded invalid byte stack", val)
+		}
+	}
+}
+
+func TestDecodeByteStackFallback(t *testing.T) {
+	data := []byte{1, 2, 3}
+	// A length with high bits set isn't a well formed byte stack, but
+	// ByteStackToHex only reads the low bits of it
+	length := new(big.Int).Lsh(big.NewInt(1), 100)
+	length.Add(length, big.NewInt(int64(len(data))))
+	// Static slice correct size, so error can be ignored
+	val, _ := value.NewTupleFromSlice([]value.Value{value.NewIntValue(length), ListToStackValue(bytesToValues(data))})
+	d := value.NewDecoder(marshalValue(t, value.NewTuple2(val, val)))
+	if _, err := d.DecodeTupleHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data2, err := DecodeByteStack(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, data2) {
+			t.Error("data changed in fallback conversion")
+		}
+	}
+	if d.More() {
+		t.Error("byte stacks not fully consumed")
+	}
+}
+
+func BenchmarkByteStackToHex(b *testing.B) {
+	data := make([]byte, 10000)
+	rand.Read(data)
+	encoded := marshalValue(b, BytesToByteStack(data))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val, err := value.UnmarshalValue(bytes.NewReader(encoded))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ByteStackToHex(val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeByteStack(b *testing.B) {
+	data := make([]byte, 10000)
+	rand.Read(data)
+	encoded := marshalValue(b, BytesToByteStack(data))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeByteStack(value.NewDecoder(encoded)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
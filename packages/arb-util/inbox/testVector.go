@@ -2,15 +2,10 @@ package inbox
 
 import (
 	"encoding/json"
-	"errors"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
-	"math/big"
 )
 
-type JSONValue struct {
-	Tuple *[]JSONValue `json:"Tuple,omitempty"`
-	Int   *string      `json:"Int,omitempty"`
-}
+type JSONValue = value.JSONValue
 
 type TestVector struct {
 	Version int         `json:"format_version"`
@@ -22,7 +17,7 @@ type TestVector struct {
 func TestVectorJSON(inbox []InboxMessage, logs []value.Value, sends []value.Value) ([]byte, error) {
 	jsonInbox := make([]JSONValue, 0, len(inbox))
 	for _, msg := range inbox {
-		val, err := valueToJSON(msg.AsValue())
+		val, err := value.NewJSONValue(msg.AsValue())
 		if err != nil {
 			return nil, err
 		}
@@ -30,7 +25,7 @@ func TestVectorJSON(inbox []InboxMessage, logs []value.Value, sends []value.Valu
 	}
 	jsonLogs := make([]JSONValue, 0, len(logs))
 	for _, avmLog := range logs {
-		val, err := valueToJSON(avmLog)
+		val, err := value.NewJSONValue(avmLog)
 		if err != nil {
 			return nil, err
 		}
@@ -38,7 +33,7 @@ func TestVectorJSON(inbox []InboxMessage, logs []value.Value, sends []value.Valu
 	}
 	jsonSends := make([]JSONValue, 0, len(sends))
 	for _, avmSend := range sends {
-		val, err := valueToJSON(avmSend)
+		val, err := value.NewJSONValue(avmSend)
 		if err != nil {
 			return nil, err
 		}
@@ -60,7 +55,7 @@ func LoadTestVector(data []byte) ([]InboxMessage, []value.Value, []value.Value,
 	}
 	inboxMessages := make([]InboxMessage, 0, len(testVector.Inbox))
 	for _, msg := range testVector.Inbox {
-		val, err := jsonToValue(msg)
+		val, err := msg.Value()
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -72,7 +67,7 @@ func LoadTestVector(data []byte) ([]InboxMessage, []value.Value, []value.Value,
 	}
 	avmLogs := make([]value.Value, 0, len(testVector.Logs))
 	for _, avmLog := range testVector.Logs {
-		val, err := jsonToValue(avmLog)
+		val, err := avmLog.Value()
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -80,7 +75,7 @@ func LoadTestVector(data []byte) ([]InboxMessage, []value.Value, []value.Value,
 	}
 	avmSends := make([]value.Value, 0, len(testVector.Sends))
 	for _, avmSend := range testVector.Sends {
-		val, err := jsonToValue(avmSend)
+		val, err := avmSend.Value()
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -88,45 +83,3 @@ func LoadTestVector(data []byte) ([]InboxMessage, []value.Value, []value.Value,
 	}
 	return inboxMessages, avmLogs, avmSends, nil
 }
-
-func valueToJSON(val value.Value) (JSONValue, error) {
-	switch val := val.(type) {
-	case value.IntValue:
-		intString := val.BigInt().Text(16)
-		return JSONValue{Int: &intString}, nil
-	case *value.TupleValue:
-		vals := make([]JSONValue, 0)
-		for _, subVal := range val.Contents() {
-			jsonSubVal, err := valueToJSON(subVal)
-			if err != nil {
-				return JSONValue{}, err
-			}
-			vals = append(vals, jsonSubVal)
-		}
-		return JSONValue{Tuple: &vals}, nil
-	default:
-		return JSONValue{}, errors.New("unsupported type")
-	}
-}
-
-func jsonToValue(val JSONValue) (value.Value, error) {
-	if val.Int != nil {
-		intVal, ok := new(big.Int).SetString(*val.Int, 16)
-		if !ok {
-			return nil, errors.New("invalid int value")
-		}
-		return value.NewIntValue(intVal), nil
-	} else if val.Tuple != nil {
-		vals := make([]value.Value, 0)
-		for _, jsonSubVal := range *val.Tuple {
-			subVal, err := jsonToValue(jsonSubVal)
-			if err != nil {
-				return nil, err
-			}
-			vals = append(vals, subVal)
-		}
-		return value.NewTupleFromSlice(vals)
-	} else {
-		return nil, errors.New("unsupported json value")
-	}
-}
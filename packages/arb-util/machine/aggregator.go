@@ -18,9 +18,32 @@ package machine
 
 import (
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 )
 
+type AggregatorStore interface {
+	LogCount() (uint64, error)
+	SaveLog(val value.Value) error
+	GetLog(index uint64) (value.Value, error)
+	MessageCount() (uint64, error)
+	SaveMessage(val value.Value) error
+	GetMessage(index uint64) (value.Value, error)
+	LatestBlock() (*common.BlockId, error)
+	SaveBlock(header *types.Header, logIndex uint64) error
+	SaveEmptyBlock(header *types.Header) error
+	GetBlock(height uint64) (*BlockInfo, error)
+	Reorg(height uint64, messageCount uint64, logCount uint64) error
+	GetPossibleRequestInfo(requestId common.Hash) *uint64
+	SaveRequest(requestId common.Hash, logIndex uint64) error
+	GetPossibleTxHashInfo(txHash common.Hash) *uint64
+	SaveTxHash(txHash common.Hash, logIndex uint64) error
+	GetPossibleMessageLog(messageIndex uint64) *uint64
+	SaveMessageLog(messageIndex uint64, logIndex uint64) error
+	GetPossibleBlock(blockHash common.Hash) *uint64
+	SaveBlockHash(blockHash common.Hash, blockHeight uint64) error
+}
+
 type BlockInfo struct {
 	BlockLog value.Value
 	Header   *types.Header
@@ -35,6 +35,7 @@ type CheckpointStorage interface {
 	SaveData(key []byte, serializedValue []byte) bool
 	GetData(key []byte) ([]byte, error)
 	DeleteData(key []byte) bool
+	UnreferencedRecords(machineHashes []common.Hash, valueHashes []common.Hash) ([]common.Hash, []common.Hash)
 }
 
 type ValueNotFoundError struct {
@@ -93,10 +93,10 @@ func (x *ExecutionAssertion) ParseLogs() []value.Value {
 }
 
 func BytesArrayToVals(data []byte, valCount uint64) []value.Value {
-	rd := bytes.NewReader(data)
+	d := value.NewDecoder(data)
 	vals := make([]value.Value, 0, valCount)
 	for i := uint64(0); i < valCount; i++ {
-		val, err := value.UnmarshalValue(rd)
+		val, err := d.Decode()
 		if err != nil {
 			panic(err)
 		}
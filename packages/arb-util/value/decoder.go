@@ -0,0 +1,368 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package value
+
+import (
+	"encoding/binary"
+	"io"
+	"math/big"
+	"math/bits"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+// DefaultMaxDecodeDepth bounds how deeply tuples may be nested in a decoded
+// value. A byte stack nests one tuple per 32 bytes, so this allows byte
+// stacks of up to 32MB
+const DefaultMaxDecodeDepth = 1 << 20
+
+// Tuples and ints are allocated in blocks of this many at a time
+const decodeSlabSize = 64
+
+const intWordCount = 256 / bits.UintSize
+
+const byteStackChunkLength = 1 + 1 + 32
+
+var errDecodeDepth = UnmarshalError{"Unmarshal: value nested too deeply"}
+var errByteStackFormat = UnmarshalError{"Unmarshal: value isn't a byte stack"}
+var errTupleFormat = UnmarshalError{"Unmarshal: value isn't a tuple"}
+
+// decodeFrame is a tuple whose contents are still being decoded
+type decodeFrame struct {
+	tup    *TupleValue
+	filled int8
+}
+
+// Decoder reads a stream of values marshalled by MarshalValue from a byte
+// slice. Unlike UnmarshalValue it doesn't recurse over tuples, so nesting is
+// limited only by the configured maximum depth, and it allocates tuples and
+// ints in blocks rather than one at a time. Tuple hashes are computed lazily
+// when first requested.
+//
+// Decoded values never refer to the decoder's input, but values decoded
+// together may share allocations, so holding on to a single small value keeps
+// its whole block alive
+type Decoder struct {
+	data     []byte
+	pos      int
+	maxDepth int
+	frames   []decodeFrame
+	tuples   []TupleValue
+	ints     []big.Int
+	words    []big.Word
+}
+
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data, maxDepth: DefaultMaxDecodeDepth}
+}
+
+func (d *Decoder) SetMaxDepth(maxDepth int) {
+	d.maxDepth = maxDepth
+}
+
+// More reports whether there is input left to decode
+func (d *Decoder) More() bool {
+	return d.pos < len(d.data)
+}
+
+// Offset returns the number of bytes consumed so far
+func (d *Decoder) Offset() int {
+	return d.pos
+}
+
+// Decode reads the next value. It returns io.EOF if no input remains
+func (d *Decoder) Decode() (Value, error) {
+	if !d.More() {
+		return nil, io.EOF
+	}
+	start := d.pos
+	val, err := d.decode(0)
+	if err != nil {
+		d.pos = start
+		d.frames = d.frames[:0]
+		return nil, err
+	}
+	return val, nil
+}
+
+func (d *Decoder) decode(depth int) (Value, error) {
+	base := len(d.frames)
+	for {
+		tipe, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		var val Value
+		switch {
+		case tipe > TypeCodeTuple && tipe <= TypeCodeTuple+MaxTupleSize:
+			if depth+len(d.frames)-base >= d.maxDepth {
+				return nil, errDecodeDepth
+			}
+			d.frames = append(d.frames, decodeFrame{tup: d.newTuple(int8(tipe - TypeCodeTuple))})
+			continue
+		case tipe == TypeCodeTuple:
+			val = NewEmptyTuple()
+		case tipe == TypeCodeInt:
+			val, err = d.readInt()
+		case tipe == TypeCodeCodePoint:
+			val, err = d.readCodePoint(depth + len(d.frames) - base + 1)
+		case tipe == TypeCodeHashPreImage:
+			val, err = d.readHashPreImage()
+		case tipe == TypeCodeCodePointStub:
+			val, err = d.readCodePointStub()
+		default:
+			return nil, UnmarshalError{"Unmarshal: invalid value type"}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Add the value to its tuple, completing every tuple that it fills
+		for {
+			if len(d.frames) == base {
+				return val, nil
+			}
+			frame := &d.frames[len(d.frames)-1]
+			frame.tup.contentsArr[frame.filled] = val
+			frame.filled++
+			if frame.filled < frame.tup.itemCount {
+				break
+			}
+			frame.tup.size = frame.tup.internalSize()
+			val = frame.tup
+			d.frames = d.frames[:len(d.frames)-1]
+		}
+	}
+}
+
+// newTuple returns an unhashed tuple of the given size whose contents and
+// size are filled in as they're decoded
+func (d *Decoder) newTuple(itemCount int8) *TupleValue {
+	if len(d.tuples) == 0 {
+		d.tuples = make([]TupleValue, decodeSlabSize)
+	}
+	tup := &d.tuples[0]
+	d.tuples = d.tuples[1:]
+	tup.itemCount = itemCount
+	tup.deferredHashing = true
+	return tup
+}
+
+func (d *Decoder) next(n int) ([]byte, error) {
+	if len(d.data)-d.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	ret := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return ret, nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	data, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+func (d *Decoder) readHash() (common.Hash, error) {
+	var hash common.Hash
+	data, err := d.next(32)
+	if err != nil {
+		return hash, err
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
+
+// readInt reads a 256 bit big endian int straight into the words of a
+// preallocated big.Int
+func (d *Decoder) readInt() (IntValue, error) {
+	data, err := d.next(32)
+	if err != nil {
+		return IntValue{}, err
+	}
+	if len(d.ints) == 0 {
+		d.ints = make([]big.Int, decodeSlabSize)
+		d.words = make([]big.Word, decodeSlabSize*intWordCount)
+	}
+	// Cap the words so that later changes to the int can't spill into the
+	// next int's words
+	words := d.words[:intWordCount:intWordCount]
+	d.words = d.words[intWordCount:]
+	for i := range words {
+		var word big.Word
+		for _, b := range data[32-(i+1)*bits.UintSize/8 : 32-i*bits.UintSize/8] {
+			word = word<<8 | big.Word(b)
+		}
+		words[i] = word
+	}
+	val := &d.ints[0]
+	d.ints = d.ints[1:]
+	val.SetBits(words)
+	return IntValue{val}, nil
+}
+
+func (d *Decoder) readCodePoint(depth int) (Value, error) {
+	immediateCount, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	opcode, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var op Operation
+	switch immediateCount {
+	case 0:
+		op = BasicOperation{Opcode(opcode)}
+	case 1:
+		if depth >= d.maxDepth {
+			return nil, errDecodeDepth
+		}
+		imm, err := d.decode(depth)
+		if err != nil {
+			return nil, err
+		}
+		op = ImmediateOperation{Opcode(opcode), imm}
+	default:
+		return nil, UnmarshalError{"immediate count must be 0 or 1"}
+	}
+	nextHash, err := d.readHash()
+	if err != nil {
+		return nil, err
+	}
+	return CodePointValue{op, nextHash}, nil
+}
+
+func (d *Decoder) readHashPreImage() (Value, error) {
+	hash, err := d.readHash()
+	if err != nil {
+		return nil, err
+	}
+	size, err := d.next(32)
+	if err != nil {
+		return nil, err
+	}
+	return NewPreImage(hash, new(big.Int).SetBytes(size).Int64()), nil
+}
+
+func (d *Decoder) readCodePointStub() (Value, error) {
+	data, err := d.next(16)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := d.readHash()
+	if err != nil {
+		return nil, err
+	}
+	return NewCodePointStub(binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:]), hash), nil
+}
+
+// DecodeTupleHeader reads the type of a tuple and returns its size, leaving
+// its members to be read by the following calls. If the next value isn't a
+// tuple, nothing is consumed
+func (d *Decoder) DecodeTupleHeader() (int, error) {
+	start := d.pos
+	tipe, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if tipe < TypeCodeTuple || tipe > TypeCodeTuple+MaxTupleSize {
+		d.pos = start
+		return 0, errTupleFormat
+	}
+	return int(tipe - TypeCodeTuple), nil
+}
+
+// DecodeByteStack reads a byte stack, a 2-tuple of a length and a stack of 32
+// byte chunks as built by inbox.BytesToByteStack, and returns the bytes it
+// holds. The chunks are copied straight from the input into the result
+// without decoding any values. If the next value isn't a well formed byte
+// stack, nothing is consumed and the value can still be read with Decode
+func (d *Decoder) DecodeByteStack() ([]byte, error) {
+	start := d.pos
+	ret, err := d.decodeByteStack()
+	if err != nil {
+		d.pos = start
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (d *Decoder) decodeByteStack() ([]byte, error) {
+	header, err := d.next(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0] != TypeCodeTuple+2 || header[1] != TypeCodeInt {
+		return nil, errByteStackFormat
+	}
+	length, err := d.next(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each chunk is a 2-tuple of an int and the rest of the stack, so the
+	// chunks are at a fixed stride and end with an empty tuple
+	chunksStart := d.pos
+	chunkCount := 0
+	for {
+		tipe, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if tipe == TypeCodeTuple {
+			break
+		}
+		if tipe != TypeCodeTuple+2 {
+			return nil, errByteStackFormat
+		}
+		chunkCount++
+		if chunkCount > d.maxDepth {
+			return nil, errDecodeDepth
+		}
+		chunkType, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if chunkType != TypeCodeInt {
+			return nil, errByteStackFormat
+		}
+		if _, err := d.next(32); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range length[:24] {
+		if b != 0 {
+			return nil, errByteStackFormat
+		}
+	}
+	dataLength := binary.BigEndian.Uint64(length[24:])
+	if dataLength > uint64(chunkCount)*32 {
+		return nil, errByteStackFormat
+	}
+
+	// The top of the stack holds the last chunk
+	ret := make([]byte, dataLength)
+	for i := 0; i*32 < len(ret); i++ {
+		chunkStart := chunksStart + (chunkCount-1-i)*byteStackChunkLength + 2
+		copy(ret[i*32:], d.data[chunkStart:chunkStart+32])
+	}
+	return ret, nil
+}
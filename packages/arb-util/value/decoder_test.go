@@ -0,0 +1,200 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package value
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func marshalValues(t testing.TB, vals []Value) []byte {
+	var buf bytes.Buffer
+	for _, val := range vals {
+		if err := MarshalValue(val, &buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func deepTuple(depth int) *TupleValue {
+	tup := NewEmptyTuple()
+	for i := 0; i < depth; i++ {
+		tup = NewTuple2(NewInt64Value(int64(i)), tup)
+	}
+	return tup
+}
+
+// wideTuple builds a tree of full tuples with the given number of levels
+func wideTuple(levels int) *TupleValue {
+	var contents []Value
+	for i := 0; i < MaxTupleSize; i++ {
+		if levels <= 1 {
+			contents = append(contents, NewIntValue(new(big.Int).Lsh(big.NewInt(int64(i+1)), 200)))
+		} else {
+			contents = append(contents, wideTuple(levels-1))
+		}
+	}
+	tup, _ := NewTupleFromSlice(contents)
+	return tup
+}
+
+func TestDecoderMatchesUnmarshal(t *testing.T) {
+	vals := append(testValues(), deepTuple(1000), wideTuple(3))
+	d := NewDecoder(marshalValues(t, vals))
+	for _, val := range vals {
+		decoded, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameValue(t, val, decoded)
+	}
+	if d.More() {
+		t.Error("input left after decoding all values")
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Error("expected EOF, got", err)
+	}
+}
+
+func TestDecoderIntsIndependent(t *testing.T) {
+	d := NewDecoder(marshalValues(t, []Value{NewInt64Value(5), NewInt64Value(7)}))
+	first, _ := d.Decode()
+	second, _ := d.Decode()
+	firstInt := first.(IntValue)
+	firstInt.val.Add(firstInt.val, new(big.Int).Lsh(big.NewInt(1), 300))
+	if second.(IntValue).BigInt().Int64() != 7 {
+		t.Error("changing one decoded int changed another")
+	}
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	data := marshalValues(t, []Value{deepTuple(100)})
+	d := NewDecoder(data)
+	d.SetMaxDepth(99)
+	if _, err := d.Decode(); err != errDecodeDepth {
+		t.Fatal("expected depth error, got", err)
+	}
+	if d.Offset() != 0 {
+		t.Error("failed decode consumed input")
+	}
+	d.SetMaxDepth(100)
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	data := marshalValues(t, testValues())
+	for i := 1; i < 200; i++ {
+		d := NewDecoder(data[:len(data)-i])
+		var err error
+		for err == nil {
+			_, err = d.Decode()
+		}
+		if err != io.ErrUnexpectedEOF {
+			t.Fatal("expected unexpected EOF, got", err)
+		}
+	}
+}
+
+func TestDecodeByteStackFallback(t *testing.T) {
+	val := NewTuple2(NewInt64Value(3), NewEmptyTuple())
+	d := NewDecoder(marshalValues(t, []Value{val}))
+	if _, err := d.DecodeByteStack(); err == nil {
+		t.Fatal("decoded tuple with too little data as a byte stack")
+	}
+	decoded, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameValue(t, val, decoded)
+}
+
+func TestDecodeTupleHeader(t *testing.T) {
+	val := NewTuple2(NewInt64Value(3), NewEmptyTuple())
+	d := NewDecoder(marshalValues(t, []Value{NewInt64Value(1), val}))
+	if _, err := d.DecodeTupleHeader(); err == nil {
+		t.Fatal("decoded int as a tuple")
+	}
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	size, err := d.DecodeTupleHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 2 {
+		t.Fatal("wrong tuple size", size)
+	}
+	for _, member := range val.Contents() {
+		decoded, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameValue(t, member, decoded)
+	}
+	if d.More() {
+		t.Error("tuple not fully consumed")
+	}
+}
+
+func BenchmarkUnmarshalValueDeep(b *testing.B) {
+	data := marshalValues(b, []Value{deepTuple(10000)})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalValue(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoderDeep(b *testing.B) {
+	data := marshalValues(b, []Value{deepTuple(10000)})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(data).Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalValueWide(b *testing.B) {
+	data := marshalValues(b, []Value{wideTuple(4)})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalValue(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoderWide(b *testing.B) {
+	data := marshalValues(b, []Value{wideTuple(4)})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(data).Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package value
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+// JSONValue is the JSON form of a Value. Exactly one field is set. Ints are
+// written as hex without a prefix and tuples as a list of their contents,
+// which is the format used by the test vectors.
+//
+// Every value type can be encoded with encoding/json and decoded into a
+// variable of the same concrete type. Since encoding/json can't pick a
+// concrete type for a field of the Value interface type, JSONValue is the
+// way to decode values whose type isn't known in advance
+type JSONValue struct {
+	Tuple         *[]JSONValue       `json:"Tuple,omitempty"`
+	Int           *string            `json:"Int,omitempty"`
+	CodePoint     *JSONCodePoint     `json:"CodePoint,omitempty"`
+	CodePointStub *JSONCodePointStub `json:"CodePointStub,omitempty"`
+	HashPreImage  *JSONHashPreImage  `json:"HashPreImage,omitempty"`
+}
+
+type JSONCodePoint struct {
+	Opcode    Opcode        `json:"opcode"`
+	Immediate *JSONValue    `json:"immediate,omitempty"`
+	NextHash  hexutil.Bytes `json:"nextHash"`
+}
+
+type JSONCodePointStub struct {
+	Segment uint64        `json:"segment"`
+	PC      uint64        `json:"pc"`
+	Hash    hexutil.Bytes `json:"hash"`
+}
+
+type JSONHashPreImage struct {
+	Hash hexutil.Bytes `json:"hash"`
+	Size int64         `json:"size"`
+}
+
+var maxIntValue = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+func NewJSONValue(val Value) (JSONValue, error) {
+	switch val := val.(type) {
+	case IntValue:
+		intString := val.val.Text(16)
+		return JSONValue{Int: &intString}, nil
+	case *TupleValue:
+		vals := make([]JSONValue, 0, val.Len())
+		for _, subVal := range val.Contents() {
+			jsonSubVal, err := NewJSONValue(subVal)
+			if err != nil {
+				return JSONValue{}, err
+			}
+			vals = append(vals, jsonSubVal)
+		}
+		return JSONValue{Tuple: &vals}, nil
+	case CodePointValue:
+		cp := &JSONCodePoint{
+			Opcode:   val.Op.GetOp(),
+			NextHash: val.NextHash.Bytes(),
+		}
+		switch op := val.Op.(type) {
+		case ImmediateOperation:
+			imm, err := NewJSONValue(op.Val)
+			if err != nil {
+				return JSONValue{}, err
+			}
+			cp.Immediate = &imm
+		case BasicOperation:
+		default:
+			return JSONValue{}, fmt.Errorf("unsupported operation type %T", op)
+		}
+		return JSONValue{CodePoint: cp}, nil
+	case CodePointStub:
+		return JSONValue{CodePointStub: &JSONCodePointStub{
+			Segment: val.Segment,
+			PC:      val.PC,
+			Hash:    val.hash.Bytes(),
+		}}, nil
+	case HashPreImage:
+		return JSONValue{HashPreImage: &JSONHashPreImage{
+			Hash: val.hashImage.Bytes(),
+			Size: val.size,
+		}}, nil
+	default:
+		return JSONValue{}, fmt.Errorf("unsupported value type %T", val)
+	}
+}
+
+func jsonHash(data hexutil.Bytes) (common.Hash, error) {
+	var hash common.Hash
+	if len(data) != len(hash) {
+		return hash, errors.New("hash must be 32 bytes")
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
+
+func (v JSONValue) Value() (Value, error) {
+	fieldCount := 0
+	for _, set := range []bool{
+		v.Tuple != nil,
+		v.Int != nil,
+		v.CodePoint != nil,
+		v.CodePointStub != nil,
+		v.HashPreImage != nil,
+	} {
+		if set {
+			fieldCount++
+		}
+	}
+	if fieldCount > 1 {
+		return nil, errors.New("json value must have exactly one type")
+	}
+	switch {
+	case v.Int != nil:
+		intVal, ok := new(big.Int).SetString(*v.Int, 16)
+		if !ok || intVal.Sign() < 0 || intVal.Cmp(maxIntValue) > 0 {
+			return nil, errors.New("invalid int value")
+		}
+		return NewIntValue(intVal), nil
+	case v.Tuple != nil:
+		vals := make([]Value, 0, len(*v.Tuple))
+		for _, jsonSubVal := range *v.Tuple {
+			subVal, err := jsonSubVal.Value()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, subVal)
+		}
+		return NewTupleFromSlice(vals)
+	case v.CodePoint != nil:
+		nextHash, err := jsonHash(v.CodePoint.NextHash)
+		if err != nil {
+			return nil, err
+		}
+		if v.CodePoint.Immediate == nil {
+			return CodePointValue{BasicOperation{v.CodePoint.Opcode}, nextHash}, nil
+		}
+		imm, err := v.CodePoint.Immediate.Value()
+		if err != nil {
+			return nil, err
+		}
+		return CodePointValue{ImmediateOperation{v.CodePoint.Opcode, imm}, nextHash}, nil
+	case v.CodePointStub != nil:
+		hash, err := jsonHash(v.CodePointStub.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return NewCodePointStub(v.CodePointStub.Segment, v.CodePointStub.PC, hash), nil
+	case v.HashPreImage != nil:
+		hash, err := jsonHash(v.HashPreImage.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if v.HashPreImage.Size < 0 {
+			return nil, errors.New("invalid hash pre-image size")
+		}
+		return NewPreImage(hash, v.HashPreImage.Size), nil
+	default:
+		return nil, errors.New("unsupported json value")
+	}
+}
+
+func MarshalValueJSON(val Value) ([]byte, error) {
+	jsonVal, err := NewJSONValue(val)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonVal)
+}
+
+func UnmarshalValueJSON(data []byte) (Value, error) {
+	var jsonVal JSONValue
+	if err := json.Unmarshal(data, &jsonVal); err != nil {
+		return nil, err
+	}
+	return jsonVal.Value()
+}
+
+func (iv IntValue) MarshalJSON() ([]byte, error) {
+	return MarshalValueJSON(iv)
+}
+
+func (tv *TupleValue) MarshalJSON() ([]byte, error) {
+	return MarshalValueJSON(tv)
+}
+
+func (cv CodePointValue) MarshalJSON() ([]byte, error) {
+	return MarshalValueJSON(cv)
+}
+
+func (cp CodePointStub) MarshalJSON() ([]byte, error) {
+	return MarshalValueJSON(cp)
+}
+
+func (hp HashPreImage) MarshalJSON() ([]byte, error) {
+	return MarshalValueJSON(hp)
+}
+
+func jsonTypeError(expected interface{}, val Value) error {
+	return fmt.Errorf("expected %T but json holds %T", expected, val)
+}
+
+func (iv *IntValue) UnmarshalJSON(data []byte) error {
+	val, err := UnmarshalValueJSON(data)
+	if err != nil {
+		return err
+	}
+	intVal, ok := val.(IntValue)
+	if !ok {
+		return jsonTypeError(iv, val)
+	}
+	*iv = intVal
+	return nil
+}
+
+func (tv *TupleValue) UnmarshalJSON(data []byte) error {
+	val, err := UnmarshalValueJSON(data)
+	if err != nil {
+		return err
+	}
+	tup, ok := val.(*TupleValue)
+	if !ok {
+		return jsonTypeError(tv, val)
+	}
+	*tv = *tup
+	return nil
+}
+
+func (cv *CodePointValue) UnmarshalJSON(data []byte) error {
+	val, err := UnmarshalValueJSON(data)
+	if err != nil {
+		return err
+	}
+	cp, ok := val.(CodePointValue)
+	if !ok {
+		return jsonTypeError(cv, val)
+	}
+	*cv = cp
+	return nil
+}
+
+func (cp *CodePointStub) UnmarshalJSON(data []byte) error {
+	val, err := UnmarshalValueJSON(data)
+	if err != nil {
+		return err
+	}
+	stub, ok := val.(CodePointStub)
+	if !ok {
+		return jsonTypeError(cp, val)
+	}
+	*cp = stub
+	return nil
+}
+
+func (hp *HashPreImage) UnmarshalJSON(data []byte) error {
+	val, err := UnmarshalValueJSON(data)
+	if err != nil {
+		return err
+	}
+	preImage, ok := val.(HashPreImage)
+	if !ok {
+		return jsonTypeError(hp, val)
+	}
+	*hp = preImage
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package value
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+func testValues() []Value {
+	maxInt := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	stub := NewCodePointStub(2, 45, common.Hash{1, 2, 3})
+	basic := CodePointValue{BasicOperation{0x34}, common.Hash{4}}
+	immediate := CodePointValue{ImmediateOperation{0x40, NewTuple2(NewInt64Value(7), stub)}, basic.Hash()}
+	nested := NewEmptyTuple()
+	for i := int64(0); i < 6; i++ {
+		nested = NewTuple2(NewIntValue(new(big.Int).Lsh(big.NewInt(i), 200)), nested)
+	}
+	return []Value{
+		NewInt64Value(0),
+		NewIntValue(maxInt),
+		NewEmptyTuple(),
+		NewTuple2(NewInt64Value(1), NewEmptyTuple()),
+		stub,
+		basic,
+		immediate,
+		NewPreImage(common.Hash{9, 8}, 12),
+		nested,
+	}
+}
+
+func checkSameValue(t *testing.T, val Value, decoded Value) {
+	t.Helper()
+	if decoded.TypeCode() != val.TypeCode() {
+		t.Fatalf("decoded %v as %v", val, decoded)
+	}
+	if decoded.Hash() != val.Hash() || decoded.Size() != val.Size() {
+		t.Errorf("decoded %v as %v", val, decoded)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, val := range testValues() {
+		data, err := json.Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := UnmarshalValueJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameValue(t, val, decoded)
+	}
+}
+
+func TestJSONUnmarshalConcrete(t *testing.T) {
+	for _, val := range testValues() {
+		data, err := json.Marshal(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		target := reflect.New(reflect.TypeOf(val))
+		if err := json.Unmarshal(data, target.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		checkSameValue(t, val, target.Elem().Interface().(Value))
+	}
+
+	var tup TupleValue
+	if err := json.Unmarshal([]byte(`{"Int":"1"}`), &tup); err == nil {
+		t.Error("decoded int into tuple")
+	}
+}
+
+func TestJSONInStruct(t *testing.T) {
+	type logEntry struct {
+		Name  string    `json:"name"`
+		Value Value     `json:"value"`
+		Saved JSONValue `json:"saved"`
+	}
+	val := NewTuple2(NewInt64Value(255), NewEmptyTuple())
+	jsonVal, err := NewJSONValue(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(logEntry{Name: "test", Value: val, Saved: jsonVal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"test","value":{"Tuple":[{"Int":"ff"},{"Tuple":[]}]},"saved":{"Tuple":[{"Int":"ff"},{"Tuple":[]}]}}`
+	if string(data) != expected {
+		t.Fatal("wrong json", string(data))
+	}
+
+	var entry struct {
+		Saved JSONValue `json:"saved"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := entry.Saved.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameValue(t, val, decoded)
+}
+
+func TestInvalidJSON(t *testing.T) {
+	cases := []string{
+		`{}`,
+		`{"Int":"xyz"}`,
+		`{"Int":"-1"}`,
+		`{"Int":"1` + strings.Repeat("0", 64) + `"}`,
+		`{"Int":"1","Tuple":[]}`,
+		`{"Tuple":[{},{},{},{},{},{},{},{},{}]}`,
+		`{"CodePointStub":{"segment":0,"pc":0,"hash":"0x01"}}`,
+		`{"HashPreImage":{"hash":"0x0000000000000000000000000000000000000000000000000000000000000000","size":-1}}`,
+	}
+	for _, c := range cases {
+		if _, err := UnmarshalValueJSON([]byte(c)); err == nil {
+			t.Error("decoded invalid json", c)
+		}
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	for _, val := range testValues() {
+		text := FormatValue(val)
+		decoded, err := ParseValue(text)
+		if err != nil {
+			t.Fatal(err, text)
+		}
+		checkSameValue(t, val, decoded)
+	}
+}
+
+func TestParseString(t *testing.T) {
+	for _, val := range testValues() {
+		if _, ok := val.(CodePointValue); ok {
+			continue
+		}
+		decoded, err := ParseValue(val.String())
+		if err != nil {
+			t.Fatal(err, val)
+		}
+		checkSameValue(t, val, decoded)
+	}
+}
+
+func TestFormatValueIndents(t *testing.T) {
+	val := NewTuple2(NewInt64Value(1), NewTuple2(NewIntValue(new(big.Int).Lsh(big.NewInt(1), 255)), NewEmptyTuple()))
+	expected := "Tuple(\n" +
+		"  1,\n" +
+		"  Tuple(\n" +
+		"    57896044618658097711785492504343953926634992332820282019728792003956564819968,\n" +
+		"    Tuple()\n" +
+		"  )\n" +
+		")"
+	if text := FormatValue(val); text != expected {
+		t.Errorf("wrong format\n%v", text)
+	}
+	if text := FormatValue(NewTuple2(NewInt64Value(1), NewEmptyTuple())); text != "Tuple(1, Tuple())" {
+		t.Errorf("wrong format %v", text)
+	}
+}
+
+func TestInvalidText(t *testing.T) {
+	cases := []string{
+		"",
+		"Tuple(",
+		"Tuple(1,)",
+		"Tuple(1, 2) 3",
+		"-5",
+		"0xzz",
+		"Tuple(0, 0, 0, 0, 0, 0, 0, 0, 0)",
+		"CodePointStub(1, 2, 0x01)",
+		"HashPreImage(0x0000000000000000000000000000000000000000000000000000000000000000, -1)",
+		"CodePoint(0x100, 0x0000000000000000000000000000000000000000000000000000000000000000)",
+	}
+	for _, c := range cases {
+		if _, err := ParseValue(c); err == nil {
+			t.Errorf("parsed invalid text %q", c)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package value
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+// Tuples that fit on a line of this length are printed on a single line
+const maxTextLineLength = 80
+
+const textIndent = "  "
+
+// FormatValue prints val in the same syntax as String, except that code
+// points include their next hash and large tuples are split over several
+// indented lines. The result can be read back with ParseValue
+func FormatValue(val Value) string {
+	var buf bytes.Buffer
+	formatValue(&buf, val, 0)
+	return buf.String()
+}
+
+func formatValue(buf *bytes.Buffer, val Value, depth int) {
+	line := formatValueLine(val)
+	tup, ok := val.(*TupleValue)
+	if !ok || len(line)+depth*len(textIndent) <= maxTextLineLength {
+		buf.WriteString(line)
+		return
+	}
+	buf.WriteString("Tuple(\n")
+	for i, item := range tup.Contents() {
+		buf.WriteString(strings.Repeat(textIndent, depth+1))
+		formatValue(buf, item, depth+1)
+		if int64(i) != tup.Len()-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(strings.Repeat(textIndent, depth))
+	buf.WriteString(")")
+}
+
+func formatValueLine(val Value) string {
+	switch val := val.(type) {
+	case *TupleValue:
+		items := make([]string, 0, val.Len())
+		for _, item := range val.Contents() {
+			items = append(items, formatValueLine(item))
+		}
+		return "Tuple(" + strings.Join(items, ", ") + ")"
+	case CodePointValue:
+		if op, ok := val.Op.(ImmediateOperation); ok {
+			return fmt.Sprintf("CodePoint(0x%x, Imd(%v), %v)", op.Op, formatValueLine(op.Val), val.NextHash)
+		}
+		return fmt.Sprintf("CodePoint(0x%x, %v)", val.Op.GetOp(), val.NextHash)
+	default:
+		return val.String()
+	}
+}
+
+type TextParseError struct {
+	Offset int
+	Msg    string
+}
+
+func (e TextParseError) Error() string {
+	return fmt.Sprintf("parse error at offset %v: %v", e.Offset, e.Msg)
+}
+
+// ParseValue reads a value printed by FormatValue. It also accepts the
+// output of String for every value other than code points
+func ParseValue(text string) (Value, error) {
+	p := &textParser{text: text}
+	val, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.text) {
+		return nil, p.errorf("unexpected trailing text")
+	}
+	return val, nil
+}
+
+type textParser struct {
+	text string
+	pos  int
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return TextParseError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// token returns the next run of letters and digits
+func (p *textParser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *textParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *textParser) expect(c byte) error {
+	if !p.consume(c) {
+		return p.errorf("expected '%c'", c)
+	}
+	return nil
+}
+
+func (p *textParser) parseValue() (Value, error) {
+	p.skipSpace()
+	start := p.pos
+	tok := p.token()
+	switch tok {
+	case "":
+		return nil, p.errorf("expected value")
+	case "Tuple":
+		return p.parseTuple()
+	case "CodePoint":
+		return p.parseCodePoint()
+	case "CodePointStub":
+		return p.parseCodePointStub()
+	case "HashPreImage":
+		return p.parseHashPreImage()
+	}
+	var val *big.Int
+	var ok bool
+	if strings.HasPrefix(tok, "0x") {
+		val, ok = new(big.Int).SetString(tok[2:], 16)
+	} else {
+		val, ok = new(big.Int).SetString(tok, 10)
+	}
+	if !ok || val.Sign() < 0 || val.Cmp(maxIntValue) > 0 {
+		p.pos = start
+		return nil, p.errorf("invalid int value %v", tok)
+	}
+	return NewIntValue(val), nil
+}
+
+func (p *textParser) parseTuple() (Value, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var vals []Value
+	if !p.consume(')') {
+		for {
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+			if p.consume(')') {
+				break
+			}
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !IsValidTupleSizeI64(int64(len(vals))) {
+		return nil, p.errorf("tuple has %v items", len(vals))
+	}
+	return NewTupleFromSlice(vals)
+}
+
+func (p *textParser) parseCodePoint() (Value, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	opcode, err := p.parseUint(8)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	var op Operation = BasicOperation{Opcode(opcode)}
+	p.skipSpace()
+	if strings.HasPrefix(p.text[p.pos:], "Imd") {
+		p.token()
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		imm, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		op = ImmediateOperation{Opcode(opcode), imm}
+	}
+	nextHash, err := p.parseHash()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return CodePointValue{op, nextHash}, nil
+}
+
+func (p *textParser) parseCodePointStub() (Value, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	segment, err := p.parseUint(64)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	pc, err := p.parseUint(64)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	hash, err := p.parseHash()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return NewCodePointStub(segment, pc, hash), nil
+}
+
+func (p *textParser) parseHashPreImage() (Value, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	hash, err := p.parseHash()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	size, err := p.parseUint(63)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return NewPreImage(hash, int64(size)), nil
+}
+
+func (p *textParser) parseUint(bitSize int) (uint64, error) {
+	p.skipSpace()
+	start := p.pos
+	tok := p.token()
+	val, err := strconv.ParseUint(tok, 0, bitSize)
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("invalid number %v", tok)
+	}
+	return val, nil
+}
+
+func (p *textParser) parseHash() (common.Hash, error) {
+	p.skipSpace()
+	start := p.pos
+	tok := p.token()
+	data, err := hexutil.Decode(tok)
+	var hash common.Hash
+	if err != nil || len(data) != len(hash) {
+		p.pos = start
+		return hash, p.errorf("invalid hash %v", tok)
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
@@ -132,6 +132,12 @@ type AssertedEvent struct {
 	MessageCount     uint64
 	LastLogHash      common.Hash
 	LogCount         uint64
+	// Total number of messages and logs output by the chain before this
+	// assertion
+	BeforeMessageCount *big.Int
+	BeforeLogCount     *big.Int
+	// Hash of the node which will be confirmed if the assertion is valid
+	ValidLeafHash common.Hash
 }
 
 type ConfirmedEvent struct {
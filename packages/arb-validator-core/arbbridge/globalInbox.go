@@ -66,6 +66,25 @@ type GlobalInboxSender interface {
 		data []byte,
 	) (common.Hash, error)
 
+	// ResendL2MessageNoWait replaces the still pending transaction txHash,
+	// which was sent by SendL2MessageNoWait, with a transaction sending data
+	// using the same nonce and a higher gas price. It returns the hash of the
+	// replacement transaction without waiting for its receipt
+	ResendL2MessageNoWait(
+		ctx context.Context,
+		data []byte,
+		txHash common.Hash,
+	) (common.Hash, error)
+
+	// L2MessageNonce returns the nonce of txHash, which was sent by
+	// SendL2MessageNoWait or ResendL2MessageNoWait
+	L2MessageNonce(ctx context.Context, txHash common.Hash) (uint64, error)
+
+	// MinedNonce returns the number of transactions sent by the account
+	// sending L2 messages that have been mined. A sent message without a
+	// receipt whose nonce is below it can never be included
+	MinedNonce(ctx context.Context) (uint64, error)
+
 	DepositEthMessage(
 		ctx context.Context,
 		destination common.Address,
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package config loads the configuration shared by the validator and
+// aggregator binaries. Settings are taken from the defaults, then a yaml or
+// toml file, then ARB_ environment variables and finally command line flags,
+// with later sources overriding earlier ones
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/naoina/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+)
+
+type L1Config struct {
+	// URL of the L1 node
+	URL string `yaml:"url" toml:"url" secret:"url"`
+}
+
+type RollupConfig struct {
+	Address string `yaml:"address" toml:"address"`
+	// Folder contains the wallet and compiled contract of the chain
+	Folder string `yaml:"folder" toml:"folder"`
+}
+
+type WalletConfig struct {
+	// Password of the wallet. If it isn't set, the password is asked for,
+	// while an empty password is used as is
+	Password *string `yaml:"password" toml:"password" secret:"true"`
+	// GasPrice in gwei
+	GasPrice float64 `yaml:"gas_price" toml:"gas_price"`
+}
+
+type CheckpointConfig struct {
+	// Path of the checkpoint database, defaulting to checkpoint_db in the
+	// rollup folder
+	Path          string `yaml:"path" toml:"path"`
+	MaxReorgDepth int64  `yaml:"max_reorg_depth" toml:"max_reorg_depth"`
+	// CleanupInterval is the number of blocks between deletions of
+	// checkpoints older than MaxReorgDepth
+	CleanupInterval int64 `yaml:"cleanup_interval" toml:"cleanup_interval"`
+}
+
+type ValidatorConfig struct {
+	// BlockTime is the expected number of seconds between L1 blocks
+	BlockTime int64 `yaml:"block_time" toml:"block_time"`
+}
+
+type AggregatorConfig struct {
+	// MaxBatchTime is the most seconds to wait before submitting a batch
+	MaxBatchTime    int64  `yaml:"max_batch_time" toml:"max_batch_time"`
+	Ordering        string `yaml:"ordering" toml:"ordering"`
+	ForwardURL      string `yaml:"forward_url" toml:"forward_url" secret:"url"`
+	Pending         bool   `yaml:"pending" toml:"pending"`
+	BackfillTxIndex bool   `yaml:"backfill_tx_index" toml:"backfill_tx_index"`
+	// ExportableCheckpoints keeps the machine of the latest confirmed node in
+	// each checkpoint, which arb-checkpoint export needs
+	ExportableCheckpoints bool `yaml:"exportable_checkpoints" toml:"exportable_checkpoints"`
+}
+
+type RPCConfig struct {
+	HTTPAddr string `yaml:"http_addr" toml:"http_addr"`
+	WSAddr   string `yaml:"ws_addr" toml:"ws_addr"`
+	// HTTPAPI and WSAPI are the namespaces exposed on each endpoint. If
+	// empty, the binary's defaults are used
+	HTTPAPI   []string `yaml:"http_api" toml:"http_api"`
+	WSAPI     []string `yaml:"ws_api" toml:"ws_api"`
+	CORS      []string `yaml:"cors" toml:"cors"`
+	VHosts    []string `yaml:"vhosts" toml:"vhosts"`
+	RateLimit float64  `yaml:"rate_limit" toml:"rate_limit"`
+	RateBurst int      `yaml:"rate_burst" toml:"rate_burst"`
+	CertFile  string   `yaml:"cert_file" toml:"cert_file"`
+	KeyFile   string   `yaml:"key_file" toml:"key_file"`
+}
+
+type MetricsConfig struct {
+	// Addr to serve metrics on. Metrics aren't served if it is empty
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+type Config struct {
+	L1         L1Config         `yaml:"l1" toml:"l1"`
+	Rollup     RollupConfig     `yaml:"rollup" toml:"rollup"`
+	Wallet     WalletConfig     `yaml:"wallet" toml:"wallet"`
+	Checkpoint CheckpointConfig `yaml:"checkpoint" toml:"checkpoint"`
+	Validator  ValidatorConfig  `yaml:"validator" toml:"validator"`
+	Aggregator AggregatorConfig `yaml:"aggregator" toml:"aggregator"`
+	RPC        RPCConfig        `yaml:"rpc" toml:"rpc"`
+	Metrics    MetricsConfig    `yaml:"metrics" toml:"metrics"`
+}
+
+// Default returns the configuration used for settings which aren't set
+// anywhere else
+func Default() *Config {
+	return &Config{
+		Wallet: WalletConfig{
+			GasPrice: 4.5,
+		},
+		Checkpoint: CheckpointConfig{
+			MaxReorgDepth:   100,
+			CleanupInterval: 25,
+		},
+		Validator: ValidatorConfig{
+			BlockTime: 2,
+		},
+		Aggregator: AggregatorConfig{
+			MaxBatchTime: 10,
+			Ordering:     "random",
+		},
+		RPC: RPCConfig{
+			HTTPAddr:  ":8547",
+			WSAddr:    ":8548",
+			CORS:      []string{"*"},
+			VHosts:    []string{"*"},
+			RateBurst: 100,
+		},
+	}
+}
+
+// LoadFile reads the settings in the yaml or toml file at path into c. The
+// format is chosen by the file extension and unknown settings are an error
+func (c *Config) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unknown config file format %v, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %v: %v", path, err)
+	}
+	return nil
+}
+
+// ApplyRollupArgs sets the rollup folder, L1 URL and rollup address from the
+// positional arguments the binaries have always accepted. Passing no
+// arguments leaves the configuration unchanged
+func (c *Config) ApplyRollupArgs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if len(args) != 3 {
+		return errors.New("expected <validator_folder> <ethURL> <rollup_address>")
+	}
+	c.Rollup.Folder = args[0]
+	c.L1.URL = args[1]
+	c.Rollup.Address = args[2]
+	return nil
+}
+
+// RollupAddress returns the parsed rollup address. It must only be called
+// after Validate succeeded
+func (c *Config) RollupAddress() common.Address {
+	return common.HexToAddress(c.Rollup.Address)
+}
+
+// CheckpointPath returns the path of the checkpoint database
+func (c *Config) CheckpointPath() string {
+	if c.Checkpoint.Path != "" {
+		return c.Checkpoint.Path
+	}
+	return filepath.Join(c.Rollup.Folder, "checkpoint_db")
+}
+
+// Validate checks that the settings needed by every binary are present and
+// that all settings are in range
+func (c *Config) Validate() error {
+	if c.L1.URL == "" {
+		return errors.New("l1.url must be set")
+	}
+	if _, err := url.Parse(c.L1.URL); err != nil {
+		return fmt.Errorf("invalid l1.url: %v", err)
+	}
+	if !ethcommon.IsHexAddress(c.Rollup.Address) {
+		return fmt.Errorf("invalid rollup.address %q", c.Rollup.Address)
+	}
+	if c.Rollup.Folder == "" {
+		return errors.New("rollup.folder must be set")
+	}
+	if c.Wallet.GasPrice < 0 {
+		return errors.New("wallet.gas_price must not be negative")
+	}
+	if c.Checkpoint.MaxReorgDepth <= 0 {
+		return errors.New("checkpoint.max_reorg_depth must be positive")
+	}
+	if c.Checkpoint.CleanupInterval <= 0 {
+		return errors.New("checkpoint.cleanup_interval must be positive")
+	}
+	if c.Validator.BlockTime <= 0 {
+		return errors.New("validator.block_time must be positive")
+	}
+	if c.Aggregator.MaxBatchTime <= 0 {
+		return errors.New("aggregator.max_batch_time must be positive")
+	}
+	if c.RPC.RateLimit < 0 {
+		return errors.New("rpc.rate_limit must not be negative")
+	}
+	if c.RPC.RateBurst < 0 {
+		return errors.New("rpc.rate_burst must not be negative")
+	}
+	if (c.RPC.CertFile == "") != (c.RPC.KeyFile == "") {
+		return errors.New("rpc.cert_file and rpc.key_file must be set together")
+	}
+	return nil
+}
+
+const redacted = "<redacted>"
+
+// redactURL keeps only the scheme and host of a URL since node providers
+// commonly put API keys in the path, query or user info
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host + "/" + redacted
+}
+
+// Redacted returns the configuration in yaml with passwords and URL
+// credentials hidden, suitable for logging
+func (c *Config) Redacted() string {
+	cfg := *c
+	for _, s := range cfg.settings() {
+		value, ok := s.stringValue()
+		if !ok || value == "" {
+			continue
+		}
+		switch s.secret {
+		case "true":
+			s.setString(redacted)
+		case "url":
+			s.setString(redactURL(value))
+		}
+	}
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testAddress = "0x895521964D724c8362A36608AAf09A3D7d0A0445"
+
+func writeConfigFile(t *testing.T, dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	yamlPath := writeConfigFile(t, dir, "config.yaml", `
+l1:
+  url: http://localhost:7545
+rollup:
+  address: `+testAddress+`
+  folder: /data/rollup
+checkpoint:
+  max_reorg_depth: 50
+rpc:
+  http_api: [eth, net]
+`)
+	tomlPath := writeConfigFile(t, dir, "config.toml", `
+[l1]
+url = "http://localhost:7545"
+
+[rollup]
+address = "`+testAddress+`"
+folder = "/data/rollup"
+
+[checkpoint]
+max_reorg_depth = 50
+
+[rpc]
+http_api = ["eth", "net"]
+`)
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg := Default()
+		if err := cfg.LoadFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.L1.URL != "http://localhost:7545" || cfg.Checkpoint.MaxReorgDepth != 50 {
+			t.Error("wrong settings loaded from", path, cfg)
+		}
+		if len(cfg.RPC.HTTPAPI) != 2 || cfg.RPC.HTTPAPI[1] != "net" {
+			t.Error("wrong list loaded from", path, cfg.RPC.HTTPAPI)
+		}
+		// Settings missing from the file keep their defaults
+		if cfg.Validator.BlockTime != 2 || cfg.RPC.HTTPAddr != ":8547" {
+			t.Error("default overwritten by", path)
+		}
+		if cfg.CheckpointPath() != filepath.Join("/data/rollup", "checkpoint_db") {
+			t.Error("wrong default checkpoint path", cfg.CheckpointPath())
+		}
+	}
+
+	unknownPath := writeConfigFile(t, dir, "unknown.yaml", "l1:\n  uri: http://localhost:7545\n")
+	if err := Default().LoadFile(unknownPath); err == nil {
+		t.Error("expected error for unknown setting")
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := writeConfigFile(t, dir, "config.yaml", `
+l1:
+  url: http://file:7545
+validator:
+  block_time: 5
+aggregator:
+  max_batch_time: 20
+`)
+	env := map[string]string{
+		"ARB_CONFIG":                 path,
+		"ARB_VALIDATOR_BLOCK_TIME":   "7",
+		"ARB_AGGREGATOR_PENDING":     "true",
+		"ARB_AGGREGATOR_FORWARD_URL": "http://env:8547",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	flags := AddFlags(fs)
+	flags.Alias("blocktime", "validator.block_time", "")
+	err := fs.Parse([]string{
+		"-blocktime=9",
+		"-rpc.http_api", "eth,arb",
+		"-aggregator.backfill_tx_index",
+		"folder", "http://args:7545", testAddress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flags.Load(lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyRollupArgs(fs.Args()); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Aggregator.MaxBatchTime != 20 {
+		t.Error("file setting not applied")
+	}
+	if !cfg.Aggregator.Pending || cfg.Aggregator.ForwardURL != "http://env:8547" {
+		t.Error("env settings not applied")
+	}
+	if cfg.Validator.BlockTime != 9 {
+		t.Error("flag should override env and file, got", cfg.Validator.BlockTime)
+	}
+	if !cfg.Aggregator.BackfillTxIndex || strings.Join(cfg.RPC.HTTPAPI, ",") != "eth,arb" {
+		t.Error("flag settings not applied")
+	}
+	if cfg.L1.URL != "http://args:7545" || cfg.Rollup.Folder != "folder" {
+		t.Error("positional arguments not applied")
+	}
+
+	if _, err := AddFlags(flag.NewFlagSet("", flag.ContinueOnError)).Load(func(key string) (string, bool) {
+		return "abc", key == "ARB_CHECKPOINT_MAX_REORG_DEPTH"
+	}); err == nil {
+		t.Error("expected error for invalid environment variable")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.L1.URL = "http://localhost:7545"
+		cfg.Rollup.Address = testAddress
+		cfg.Rollup.Folder = "folder"
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, modify := range map[string]func(cfg *Config){
+		"missing url":           func(cfg *Config) { cfg.L1.URL = "" },
+		"invalid address":       func(cfg *Config) { cfg.Rollup.Address = "0x1234" },
+		"missing folder":        func(cfg *Config) { cfg.Rollup.Folder = "" },
+		"zero reorg depth":      func(cfg *Config) { cfg.Checkpoint.MaxReorgDepth = 0 },
+		"zero cleanup interval": func(cfg *Config) { cfg.Checkpoint.CleanupInterval = 0 },
+		"zero block time":       func(cfg *Config) { cfg.Validator.BlockTime = 0 },
+		"negative limit":        func(cfg *Config) { cfg.RPC.RateLimit = -1 },
+		"cert without key":      func(cfg *Config) { cfg.RPC.CertFile = "cert.pem" },
+	} {
+		cfg := valid()
+		modify(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected validation error for", name)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.L1.URL = "https://mainnet.infura.io/v3/secretkey"
+	cfg.Aggregator.ForwardURL = "http://localhost:8547"
+	password := "hunter2"
+	cfg.Wallet.Password = &password
+
+	output := cfg.Redacted()
+	if strings.Contains(output, "secretkey") || strings.Contains(output, "hunter2") {
+		t.Error("secrets not redacted:\n", output)
+	}
+	if !strings.Contains(output, "https://mainnet.infura.io/<redacted>") {
+		t.Error("expected url host to be kept:\n", output)
+	}
+	if !strings.Contains(output, "http://localhost:8547") {
+		t.Error("url without credentials should be printed:\n", output)
+	}
+	if *cfg.Wallet.Password != "hunter2" {
+		t.Error("redacting modified the configuration")
+	}
+}
+
+func TestOptionalPassword(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cfg := Default()
+	if err := cfg.LoadFile(writeConfigFile(t, dir, "unset.yaml", "wallet:\n  gas_price: 5\n")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Wallet.Password != nil {
+		t.Error("password should be unset")
+	}
+	if strings.Contains(cfg.Redacted(), redacted) {
+		t.Error("unset password was redacted")
+	}
+
+	for _, path := range []string{
+		writeConfigFile(t, dir, "empty.yaml", "wallet:\n  password: \"\"\n"),
+		writeConfigFile(t, dir, "empty.toml", "[wallet]\npassword = \"\"\n"),
+	} {
+		cfg := Default()
+		if err := cfg.LoadFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Wallet.Password == nil || *cfg.Wallet.Password != "" {
+			t.Error("empty password should be set in", path)
+		}
+	}
+
+	cfg = Default()
+	if err := cfg.ApplyEnv(func(key string) (string, bool) {
+		return "", key == "ARB_WALLET_PASSWORD"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Wallet.Password == nil || *cfg.Wallet.Password != "" {
+		t.Error("empty password should be set from the environment")
+	}
+	if err := cfg.Set("wallet.password", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if *cfg.Wallet.Password != "pass" {
+		t.Error("wrong password", *cfg.Wallet.Password)
+	}
+}
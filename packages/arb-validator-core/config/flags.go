@@ -0,0 +1,251 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is prepended to the upper cased setting key, with dots replaced
+// by underscores, to get its environment variable. For example l1.url is
+// set by ARB_L1_URL
+const envPrefix = "ARB_"
+
+// configPathEnv is the environment variable read for the config file path
+// if none was given on the command line
+const configPathEnv = envPrefix + "CONFIG"
+
+// setting is a single configurable value, identified by a key of the form
+// section.name matching its location in the config file
+type setting struct {
+	key    string
+	field  reflect.Value
+	secret string
+}
+
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.Replace(s.key, ".", "_", -1))
+}
+
+// stringValue returns the value of a string setting, which may be optional,
+// and whether it is set
+func (s setting) stringValue() (string, bool) {
+	switch {
+	case s.field.Kind() == reflect.String:
+		return s.field.String(), true
+	case s.isOptionalString():
+		if s.field.IsNil() {
+			return "", false
+		}
+		return s.field.Elem().String(), true
+	default:
+		return "", false
+	}
+}
+
+// setString sets a string setting. Optional strings are given a new value
+// rather than changing the one they point to, which may be shared with a
+// copy of the configuration
+func (s setting) setString(value string) {
+	if s.isOptionalString() {
+		s.field.Set(reflect.ValueOf(&value))
+	} else {
+		s.field.SetString(value)
+	}
+}
+
+// isOptionalString reports whether the setting is a *string, which is nil if
+// it isn't set so that it can be told apart from an empty string
+func (s setting) isOptionalString() bool {
+	return s.field.Kind() == reflect.Ptr && s.field.Type().Elem().Kind() == reflect.String
+}
+
+func (c *Config) settings() []setting {
+	settings := make([]setting, 0)
+	cfg := reflect.ValueOf(c).Elem()
+	for i := 0; i < cfg.NumField(); i++ {
+		section := cfg.Field(i)
+		sectionName := cfg.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			settings = append(settings, setting{
+				key:    sectionName + "." + field.Tag.Get("yaml"),
+				field:  section.Field(j),
+				secret: field.Tag.Get("secret"),
+			})
+		}
+	}
+	return settings
+}
+
+func (c *Config) setting(key string) (setting, bool) {
+	for _, s := range c.settings() {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Set parses value into the setting with the given key. Lists are given as
+// comma separated values
+func (c *Config) Set(key string, value string) error {
+	s, ok := c.setting(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %v", key)
+	}
+	if s.isOptionalString() {
+		s.setString(value)
+		return nil
+	}
+	switch s.field.Kind() {
+	case reflect.String:
+		s.field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %v: %v", key, err)
+		}
+		s.field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %v: %v", key, err)
+		}
+		s.field.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %v: %v", key, err)
+		}
+		s.field.SetFloat(f)
+	case reflect.Slice:
+		s.field.Set(reflect.ValueOf(splitList(value)))
+	default:
+		return fmt.Errorf("setting %v has unsupported type %v", key, s.field.Type())
+	}
+	return nil
+}
+
+// ApplyEnv overrides settings with the environment variables returned by
+// lookupEnv, which is normally os.LookupEnv
+func (c *Config) ApplyEnv(lookupEnv func(string) (string, bool)) error {
+	for _, s := range c.settings() {
+		if value, ok := lookupEnv(s.env()); ok {
+			if err := c.Set(s.key, value); err != nil {
+				return fmt.Errorf("error in %v: %v", s.env(), err)
+			}
+		}
+	}
+	return nil
+}
+
+type override struct {
+	key   string
+	value string
+}
+
+// Flags collects the command line flags which override the configuration.
+// Every setting has a flag named after its key, and binaries can add aliases
+// for the flags they accepted before configuration files were supported
+type Flags struct {
+	fs        *flag.FlagSet
+	path      *string
+	overrides []override
+}
+
+type overrideValue struct {
+	flags  *Flags
+	key    string
+	isBool bool
+}
+
+func (v *overrideValue) String() string {
+	return ""
+}
+
+func (v *overrideValue) Set(value string) error {
+	v.flags.overrides = append(v.flags.overrides, override{key: v.key, value: value})
+	return nil
+}
+
+func (v *overrideValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func AddFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		fs:   fs,
+		path: fs.String("config", "", "path to a yaml or toml config file (defaults to $"+configPathEnv+")"),
+	}
+	for _, s := range Default().settings() {
+		f.addFlag(s.key, s.key, fmt.Sprintf("overrides %v (or set $%v)", s.key, s.env()))
+	}
+	return f
+}
+
+func (f *Flags) addFlag(name string, key string, usage string) {
+	s, ok := Default().setting(key)
+	if !ok {
+		panic(fmt.Sprintf("flag %v refers to unknown setting %v", name, key))
+	}
+	f.fs.Var(&overrideValue{flags: f, key: key, isBool: s.field.Kind() == reflect.Bool}, name, usage)
+}
+
+// Alias adds a flag called name which sets the setting key
+func (f *Flags) Alias(name string, key string, usage string) {
+	f.addFlag(name, key, usage)
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the parsed flags. The result still needs to be validated
+func (f *Flags) Load(lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	path := *f.path
+	if path == "" {
+		path, _ = lookupEnv(configPathEnv)
+	}
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ApplyEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+	for _, o := range f.overrides {
+		if err := cfg.Set(o.key, o.value); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
@@ -260,6 +260,10 @@ func (vm *ethRollupWatcher) processEvents(
 			MessageCount:     eventVal.MessageCount,
 			LastLogHash:      eventVal.Fields[5],
 			LogCount:         eventVal.LogCount,
+
+			BeforeMessageCount: eventVal.BeforeMessageCount,
+			BeforeLogCount:     eventVal.BeforeLogCount,
+			ValidLeafHash:      eventVal.Fields[6],
 		}, nil
 	case rollupConfirmedID:
 		eventVal, err := vm.ArbRollup.ParseRollupConfirmed(ethLog)
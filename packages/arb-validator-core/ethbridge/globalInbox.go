@@ -87,6 +87,54 @@ func (con *globalInbox) SendL2MessageNoWait(ctx context.Context, data []byte) (c
 	return common.NewHashFromEth(tx.Hash()), nil
 }
 
+// resendGasPriceBump is the percentage by which the gas price of a resent
+// transaction is raised above the one it replaces
+const resendGasPriceBump = 25
+
+func (con *globalInbox) ResendL2MessageNoWait(ctx context.Context, data []byte, txHash common.Hash) (common.Hash, error) {
+	oldTx, isPending, err := con.client.TransactionByHash(ctx, txHash.ToEthHash())
+	if err != nil {
+		return common.Hash{}, errors2.Wrap(err, "failed to fetch transaction to replace")
+	}
+	if !isPending {
+		return common.Hash{}, errors.New("transaction to replace is no longer pending")
+	}
+
+	gasPrice := new(big.Int).Mul(oldTx.GasPrice(), big.NewInt(100+resendGasPriceBump))
+	gasPrice = gasPrice.Div(gasPrice, big.NewInt(100))
+	suggestedPrice, err := con.client.SuggestGasPrice(ctx)
+	if err == nil && suggestedPrice.Cmp(gasPrice) > 0 {
+		gasPrice = suggestedPrice
+	}
+
+	con.auth.Lock()
+	defer con.auth.Unlock()
+	auth := con.auth.getAuth(ctx)
+	auth.Nonce = new(big.Int).SetUint64(oldTx.Nonce())
+	auth.GasPrice = gasPrice
+	tx, err := con.GlobalInbox.SendL2MessageFromOrigin(
+		auth,
+		con.rollupAddress,
+		data,
+	)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.NewHashFromEth(tx.Hash()), nil
+}
+
+func (con *globalInbox) L2MessageNonce(ctx context.Context, txHash common.Hash) (uint64, error) {
+	tx, _, err := con.client.TransactionByHash(ctx, txHash.ToEthHash())
+	if err != nil {
+		return 0, err
+	}
+	return tx.Nonce(), nil
+}
+
+func (con *globalInbox) MinedNonce(ctx context.Context) (uint64, error) {
+	return con.client.NonceAt(ctx, con.auth.auth.From, nil)
+}
+
 func (con *globalInbox) DepositEthMessage(
 	ctx context.Context,
 	destination common.Address,
@@ -5,10 +5,12 @@ go 1.13
 require (
 	github.com/ethereum/go-ethereum v1.9.24
 	github.com/golang/protobuf v1.4.3
+	github.com/naoina/toml v0.1.2-0.20170918210437-9fafd6967416
 	github.com/offchainlabs/arbitrum/packages/arb-util v0.7.3
 	github.com/pkg/errors v0.9.1
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/offchainlabs/arbitrum/packages/arb-util => ../arb-util
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics collects the metrics of the aggregator and validator and
+// serves them in the Prometheus format. Each subsystem creates its collectors
+// in its own registry returned by NewRegistry
+package metrics
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+)
+
+func init() {
+	// The go-ethereum constructors return no-op collectors unless metrics are
+	// enabled. Since packages are initialized after the packages they
+	// import, collectors created by subsystems importing this package are
+	// always live
+	metrics.Enabled = true
+}
+
+// root contains the metrics of every subsystem
+var root = metrics.NewRegistry()
+
+// NewRegistry returns the registry for the metrics of subsystem. Their names
+// are prefixed with arb/<subsystem>/, which becomes arb_<subsystem>_ in the
+// Prometheus output
+func NewRegistry(subsystem string) metrics.Registry {
+	return metrics.NewPrefixedChildRegistry(root, "arb/"+subsystem+"/")
+}
+
+// NewHistogram creates and registers a histogram sampling the recent values
+// it was updated with
+func NewHistogram(name string, r metrics.Registry) metrics.Histogram {
+	return metrics.NewRegisteredHistogram(name, r, metrics.NewExpDecaySample(1028, 0.015))
+}
+
+// Handler serves all registered metrics in the Prometheus format
+func Handler() http.Handler {
+	return prometheus.Handler(root)
+}
+
+// AddMetricsFlags adds the -metrics-addr flag to fs and returns the address
+// it is set to, which should be passed to StartServer once fs is parsed
+func AddMetricsFlags(fs *flag.FlagSet) *string {
+	return fs.String(
+		"metrics-addr",
+		"",
+		"address to serve prometheus metrics on, such as localhost:6070 (disabled if empty)",
+	)
+}
+
+// StartServer serves the metrics at /metrics on addr in the background. If
+// addr is empty, no server is started
+func StartServer(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		log.Println("Serving metrics on", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("Metrics server stopped:", err)
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry("test")
+	metrics.NewRegisteredGauge("queue/txes", r).Update(7)
+	NewHistogram("batch/txes", r).Update(3)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(body)
+	for _, expected := range []string{
+		"arb_test_queue_txes 7",
+		"arb_test_batch_txes_count 1",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%v", expected, output)
+		}
+	}
+}
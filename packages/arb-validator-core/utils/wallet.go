@@ -64,12 +64,6 @@ func GetKeystore(
 	args WalletFlags,
 	flags *flag.FlagSet,
 ) (*bind.TransactOpts, error) {
-	ks := keystore.NewKeyStore(
-		filepath.Join(validatorFolder, "wallets"),
-		keystore.StandardScryptN,
-		keystore.StandardScryptP,
-	)
-
 	found := false
 	flags.Visit(func(f *flag.Flag) {
 		if f.Name == "password" {
@@ -77,8 +71,36 @@ func GetKeystore(
 		}
 	})
 
-	var passphrase string
-	if !found {
+	var passphrase *string
+	if found {
+		passphrase = args.passphrase
+	}
+	return openKeystore(validatorFolder, passphrase, *args.gasPrice)
+}
+
+// GetKeystoreWithPassword is like GetKeystore but takes the password and gas
+// price from a loaded configuration. The password is asked for if it is nil,
+// while an empty password is used as is
+func GetKeystoreWithPassword(
+	validatorFolder string,
+	password *string,
+	gasPrice float64,
+) (*bind.TransactOpts, error) {
+	return openKeystore(validatorFolder, password, gasPrice)
+}
+
+func openKeystore(
+	validatorFolder string,
+	passphrase *string,
+	gasPrice float64,
+) (*bind.TransactOpts, error) {
+	ks := keystore.NewKeyStore(
+		filepath.Join(validatorFolder, "wallets"),
+		keystore.StandardScryptN,
+		keystore.StandardScryptP,
+	)
+
+	if passphrase == nil {
 		if len(ks.Accounts()) == 0 {
 			fmt.Print("Enter new account password: ")
 		} else {
@@ -89,24 +111,21 @@ func GetKeystore(
 		if err != nil {
 			return nil, err
 		}
-		passphrase = string(bytePassword)
-
-		passphrase = strings.TrimSpace(passphrase)
-	} else {
-		passphrase = *args.passphrase
+		entered := strings.TrimSpace(string(bytePassword))
+		passphrase = &entered
 	}
 
 	var account accounts.Account
 	if len(ks.Accounts()) == 0 {
 		var err error
-		account, err = ks.NewAccount(passphrase)
+		account, err = ks.NewAccount(*passphrase)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		account = ks.Accounts()[0]
 	}
-	err := ks.Unlock(account, passphrase)
+	err := ks.Unlock(account, *passphrase)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +134,7 @@ func GetKeystore(
 		return nil, err
 	}
 
-	gasPriceAsFloat := 1e9 * (*args.gasPrice)
+	gasPriceAsFloat := 1e9 * gasPrice
 	if gasPriceAsFloat < math.MaxInt64 {
 		auth.GasPrice = big.NewInt(int64(gasPriceAsFloat))
 	}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package valprotocol
+
+import (
+	"fmt"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/hashing"
+)
+
+// AccumulatorProof proves that a value is included in the messages or logs
+// accumulator of an assertion. The accumulators are hash chains computed the
+// same way as the one step proof does on L1,
+// acc = keccak256(abi.encodePacked(acc, valHash)), starting from the first
+// hash of the assertion. The proof is checked by folding ValueHash and then
+// each of SuffixHashes into PrefixAcc and comparing the result to the
+// assertion's LastMessageHash or LastLogHash. This is a hash chain proof, not
+// a merkle proof, so it can't be checked with MerkleLib. AccumulatorLib in
+// arb-bridge-eth checks it on L1
+type AccumulatorProof struct {
+	// Accumulator covering every value before the proven one
+	PrefixAcc common.Hash
+	// Hash of the proven value
+	ValueHash common.Hash
+	// Hashes of the values after the proven one, in order
+	SuffixHashes []common.Hash
+}
+
+func accumulate(acc common.Hash, valHash common.Hash) common.Hash {
+	return hashing.SoliditySHA3(
+		hashing.Bytes32(acc),
+		hashing.Bytes32(valHash),
+	)
+}
+
+// NewAccumulatorProof builds a proof that the value at index was included in
+// the accumulator built over valHashes starting from initialHash
+func NewAccumulatorProof(initialHash common.Hash, valHashes []common.Hash, index uint64) (*AccumulatorProof, error) {
+	if index >= uint64(len(valHashes)) {
+		return nil, fmt.Errorf("value index %v out of range of %v values", index, len(valHashes))
+	}
+	prefixAcc := initialHash
+	for _, valHash := range valHashes[:index] {
+		prefixAcc = accumulate(prefixAcc, valHash)
+	}
+	suffixHashes := make([]common.Hash, len(valHashes)-int(index)-1)
+	copy(suffixHashes, valHashes[index+1:])
+	return &AccumulatorProof{
+		PrefixAcc:    prefixAcc,
+		ValueHash:    valHashes[index],
+		SuffixHashes: suffixHashes,
+	}, nil
+}
+
+// Accumulator returns the value of the accumulator after every value covered
+// by the proof was added
+func (p *AccumulatorProof) Accumulator() common.Hash {
+	acc := accumulate(p.PrefixAcc, p.ValueHash)
+	for _, valHash := range p.SuffixHashes {
+		acc = accumulate(acc, valHash)
+	}
+	return acc
+}
+
+// Verify checks that the proof shows inclusion of a value with hash valHash
+// in an accumulator whose final value is lastHash
+func (p *AccumulatorProof) Verify(valHash common.Hash, lastHash common.Hash) bool {
+	return p.ValueHash == valHash && p.Accumulator() == lastHash
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2020, Offchain Labs, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package valprotocol
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
+)
+
+func TestAccumulatorProof(t *testing.T) {
+	vals := []value.Value{
+		value.NewInt64Value(1),
+		value.NewTuple2(value.NewInt64Value(2), value.NewInt64Value(3)),
+		value.NewIntValue(big.NewInt(4)),
+		value.NewEmptyTuple(),
+	}
+	var buf bytes.Buffer
+	valHashes := make([]common.Hash, 0, len(vals))
+	for _, val := range vals {
+		if err := value.MarshalValue(val, &buf); err != nil {
+			t.Fatal(err)
+		}
+		valHashes = append(valHashes, val.Hash())
+	}
+	initialHash := common.RandHash()
+	lastHash := BytesArrayAccumHash(initialHash, buf.Bytes(), uint64(len(vals)))
+
+	for i, val := range vals {
+		proof, err := NewAccumulatorProof(initialHash, valHashes, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.Accumulator() != lastHash {
+			t.Error("accumulator of proof", i, "doesn't match BytesArrayAccumHash")
+		}
+		if !proof.Verify(val.Hash(), lastHash) {
+			t.Error("proof", i, "failed to verify")
+		}
+		if proof.Verify(common.RandHash(), lastHash) {
+			t.Error("proof", i, "verified with the wrong value")
+		}
+		if proof.Verify(val.Hash(), common.RandHash()) {
+			t.Error("proof", i, "verified with the wrong accumulator")
+		}
+	}
+
+	if _, err := NewAccumulatorProof(initialHash, valHashes, uint64(len(vals))); err == nil {
+		t.Error("expected error for out of range index")
+	}
+}
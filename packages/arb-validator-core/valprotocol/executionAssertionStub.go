@@ -17,13 +17,11 @@
 package valprotocol
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/value"
 	"math/big"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
-	"github.com/offchainlabs/arbitrum/packages/arb-util/hashing"
 )
 
 type ExecutionAssertionStub struct {
@@ -42,15 +40,13 @@ type ExecutionAssertionStub struct {
 
 func BytesArrayAccumHash(initialHash common.Hash, data []byte, valCount uint64) common.Hash {
 	lastMsgHash := initialHash
-	rd := bytes.NewReader(data)
+	d := value.NewDecoder(data)
 	for i := uint64(0); i < valCount; i++ {
-		val, err := value.UnmarshalValue(rd)
+		val, err := d.Decode()
 		if err != nil {
 			panic(err)
 		}
-		lastMsgHash = hashing.SoliditySHA3(
-			hashing.Bytes32(lastMsgHash),
-			hashing.Bytes32(val.Hash()))
+		lastMsgHash = accumulate(lastMsgHash, val.Hash())
 	}
 	return lastMsgHash
 }
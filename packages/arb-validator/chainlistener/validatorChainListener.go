@@ -18,8 +18,10 @@ package chainlistener
 
 import (
 	"context"
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/challenges"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/nodegraph"
@@ -30,6 +32,14 @@ import (
 	"time"
 )
 
+var (
+	metricsRegistry = metrics.NewRegistry("validator")
+
+	// activeChallengesGauge is the number of challenges the validator is
+	// currently taking part in as asserter or challenger
+	activeChallengesGauge = gethmetrics.NewRegisteredGauge("challenges/active", metricsRegistry)
+)
+
 type attemptedMove struct {
 	nodeHeight uint64
 	nodeHash   common.Hash
@@ -325,6 +335,8 @@ func (lis *ValidatorChainListener) launchChallenge(
 		switch chal.ConflictNode().LinkType() {
 		case valprotocol.InvalidInboxTopChildType:
 			go func() {
+				activeChallengesGauge.Inc(1)
+				defer activeChallengesGauge.Dec(1)
 				res, err := challenges.DefendInboxTopClaim(
 					ctx,
 					asserterKey.client,
@@ -347,6 +359,8 @@ func (lis *ValidatorChainListener) launchChallenge(
 			}()
 		case valprotocol.InvalidExecutionChildType:
 			go func() {
+				activeChallengesGauge.Inc(1)
+				defer activeChallengesGauge.Dec(1)
 				res, err := challenges.DefendExecutionClaim(
 					ctx,
 					asserterKey.client,
@@ -376,6 +390,8 @@ func (lis *ValidatorChainListener) launchChallenge(
 		switch chal.ConflictNode().LinkType() {
 		case valprotocol.InvalidInboxTopChildType:
 			go func() {
+				activeChallengesGauge.Inc(1)
+				defer activeChallengesGauge.Dec(1)
 				res, err := challenges.ChallengeInboxTopClaim(
 					ctx,
 					challenger.client,
@@ -393,6 +409,8 @@ func (lis *ValidatorChainListener) launchChallenge(
 			}()
 		case valprotocol.InvalidExecutionChildType:
 			go func() {
+				activeChallengesGauge.Inc(1)
+				defer activeChallengesGauge.Dec(1)
 				res, err := challenges.ChallengeExecutionClaim(
 					ctx,
 					challenger.client,
@@ -22,6 +22,7 @@ import (
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/structures"
+	"io/ioutil"
 	"log"
 	"math/big"
 	"math/rand"
@@ -109,10 +110,18 @@ func TestMain(m *testing.M) {
 	}
 	rollupTester = deployedTester
 
+	checkpointDir, err = ioutil.TempDir("", "chainobserver")
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	code := m.Run()
 	if err := os.RemoveAll(dbPath); err != nil {
 		log.Fatal(err)
 	}
+	if err := os.RemoveAll(checkpointDir); err != nil {
+		log.Fatal(err)
+	}
 	os.Exit(code)
 }
 
@@ -178,8 +187,7 @@ func TestConfirmAssertion(t *testing.T) {
 
 	checkpointer, err := checkpointing.NewIndexedCheckpointer(
 		rollupAddress,
-		dbPath,
-		big.NewInt(100000),
+		checkpointing.Config{DatabasePath: dbPath, MaxReorgHeight: big.NewInt(100000)},
 		true,
 	)
 	if err != nil {
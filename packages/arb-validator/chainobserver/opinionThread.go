@@ -24,15 +24,26 @@ import (
 	"sync"
 	"time"
 
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/protocol"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/valprotocol"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/chainlistener"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/nodegraph"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/structures"
 )
 
+var (
+	metricsRegistry = metrics.NewRegistry("chainobserver")
+
+	// arbGasCounter counts the ArbGas used by assertions executed while
+	// preparing assertions and checking those of other validators
+	arbGasCounter = gethmetrics.NewRegisteredCounter("arbgas", metricsRegistry)
+)
+
 func (chain *ChainObserver) startOpinionUpdateThread(ctx context.Context) {
 	go func() {
 		log.Println("Launching opinion thread")
@@ -240,6 +251,7 @@ func (chain *ChainObserver) prepareAssertion(maxValidBlock *common.BlockId) (*ch
 	beforeHash := mach.Hash()
 
 	assertion, stepsRun := mach.ExecuteAssertion(maxSteps, messages, 0)
+	arbGasCounter.Inc(int64(assertion.NumGas))
 
 	afterHash := mach.Hash()
 
@@ -295,6 +307,7 @@ func (chain *ChainObserver) getNodeOpinion(
 		messages,
 		0,
 	)
+	arbGasCounter.Inc(int64(assertion.NumGas))
 	chain.RLock()
 	defer chain.RUnlock()
 	if params.NumSteps != stepsRun || !assertionStub.Equals(structures.NewExecutionAssertionStubFromWholeAssertion(assertion, assertionStub.BeforeInboxHash, chain.Inbox.MessageStack)) {
@@ -23,6 +23,7 @@ import (
 	"log"
 	"math/big"
 	"math/rand"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -74,6 +75,10 @@ var contractPath = arbos.Path()
 var dummyRollupAddress = common.Address{1}
 var dummyAddress common.Address
 
+// checkpointDir holds the databases of fresh_rocksdb chains and is removed
+// once the tests finish
+var checkpointDir string
+
 func setUpChain(rollupAddress common.Address, checkpointType string, contractPath string) (*ChainObserver, error) {
 	var checkpointer checkpointing.RollupCheckpointer
 	switch checkpointType {
@@ -81,7 +86,14 @@ func setUpChain(rollupAddress common.Address, checkpointType string, contractPat
 		checkpointer = NewDummyCheckpointer()
 	case "fresh_rocksdb":
 		var err error
-		checkpointer, err = checkpointing.NewIndexedCheckpointer(rollupAddress, "", big.NewInt(1000000), true)
+		checkpointer, err = checkpointing.NewIndexedCheckpointer(
+			rollupAddress,
+			checkpointing.Config{
+				DatabasePath:   filepath.Join(checkpointDir, rollupAddress.Hex()),
+				MaxReorgHeight: big.NewInt(1000000),
+			},
+			true,
+		)
 		if err != nil {
 			return nil, err
 		}
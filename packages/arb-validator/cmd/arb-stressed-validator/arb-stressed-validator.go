@@ -23,6 +23,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/cmdhelper"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
@@ -52,12 +53,12 @@ func main() {
 	}
 }
 
-func createStressedManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, dbPath string) (*rollupmanager.Manager, error) {
+func createStressedManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, checkpointConfig checkpointing.Config) (*rollupmanager.Manager, error) {
 	return rollupmanager.CreateManager(
 		context.Background(),
 		rollupAddress,
 		arbbridge.NewStressTestClient(client, time.Second*10),
 		contractFile,
-		dbPath,
+		checkpointConfig,
 	)
 }
@@ -31,6 +31,7 @@ import (
 
 	errors2 "github.com/pkg/errors"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
@@ -139,6 +140,6 @@ func createRollupChain() error {
 	return nil
 }
 
-func createManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, dbPath string) (*rollupmanager.Manager, error) {
-	return rollupmanager.CreateManager(context.Background(), rollupAddress, client, contractFile, dbPath)
+func createManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, checkpointConfig checkpointing.Config) (*rollupmanager.Manager, error) {
+	return rollupmanager.CreateManager(context.Background(), rollupAddress, client, contractFile, checkpointConfig)
 }
@@ -20,9 +20,9 @@ import (
 	"context"
 	"flag"
 	"log"
-	"math/big"
 	"os"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/cmdhelper"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
@@ -54,11 +54,10 @@ func main() {
 	}
 }
 
-func createEvilManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, dbPath string) (*rollupmanager.Manager, error) {
+func createEvilManager(rollupAddress common.Address, client arbbridge.ArbClient, contractFile string, checkpointConfig checkpointing.Config) (*rollupmanager.Manager, error) {
 	cp, err := rolluptest.NewEvilRollupCheckpointer(
 		rollupAddress,
-		dbPath,
-		big.NewInt(100),
+		checkpointConfig,
 		false,
 	)
 	if err != nil {
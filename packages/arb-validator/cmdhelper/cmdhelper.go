@@ -21,13 +21,17 @@ import (
 	"flag"
 	"fmt"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethutils"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/config"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/ethbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/utils"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/chainlistener"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/rollupmanager"
@@ -35,6 +39,24 @@ import (
 
 var ContractName = "contract.mexe"
 
+// loadConfig builds the configuration from the config file, environment and
+// parsed flags, taking the rollup folder, L1 URL and rollup address from the
+// positional arguments if they were given
+func loadConfig(configFlags *config.Flags, fs *flag.FlagSet) (*config.Config, error) {
+	cfg, err := configFlags.Load(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyRollupArgs(fs.Args()); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	log.Printf("Configuration:\n%v", cfg.Redacted())
+	return cfg, nil
+}
+
 // ValidateRollupChain creates a validator given the managerCreationFunc.
 // This allows for the abstraction of the manager setup away from command line
 // parsing and initialization of common structures and behavior
@@ -43,54 +65,56 @@ func ValidateRollupChain(
 	managerCreationFunc func(
 		rollupAddress common.Address,
 		client arbbridge.ArbClient,
-		contractFile string, dbPath string,
+		contractFile string,
+		checkpointConfig checkpointing.Config,
 	) (*rollupmanager.Manager, error),
 ) error {
 	ctx := context.Background()
 	// Check number of args
 
 	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
-	walletVars := utils.AddWalletFlags(validateCmd)
-	blocktime := validateCmd.Int64(
-		"blocktime",
-		2,
-		"blocktime=NumSeconds",
-	)
+	configFlags := config.AddFlags(validateCmd)
+	configFlags.Alias("password", "wallet.password", "password=pass")
+	configFlags.Alias("gasprice", "wallet.gas_price", "gasprice=FloatInGwei")
+	configFlags.Alias("blocktime", "validator.block_time", "blocktime=NumSeconds")
+	configFlags.Alias("metrics-addr", "metrics.addr", "address to serve prometheus metrics on (disabled if empty)")
 	err := validateCmd.Parse(os.Args[2:])
 	if err != nil {
 		return err
 	}
 
-	if validateCmd.NArg() != 3 {
+	cfg, err := loadConfig(configFlags, validateCmd)
+	if err != nil {
 		return fmt.Errorf(
-			"usage: %v validate %v [--blocktime=NumSeconds] %v",
+			"%v\nusage: %v validate [--config=path] %v [--blocktime=NumSeconds] %v",
+			err,
 			execName,
 			utils.WalletArgsString,
 			utils.RollupArgsString,
 		)
 	}
 
-	common.SetDurationPerBlock(time.Duration(*blocktime) * time.Second)
+	common.SetDurationPerBlock(time.Duration(cfg.Validator.BlockTime) * time.Second)
 
-	rollupArgs := utils.ParseRollupCommand(validateCmd, 0)
+	metrics.StartServer(cfg.Metrics.Addr)
 
-	auth, err := utils.GetKeystore(
-		rollupArgs.ValidatorFolder,
-		walletVars,
-		validateCmd,
+	auth, err := utils.GetKeystoreWithPassword(
+		cfg.Rollup.Folder,
+		cfg.Wallet.Password,
+		cfg.Wallet.GasPrice,
 	)
 	if err != nil {
 		return err
 	}
 
 	// Rollup creation
-	ethclint, err := ethutils.NewRPCEthClient(rollupArgs.EthURL)
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
 	if err != nil {
 		return err
 	}
 	client := ethbridge.NewEthAuthClient(ethclint, auth)
 
-	rollup, err := client.NewRollup(rollupArgs.Address)
+	rollup, err := client.NewRollup(cfg.RollupAddress())
 	if err != nil {
 		return err
 	}
@@ -106,7 +130,7 @@ func ValidateRollupChain(
 
 	validatorListener := chainlistener.NewValidatorChainListener(
 		ctx,
-		rollupArgs.Address,
+		cfg.RollupAddress(),
 		rollup,
 	)
 	err = validatorListener.AddStaker(client)
@@ -114,14 +138,13 @@ func ValidateRollupChain(
 		return err
 	}
 
-	contractFile := filepath.Join(rollupArgs.ValidatorFolder, ContractName)
-	dbPath := filepath.Join(rollupArgs.ValidatorFolder, "checkpoint_db")
+	contractFile := filepath.Join(cfg.Rollup.Folder, ContractName)
 
 	manager, err := managerCreationFunc(
-		rollupArgs.Address,
+		cfg.RollupAddress(),
 		client,
 		contractFile,
-		dbPath,
+		checkpointing.NewConfig(cfg),
 	)
 
 	if err != nil {
@@ -143,47 +166,51 @@ func ObserveRollupChain(
 	managerCreationFunc func(
 		rollupAddress common.Address,
 		client arbbridge.ArbClient,
-		contractFile string, dbPath string,
+		contractFile string,
+		checkpointConfig checkpointing.Config,
 	) (*rollupmanager.Manager, error),
 ) error {
 	ctx := context.Background()
 	// Check number of args
 	validateCmd := flag.NewFlagSet("observe", flag.ExitOnError)
+	configFlags := config.AddFlags(validateCmd)
 	quietFlag := validateCmd.Bool(
 		"q",
 		false,
 		"quiet validator output",
 	)
+	configFlags.Alias("metrics-addr", "metrics.addr", "address to serve prometheus metrics on (disabled if empty)")
 	err := validateCmd.Parse(os.Args[2:])
 	if err != nil {
 		return err
 	}
 
-	if validateCmd.NArg() != 3 {
+	cfg, err := loadConfig(configFlags, validateCmd)
+	if err != nil {
 		return fmt.Errorf(
-			"usage: %v validate %v",
+			"%v\nusage: %v observe [--config=path] %v",
+			err,
 			execName,
 			utils.RollupArgsString,
 		)
 	}
 
-	rollupArgs := utils.ParseRollupCommand(validateCmd, 0)
+	metrics.StartServer(cfg.Metrics.Addr)
 
 	// Rollup creation
-	ethclint, err := ethutils.NewRPCEthClient(rollupArgs.EthURL)
+	ethclint, err := ethutils.NewRPCEthClient(cfg.L1.URL)
 	if err != nil {
 		return err
 	}
 	client := ethbridge.NewEthClient(ethclint)
 
-	contractFile := filepath.Join(rollupArgs.ValidatorFolder, ContractName)
-	dbPath := filepath.Join(rollupArgs.ValidatorFolder, "checkpoint_db")
+	contractFile := filepath.Join(cfg.Rollup.Folder, ContractName)
 
 	manager, err := managerCreationFunc(
-		rollupArgs.Address,
+		cfg.RollupAddress(),
 		client,
 		contractFile,
-		dbPath,
+		checkpointing.NewConfig(cfg),
 	)
 
 	if err != nil {
@@ -21,12 +21,15 @@ import (
 	"strings"
 
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
+	"github.com/offchainlabs/arbitrum/packages/arb-util/gomachine"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/machine"
 )
 
 func LoadMachineFromFile(fileName string, warnMode bool, vmtype string) (machine.Machine, error) {
 	if strings.EqualFold(vmtype, "cpp") {
 		return cmachine.New(fileName)
+	} else if strings.EqualFold(vmtype, "go") {
+		return gomachine.New(fileName)
 	} else {
 		return nil, fmt.Errorf("invalid machine type specified %v", vmtype)
 	}
@@ -24,10 +24,13 @@ import (
 	"sync"
 	"time"
 
+	gethmetrics "github.com/ethereum/go-ethereum/metrics"
+
 	"github.com/offchainlabs/arbitrum/packages/arb-avm-cpp/cmachine"
 	"github.com/offchainlabs/arbitrum/packages/arb-checkpointer/checkpointing"
 	"github.com/offchainlabs/arbitrum/packages/arb-util/common"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/arbbridge"
+	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/metrics"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator-core/observer"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/chainlistener"
 	"github.com/offchainlabs/arbitrum/packages/arb-validator/chainobserver"
@@ -46,7 +49,12 @@ type Manager struct {
 	checkpointer  checkpointing.RollupCheckpointer
 }
 
-const defaultMaxReorgDepth = 100
+var (
+	metricsRegistry = metrics.NewRegistry("rollupmanager")
+
+	// lagGauge is the number of L1 blocks the manager is behind the head
+	lagGauge = gethmetrics.NewRegisteredGauge("lag", metricsRegistry)
+)
 
 const assumedValidThreshold = 2
 
@@ -55,12 +63,11 @@ func CreateManager(
 	rollupAddr common.Address,
 	clnt arbbridge.ArbClient,
 	aoFilePath string,
-	dbPath string,
+	checkpointConfig checkpointing.Config,
 ) (*Manager, error) {
 	checkpointer, err := checkpointing.NewIndexedCheckpointer(
 		rollupAddr,
-		dbPath,
-		big.NewInt(defaultMaxReorgDepth),
+		checkpointConfig,
 		false,
 	)
 	if err != nil {
@@ -218,6 +225,11 @@ func CreateManagerAdvanced(
 					if err != nil {
 						return err
 					}
+					// The lag is only reported, so failing to get the head
+					// isn't an error
+					if currentOnChain, err := clnt.BlockIdForHeight(runCtx, nil); err == nil {
+						lagGauge.Update(new(big.Int).Sub(currentOnChain.Height.AsInt(), fetchEnd).Int64())
+					}
 					if fetchEnd.Cmp(startHeight) > 0 {
 						man.activeChain.NotifyNewBlock(endBlockId)
 					}
@@ -253,6 +265,8 @@ func CreateManagerAdvanced(
 						return err
 					}
 
+					lagGauge.Update(new(big.Int).Sub(currentOnChain.Height.AsInt(), blockId.Height.AsInt()).Int64())
+
 					if !caughtUpToL1 && blockId.Height.Cmp(currentOnChain.Height) >= 0 {
 						caughtUpToL1 = true
 						man.activeChain.NowAtHead()
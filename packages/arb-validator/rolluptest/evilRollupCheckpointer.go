@@ -31,14 +31,12 @@ import (
 
 func NewEvilRollupCheckpointer(
 	rollupAddr common.Address,
-	databasePath string,
-	maxReorgDepth *big.Int,
+	config checkpointing.Config,
 	forceFreshStart bool,
 ) (*EvilRollupCheckpointer, error) {
 	cp, err := checkpointing.NewIndexedCheckpointer(
 		rollupAddr,
-		databasePath,
-		maxReorgDepth,
+		config,
 		forceFreshStart,
 	)
 	return &EvilRollupCheckpointer{cp}, err